@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+func openStore(ctx context.Context) (*pgxpool.Pool, db.Store) {
+	conn, err := pgxpool.New(ctx, util.Config.DBSource)
+	if err != nil {
+		log.Fatal("cannot connect to db: ", err)
+	}
+	return conn, db.NewStore(conn)
+}
+
+func newTabWriter() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+}
+
+func requireFlag(fs *flag.FlagSet, ok bool, name string) {
+	if !ok {
+		fmt.Fprintf(os.Stderr, "-%s is required\n", name)
+		fs.Usage()
+		os.Exit(2)
+	}
+}
+
+func runUser(args []string) {
+	if len(args) == 0 || args[0] != "create" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("user create", flag.ExitOnError)
+	username := fs.String("username", "", "username")
+	password := fs.String("password", "", "password")
+	fullName := fs.String("full-name", "", "full name")
+	email := fs.String("email", "", "email")
+	role := fs.String("role", util.DepositorRole, "role of the user (depositor or admin)")
+	verified := fs.Bool("verified", true, "mark the email as verified, otherwise the user has to request a verification email before logging in")
+	fs.Parse(args[1:])
+	requireFlag(fs, *username != "", "username")
+	requireFlag(fs, len(*password) >= 6, "password (at least 6 characters)")
+	requireFlag(fs, *email != "", "email")
+	if !util.IsValidRole(*role) {
+		log.Fatal("invalid role: ", *role)
+	}
+
+	hashPassword, err := util.HashPassword(*password)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	conn, store := openStore(ctx)
+	defer conn.Close()
+
+	user, err := store.CreateUser(ctx, db.CreateUserParams{
+		Username:        *username,
+		HashedPassword:  hashPassword,
+		FullName:        *fullName,
+		Email:           *email,
+		Role:            *role,
+		IsEmailVerified: *verified,
+	})
+	if err != nil {
+		log.Fatal("cannot create user: ", err)
+	}
+	fmt.Printf("created %s user %s\n", user.Role, user.Username)
+}
+
+func runAccount(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("account list", flag.ExitOnError)
+		owner := fs.String("owner", "", "only list accounts of this user")
+		limit := fs.Int("limit", 50, "maximum number of accounts")
+		offset := fs.Int("offset", 0, "number of accounts to skip")
+		fs.Parse(args[1:])
+
+		conn, store := openStore(ctx)
+		defer conn.Close()
+
+		var accounts []db.Account
+		var err error
+		if *owner != "" {
+			accounts, err = store.ListAccount(ctx, db.ListAccountParams{
+				Owner:  *owner,
+				Limit:  int32(*limit),
+				Offset: int32(*offset),
+			})
+		} else {
+			accounts, err = store.ListAllAccounts(ctx, db.ListAllAccountsParams{
+				Limit:  int32(*limit),
+				Offset: int32(*offset),
+			})
+		}
+		if err != nil {
+			log.Fatal("cannot list accounts: ", err)
+		}
+
+		w := newTabWriter()
+		fmt.Fprintln(w, "ID\tOWNER\tBALANCE\tOVERDRAFT\tCURRENCY\tFROZEN\tCREATED AT")
+		for _, account := range accounts {
+			fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%s\t%t\t%s\n", account.ID, account.Owner, account.Balance, account.OverdraftLimit,
+				account.Currency, account.IsFrozen, account.CreatedAt.Time.Format("2006-01-02 15:04:05"))
+		}
+		w.Flush()
+	case "freeze", "unfreeze":
+		fs := flag.NewFlagSet("account "+args[0], flag.ExitOnError)
+		id := fs.Int64("id", 0, "account id")
+		fs.Parse(args[1:])
+		requireFlag(fs, *id > 0, "id")
+
+		conn, store := openStore(ctx)
+		defer conn.Close()
+
+		account, err := store.UpdateAccountFrozen(ctx, db.UpdateAccountFrozenParams{
+			ID:       *id,
+			IsFrozen: args[0] == "freeze",
+		})
+		if err != nil {
+			log.Fatal("cannot update account: ", err)
+		}
+		fmt.Printf("account %d frozen: %t\n", account.ID, account.IsFrozen)
+	case "overdraft":
+		fs := flag.NewFlagSet("account overdraft", flag.ExitOnError)
+		id := fs.Int64("id", 0, "account id")
+		limit := fs.String("limit", "", "how far below zero the balance may go, like 500.00, 0 to remove the overdraft")
+		operator := fs.String("operator", os.Getenv("USER"), "operator changing the limit")
+		fs.Parse(args[1:])
+		requireFlag(fs, *id > 0, "id")
+		requireFlag(fs, *limit != "", "limit")
+		requireFlag(fs, *operator != "", "operator")
+
+		conn, store := openStore(ctx)
+		defer conn.Close()
+
+		account, err := store.GetAccount(ctx, *id)
+		if err != nil {
+			log.Fatal("cannot get account: ", err)
+		}
+		currency, err := store.GetCurrency(ctx, account.Currency)
+		if err != nil {
+			log.Fatal("cannot get currency: ", err)
+		}
+		money, err := util.ParseMoney(*limit, util.Currency{Code: currency.Code, Exponent: int(currency.Exponent)})
+		if err != nil {
+			log.Fatal(err)
+		}
+		if money.Amount < 0 {
+			log.Fatal("limit must not be negative")
+		}
+
+		err, result := store.UpdateOverdraftLimitTx(ctx, db.UpdateOverdraftLimitTxParams{
+			AccountID:      account.ID,
+			OverdraftLimit: money.Amount,
+			Operator:       *operator,
+		})
+		if err != nil {
+			log.Fatal("cannot update account: ", err)
+		}
+		fmt.Printf("account %d overdraft limit: %s\n", result.Account.ID, money)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func runCurrency(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("currency list", flag.ExitOnError)
+		fs.Parse(args[1:])
+
+		conn, store := openStore(ctx)
+		defer conn.Close()
+
+		currencies, err := store.ListCurrencies(ctx)
+		if err != nil {
+			log.Fatal("cannot list currencies: ", err)
+		}
+
+		w := newTabWriter()
+		fmt.Fprintln(w, "CODE\tEXPONENT\tENABLED\tCREATED AT")
+		for _, currency := range currencies {
+			fmt.Fprintf(w, "%s\t%d\t%t\t%s\n", currency.Code, currency.Exponent, currency.Enabled,
+				currency.CreatedAt.Time.Format("2006-01-02 15:04:05"))
+		}
+		w.Flush()
+	case "add":
+		fs := flag.NewFlagSet("currency add", flag.ExitOnError)
+		code := fs.String("code", "", "ISO 4217 code, like USD")
+		exponent := fs.Int("exponent", 2, "digits after the decimal separator")
+		disabled := fs.Bool("disabled", false, "add the currency without enabling it")
+		fs.Parse(args[1:])
+		requireFlag(fs, *code != "", "code")
+		requireFlag(fs, *exponent >= 0 && *exponent <= 4, "exponent (between 0 and 4)")
+
+		conn, store := openStore(ctx)
+		defer conn.Close()
+
+		currency, err := store.CreateCurrency(ctx, db.CreateCurrencyParams{
+			Code:     *code,
+			Exponent: int16(*exponent),
+			Enabled:  !*disabled,
+		})
+		if err != nil {
+			log.Fatal("cannot add currency: ", err)
+		}
+		fmt.Printf("added currency %s (exponent %d, enabled: %t)\n", currency.Code, currency.Exponent, currency.Enabled)
+	case "enable", "disable":
+		fs := flag.NewFlagSet("currency "+args[0], flag.ExitOnError)
+		code := fs.String("code", "", "ISO 4217 code, like USD")
+		fs.Parse(args[1:])
+		requireFlag(fs, *code != "", "code")
+
+		conn, store := openStore(ctx)
+		defer conn.Close()
+
+		currency, err := store.UpdateCurrencyEnabled(ctx, db.UpdateCurrencyEnabledParams{
+			Code:    *code,
+			Enabled: args[0] == "enable",
+		})
+		if err != nil {
+			log.Fatal("cannot update currency: ", err)
+		}
+		fmt.Printf("currency %s enabled: %t\n", currency.Code, currency.Enabled)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func runSession(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("session list", flag.ExitOnError)
+		username := fs.String("username", "", "owner of the sessions")
+		fs.Parse(args[1:])
+		requireFlag(fs, *username != "", "username")
+
+		conn, store := openStore(ctx)
+		defer conn.Close()
+
+		sessions, err := store.ListSessions(ctx, *username)
+		if err != nil {
+			log.Fatal("cannot list sessions: ", err)
+		}
+
+		w := newTabWriter()
+		fmt.Fprintln(w, "ID\tCLIENT IP\tUSER AGENT\tBLOCKED\tEXPIRES AT\tCREATED AT")
+		for _, session := range sessions {
+			id, _ := session.ID.Value()
+			fmt.Fprintf(w, "%v\t%s\t%s\t%t\t%s\t%s\n", id, session.ClientIp, session.UserAgent, session.IsBlocked,
+				session.ExpiresAt.Time.Format("2006-01-02 15:04:05"), session.CreatedAt.Time.Format("2006-01-02 15:04:05"))
+		}
+		w.Flush()
+	case "block":
+		fs := flag.NewFlagSet("session block", flag.ExitOnError)
+		id := fs.String("id", "", "id of the session to block")
+		username := fs.String("username", "", "block every session of this user")
+		fs.Parse(args[1:])
+		requireFlag(fs, *id != "" || *username != "", "id or -username")
+
+		conn, store := openStore(ctx)
+		defer conn.Close()
+
+		if *username != "" {
+			if err := store.BlockUserSessions(ctx, *username); err != nil {
+				log.Fatal("cannot block sessions: ", err)
+			}
+			fmt.Printf("blocked all sessions of %s\n", *username)
+			return
+		}
+
+		var sessionID pgtype.UUID
+		if err := sessionID.Scan(*id); err != nil {
+			log.Fatal("invalid session id: ", err)
+		}
+		session, err := store.BlockSession(ctx, sessionID)
+		if err != nil {
+			log.Fatal("cannot block session: ", err)
+		}
+		fmt.Printf("blocked session %s of %s\n", *id, session.Username)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// runReconcile compares every account balance with the sum of its ledger
+// entries and exits non-zero when they disagree.
+func runReconcile(args []string) {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	fs.Parse(args)
+
+	ctx := context.Background()
+	conn, store := openStore(ctx)
+	defer conn.Close()
+
+	mismatches, err := store.ListAccountBalanceMismatches(ctx)
+	if err != nil {
+		log.Fatal("cannot reconcile ledger: ", err)
+	}
+	if len(mismatches) == 0 {
+		fmt.Println("all account balances match their entries")
+		return
+	}
+
+	w := newTabWriter()
+	fmt.Fprintln(w, "ID\tOWNER\tCURRENCY\tBALANCE\tENTRIES\tDIFFERENCE")
+	for _, m := range mismatches {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%d\n", m.ID, m.Owner, m.Currency, m.Balance, m.EntriesTotal, m.Balance-m.EntriesTotal)
+	}
+	w.Flush()
+	conn.Close()
+	os.Exit(1)
+}
+
+// runAudit walks the whole audit log and exits non-zero at the first event
+// that was modified or does not follow the one before it.
+func runAudit(args []string) {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	fs.Parse(args[1:])
+
+	ctx := context.Background()
+	conn, store := openStore(ctx)
+	defer conn.Close()
+
+	var lastID int64
+	var lastHash string
+	var count int
+	for {
+		events, err := store.ListAuditEventsAfter(ctx, db.ListAuditEventsAfterParams{ID: lastID, Limit: 1000})
+		if err != nil {
+			log.Fatal("cannot list audit events: ", err)
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		lastHash, err = db.VerifyAuditChain(lastHash, events)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			conn.Close()
+			os.Exit(1)
+		}
+		lastID = events[len(events)-1].ID
+		count += len(events)
+	}
+	fmt.Printf("verified %d audit events\n", count)
+}
+
+func runAdjust(args []string) {
+	fs := flag.NewFlagSet("adjust", flag.ExitOnError)
+	from := fs.Int64("from", 0, "account to debit")
+	to := fs.Int64("to", 0, "account to credit")
+	amount := fs.String("amount", "", "amount to move, like 12.34")
+	reason := fs.String("reason", "", "reason recorded with the adjustment")
+	operator := fs.String("operator", os.Getenv("USER"), "operator performing the adjustment")
+	fs.Parse(args)
+	requireFlag(fs, *from > 0, "from")
+	requireFlag(fs, *to > 0 && *to != *from, "to (different from -from)")
+	requireFlag(fs, *amount != "", "amount")
+	requireFlag(fs, *reason != "", "reason")
+	requireFlag(fs, *operator != "", "operator")
+
+	ctx := context.Background()
+	conn, store := openStore(ctx)
+	defer conn.Close()
+
+	fromAccount, err := store.GetAccount(ctx, *from)
+	if err != nil {
+		log.Fatal("cannot get account: ", err)
+	}
+	toAccount, err := store.GetAccount(ctx, *to)
+	if err != nil {
+		log.Fatal("cannot get account: ", err)
+	}
+	if fromAccount.Currency != toAccount.Currency {
+		log.Fatalf("currency mismatch: %s and %s", fromAccount.Currency, toAccount.Currency)
+	}
+	currency, err := store.GetCurrency(ctx, fromAccount.Currency)
+	if err != nil {
+		log.Fatal("cannot get currency: ", err)
+	}
+	money, err := util.ParseMoney(*amount, util.Currency{Code: currency.Code, Exponent: int(currency.Exponent)})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !money.IsPositive() {
+		log.Fatal("amount must be positive")
+	}
+
+	err, result := store.AdminTransferTx(ctx, db.AdminTransferTxParams{
+		TransferTxParams: db.TransferTxParams{
+			FromAccountID: *from,
+			ToAccountID:   *to,
+			Amount:        money,
+		},
+		Operator: *operator,
+		Reason:   *reason,
+	})
+	if err != nil {
+		log.Fatal("cannot adjust: ", err)
+	}
+	fmt.Printf("transfer %d: moved %s from %d to %d (adjustment %d)\n", result.Transfer.ID, money,
+		*from, *to, result.Adjustment.ID)
+}
@@ -1,17 +1,62 @@
 package api
 
 import (
+	"context"
 	"errors"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	db "github.com/jxgzzztang/simplebank/db/sqlc"
 	"github.com/jxgzzztang/simplebank/util"
 	"net/http"
+	"strconv"
 )
 
 type CreateAccountRequest struct {
-	Currency string `json:"currency" binding:"required,oneof=CNY USD"`
+	Currency string `json:"currency" binding:"required,currency"`
+	// Type is checking or savings, checking when empty.
+	Type string `json:"type" binding:"omitempty,oneof=checking savings"`
+}
+
+// AccountResponse is an account with its balance in its currency, encoded as
+// a decimal string like {"amount":"12.34","currency":"USD"}.
+type AccountResponse struct {
+	ID        int64      `json:"id"`
+	Owner     string     `json:"owner"`
+	Balance   util.Money `json:"balance" swaggertype:"object,string"`
+	// AvailableBalance is the balance and overdraft limit less the active
+	// holds on the account, what transfers out of it can spend.
+	AvailableBalance util.Money `json:"available_balance" swaggertype:"object,string"`
+	// OverdraftLimit is how far below zero transfers may take the balance.
+	OverdraftLimit util.Money `json:"overdraft_limit" swaggertype:"object,string"`
+	Currency  string    `json:"currency"`
+	Type      string    `json:"type"`
+	IsFrozen  bool      `json:"is_frozen"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (server *Server) newAccountResponse(ctx context.Context, account db.Account) (AccountResponse, error) {
+	currency, err := server.currencies.currency(ctx, account.Currency)
+	if err != nil {
+		return AccountResponse{}, err
+	}
+	held, err := server.store.GetAccountHeldAmount(ctx, account.ID)
+	if err != nil {
+		return AccountResponse{}, err
+	}
+	return AccountResponse{
+		ID:        account.ID,
+		Owner:     account.Owner,
+		Balance:   util.NewMoney(account.Balance, currency),
+		AvailableBalance: util.NewMoney(account.Balance+account.OverdraftLimit-held, currency),
+		OverdraftLimit: util.NewMoney(account.OverdraftLimit, currency),
+		Currency:  account.Currency,
+		Type:      account.Type,
+		IsFrozen:  account.IsFrozen,
+		CreatedAt: account.CreatedAt.Time,
+	}, nil
 }
 
 // CreateAccount godoc
@@ -20,7 +65,8 @@ type CreateAccountRequest struct {
 // @Tags         accounts
 // @Accept       json
 // @Produce      json
-// @Success      200  {object} 	db.Account
+// @Param 		account  body  CreateAccountRequest true "currency and type of the account"
+// @Success      200  {object} 	AccountResponse
 // @Failure      400  {object}  api.ErrorResponse
 // @Failure      500  {object}  api.ErrorResponse
 // @Router       /createAccount [post]
@@ -36,14 +82,21 @@ func (server *Server) CreateAccount(ctx *gin.Context) {
 	accountArg := db.CreateAccountParams{
 		Owner: parsePayload.Username,
 		Currency: req.Currency,
+		Type: util.CheckingAccount,
+	}
+	if req.Type != "" {
+		accountArg.Type = req.Type
 	}
 
-	account, err := server.store.CreateAccount(ctx, accountArg)
+	err, result := server.store.CreateAccountTx(ctx, db.CreateAccountTxParams{
+		CreateAccountParams: accountArg,
+		Audit:               auditActor(ctx),
+	})
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
 			switch pgErr.ConstraintName {
-			case "owner_currency_key", "accounts_owner_fkey":
+			case "owner_currency_type_key", "accounts_owner_fkey":
 				ctx.JSON(http.StatusForbidden, errorResponse(err))
 				return
 			}
@@ -52,7 +105,13 @@ func (server *Server) CreateAccount(ctx *gin.Context) {
 		return
 
 	}
-	ctx.JSON(http.StatusOK, account)
+
+	resp, err := server.newAccountResponse(ctx, result.Account)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, resp)
 }
 
 
@@ -67,7 +126,7 @@ type GetAccountRequest struct {
 // @Accept       json
 // @Produce      json
 // @Param 		id  path  int true "Account ID"
-// @Success      200  {object} 	db.Account
+// @Success      200  {object} 	AccountResponse
 // @Failure      400  {object}  api.ErrorResponse
 // @Failure      500  {object}  api.ErrorResponse
 // @Router       /createAccount/{id} [get]
@@ -90,11 +149,18 @@ func (server *Server) GetAccount(ctx *gin.Context) {
 	payload := ctx.MustGet(authorizationPayloadKey).(*util.TokenPayload)
 
 	if payload.Username != account.Owner {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("invalid owner")))
+		err := errors.New("invalid owner")
+		server.auditDenied(ctx, accountTarget(account.ID), err)
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, account)
+	resp, err := server.newAccountResponse(ctx, account)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, resp)
 }
 
 type ListAccountsRequest struct {
@@ -110,7 +176,7 @@ type ListAccountsRequest struct {
 // @Produce      json
 // @Param 		pageSize  query  int true "page size"
 // @Param 		pageNumber query int true "page number"
-// @Success      200  {array} 	db.Account
+// @Success      200  {array} 	AccountResponse
 // @Failure      400  {object}  api.ErrorResponse
 // @Failure      500  {object}  api.ErrorResponse
 // @Router       /listAccounts [get]
@@ -135,5 +201,19 @@ func (server *Server) ListAccounts(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, accounts)
-}
\ No newline at end of file
+	resp := make([]AccountResponse, 0, len(accounts))
+	for _, account := range accounts {
+		accountResp, err := server.newAccountResponse(ctx, account)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		resp = append(resp, accountResp)
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// accountTarget names an account in the audit log.
+func accountTarget(id int64) string {
+	return "account:" + strconv.FormatInt(id, 10)
+}
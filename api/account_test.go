@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/jackc/pgx/v5"
@@ -22,6 +23,9 @@ func TestAccount(t *testing.T)  {
 	defer ctrl.Finish()
 	user, _ := RandomUser(t)
 	account := randomAccount(user)
+	overdrawn := randomAccount(user)
+	overdrawn.Balance = -40
+	overdrawn.OverdraftLimit = 100
 
 	testCases := []struct {
 		Name string
@@ -38,10 +42,27 @@ func TestAccount(t *testing.T)  {
 			},
 			BuildStubs: func(store *mock.MockStore) {
 				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().GetAccountHeldAmount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(int64(25), nil)
 			},
 			CheckResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
-				requireMatchRequestBody(t, recorder.Body, account)
+				requireMatchRequestBody(t, recorder.Body, account, 25)
+			},
+		},
+		{
+			Name: "Overdrawn",
+			AccountID: overdrawn.ID,
+			SetupAuth: func(t *testing.T, request *http.Request) {
+				AddAuthorization(t, request, user.Username, time.Minute)
+			},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(overdrawn.ID)).Times(1).Return(overdrawn, nil)
+				store.EXPECT().GetAccountHeldAmount(gomock.Any(), gomock.Eq(overdrawn.ID)).Times(1).Return(int64(10), nil)
+			},
+			CheckResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				// 50 available, the 100 of overdraft less the 40 used and the 10 held
+				requireMatchRequestBody(t, recorder.Body, overdrawn, 10)
 			},
 		},
 		{
@@ -52,6 +73,7 @@ func TestAccount(t *testing.T)  {
 			},
 			BuildStubs: func(store *mock.MockStore) {
 				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().RecordAuditEventTx(gomock.Any(), auditEvent("unauthorized_user", db.AuditActionAuthorizationDenied)).Times(1).Return(nil, db.RecordAuditEventTxResult{})
 			},
 			CheckResponse: func(recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusUnauthorized, recorder.Code)
@@ -101,9 +123,11 @@ func TestAccount(t *testing.T)  {
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			store := mock.NewMockStore(ctrl)
+			stubAuthUser(store)
+			stubCurrencies(store)
 			tc.BuildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 			recorder := httptest.NewRecorder()
 
 			url := fmt.Sprintf("/account/%d", tc.AccountID)
@@ -126,13 +150,88 @@ func randomAccount(user db.User) db.Account {
 	}
 }
 
-func requireMatchRequestBody(t *testing.T, body *bytes.Buffer, account db.Account) {
+func requireMatchRequestBody(t *testing.T, body *bytes.Buffer, account db.Account, held int64) {
 	data, err := io.ReadAll(body)
 	require.NoError(t, err)
-	var actual db.Account
+	var actual AccountResponse
 
 	err = json.Unmarshal(data, &actual)
 	require.NoError(t, err)
-	require.Equal(t, account, actual)
+	currency := util.Currency{Code: account.Currency, Exponent: 2}
+	require.Equal(t, AccountResponse{
+		ID:        account.ID,
+		Owner:     account.Owner,
+		Balance:   util.NewMoney(account.Balance, currency),
+		AvailableBalance: util.NewMoney(account.Balance+account.OverdraftLimit-held, currency),
+		OverdraftLimit: util.NewMoney(account.OverdraftLimit, currency),
+		Currency:  account.Currency,
+		IsFrozen:  account.IsFrozen,
+		CreatedAt: account.CreatedAt.Time,
+	}, actual)
 
-}
\ No newline at end of file
+}
+func TestCreateAccountType(t *testing.T) {
+	user, _ := RandomUser(t)
+
+	testCases := []struct {
+		Name          string
+		Type          string
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "Savings",
+			Type: util.SavingsAccount,
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateAccountTxParams) (error, db.CreateAccountTxResult) {
+						require.Equal(t, db.CreateAccountParams{Owner: user.Username, Currency: util.USD, Type: util.SavingsAccount}, arg.CreateAccountParams)
+						// the account is recorded in the audit log as part of creating it
+						require.Equal(t, user.Username, arg.Audit.Actor)
+						return nil, db.CreateAccountTxResult{Account: db.Account{ID: 1, Owner: user.Username, Currency: util.USD, Type: util.SavingsAccount}}
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp AccountResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, util.SavingsAccount, resp.Type)
+			},
+		},
+		{
+			Name: "InvalidType",
+			Type: "brokerage",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			stubAuthUser(store)
+			stubCurrencies(store)
+			stubHeldAmounts(store)
+			tc.BuildStubs(store)
+			server := newTestServer(t, store)
+
+			body, err := json.Marshal(CreateAccountRequest{Currency: util.USD, Type: tc.Type})
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, "/createAccount", bytes.NewReader(body))
+			require.NoError(t, err)
+			AddAuthorization(t, request, user.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
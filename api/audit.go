@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+// auditActor describes the client of a request for the audit log. The actor
+// is the authenticated user, if any.
+func auditActor(ctx *gin.Context) db.AuditActor {
+	actor := db.AuditActor{
+		ClientIp:  ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+		RequestID: requestID(ctx),
+	}
+	if payload, ok := ctx.Get(authorizationPayloadKey); ok {
+		actor.Actor = payload.(*util.TokenPayload).Username
+	}
+	return actor
+}
+
+// recordAuditEvent appends an event for the current request to the audit log.
+func (server *Server) recordAuditEvent(ctx *gin.Context, params db.AuditEventParams) error {
+	err, _ := server.store.RecordAuditEventTx(ctx, params)
+	return err
+}
+
+// auditDenied records a failed authorization check on target. The request is
+// refused either way, so a failure to record it is only reported to gin.
+func (server *Server) auditDenied(ctx *gin.Context, target string, reason error) {
+	err := server.recordAuditEvent(ctx, db.AuditEventParams{
+		AuditActor: auditActor(ctx),
+		Action:     db.AuditActionAuthorizationDenied,
+		Target:     target,
+		After:      gin.H{"method": ctx.Request.Method, "route": ctx.FullPath(), "reason": reason.Error()},
+	})
+	if err != nil {
+		_ = ctx.Error(err)
+	}
+}
+
+type ListAuditEventsRequest struct {
+	Actor      string `form:"actor"`
+	Action     string `form:"action"`
+	Target     string `form:"target"`
+	PageSize   int32  `form:"pageSize" binding:"required,min=1,max=100"`
+	PageNumber int32  `form:"pageNumber" binding:"required,min=1"`
+}
+
+type AuditEventResponse struct {
+	ID        int64           `json:"id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	ClientIp  string          `json:"client_ip"`
+	UserAgent string          `json:"user_agent"`
+	RequestID string          `json:"request_id"`
+	Before    json.RawMessage `json:"before" swaggertype:"object"`
+	After     json.RawMessage `json:"after" swaggertype:"object"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func newAuditEventResponse(event db.AuditEvent) AuditEventResponse {
+	return AuditEventResponse{
+		ID:        event.ID,
+		Actor:     event.Actor,
+		Action:    event.Action,
+		Target:    event.Target,
+		ClientIp:  event.ClientIp,
+		UserAgent: event.UserAgent,
+		RequestID: event.RequestID,
+		Before:    event.Before,
+		After:     event.After,
+		PrevHash:  event.PrevHash,
+		Hash:      event.Hash,
+		CreatedAt: event.CreatedAt.Time,
+	}
+}
+
+// ListAuditEvents godoc
+// @Summary      ListAuditEvents
+// @Description  list audit events, newest first, for admins
+// @Tags         admin
+// @Accept       json
+// @Produce      json
+// @Param 		actor  query  string false "actor"
+// @Param 		action  query  string false "action, like transfer.create"
+// @Param 		target  query  string false "target, like account:1"
+// @Param 		pageSize  query  int true "page size"
+// @Param 		pageNumber query int true "page number"
+// @Success      200  {array} 	AuditEventResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      403  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /admin/audit_events [get]
+func (server *Server) ListAuditEvents(ctx *gin.Context) {
+	var req ListAuditEventsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	events, err := server.store.ListAuditEvents(ctx, db.ListAuditEventsParams{
+		Actor:  pgtype.Text{String: req.Actor, Valid: req.Actor != ""},
+		Action: pgtype.Text{String: req.Action, Valid: req.Action != ""},
+		Target: pgtype.Text{String: req.Target, Valid: req.Target != ""},
+		Limit:  req.PageSize,
+		Offset: (req.PageNumber - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := make([]AuditEventResponse, len(events))
+	for i, event := range events {
+		resp[i] = newAuditEventResponse(event)
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
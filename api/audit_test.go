@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func auditEvent(actor string, action string) gomock.Matcher {
+	return gomock.Cond(func(x any) bool {
+		arg, ok := x.(db.AuditEventParams)
+		return ok && arg.Actor == actor && arg.Action == action
+	})
+}
+
+func TestListAuditEvents(t *testing.T) {
+	admin := db.User{Username: util.RandomOwner(), Role: util.AdminRole, IsEmailVerified: true}
+	depositor := db.User{Username: util.RandomOwner(), Role: util.DepositorRole, IsEmailVerified: true}
+
+	events := []db.AuditEvent{
+		{ID: 2, Actor: depositor.Username, Action: db.AuditActionTransferCreate, Target: "transfer:1", After: []byte(`{"id":1}`), PrevHash: "a", Hash: "b"},
+		{ID: 1, Actor: depositor.Username, Action: db.AuditActionAccountCreate, Target: "account:1", Hash: "a"},
+	}
+
+	testCases := []struct {
+		Name          string
+		User          db.User
+		Query         string
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:  "ok",
+			User:  admin,
+			Query: "?pageSize=10&pageNumber=1&actor=" + depositor.Username,
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.ListAuditEventsParams) ([]db.AuditEvent, error) {
+						require.Equal(t, depositor.Username, arg.Actor.String)
+						require.True(t, arg.Actor.Valid)
+						require.False(t, arg.Action.Valid)
+						require.False(t, arg.Target.Valid)
+						require.EqualValues(t, 10, arg.Limit)
+						require.Zero(t, arg.Offset)
+						return events, nil
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp []AuditEventResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Len(t, resp, 2)
+				require.JSONEq(t, `{"id":1}`, string(resp[0].After))
+				require.Equal(t, "null", string(resp[1].After))
+			},
+		},
+		{
+			Name:  "not an admin",
+			User:  depositor,
+			Query: "?pageSize=10&pageNumber=1",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().RecordAuditEventTx(gomock.Any(), auditEvent(depositor.Username, db.AuditActionAuthorizationDenied)).Times(1).Return(nil, db.RecordAuditEventTxResult{})
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			Name:  "bad request",
+			User:  admin,
+			Query: "?pageSize=1000&pageNumber=1",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ListAuditEvents(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(tc.User.Username)).AnyTimes().Return(tc.User, nil)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/admin/audit_events"+tc.Query, nil)
+			require.NoError(t, err)
+			AddAuthorization(t, request, tc.User.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
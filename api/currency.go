@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+// currencyCacheTTL bounds how long a change to the currencies table takes to
+// reach the server.
+const currencyCacheTTL = time.Minute
+
+// currencyRegistry caches the currencies table, which is read by most
+// requests and rarely changes.
+type currencyRegistry struct {
+	store db.Store
+
+	// mu guards currencies and expiresAt. The map is replaced on refresh and
+	// never written to, so it can be read after mu is released.
+	mu         sync.Mutex
+	currencies map[string]db.Currency
+	expiresAt  time.Time
+}
+
+func newCurrencyRegistry(store db.Store) *currencyRegistry {
+	return &currencyRegistry{store: store}
+}
+
+// lookup returns the currency with the given code, enabled or not. The table
+// is queried without holding the lock, so a slow database only holds up the
+// requests that find the cache expired.
+func (registry *currencyRegistry) lookup(ctx context.Context, code string) (db.Currency, bool, error) {
+	registry.mu.Lock()
+	currencies, expired := registry.currencies, time.Now().After(registry.expiresAt)
+	registry.mu.Unlock()
+
+	if expired {
+		list, err := registry.store.ListCurrencies(ctx)
+		if err != nil {
+			return db.Currency{}, false, err
+		}
+		currencies = make(map[string]db.Currency, len(list))
+		for _, currency := range list {
+			currencies[currency.Code] = currency
+		}
+
+		registry.mu.Lock()
+		registry.currencies = currencies
+		registry.expiresAt = time.Now().Add(currencyCacheTTL)
+		registry.mu.Unlock()
+	}
+
+	currency, ok := currencies[code]
+	return currency, ok, nil
+}
+
+// currency returns what amounts of the currency with the given code need to
+// be formatted and parsed.
+func (registry *currencyRegistry) currency(ctx context.Context, code string) (util.Currency, error) {
+	currency, ok, err := registry.lookup(ctx, code)
+	if err != nil {
+		return util.Currency{}, err
+	}
+	if !ok {
+		return util.Currency{}, fmt.Errorf("%w: %s", util.ErrUnknownCurrency, code)
+	}
+	return util.Currency{Code: currency.Code, Exponent: int(currency.Exponent)}, nil
+}
+
+type CurrencyResponse struct {
+	Code     string `json:"code"`
+	Exponent int16  `json:"exponent"`
+}
+
+// ListCurrencies godoc
+// @Summary      ListCurrencies
+// @Description  list the currencies accounts can be opened in, amounts have exponent digits after the decimal separator
+// @Tags         accounts
+// @Produce      json
+// @Success      200  {array} 	CurrencyResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /currencies [get]
+func (server *Server) ListCurrencies(ctx *gin.Context) {
+	currencies, err := server.store.ListCurrencies(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := []CurrencyResponse{}
+	for _, currency := range currencies {
+		if currency.Enabled {
+			resp = append(resp, CurrencyResponse{Code: currency.Code, Exponent: currency.Exponent})
+		}
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
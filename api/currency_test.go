@@ -0,0 +1,170 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// testCurrencies are the currencies of a fresh database plus a disabled one
+// without minor units.
+var testCurrencies = []db.Currency{
+	{Code: util.CNY, Exponent: 2, Enabled: true},
+	{Code: util.EUR, Exponent: 2, Enabled: true},
+	{Code: "JPY", Exponent: 0, Enabled: false},
+	{Code: util.USD, Exponent: 2, Enabled: true},
+}
+
+// stubCurrencies serves testCurrencies to the currency registry.
+func stubCurrencies(store *mock.MockStore) {
+	store.EXPECT().ListCurrencies(gomock.Any()).AnyTimes().Return(testCurrencies, nil)
+}
+
+func TestListCurrencies(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock.NewMockStore(ctrl)
+	stubCurrencies(store)
+	server := newTestServer(t, store)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/currencies", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var resp []CurrencyResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+	require.Equal(t, []CurrencyResponse{
+		{Code: util.CNY, Exponent: 2},
+		{Code: util.EUR, Exponent: 2},
+		{Code: util.USD, Exponent: 2},
+	}, resp)
+}
+
+func TestCurrencyRegistryRefresh(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock.NewMockStore(ctrl)
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	store.EXPECT().ListCurrencies(gomock.Any()).Times(2).
+		DoAndReturn(func(ctx context.Context) ([]db.Currency, error) {
+			started <- struct{}{}
+			<-release
+			return testCurrencies, nil
+		})
+	registry := newCurrencyRegistry(store)
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := registry.currency(context.Background(), util.USD)
+			errs <- err
+		}()
+	}
+	// a slow refresh does not hold the registry locked for other requests
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("lookup waited for another request's refresh")
+		}
+	}
+	close(release)
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+
+	// the refreshed currencies are cached
+	currency, err := registry.currency(context.Background(), util.USD)
+	require.NoError(t, err)
+	require.Equal(t, util.Currency{Code: util.USD, Exponent: 2}, currency)
+}
+
+func TestCreateAccountCurrency(t *testing.T) {
+	user, _ := RandomUser(t)
+
+	testCases := []struct {
+		Name          string
+		Currency      string
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:     "enabled",
+			Currency: util.EUR,
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateAccountTxParams) (error, db.CreateAccountTxResult) {
+						require.Equal(t, db.CreateAccountParams{Owner: user.Username, Currency: util.EUR, Type: util.CheckingAccount}, arg.CreateAccountParams)
+						return nil, db.CreateAccountTxResult{Account: db.Account{ID: 1, Owner: user.Username, Balance: 1234, Currency: util.EUR}}
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp AccountResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, util.EUR, resp.Currency)
+				require.Equal(t, util.NewMoney(1234, util.Currency{Code: util.EUR, Exponent: 2}), resp.Balance)
+				require.Contains(t, recorder.Body.String(), `"balance":{"amount":"12.34","currency":"EUR"}`)
+			},
+		},
+		{
+			Name:     "disabled",
+			Currency: "JPY",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name:     "unknown",
+			Currency: "XXX",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CreateAccountTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			stubAuthUser(store)
+			stubCurrencies(store)
+			stubHeldAmounts(store)
+			tc.BuildStubs(store)
+			server := newTestServer(t, store)
+
+			body, err := json.Marshal(CreateAccountRequest{Currency: tc.Currency})
+			require.NoError(t, err)
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodPost, "/createAccount", bytes.NewReader(body))
+			require.NoError(t, err)
+			AddAuthorization(t, request, user.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
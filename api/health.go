@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+// readinessTimeout bounds all readiness checks of a single probe.
+const readinessTimeout = 2 * time.Second
+
+const (
+	healthOK           = "ok"
+	healthUnavailable  = "unavailable"
+	healthShuttingDown = "shutting_down"
+)
+
+// HealthCheck returns why a dependency of the server is not ready, nil when
+// it is.
+type HealthCheck func(ctx context.Context) error
+
+type health struct {
+	checks map[string]HealthCheck
+	// shuttingDown fails readiness while the server drains its connections.
+	shuttingDown atomic.Bool
+}
+
+type HealthResponse struct {
+	Status string `json:"status"`
+	// Checks maps every readiness check to ok or unavailable.
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// AddReadinessCheck registers a check that has to pass for /readyz to
+// succeed. It must be called before Start.
+func (server *Server) AddReadinessCheck(name string, check HealthCheck) {
+	server.health.checks[name] = check
+}
+
+// Healthz godoc
+// @Summary      Liveness probe
+// @Description  answers as long as the process is able to serve requests
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  HealthResponse
+// @Router       /healthz [get]
+func (server *Server) Healthz(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, HealthResponse{Status: healthOK})
+}
+
+// Readyz godoc
+// @Summary      Readiness probe
+// @Description  checks the database, the schema version and the background workers, and fails once the server is shutting down
+// @Tags         health
+// @Produce      json
+// @Success      200  {object}  HealthResponse
+// @Failure      503  {object}  HealthResponse
+// @Router       /readyz [get]
+func (server *Server) Readyz(ctx *gin.Context) {
+	if server.health.shuttingDown.Load() {
+		ctx.JSON(http.StatusServiceUnavailable, HealthResponse{Status: healthShuttingDown})
+		return
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+
+	resp := HealthResponse{Status: healthOK, Checks: make(map[string]string, len(server.health.checks))}
+	status := http.StatusOK
+	for name, check := range server.health.checks {
+		if err := check(checkCtx); err != nil {
+			// the probe is unauthenticated, why a check failed only goes to
+			// the log
+			util.Logger(ctx).Error("readiness check failed", "check", name, "error", err)
+			resp.Checks[name] = healthUnavailable
+			resp.Status = healthUnavailable
+			status = http.StatusServiceUnavailable
+			continue
+		}
+		resp.Checks[name] = healthOK
+	}
+
+	ctx.JSON(status, resp)
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jxgzzztang/simplebank/db/mock"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestReadyz(t *testing.T) {
+	testCases := []struct {
+		Name          string
+		Checks        map[string]HealthCheck
+		ShuttingDown  bool
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "ready",
+			Checks: map[string]HealthCheck{
+				"database": func(ctx context.Context) error { return nil },
+				"workers":  func(ctx context.Context) error { return nil },
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				resp := decodeHealthResponse(t, recorder)
+				require.Equal(t, healthOK, resp.Status)
+				require.Equal(t, map[string]string{"database": healthOK, "workers": healthOK}, resp.Checks)
+			},
+		},
+		{
+			Name: "failing check",
+			Checks: map[string]HealthCheck{
+				"database": func(ctx context.Context) error { return nil },
+				"workers":  func(ctx context.Context) error { return errors.New("task processor is not running") },
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				resp := decodeHealthResponse(t, recorder)
+				require.Equal(t, healthUnavailable, resp.Status)
+				require.Equal(t, healthOK, resp.Checks["database"])
+				require.Equal(t, healthUnavailable, resp.Checks["workers"])
+				require.NotContains(t, recorder.Body.String(), "task processor")
+			},
+		},
+		{
+			Name: "shutting down",
+			Checks: map[string]HealthCheck{
+				"database": func(ctx context.Context) error { return nil },
+			},
+			ShuttingDown: true,
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+				require.Equal(t, healthShuttingDown, decodeHealthResponse(t, recorder).Status)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := newTestServer(t, mock.NewMockStore(ctrl))
+			for name, check := range tc.Checks {
+				server.AddReadinessCheck(name, check)
+			}
+			server.health.shuttingDown.Store(tc.ShuttingDown)
+
+			recorder := httptest.NewRecorder()
+			request, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+			require.NoError(t, err)
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestHealthz(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	server := newTestServer(t, mock.NewMockStore(ctrl))
+	server.health.shuttingDown.Store(true)
+
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/healthz", nil)
+	require.NoError(t, err)
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestStartDrainsBeforeReturning(t *testing.T) {
+	shutdownConfig := util.Config.Shutdown
+	util.Config.Shutdown = util.Shutdown{DrainDelay: 200 * time.Millisecond, Timeout: time.Second}
+	defer func() { util.Config.Shutdown = shutdownConfig }()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	server := newTestServer(t, mock.NewMockStore(ctrl))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Start(ctx, "127.0.0.1:0")
+	}()
+
+	readyz := func() int {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/readyz", nil)
+		require.NoError(t, err)
+		server.router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+	require.Equal(t, http.StatusOK, readyz())
+
+	cancel()
+	require.Eventually(t, func() bool {
+		return readyz() == http.StatusServiceUnavailable
+	}, time.Second, 10*time.Millisecond)
+
+	// readiness fails while the server still drains
+	select {
+	case err := <-done:
+		t.Fatalf("server returned before draining: %v", err)
+	default:
+	}
+	require.NoError(t, <-done)
+}
+
+func decodeHealthResponse(t *testing.T, recorder *httptest.ResponseRecorder) HealthResponse {
+	var resp HealthResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+	return resp
+}
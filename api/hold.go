@@ -0,0 +1,340 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const defaultHoldMaxDuration = 7 * 24 * time.Hour
+
+type PlaceHoldRequest struct {
+	AccountID int64 `json:"account_id" binding:"required,min=1"`
+	// Amount is a decimal string like "12.34", see TransferRequest.
+	Amount   string `json:"amount" binding:"required"`
+	Currency string `json:"currency" binding:"required,currency"`
+	// Reference identifies the hold for the client, placing a hold with a
+	// reference already used on the account fails with 409.
+	Reference string `json:"reference" binding:"required,max=64"`
+	// ExpiresIn is the number of seconds until the hold expires.
+	ExpiresIn int64 `json:"expires_in" binding:"required,min=1"`
+}
+
+type HoldRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type CaptureHoldRequest struct {
+	ToAccountID int64 `json:"to_account_id" binding:"required,min=1"`
+	// Amount is captured and the rest of the hold released. The whole hold is
+	// captured when it is empty.
+	Amount string `json:"amount"`
+}
+
+type HoldResponse struct {
+	ID             int64      `json:"id"`
+	AccountID      int64      `json:"account_id"`
+	Amount         util.Money `json:"amount" swaggertype:"object,string"`
+	CapturedAmount util.Money `json:"captured_amount" swaggertype:"object,string"`
+	Reference      string     `json:"reference"`
+	Status         string     `json:"status"`
+	TransferID     *int64     `json:"transfer_id,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+}
+
+type CaptureHoldResponse struct {
+	Hold     HoldResponse     `json:"hold"`
+	Transfer TransferResponse `json:"transfer"`
+}
+
+func newHoldResponse(hold db.Hold, currency util.Currency) HoldResponse {
+	resp := HoldResponse{
+		ID:             hold.ID,
+		AccountID:      hold.AccountID,
+		Amount:         util.NewMoney(hold.Amount, currency),
+		CapturedAmount: util.NewMoney(hold.CapturedAmount, currency),
+		Reference:      hold.Reference,
+		Status:         hold.Status,
+		ExpiresAt:      hold.ExpiresAt.Time,
+		CreatedAt:      hold.CreatedAt.Time,
+	}
+	if hold.TransferID.Valid {
+		resp.TransferID = &hold.TransferID.Int64
+	}
+	if hold.ClosedAt.Valid {
+		resp.ClosedAt = &hold.ClosedAt.Time
+	}
+	return resp
+}
+
+// holdTarget names a hold in the audit log.
+func holdTarget(id int64) string {
+	return "hold:" + strconv.FormatInt(id, 10)
+}
+
+func holdMaxDuration() time.Duration {
+	if util.Config.Holds.MaxDuration > 0 {
+		return util.Config.Holds.MaxDuration
+	}
+	return defaultHoldMaxDuration
+}
+
+// PlaceHold godoc
+// @Summary      PlaceHold
+// @Description  reserve funds of an account until the hold is captured, released or expires
+// @Tags         holds
+// @Accept       json
+// @Produce      json
+// @Param hold body PlaceHoldRequest true "hold to place"
+// @Success      200  {object} 	HoldResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      403  {object}  api.ErrorResponse
+// @Failure      409  {object}  api.ErrorResponse
+// @Failure      422  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /holds [post]
+func (server *Server) PlaceHold(ctx *gin.Context) {
+	var req PlaceHoldRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	expiresIn := time.Duration(req.ExpiresIn) * time.Second
+	if maxDuration := holdMaxDuration(); expiresIn > maxDuration {
+		ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("a hold can last at most %s", maxDuration)))
+		return
+	}
+
+	currency, err := server.currencies.currency(ctx, req.Currency)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	amount, err := util.ParseMoney(req.Amount, currency)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !amount.IsPositive() {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("amount must be positive")))
+		return
+	}
+
+	account, isValid := server.validateCurrency(ctx, req.AccountID, req.Currency)
+	if !isValid {
+		return
+	}
+
+	payload := ctx.MustGet(authorizationPayloadKey).(*util.TokenPayload)
+	if payload.Username != account.Owner {
+		err := errors.New("account does not have permission to place a hold")
+		server.auditDenied(ctx, accountTarget(account.ID), err)
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	actor := auditActor(ctx)
+	err, result := server.store.PlaceHoldTx(ctx, db.PlaceHoldTxParams{
+		AccountID: account.ID,
+		Amount:    amount,
+		Reference: req.Reference,
+		ExpiresAt: time.Now().Add(expiresIn),
+		Audit:     &actor,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.Is(err, db.ErrInsufficientFunds):
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		case errors.As(err, &pgErr) && pgErr.ConstraintName == "holds_account_id_reference_key":
+			ctx.JSON(http.StatusConflict, errorResponse(errors.New("reference is already used by a hold on the account")))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newHoldResponse(result.Hold, currency))
+}
+
+// ownedHold loads the hold named in the uri and the account it is on, and
+// checks that the account belongs to the caller. It writes the error response
+// and returns false otherwise.
+func (server *Server) ownedHold(ctx *gin.Context) (db.Hold, db.Account, bool) {
+	var req HoldRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.Hold{}, db.Account{}, false
+	}
+
+	hold, err := server.store.GetHold(ctx, req.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return hold, db.Account{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return hold, db.Account{}, false
+	}
+	account, err := server.store.GetAccount(ctx, hold.AccountID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return hold, account, false
+	}
+
+	payload := ctx.MustGet(authorizationPayloadKey).(*util.TokenPayload)
+	if payload.Username != account.Owner {
+		err := errors.New("hold is on an account of another user")
+		server.auditDenied(ctx, holdTarget(hold.ID), err)
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return hold, account, false
+	}
+	return hold, account, true
+}
+
+// CaptureHold godoc
+// @Summary      CaptureHold
+// @Description  transfer all or part of a hold and release the rest
+// @Tags         holds
+// @Accept       json
+// @Produce      json
+// @Param id path int true "Hold ID"
+// @Param capture body CaptureHoldRequest true "where to transfer the captured amount"
+// @Success      200  {object} 	CaptureHoldResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      403  {object}  api.ErrorResponse
+// @Failure      404  {object}  api.ErrorResponse
+// @Failure      409  {object}  api.ErrorResponse
+// @Failure      422  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /holds/{id}/capture [post]
+func (server *Server) CaptureHold(ctx *gin.Context) {
+	var req CaptureHoldRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	hold, account, ok := server.ownedHold(ctx)
+	if !ok {
+		return
+	}
+	// the hold was placed before the account was frozen, capturing it would
+	// still move money out
+	if account.IsFrozen {
+		ctx.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("account [%d] is frozen", account.ID)))
+		return
+	}
+
+	currency, err := server.currencies.currency(ctx, account.Currency)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	amount := util.NewMoney(hold.Amount, currency)
+	if req.Amount != "" {
+		amount, err = util.ParseMoney(req.Amount, currency)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		if !amount.IsPositive() {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("amount must be positive")))
+			return
+		}
+	}
+
+	payload := ctx.MustGet(authorizationPayloadKey).(*util.TokenPayload)
+	if !requireStepUp(ctx, payload, amount) {
+		return
+	}
+
+	_, isValid := server.validateCurrency(ctx, req.ToAccountID, account.Currency)
+	if !isValid {
+		return
+	}
+
+	actor := auditActor(ctx)
+	err, result := server.store.CaptureHoldTx(ctx, db.CaptureHoldTxParams{
+		HoldID:      hold.ID,
+		ToAccountID: req.ToAccountID,
+		Amount:      amount,
+		Audit:       &actor,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrHoldNotActive):
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+		case errors.Is(err, db.ErrCaptureExceedsHold), errors.Is(err, db.ErrInsufficientFunds):
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		case errors.Is(err, util.ErrCurrencyMismatch):
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+
+	transfer, err := server.newTransferResponse(ctx, result.TransferTxResult, currency)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, CaptureHoldResponse{
+		Hold:     newHoldResponse(result.Hold, currency),
+		Transfer: transfer,
+	})
+}
+
+// ReleaseHold godoc
+// @Summary      ReleaseHold
+// @Description  give the funds of an active hold back to the account
+// @Tags         holds
+// @Produce      json
+// @Param id path int true "Hold ID"
+// @Success      200  {object} 	HoldResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      403  {object}  api.ErrorResponse
+// @Failure      404  {object}  api.ErrorResponse
+// @Failure      409  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /holds/{id}/release [post]
+func (server *Server) ReleaseHold(ctx *gin.Context) {
+	hold, account, ok := server.ownedHold(ctx)
+	if !ok {
+		return
+	}
+	currency, err := server.currencies.currency(ctx, account.Currency)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	actor := auditActor(ctx)
+	err, result := server.store.ReleaseHoldTx(ctx, db.ReleaseHoldTxParams{
+		HoldID: hold.ID,
+		Audit:  &actor,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrHoldNotActive) {
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newHoldResponse(result.Hold, currency))
+}
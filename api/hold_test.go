@@ -0,0 +1,386 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+// stubHeldAmounts reports no holds on any account.
+func stubHeldAmounts(store *mock.MockStore) {
+	store.EXPECT().GetAccountHeldAmount(gomock.Any(), gomock.Any()).AnyTimes().Return(int64(0), nil)
+}
+
+func randomHold(account db.Account) db.Hold {
+	return db.Hold{
+		ID:        util.RandomInt(1, 1000),
+		AccountID: account.ID,
+		Amount:    util.RandomInt(100, 1000),
+		Reference: util.RandomString(8),
+		Status:    db.HoldActive,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
+}
+
+func TestPlaceHold(t *testing.T) {
+	user, _ := RandomUser(t)
+	account := randomAccount(user)
+	currency := util.Currency{Code: account.Currency, Exponent: 2}
+
+	testCases := []struct {
+		Name          string
+		Username      string
+		Body          gin.H
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:     "OK",
+			Username: user.Username,
+			Body:     gin.H{"account_id": account.ID, "amount": "12.50", "currency": account.Currency, "reference": "order-1", "expires_in": 3600},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().PlaceHoldTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.PlaceHoldTxParams) (error, db.PlaceHoldTxResult) {
+						require.Equal(t, account.ID, arg.AccountID)
+						require.Equal(t, util.NewMoney(1250, currency), arg.Amount)
+						require.Equal(t, "order-1", arg.Reference)
+						require.WithinDuration(t, time.Now().Add(time.Hour), arg.ExpiresAt, time.Minute)
+						require.Equal(t, user.Username, arg.Audit.Actor)
+						return nil, db.PlaceHoldTxResult{Hold: db.Hold{
+							ID:        1,
+							AccountID: arg.AccountID,
+							Amount:    arg.Amount.Amount,
+							Reference: arg.Reference,
+							Status:    db.HoldActive,
+							ExpiresAt: pgtype.Timestamptz{Time: arg.ExpiresAt, Valid: true},
+						}}
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp HoldResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, util.NewMoney(1250, currency), resp.Amount)
+				require.Equal(t, db.HoldActive, resp.Status)
+				require.Nil(t, resp.TransferID)
+			},
+		},
+		{
+			Name:     "InsufficientFunds",
+			Username: user.Username,
+			Body:     gin.H{"account_id": account.ID, "amount": "12.50", "currency": account.Currency, "reference": "order-1", "expires_in": 3600},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().PlaceHoldTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ErrInsufficientFunds, db.PlaceHoldTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			Name:     "DuplicateReference",
+			Username: user.Username,
+			Body:     gin.H{"account_id": account.ID, "amount": "12.50", "currency": account.Currency, "reference": "order-1", "expires_in": 3600},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().PlaceHoldTx(gomock.Any(), gomock.Any()).Times(1).
+					Return(&pgconn.PgError{Code: "23505", ConstraintName: "holds_account_id_reference_key"}, db.PlaceHoldTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			Name:     "NotOwner",
+			Username: "other_user",
+			Body:     gin.H{"account_id": account.ID, "amount": "12.50", "currency": account.Currency, "reference": "order-1", "expires_in": 3600},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+				store.EXPECT().RecordAuditEventTx(gomock.Any(), auditEvent("other_user", db.AuditActionAuthorizationDenied)).Times(1).Return(nil, db.RecordAuditEventTxResult{})
+				store.EXPECT().PlaceHoldTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			Name:     "TooLong",
+			Username: user.Username,
+			Body:     gin.H{"account_id": account.ID, "amount": "12.50", "currency": account.Currency, "reference": "order-1", "expires_in": int64(defaultHoldMaxDuration/time.Second) + 1},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().PlaceHoldTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			stubAuthUser(store)
+			stubCurrencies(store)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.Body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/holds", bytes.NewReader(body))
+			require.NoError(t, err)
+			AddAuthorization(t, request, tc.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestCaptureHold(t *testing.T) {
+	user, _ := RandomUser(t)
+	account := randomAccount(user)
+	merchant := randomAccount(user)
+	merchant.ID = account.ID + 1
+	merchant.Currency = account.Currency
+	currency := util.Currency{Code: account.Currency, Exponent: 2}
+	hold := randomHold(account)
+
+	captured := func(amount int64) func(ctx context.Context, arg db.CaptureHoldTxParams) (error, db.CaptureHoldTxResult) {
+		return func(ctx context.Context, arg db.CaptureHoldTxParams) (error, db.CaptureHoldTxResult) {
+			require.Equal(t, hold.ID, arg.HoldID)
+			require.Equal(t, merchant.ID, arg.ToAccountID)
+			require.Equal(t, util.NewMoney(amount, currency), arg.Amount)
+
+			result := db.CaptureHoldTxResult{Hold: hold}
+			result.Hold.Status = db.HoldCaptured
+			result.Hold.CapturedAmount = amount
+			result.Hold.TransferID = pgtype.Int8{Int64: 7, Valid: true}
+			result.Transfer = db.Transfer{ID: 7, FromAccountID: account.ID, ToAccountID: merchant.ID, Amount: amount}
+			result.FromAccount = account
+			result.ToAccount = merchant
+			return nil, result
+		}
+	}
+
+	testCases := []struct {
+		Name          string
+		Body          gin.H
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "Full",
+			Body: gin.H{"to_account_id": merchant.ID},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CaptureHoldTx(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(captured(hold.Amount))
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp CaptureHoldResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, db.HoldCaptured, resp.Hold.Status)
+				require.Equal(t, util.NewMoney(hold.Amount, currency), resp.Hold.CapturedAmount)
+				require.Equal(t, int64(7), *resp.Hold.TransferID)
+				require.Equal(t, util.NewMoney(hold.Amount, currency), resp.Transfer.Transfer.Amount)
+			},
+		},
+		{
+			Name: "Partial",
+			Body: gin.H{"to_account_id": merchant.ID, "amount": "0.50"},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CaptureHoldTx(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(captured(50))
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp CaptureHoldResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, util.NewMoney(50, currency), resp.Hold.CapturedAmount)
+			},
+		},
+		{
+			Name: "NotActive",
+			Body: gin.H{"to_account_id": merchant.ID},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CaptureHoldTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ErrHoldNotActive, db.CaptureHoldTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			Name: "ExceedsHold",
+			Body: gin.H{"to_account_id": merchant.ID, "amount": "100.00"},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CaptureHoldTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ErrCaptureExceedsHold, db.CaptureHoldTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			stubAuthUser(store)
+			stubCurrencies(store)
+			stubHeldAmounts(store)
+			store.EXPECT().GetHold(gomock.Any(), gomock.Eq(hold.ID)).Times(1).Return(hold, nil)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(merchant.ID)).Times(1).Return(merchant, nil)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.Body)
+			require.NoError(t, err)
+			url := fmt.Sprintf("/holds/%d/capture", hold.ID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			require.NoError(t, err)
+			AddAuthorization(t, request, user.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestCaptureHoldFrozenAccount(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	user, _ := RandomUser(t)
+	account := randomAccount(user)
+	account.IsFrozen = true
+	hold := randomHold(account)
+
+	store := mock.NewMockStore(ctrl)
+	stubAuthUser(store)
+	stubCurrencies(store)
+	store.EXPECT().GetHold(gomock.Any(), gomock.Eq(hold.ID)).Times(1).Return(hold, nil)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+	store.EXPECT().CaptureHoldTx(gomock.Any(), gomock.Any()).Times(0)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	body, err := json.Marshal(gin.H{"to_account_id": account.ID + 1})
+	require.NoError(t, err)
+	url := fmt.Sprintf("/holds/%d/capture", hold.ID)
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	require.NoError(t, err)
+	AddAuthorization(t, request, user.Username, time.Minute)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestReleaseHold(t *testing.T) {
+	user, _ := RandomUser(t)
+	account := randomAccount(user)
+	hold := randomHold(account)
+
+	testCases := []struct {
+		Name          string
+		Username      string
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:     "OK",
+			Username: user.Username,
+			BuildStubs: func(store *mock.MockStore) {
+				released := hold
+				released.Status = db.HoldReleased
+				released.ClosedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+				store.EXPECT().ReleaseHoldTx(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(
+					func(_ context.Context, arg db.ReleaseHoldTxParams) (error, db.ReleaseHoldTxResult) {
+						require.Equal(t, hold.ID, arg.HoldID)
+						require.Equal(t, user.Username, arg.Audit.Actor)
+						return nil, db.ReleaseHoldTxResult{Hold: released}
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp HoldResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, db.HoldReleased, resp.Status)
+				require.NotNil(t, resp.ClosedAt)
+			},
+		},
+		{
+			Name:     "NotActive",
+			Username: user.Username,
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ReleaseHoldTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ErrHoldNotActive, db.ReleaseHoldTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			Name:     "NotOwner",
+			Username: "other_user",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().RecordAuditEventTx(gomock.Any(), auditEvent("other_user", db.AuditActionAuthorizationDenied)).Times(1).Return(nil, db.RecordAuditEventTxResult{})
+				store.EXPECT().ReleaseHoldTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			stubAuthUser(store)
+			stubCurrencies(store)
+			store.EXPECT().GetHold(gomock.Any(), gomock.Eq(hold.ID)).Times(1).Return(hold, nil)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account.ID)).Times(1).Return(account, nil)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/holds/%d/release", hold.ID)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+			AddAuthorization(t, request, tc.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"log/slog"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jxgzzztang/simplebank/util"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "requestIDKey"
+)
+
+// validRequestID limits the request IDs accepted from clients, which end up
+// in logs and the audit log.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// requestIDMiddleware gives every request an ID, the one sent by the client
+// if it is sensible, and a logger carrying it in the request context.
+func requestIDMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		requestID := ctx.GetHeader(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		ctx.Set(requestIDKey, requestID)
+		ctx.Header(requestIDHeader, requestID)
+
+		requestLogger := logger.With(slog.String("request_id", requestID))
+		ctx.Request = ctx.Request.WithContext(util.WithLogger(ctx.Request.Context(), requestLogger))
+		ctx.Next()
+	}
+}
+
+// accessLogMiddleware logs every request once it is served. Only the path is
+// logged, query strings may carry secrets like the email verification code.
+func accessLogMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		attrs := []slog.Attr{
+			slog.String("method", ctx.Request.Method),
+			slog.String("route", ctx.FullPath()),
+			slog.String("path", ctx.Request.URL.Path),
+			slog.Int("status", ctx.Writer.Status()),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", ctx.ClientIP()),
+		}
+		if payload, ok := ctx.Get(authorizationPayloadKey); ok {
+			attrs = append(attrs, slog.String("username", payload.(*util.TokenPayload).Username))
+		}
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.HasTraceID() {
+			attrs = append(attrs, slog.String("trace_id", spanContext.TraceID().String()))
+		}
+		if len(ctx.Errors) > 0 {
+			attrs = append(attrs, slog.String("errors", ctx.Errors.String()))
+		}
+
+		level := slog.LevelInfo
+		if ctx.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+		util.Logger(ctx).LogAttrs(ctx, level, "request", attrs...)
+	}
+}
+
+// requestID returns the ID given to the request by requestIDMiddleware.
+func requestID(ctx *gin.Context) string {
+	return ctx.GetString(requestIDKey)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jxgzzztang/simplebank/db/mock"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRequestLogging(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mock.NewMockStore(ctrl)
+	stubAuthUser(store)
+
+	var buf bytes.Buffer
+	router := gin.New()
+	router.ContextWithFallback = true
+	router.Use(requestIDMiddleware(util.NewLogger(&buf, util.Log{})), accessLogMiddleware())
+
+	var handlerRequestID string
+	router.GET("/auth/:id", authMiddleware(store), func(ctx *gin.Context) {
+		// handlers and the store layer get the request logger from the context
+		handlerRequestID = requestID(ctx)
+		util.Logger(ctx).Info("in handler")
+		ctx.JSON(http.StatusOK, gin.H{})
+	})
+
+	username := util.RandomOwner()
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/auth/1?code=secret", nil)
+	require.NoError(t, err)
+	request.Header.Set(requestIDHeader, "client-id.1")
+	AddAuthorization(t, request, username, time.Minute)
+
+	router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "client-id.1", recorder.Header().Get(requestIDHeader))
+	require.Equal(t, "client-id.1", handlerRequestID)
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var handlerEntry, accessEntry map[string]any
+	require.NoError(t, json.Unmarshal(lines[0], &handlerEntry))
+	require.Equal(t, "client-id.1", handlerEntry["request_id"])
+
+	require.NoError(t, json.Unmarshal(lines[1], &accessEntry))
+	require.Equal(t, "request", accessEntry["msg"])
+	require.Equal(t, "client-id.1", accessEntry["request_id"])
+	require.Equal(t, http.MethodGet, accessEntry["method"])
+	require.Equal(t, "/auth/:id", accessEntry["route"])
+	require.EqualValues(t, http.StatusOK, accessEntry["status"])
+	require.Equal(t, username, accessEntry["username"])
+	require.NotContains(t, buf.String(), "secret")
+	require.NotContains(t, buf.String(), request.Header.Get(authorizationHeader))
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	router := gin.New()
+	router.Use(requestIDMiddleware(util.NewLogger(&bytes.Buffer{}, util.Log{})))
+	router.GET("/", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{})
+	})
+
+	for _, clientID := range []string{"", "has spaces", string(bytes.Repeat([]byte("a"), 129))} {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/", nil)
+		require.NoError(t, err)
+		request.Header.Set(requestIDHeader, clientID)
+
+		router.ServeHTTP(recorder, request)
+		generated := recorder.Header().Get(requestIDHeader)
+		require.NotEmpty(t, generated)
+		require.NotEqual(t, clientID, generated)
+		require.Regexp(t, validRequestID, generated)
+	}
+}
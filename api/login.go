@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	db "github.com/jxgzzztang/simplebank/db/sqlc"
@@ -33,28 +34,71 @@ func (server *Server) Login(ctx *gin.Context) {
 		return
 	}
 
+	if !server.allowLoginAttempt(ctx, req.Username) {
+		return
+	}
+
 	user, err := server.store.GetUser(ctx, req.Username)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			checkPassword(ctx, req.Password, dummyPasswordHash())
+			server.rejectLogin(ctx, req.Username)
 			return
 		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	if err := util.CheckPassword(req.Password, user.HashedPassword); err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+	if err := checkPassword(ctx, req.Password, user.HashedPassword); err != nil {
+		server.rejectLogin(ctx, req.Username)
+		return
+	}
+
+	mfaRequired, err := server.isMFAEnabled(ctx, user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// a correct password only counts as a successful login when no second
+	// factor is pending, otherwise it would reset the failure counter that
+	// also guards the code check
+	outcome := loginSuccess
+	if mfaRequired {
+		outcome = loginMFAChallenge
+	}
+	if err := server.recordLoginAttempt(ctx, user.Username, outcome); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if !user.IsEmailVerified {
+		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("email is not verified")))
 		return
 	}
 
-	token, accessTokenPayload, err := util.CreateToken(req.Username, util.Config.Jwt.ExpiresDuration)
+	if mfaRequired {
+		server.startMFAChallenge(ctx, user)
+		return
+	}
+
+	server.createSession(ctx, user, util.AMRPassword)
+}
+
+// createSession issues an access and a refresh token for a user who has
+// passed every login step with the given authentication methods.
+func (server *Server) createSession(ctx *gin.Context, user db.User, amr ...string) {
+	authentication := util.WithAuthentication(time.Now(), amr...)
+
+	token, accessTokenPayload, err := util.CreateToken(user.Username, util.Config.Jwt.ExpiresDuration, authentication)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	refreshToken, refreshTokenPayload, err := util.CreateToken(user.Username, util.Config.Jwt.RefreshDuration)
+	// the refresh token keeps auth_time so renewed access tokens do not look
+	// freshly authenticated
+	refreshToken, refreshTokenPayload, err := util.CreateToken(user.Username, util.Config.Jwt.RefreshDuration, authentication)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
@@ -77,6 +121,19 @@ func (server *Server) Login(ctx *gin.Context) {
 		return
 	}
 
+	actor := auditActor(ctx)
+	actor.Actor = user.Username
+	err = server.recordAuditEvent(ctx, db.AuditEventParams{
+		AuditActor: actor,
+		Action:     db.AuditActionLogin,
+		Target:     "session:" + uuid.UUID(refreshTokenPayload.ID.Bytes).String(),
+		After:      gin.H{"amr": amr, "expires_at": session.ExpiresAt.Time},
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
 	resp := UserResponse{
 		SessionID:             refreshTokenPayload.ID,
 		UserInfo:              CreateUserInfoResponse(user),
@@ -87,5 +144,4 @@ func (server *Server) Login(ctx *gin.Context) {
 	}
 
 	ctx.JSON(http.StatusOK, resp)
-
 }
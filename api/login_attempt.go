@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/metrics"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const (
+	loginSuccess            = "success"
+	loginInvalidCredentials = "invalid_credentials"
+	loginMFAChallenge       = "mfa_challenge"
+	loginLocked             = "locked"
+
+	// maxDelayShift bounds the exponential delay so the shift cannot overflow.
+	maxDelayShift = 16
+)
+
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// dummyPasswordHash is checked when the username does not exist, so unknown
+// and known usernames take about the same time to reject.
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := util.HashPassword("simplebank-dummy-password")
+	return hash
+})
+
+// loginRetryAfter returns how long the client has to wait before another
+// login attempt for the username, or from the client ip, is accepted.
+func (server *Server) loginRetryAfter(ctx context.Context, username string, clientIP string) (time.Duration, error) {
+	config := util.Config.Login
+
+	since := pgtype.Timestamptz{InfinityModifier: pgtype.NegativeInfinity, Valid: true}
+	if config.FailureWindow > 0 {
+		since = pgtype.Timestamptz{Time: time.Now().Add(-config.FailureWindow), Valid: true}
+	}
+
+	var wait time.Duration
+	if config.MaxFailures > 0 || config.DelayStep > 0 {
+		failures, err := server.store.CountUserLoginFailures(ctx, db.CountUserLoginFailuresParams{
+			Username: username,
+			Since:    since,
+		})
+		if err != nil {
+			return 0, err
+		}
+		delay := loginDelay(failures.Failures, config)
+		wait = max(wait, time.Until(failures.LastFailedAt.Time.Add(delay)))
+	}
+
+	if config.MaxIPFailures > 0 {
+		failures, err := server.store.CountIPLoginFailures(ctx, db.CountIPLoginFailuresParams{
+			ClientIp: clientIP,
+			Since:    since,
+		})
+		if err != nil {
+			return 0, err
+		}
+		if failures.Failures >= int64(config.MaxIPFailures) {
+			wait = max(wait, time.Until(failures.LastFailedAt.Time.Add(config.LockoutDuration)))
+		}
+	}
+
+	return wait, nil
+}
+
+// allowLoginAttempt answers 429 with a Retry-After header and returns false
+// while the username or the client ip is locked out.
+func (server *Server) allowLoginAttempt(ctx *gin.Context, username string) bool {
+	retryAfter, err := server.loginRetryAfter(ctx, username, ctx.ClientIP())
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	if retryAfter <= 0 {
+		return true
+	}
+
+	if err := server.recordLoginAttempt(ctx, username, loginLocked); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	setRetryAfter(ctx, retryAfter)
+	ctx.JSON(http.StatusTooManyRequests, errorResponse(errors.New("too many failed login attempts, try again later")))
+	return false
+}
+
+// loginDelay is the wait imposed after a number of consecutive failures. It
+// doubles from DelayStep with every failure and becomes a lockout once
+// MaxFailures is reached.
+func loginDelay(failures int64, config util.Login) time.Duration {
+	if failures <= 0 {
+		return 0
+	}
+	if config.MaxFailures > 0 && failures >= int64(config.MaxFailures) {
+		return config.LockoutDuration
+	}
+	if config.DelayStep <= 0 {
+		return 0
+	}
+
+	delay := config.DelayStep << min(failures-1, maxDelayShift)
+	if config.LockoutDuration > 0 {
+		delay = min(delay, config.LockoutDuration)
+	}
+	return delay
+}
+
+// recordLoginAttempt writes the outcome of a login attempt to the audit
+// table, which is also what the failure counters are computed from.
+func (server *Server) recordLoginAttempt(ctx *gin.Context, username string, outcome string) error {
+	_, err := server.store.CreateLoginAttempt(ctx, db.CreateLoginAttemptParams{
+		Username:  username,
+		ClientIp:  ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+		Outcome:   outcome,
+	})
+	if err != nil {
+		return err
+	}
+	metrics.ObserveLoginAttempt(outcome)
+	return nil
+}
+
+// rejectLogin records a failed attempt and answers with the same error
+// whether the username or the password was wrong.
+func (server *Server) rejectLogin(ctx *gin.Context, username string) {
+	if err := server.recordLoginAttempt(ctx, username, loginInvalidCredentials); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusUnauthorized, errorResponse(errInvalidCredentials))
+}
+
+func setRetryAfter(ctx *gin.Context, wait time.Duration) {
+	ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
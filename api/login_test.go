@@ -6,10 +6,14 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jxgzzztang/simplebank/db/mock"
 	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
@@ -20,6 +24,7 @@ func TestLogin(t *testing.T) {
 	testCases := []struct {
 		Name string
 		Body gin.H
+		Login util.Login
 		BuildStep func(store *mock.MockStore)
 		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
 	}{
@@ -33,8 +38,151 @@ func TestLogin(t *testing.T) {
 				// 现有的期望调用
 				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
 				
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginSuccess)).Times(1).Return(db.LoginAttempt{}, nil)
+
 				// 添加对 CreateSession 的期望调用
 				store.EXPECT().CreateSessions(gomock.Any(), gomock.Any()).Times(1).Return(db.Session{}, nil)
+				store.EXPECT().RecordAuditEventTx(gomock.Any(), auditEvent(user.Username, db.AuditActionLogin)).Times(1).Return(nil, db.RecordAuditEventTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name: "email not verified",
+			Body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			BuildStep: func(store *mock.MockStore) {
+				unverified := user
+				unverified.IsEmailVerified = false
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(unverified, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).Times(1).Return(db.LoginAttempt{}, nil)
+				store.EXPECT().CreateSessions(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			Name: "mfa required",
+			Body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			BuildStep: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserTotp{
+					Username:    user.Username,
+					ConfirmedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+				}, nil)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginMFAChallenge)).Times(1).Return(db.LoginAttempt{}, nil)
+				store.EXPECT().CreateSessions(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp MFAChallengeResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.True(t, resp.MFARequired)
+
+				payload, ok := util.ParseToken(resp.MFAToken)
+				require.True(t, ok)
+				require.Equal(t, util.TokenPurposeMFA, payload.Purpose)
+				require.Equal(t, user.Username, payload.Username)
+			},
+		},
+		{
+			Name: "unknown user",
+			Body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			BuildStep: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginInvalidCredentials)).Times(1).Return(db.LoginAttempt{}, nil)
+				store.EXPECT().CreateSessions(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.JSONEq(t, `{"error": "invalid credentials"}`, recorder.Body.String())
+			},
+		},
+		{
+			Name: "wrong password",
+			Body: gin.H{
+				"username": user.Username,
+				"password": "wrong-password",
+			},
+			BuildStep: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginInvalidCredentials)).Times(1).Return(db.LoginAttempt{}, nil)
+				store.EXPECT().CreateSessions(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.JSONEq(t, `{"error": "invalid credentials"}`, recorder.Body.String())
+			},
+		},
+		{
+			Name: "locked",
+			Body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			Login: util.Login{MaxFailures: 3, LockoutDuration: time.Minute},
+			BuildStep: func(store *mock.MockStore) {
+				store.EXPECT().CountUserLoginFailures(gomock.Any(), gomock.Any()).Times(1).Return(db.CountUserLoginFailuresRow{
+					Failures:     3,
+					LastFailedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+				}, nil)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginLocked)).Times(1).Return(db.LoginAttempt{}, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+				require.Equal(t, "60", recorder.Header().Get("Retry-After"))
+			},
+		},
+		{
+			Name: "ip locked",
+			Body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			Login: util.Login{MaxIPFailures: 10, LockoutDuration: time.Minute},
+			BuildStep: func(store *mock.MockStore) {
+				store.EXPECT().CountIPLoginFailures(gomock.Any(), gomock.Any()).Times(1).Return(db.CountIPLoginFailuresRow{
+					Failures:     10,
+					LastFailedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+				}, nil)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginLocked)).Times(1).Return(db.LoginAttempt{}, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+			},
+		},
+		{
+			Name: "delay elapsed",
+			Body: gin.H{
+				"username": user.Username,
+				"password": password,
+			},
+			Login: util.Login{MaxFailures: 3, LockoutDuration: time.Minute, DelayStep: time.Second},
+			BuildStep: func(store *mock.MockStore) {
+				store.EXPECT().CountUserLoginFailures(gomock.Any(), gomock.Any()).Times(1).Return(db.CountUserLoginFailuresRow{
+					Failures:     2,
+					LastFailedAt: pgtype.Timestamptz{Time: time.Now().Add(-3 * time.Second), Valid: true},
+				}, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginSuccess)).Times(1).Return(db.LoginAttempt{}, nil)
+				store.EXPECT().CreateSessions(gomock.Any(), gomock.Any()).Times(1).Return(db.Session{}, nil)
+				store.EXPECT().RecordAuditEventTx(gomock.Any(), auditEvent(user.Username, db.AuditActionLogin)).Times(1).Return(nil, db.RecordAuditEventTxResult{})
 			},
 			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -45,13 +193,17 @@ func TestLogin(t *testing.T) {
 	for i := range testCases {
 		tc := testCases[i]
 		t.Run(tc.Name, func(t *testing.T) {
+			loginConfig := util.Config.Login
+			util.Config.Login = tc.Login
+			defer func() { util.Config.Login = loginConfig }()
+
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			store := mock.NewMockStore(ctrl)
 			tc.BuildStep(store)
 			body, err := json.Marshal(tc.Body)
 			require.NoError(t, err)
-			server := NewServer(store)
+			server := newTestServer(t, store)
 
 			recorder := httptest.NewRecorder()
 
@@ -59,8 +211,30 @@ func TestLogin(t *testing.T) {
 			require.NoError(t, err)
 
 			server.router.ServeHTTP(recorder, request)
-
+			tc.CheckResponse(t, recorder)
 		})
 	}
 
 }
+
+func loginAttemptOutcome(username string, outcome string) gomock.Matcher {
+	return gomock.Cond(func(x any) bool {
+		arg, ok := x.(db.CreateLoginAttemptParams)
+		return ok && arg.Username == username && arg.Outcome == outcome
+	})
+}
+
+func TestLoginDelay(t *testing.T) {
+	config := util.Login{MaxFailures: 5, LockoutDuration: time.Minute, DelayStep: time.Second}
+
+	require.Zero(t, loginDelay(0, config))
+	require.Equal(t, time.Second, loginDelay(1, config))
+	require.Equal(t, 2*time.Second, loginDelay(2, config))
+	require.Equal(t, 8*time.Second, loginDelay(4, config))
+	require.Equal(t, time.Minute, loginDelay(5, config))
+	require.Equal(t, time.Minute, loginDelay(1000, config))
+
+	config.MaxFailures = 0
+	require.Equal(t, time.Minute, loginDelay(1000, config))
+	require.Zero(t, loginDelay(3, util.Login{}))
+}
@@ -2,10 +2,17 @@ package api
 
 import (
 	"github.com/gin-gonic/gin"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/ratelimit"
+	"github.com/jxgzzztang/simplebank/worker"
 	"os"
 	"testing"
 )
 
+func newTestServer(t *testing.T, store db.Store) Server {
+	return NewServer(store, worker.NewPostgresTaskDistributor(), ratelimit.NewMemoryLimiter())
+}
+
 func TestMain(m *testing.M)  {
 	gin.SetMode(gin.TestMode)
 	os.Exit(m.Run())
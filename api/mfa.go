@@ -0,0 +1,277 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const (
+	recoveryCodeCount = 10
+
+	defaultMFAChallengeDuration = 5 * time.Minute
+)
+
+var errTOTPEnabled = errors.New("two-factor authentication is already enabled")
+
+type MFAChallengeResponse struct {
+	MFARequired       bool      `json:"mfa_required"`
+	MFAToken          string    `json:"mfa_token"`
+	MFATokenExpiredAt time.Time `json:"mfa_token_expired_at"`
+}
+
+// isMFAEnabled reports whether the user has confirmed a TOTP secret.
+func (server *Server) isMFAEnabled(ctx context.Context, username string) (bool, error) {
+	userTOTP, err := server.store.GetUserTOTP(ctx, username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return userTOTP.ConfirmedAt.Valid, nil
+}
+
+// startMFAChallenge answers the password step of a login with a short-lived
+// token that can only be exchanged for a session at /login/mfa.
+func (server *Server) startMFAChallenge(ctx *gin.Context, user db.User) {
+	duration := util.Config.MFA.ChallengeDuration
+	if duration <= 0 {
+		duration = defaultMFAChallengeDuration
+	}
+
+	token, payload, err := util.CreateToken(user.Username, duration, util.WithPurpose(util.TokenPurposeMFA))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, MFAChallengeResponse{
+		MFARequired:       true,
+		MFAToken:          token,
+		MFATokenExpiredAt: payload.ExpiresAt.Time,
+	})
+}
+
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// LoginMFA godoc
+// @Summary      LoginMFA
+// @Description  finish a login with a TOTP or recovery code and the token returned by /login
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param loginMFAData body LoginMFARequest true "mfa token and code"
+// @Success      200  {object} 	api.UserResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      429  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /login/mfa [post]
+func (server *Server) LoginMFA(ctx *gin.Context) {
+	var req LoginMFARequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	payload, ok := util.ParseToken(req.MFAToken)
+	if !ok || payload.Purpose != util.TokenPurposeMFA {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("invalid mfa token")))
+		return
+	}
+
+	if !server.allowLoginAttempt(ctx, payload.Username) {
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("invalid mfa token")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	valid, err := server.verifySecondFactor(ctx, user.Username, req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if !valid {
+		server.rejectLogin(ctx, user.Username)
+		return
+	}
+
+	if err := server.recordLoginAttempt(ctx, user.Username, loginSuccess); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	server.createSession(ctx, user, util.AMRPassword, util.AMROTP)
+}
+
+// verifySecondFactor accepts either a current TOTP code or an unused recovery
+// code. Both are consumed, so neither can be replayed.
+func (server *Server) verifySecondFactor(ctx context.Context, username string, code string) (bool, error) {
+	userTOTP, err := server.store.GetUserTOTP(ctx, username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	if !userTOTP.ConfirmedAt.Valid {
+		return false, nil
+	}
+
+	if util.IsTOTPCode(code) {
+		step, ok := util.ValidateTOTP(code, userTOTP.Secret, time.Now())
+		if !ok {
+			return false, nil
+		}
+		_, err = server.store.UseTOTPStep(ctx, db.UseTOTPStepParams{
+			Step:     step,
+			Username: username,
+		})
+	} else {
+		_, err = server.store.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{
+			Username: username,
+			CodeHash: util.HashToken(util.NormalizeRecoveryCode(code)),
+		})
+	}
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+type EnrollTOTPResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+// EnrollTOTP godoc
+// @Summary      EnrollTOTP
+// @Description  generate a TOTP secret for the current user, it is enforced once confirmed
+// @Tags         users
+// @Produce      json
+// @Success      200  {object} 	api.EnrollTOTPResponse
+// @Failure      409  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /users/me/totp [post]
+func (server *Server) EnrollTOTP(ctx *gin.Context) {
+	user := ctx.MustGet(authorizationUserKey).(db.User)
+
+	secret, uri, err := util.GenerateTOTPSecret(user.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	_, err = server.store.UpsertUserTOTP(ctx, db.UpsertUserTOTPParams{
+		Username: user.Username,
+		Secret:   secret,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusConflict, errorResponse(errTOTPEnabled))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, EnrollTOTPResponse{
+		Secret:     secret,
+		OtpauthURI: uri,
+	})
+}
+
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+type ConfirmTOTPResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ConfirmTOTP godoc
+// @Summary      ConfirmTOTP
+// @Description  enable two-factor login with a code from the authenticator app, returns the recovery codes once
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param confirmTOTPData body ConfirmTOTPRequest true "code"
+// @Success      200  {object} 	api.ConfirmTOTPResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      409  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /users/me/totp/confirm [post]
+func (server *Server) ConfirmTOTP(ctx *gin.Context) {
+	var req ConfirmTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user := ctx.MustGet(authorizationUserKey).(db.User)
+
+	userTOTP, err := server.store.GetUserTOTP(ctx, user.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("two-factor authentication has not been set up")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if userTOTP.ConfirmedAt.Valid {
+		ctx.JSON(http.StatusConflict, errorResponse(errTOTPEnabled))
+		return
+	}
+
+	if _, ok := util.ValidateTOTP(req.Code, userTOTP.Secret, time.Now()); !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid code")))
+		return
+	}
+
+	recoveryCodes, err := util.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	hashes := make([]string, len(recoveryCodes))
+	for i, code := range recoveryCodes {
+		hashes[i] = util.HashToken(util.NormalizeRecoveryCode(code))
+	}
+
+	err, _ = server.store.ConfirmTOTPTx(ctx, db.ConfirmTOTPTxParams{
+		Username:           user.Username,
+		RecoveryCodeHashes: hashes,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusConflict, errorResponse(errTOTPEnabled))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ConfirmTOTPResponse{RecoveryCodes: recoveryCodes})
+}
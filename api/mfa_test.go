@@ -0,0 +1,370 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func randomUserTOTP(t *testing.T, username string, confirmed bool) db.UserTotp {
+	secret, _, err := util.GenerateTOTPSecret(username)
+	require.NoError(t, err)
+	return db.UserTotp{
+		Username:    username,
+		Secret:      secret,
+		ConfirmedAt: pgtype.Timestamptz{Time: time.Now(), Valid: confirmed},
+	}
+}
+
+func currentTOTPCode(t *testing.T, secret string) string {
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	return code
+}
+
+// invalidTOTPCode returns a code that is not accepted for the secret right now.
+func invalidTOTPCode(t *testing.T, secret string) string {
+	for i := 0; ; i++ {
+		code := fmt.Sprintf("%06d", i)
+		if _, ok := util.ValidateTOTP(code, secret, time.Now()); !ok {
+			return code
+		}
+	}
+}
+
+func TestLoginMFA(t *testing.T) {
+	user, _ := RandomUser(t)
+	userTOTP := randomUserTOTP(t, user.Username, true)
+
+	mfaToken, _, err := util.CreateToken(user.Username, time.Minute, util.WithPurpose(util.TokenPurposeMFA))
+	require.NoError(t, err)
+	accessToken, _, err := util.CreateToken(user.Username, time.Minute)
+	require.NoError(t, err)
+
+	recoveryCode := "abcde-fghij"
+
+	testCases := []struct {
+		Name          string
+		Body          gin.H
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "ok totp",
+			Body: gin.H{"mfa_token": mfaToken, "code": currentTOTPCode(t, userTOTP.Secret)},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(userTOTP, nil)
+				store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(userTOTP, nil)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginSuccess)).Times(1).Return(db.LoginAttempt{}, nil)
+				store.EXPECT().CreateSessions(gomock.Any(), gomock.Any()).Times(1).Return(db.Session{}, nil)
+				store.EXPECT().RecordAuditEventTx(gomock.Any(), auditEvent(user.Username, db.AuditActionLogin)).Times(1).Return(nil, db.RecordAuditEventTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp UserResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.NotEmpty(t, resp.AccessToken)
+				require.NotEmpty(t, resp.RefreshToken)
+			},
+		},
+		{
+			Name: "ok recovery code",
+			Body: gin.H{"mfa_token": mfaToken, "code": "ABCDE-FGHIJ"},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(userTOTP, nil)
+				store.EXPECT().UseRecoveryCode(gomock.Any(), gomock.Eq(db.UseRecoveryCodeParams{
+					Username: user.Username,
+					CodeHash: util.HashToken(util.NormalizeRecoveryCode(recoveryCode)),
+				})).Times(1).Return(db.RecoveryCode{}, nil)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginSuccess)).Times(1).Return(db.LoginAttempt{}, nil)
+				store.EXPECT().CreateSessions(gomock.Any(), gomock.Any()).Times(1).Return(db.Session{}, nil)
+				store.EXPECT().RecordAuditEventTx(gomock.Any(), auditEvent(user.Username, db.AuditActionLogin)).Times(1).Return(nil, db.RecordAuditEventTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name: "replayed code",
+			Body: gin.H{"mfa_token": mfaToken, "code": currentTOTPCode(t, userTOTP.Secret)},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(userTOTP, nil)
+				store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(db.UserTotp{}, pgx.ErrNoRows)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginInvalidCredentials)).Times(1).Return(db.LoginAttempt{}, nil)
+				store.EXPECT().CreateSessions(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			Name: "wrong code",
+			Body: gin.H{"mfa_token": mfaToken, "code": "12345a"},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(userTOTP, nil)
+				store.EXPECT().UseRecoveryCode(gomock.Any(), gomock.Any()).Times(1).Return(db.RecoveryCode{}, pgx.ErrNoRows)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginInvalidCredentials)).Times(1).Return(db.LoginAttempt{}, nil)
+				store.EXPECT().CreateSessions(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			Name: "access token instead of mfa token",
+			Body: gin.H{"mfa_token": accessToken, "code": currentTOTPCode(t, userTOTP.Secret)},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateSessions(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.Body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/login/mfa", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestMFATokenIsNotAnAccessToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+
+	mfaToken, _, err := util.CreateToken(util.RandomOwner(), time.Minute, util.WithPurpose(util.TokenPurposeMFA))
+	require.NoError(t, err)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/listAccounts", nil)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeader, fmt.Sprintf("%s %s", authorizationTypeBearer, mfaToken))
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func TestEnrollTOTP(t *testing.T) {
+	user, _ := RandomUser(t)
+
+	testCases := []struct {
+		Name          string
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "ok",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().UpsertUserTOTP(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.UpsertUserTOTPParams) (db.UserTotp, error) {
+						require.Equal(t, user.Username, arg.Username)
+						return db.UserTotp{Username: arg.Username, Secret: arg.Secret}, nil
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp EnrollTOTPResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.NotEmpty(t, resp.Secret)
+				require.Contains(t, resp.OtpauthURI, resp.Secret)
+			},
+		},
+		{
+			Name: "already enabled",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().UpsertUserTOTP(gomock.Any(), gomock.Any()).Times(1).Return(db.UserTotp{}, pgx.ErrNoRows)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).AnyTimes().Return(user, nil)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodPost, "/users/me/totp", nil)
+			require.NoError(t, err)
+			AddAuthorization(t, request, user.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestEnrollTOTPResponseIsRedacted(t *testing.T) {
+	secret := "JBSWY3DPEHPK3PXP"
+	body, err := json.Marshal(EnrollTOTPResponse{
+		Secret:     secret,
+		OtpauthURI: "otpauth://totp/simplebank:alice?secret=" + secret,
+	})
+	require.NoError(t, err)
+	var fields map[string]string
+	require.NoError(t, json.Unmarshal(body, &fields))
+
+	// log the response under its own field names
+	var buf bytes.Buffer
+	logger := util.NewLogger(&buf, util.Log{})
+	for key, value := range fields {
+		logger.Info("enrolled", slog.String(key, value))
+	}
+	require.NotContains(t, buf.String(), secret)
+}
+
+func TestConfirmTOTP(t *testing.T) {
+	user, _ := RandomUser(t)
+	pending := randomUserTOTP(t, user.Username, false)
+
+	testCases := []struct {
+		Name          string
+		Code          string
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "ok",
+			Code: currentTOTPCode(t, pending.Secret),
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(pending, nil)
+				store.EXPECT().ConfirmTOTPTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.ConfirmTOTPTxParams) (error, db.ConfirmTOTPTxResult) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Len(t, arg.RecoveryCodeHashes, recoveryCodeCount)
+						return nil, db.ConfirmTOTPTxResult{}
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var resp ConfirmTOTPResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Len(t, resp.RecoveryCodes, recoveryCodeCount)
+			},
+		},
+		{
+			Name: "invalid code",
+			Code: invalidTOTPCode(t, pending.Secret),
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(pending, nil)
+				store.EXPECT().ConfirmTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name: "not set up",
+			Code: "123456",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(db.UserTotp{}, pgx.ErrNoRows)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name: "already enabled",
+			Code: "123456",
+			BuildStubs: func(store *mock.MockStore) {
+				confirmed := pending
+				confirmed.ConfirmedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(confirmed, nil)
+				store.EXPECT().ConfirmTOTPTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			Name: "bad request",
+			Code: "abc",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).AnyTimes().Return(user, nil)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{"code": tc.Code})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/me/totp/confirm", bytes.NewReader(body))
+			require.NoError(t, err)
+			AddAuthorization(t, request, user.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
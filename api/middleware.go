@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
 	"github.com/jxgzzztang/simplebank/util"
 )
 
@@ -14,9 +17,12 @@ const (
 	authorizationHeader = "Authorization"
 	authorizationTypeBearer = "bearer"
 	authorizationPayloadKey = "payloadKey"
+	authorizationUserKey = "userKey"
 )
 
-func authMiddleware() gin.HandlerFunc {
+// authMiddleware accepts a bearer access token and loads its user. Tokens
+// issued before the last password change are rejected.
+func authMiddleware(store db.Store) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		token := ctx.GetHeader(authorizationHeader)
 
@@ -35,7 +41,6 @@ func authMiddleware() gin.HandlerFunc {
 		}
 
 		authorizationType := strings.ToLower(fields[0])
-		fmt.Println(authorizationType, authorizationTypeBearer)
 		if authorizationType != authorizationTypeBearer {
 			err := errors.New("invalid authorization header type")
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
@@ -45,12 +50,45 @@ func authMiddleware() gin.HandlerFunc {
 		accessToken := fields[1]
 
 		payload, ok := util.ParseToken(accessToken);
-		fmt.Println(payload)
-		if !ok {
+		// tokens with a purpose, like the mfa challenge, are not access tokens
+		if !ok || payload.Purpose != "" {
 			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errors.New("token is invalid")))
 			return
 		}
+
+		user, err := store.GetUser(ctx, payload.Username)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errors.New("token is invalid")))
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		// iat only has second precision
+		if payload.IssuedAt == nil || payload.IssuedAt.Time.Before(user.PasswordChangedAt.Time.Truncate(time.Second)) {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errors.New("token was issued before the password was changed")))
+			return
+		}
+
 		ctx.Set(authorizationPayloadKey, payload)
+		ctx.Set(authorizationUserKey, user)
+		ctx.Next()
+	}
+}
+
+// roleMiddleware only lets users with the given role through. It must run
+// after authMiddleware.
+func roleMiddleware(server *Server, role string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		user := ctx.MustGet(authorizationUserKey).(db.User)
+		if user.Role != role {
+			err := fmt.Errorf("%s role required", role)
+			server.auditDenied(ctx, "route:"+ctx.Request.Method+" "+ctx.FullPath(), err)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
 		ctx.Next()
 	}
 }
\ No newline at end of file
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -8,26 +9,39 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
 	"github.com/jxgzzztang/simplebank/util"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
 
-func AddAuthorization(t *testing.T, request *http.Request, username string, duration time.Duration) {
-	token, payload, err := util.CreateToken(username, duration)
+func AddAuthorization(t *testing.T, request *http.Request, username string, duration time.Duration, opts ...util.TokenOption) {
+	token, payload, err := util.CreateToken(username, duration, opts...)
 	require.NoError(t, err)
 	require.NotEmpty(t, token)
 	require.NotEmpty(t, payload)
 	request.Header.Set(authorizationHeader, fmt.Sprintf("%s %s", authorizationTypeBearer, token))
 }
 
+// stubAuthUser lets authMiddleware load any user whose password was never
+// changed, for tests that are not about authentication.
+func stubAuthUser(store *mock.MockStore) {
+	store.EXPECT().GetUser(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, username string) (db.User, error) {
+			return db.User{Username: username, Role: util.DepositorRole, IsEmailVerified: true}, nil
+		})
+}
+
 func TestAuthMiddleware(t *testing.T)  {
 	username := util.RandomOwner()
 
 	testCases := []struct {
 		Name string
 		SetupAuth func(t *testing.T, request *http.Request)
+		BuildStubs func(store *mock.MockStore)
 		CheckResponse func(t *testing.T, response *httptest.ResponseRecorder)
 	}{
 		{
@@ -35,10 +49,64 @@ func TestAuthMiddleware(t *testing.T)  {
 			SetupAuth: func(t *testing.T, request *http.Request) {
 				AddAuthorization(t, request, username, time.Minute)
 			},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(username)).Times(1).Return(db.User{Username: username}, nil)
+			},
 			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, response.Code)
 			},
 		},
+		{
+			Name: "no authorization",
+			SetupAuth: func(t *testing.T, request *http.Request) {
+			},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, response.Code)
+			},
+		},
+		{
+			Name: "unknown user",
+			SetupAuth: func(t *testing.T, request *http.Request) {
+				AddAuthorization(t, request, username, time.Minute)
+			},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(username)).Times(1).Return(db.User{}, pgx.ErrNoRows)
+			},
+			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, response.Code)
+			},
+		},
+		{
+			Name: "password changed",
+			SetupAuth: func(t *testing.T, request *http.Request) {
+				AddAuthorization(t, request, username, time.Minute)
+			},
+			BuildStubs: func(store *mock.MockStore) {
+				user := db.User{
+					Username:          username,
+					PasswordChangedAt: pgtype.Timestamptz{Time: time.Now().Add(time.Second), Valid: true},
+				}
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(username)).Times(1).Return(user, nil)
+			},
+			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, response.Code)
+			},
+		},
+		{
+			Name: "internal server error",
+			SetupAuth: func(t *testing.T, request *http.Request) {
+				AddAuthorization(t, request, username, time.Minute)
+			},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(username)).Times(1).Return(db.User{}, pgx.ErrTxClosed)
+			},
+			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, response.Code)
+			},
+		},
 	}
 
 	for i := range testCases {
@@ -47,11 +115,12 @@ func TestAuthMiddleware(t *testing.T)  {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			store := mock.NewMockStore(ctrl)
+			tc.BuildStubs(store)
 
-			server := NewServer(store)
+			server := newTestServer(t, store)
 
 			authPath := "/auth"
-			server.router.GET(authPath, authMiddleware(), func(ctx *gin.Context) {
+			server.router.GET(authPath, authMiddleware(store), func(ctx *gin.Context) {
 				ctx.JSON(http.StatusOK, gin.H{})
 			})
 			recorder := httptest.NewRecorder()
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/jxgzzztang/simplebank/worker"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ForgotPasswordResponse struct {
+	Message string `json:"message"`
+}
+
+// ForgotPassword godoc
+// @Summary      ForgotPassword
+// @Description  email a password reset link to the owner of the address
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param forgotPasswordData body ForgotPasswordRequest true "email"
+// @Success      200  {object} 	api.ForgotPasswordResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /password/forgot [post]
+func (server *Server) ForgotPassword(ctx *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// the response is the same whether or not the email is registered, so the
+	// endpoint cannot be used to find out who has an account
+	resp := ForgotPasswordResponse{
+		Message: "if the email belongs to an account, a reset link has been sent to it",
+	}
+
+	user, err := server.store.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusOK, resp)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = server.taskDistributor.DistributeTaskSendResetPassword(ctx, server.store, &worker.PayloadSendResetPassword{
+		Username: user.Username,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// resetPasswordPage is the form reset emails link to when no other page is
+// configured. It reads the token from its own query and posts it with the
+// new password to POST /password/reset.
+const resetPasswordPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Reset your Simple Bank password</title>
+</head>
+<body>
+<form id="reset">
+<label>New password <input type="password" name="new_password" minlength="6" required></label>
+<button type="submit">Reset password</button>
+</form>
+<p id="result"></p>
+<script>
+document.getElementById("reset").addEventListener("submit", async (event) => {
+	event.preventDefault();
+	const token = new URLSearchParams(window.location.search).get("token");
+	const response = await fetch(window.location.pathname, {
+		method: "POST",
+		headers: {"Content-Type": "application/json"},
+		body: JSON.stringify({token: token, new_password: event.target.new_password.value}),
+	});
+	const body = await response.json();
+	document.getElementById("result").textContent = response.ok ? "Your password has been reset." : body.error;
+});
+</script>
+</body>
+</html>
+`
+
+// ResetPasswordPage godoc
+// @Summary      ResetPasswordPage
+// @Description  the page reset emails link to, a form that posts the token from its query to POST /password/reset
+// @Tags         users
+// @Produce      html
+// @Param 		token  query  string false "reset token"
+// @Success      200  {string}  string
+// @Router       /password/reset [get]
+func (server *Server) ResetPasswordPage(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(resetPasswordPage))
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required,len=32"`
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// ResetPassword godoc
+// @Summary      ResetPassword
+// @Description  set a new password with a reset token and sign out every session
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param resetPasswordData body ResetPasswordRequest true "token and new password"
+// @Success      200  {object} 	api.UserInfoResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /password/reset [post]
+func (server *Server) ResetPassword(ctx *gin.Context) {
+	var req ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	hashedPassword, err := hashPassword(ctx, req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err, result := server.store.ResetPasswordTx(ctx, db.ResetPasswordTxParams{
+		TokenHash:      util.HashToken(req.Token),
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid or expired reset token")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, CreateUserInfoResponse(result.User))
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/jxgzzztang/simplebank/worker"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestForgotPassword(t *testing.T) {
+	user, _ := RandomUser(t)
+
+	testCases := []struct {
+		Name          string
+		Body          gin.H
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "ok",
+			Body: gin.H{"email": user.Email},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).Times(1).Return(user, nil)
+				store.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateTaskParams) (db.Task, error) {
+						require.Equal(t, worker.TaskSendResetPassword, arg.Type)
+						var payload worker.PayloadSendResetPassword
+						require.NoError(t, json.Unmarshal(arg.Payload, &payload))
+						require.Equal(t, user.Username, payload.Username)
+						return db.Task{}, nil
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name: "unknown email",
+			Body: gin.H{"email": user.Email},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).Times(1).Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name: "invalid email",
+			Body: gin.H{"email": "invalid"},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name: "internal server error",
+			Body: gin.H{"email": user.Email},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(1).Return(db.User{}, pgx.ErrTxClosed)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.Body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/password/forgot", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	user, _ := RandomUser(t)
+	token := util.RandomString(32)
+	newPassword := util.RandomString(8)
+
+	testCases := []struct {
+		Name          string
+		Body          gin.H
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "ok",
+			Body: gin.H{"token": token, "new_password": newPassword},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.ResetPasswordTxParams) (error, db.ResetPasswordTxResult) {
+						require.Equal(t, util.HashToken(token), arg.TokenHash)
+						require.NoError(t, util.CheckPassword(newPassword, arg.HashedPassword))
+						return nil, db.ResetPasswordTxResult{User: user}
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name: "invalid token",
+			Body: gin.H{"token": token, "new_password": newPassword},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(1).Return(pgx.ErrNoRows, db.ResetPasswordTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name: "short password",
+			Body: gin.H{"token": token, "new_password": "abc"},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name: "internal server error",
+			Body: gin.H{"token": token, "new_password": newPassword},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ResetPasswordTx(gomock.Any(), gomock.Any()).Times(1).Return(pgx.ErrTxClosed, db.ResetPasswordTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.Body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/password/reset", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestResetPasswordLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	baseURL := util.Config.BaseURL
+	util.Config.BaseURL = "http://localhost:8080"
+	defer func() { util.Config.BaseURL = baseURL }()
+
+	// the link in reset emails has to open a page the server serves
+	link, err := url.Parse(worker.PasswordResetURL(util.RandomString(32)))
+	require.NoError(t, err)
+
+	server := newTestServer(t, mock.NewMockStore(ctrl))
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, link.RequestURI(), nil)
+	require.NoError(t, err)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Header().Get("Content-Type"), "text/html")
+	require.Contains(t, recorder.Body.String(), "new_password")
+}
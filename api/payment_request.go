@@ -0,0 +1,414 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/jxgzzztang/simplebank/worker"
+)
+
+const defaultPaymentRequestDuration = 7 * 24 * time.Hour
+
+type CreatePaymentRequestRequest struct {
+	// Payer is the username of the user asked for the money.
+	Payer string `json:"payer" binding:"required"`
+	// Amount is a decimal string like "12.34", see TransferRequest.
+	Amount   string `json:"amount" binding:"required"`
+	Currency string `json:"currency" binding:"required,currency"`
+	Note     string `json:"note" binding:"max=140"`
+}
+
+type ListPaymentRequestsRequest struct {
+	// Direction is incoming for the pending requests the user is asked to
+	// pay, outgoing for every request the user made.
+	Direction  string `form:"direction" binding:"required,oneof=incoming outgoing"`
+	PageSize   int32  `form:"pageSize" binding:"required,min=1,max=10"`
+	PageNumber int32  `form:"pageNumber" binding:"required,min=1"`
+}
+
+type PaymentRequestURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type PaymentRequestResponse struct {
+	ID         int64      `json:"id"`
+	Requester  string     `json:"requester"`
+	Payer      string     `json:"payer"`
+	Amount     util.Money `json:"amount" swaggertype:"object,string"`
+	Note       string     `json:"note"`
+	Status     string     `json:"status"`
+	TransferID *int64     `json:"transfer_id,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+type AcceptPaymentRequestResponse struct {
+	PaymentRequest PaymentRequestResponse `json:"payment_request"`
+	Transfer       TransferResponse       `json:"transfer"`
+}
+
+func (server *Server) newPaymentRequestResponse(ctx context.Context, paymentRequest db.PaymentRequest) (PaymentRequestResponse, error) {
+	currency, err := server.currencies.currency(ctx, paymentRequest.Currency)
+	if err != nil {
+		return PaymentRequestResponse{}, err
+	}
+
+	resp := PaymentRequestResponse{
+		ID:        paymentRequest.ID,
+		Requester: paymentRequest.Requester,
+		Payer:     paymentRequest.Payer,
+		Amount:    util.NewMoney(paymentRequest.Amount, currency),
+		Note:      paymentRequest.Note,
+		Status:    paymentRequest.Status,
+		ExpiresAt: paymentRequest.ExpiresAt.Time,
+		CreatedAt: paymentRequest.CreatedAt.Time,
+	}
+	// requests past their expiry are expired even before they are swept
+	if resp.Status == db.PaymentRequestPending && !resp.ExpiresAt.After(time.Now()) {
+		resp.Status = db.PaymentRequestExpired
+	}
+	if paymentRequest.TransferID.Valid {
+		resp.TransferID = &paymentRequest.TransferID.Int64
+	}
+	if paymentRequest.ResolvedAt.Valid {
+		resp.ResolvedAt = &paymentRequest.ResolvedAt.Time
+	}
+	return resp, nil
+}
+
+// paymentRequestTarget names a payment request in the audit log.
+func paymentRequestTarget(id int64) string {
+	return "payment_request:" + strconv.FormatInt(id, 10)
+}
+
+func paymentRequestDuration() time.Duration {
+	if util.Config.PaymentRequests.Duration > 0 {
+		return util.Config.PaymentRequests.Duration
+	}
+	return defaultPaymentRequestDuration
+}
+
+// notifyPaymentRequest returns a hook enqueueing the email that tells a party
+// of a payment request about its new status.
+func (server *Server) notifyPaymentRequest(ctx context.Context) func(q db.Querier, paymentRequest db.PaymentRequest) error {
+	return func(q db.Querier, paymentRequest db.PaymentRequest) error {
+		return server.taskDistributor.DistributeTaskSendPaymentRequest(ctx, q, &worker.PayloadSendPaymentRequest{
+			PaymentRequestID: paymentRequest.ID,
+			Status:           paymentRequest.Status,
+		})
+	}
+}
+
+// CreatePaymentRequest godoc
+// @Summary      CreatePaymentRequest
+// @Description  ask another user for money, they are notified and can accept or decline
+// @Tags         payment requests
+// @Accept       json
+// @Produce      json
+// @Param paymentRequest body CreatePaymentRequestRequest true "who to ask for how much"
+// @Success      200  {object} 	PaymentRequestResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      404  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /payment_requests [post]
+func (server *Server) CreatePaymentRequest(ctx *gin.Context) {
+	var req CreatePaymentRequestRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	payload := ctx.MustGet(authorizationPayloadKey).(*util.TokenPayload)
+	if req.Payer == payload.Username {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("cannot request money from yourself")))
+		return
+	}
+
+	currency, err := server.currencies.currency(ctx, req.Currency)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	amount, err := util.ParseMoney(req.Amount, currency)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !amount.IsPositive() {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("amount must be positive")))
+		return
+	}
+
+	if _, err := server.store.GetUser(ctx, req.Payer); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("payer does not exist")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	// the money is paid into the account of the requester in the currency
+	_, err = server.store.GetAccountByOwnerCurrency(ctx, db.GetAccountByOwnerCurrencyParams{
+		Owner:    payload.Username,
+		Currency: req.Currency,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("you have no account in %s", req.Currency)))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err, result := server.store.CreatePaymentRequestTx(ctx, db.CreatePaymentRequestTxParams{
+		CreatePaymentRequestParams: db.CreatePaymentRequestParams{
+			Requester: payload.Username,
+			Payer:     req.Payer,
+			Amount:    amount.Amount,
+			Currency:  req.Currency,
+			Note:      req.Note,
+			ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(paymentRequestDuration()), Valid: true},
+		},
+		AfterCreate: server.notifyPaymentRequest(ctx),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp, err := server.newPaymentRequestResponse(ctx, result.PaymentRequest)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// ListPaymentRequests godoc
+// @Summary      ListPaymentRequests
+// @Description  list the pending requests to pay or the requests made, newest first
+// @Tags         payment requests
+// @Produce      json
+// @Param direction query string true "incoming or outgoing"
+// @Param pageSize query int true "page size"
+// @Param pageNumber query int true "page number"
+// @Success      200  {array} 	PaymentRequestResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /payment_requests [get]
+func (server *Server) ListPaymentRequests(ctx *gin.Context) {
+	var req ListPaymentRequestsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	payload := ctx.MustGet(authorizationPayloadKey).(*util.TokenPayload)
+	limit, offset := req.PageSize, (req.PageNumber-1)*req.PageSize
+
+	var paymentRequests []db.PaymentRequest
+	var err error
+	if req.Direction == "incoming" {
+		paymentRequests, err = server.store.ListIncomingPaymentRequests(ctx, db.ListIncomingPaymentRequestsParams{
+			Payer:  payload.Username,
+			Limit:  limit,
+			Offset: offset,
+		})
+	} else {
+		paymentRequests, err = server.store.ListOutgoingPaymentRequests(ctx, db.ListOutgoingPaymentRequestsParams{
+			Requester: payload.Username,
+			Limit:     limit,
+			Offset:    offset,
+		})
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp := make([]PaymentRequestResponse, 0, len(paymentRequests))
+	for _, paymentRequest := range paymentRequests {
+		paymentRequestResp, err := server.newPaymentRequestResponse(ctx, paymentRequest)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		resp = append(resp, paymentRequestResp)
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// payerPaymentRequest loads the payment request named in the uri and checks
+// that the caller is asked to pay it. It writes the error response and
+// returns false otherwise.
+func (server *Server) payerPaymentRequest(ctx *gin.Context) (db.PaymentRequest, bool) {
+	var uri PaymentRequestURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return db.PaymentRequest{}, false
+	}
+
+	paymentRequest, err := server.store.GetPaymentRequest(ctx, uri.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return paymentRequest, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return paymentRequest, false
+	}
+
+	payload := ctx.MustGet(authorizationPayloadKey).(*util.TokenPayload)
+	if payload.Username != paymentRequest.Payer {
+		err := errors.New("payment request is addressed to another user")
+		server.auditDenied(ctx, paymentRequestTarget(paymentRequest.ID), err)
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return paymentRequest, false
+	}
+	return paymentRequest, true
+}
+
+// AcceptPaymentRequest godoc
+// @Summary      AcceptPaymentRequest
+// @Description  pay a pending payment request from the account of the payer in its currency
+// @Tags         payment requests
+// @Produce      json
+// @Param id path int true "Payment request ID"
+// @Success      200  {object} 	AcceptPaymentRequestResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      403  {object}  api.ErrorResponse
+// @Failure      404  {object}  api.ErrorResponse
+// @Failure      409  {object}  api.ErrorResponse
+// @Failure      422  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /payment_requests/{id}/accept [post]
+func (server *Server) AcceptPaymentRequest(ctx *gin.Context) {
+	paymentRequest, ok := server.payerPaymentRequest(ctx)
+	if !ok {
+		return
+	}
+
+	fromAccount, err := server.store.GetAccountByOwnerCurrency(ctx, db.GetAccountByOwnerCurrencyParams{
+		Owner:    paymentRequest.Payer,
+		Currency: paymentRequest.Currency,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("you have no account in %s", paymentRequest.Currency)))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if fromAccount.IsFrozen {
+		ctx.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("account [%d] is frozen", fromAccount.ID)))
+		return
+	}
+
+	currency, err := server.currencies.currency(ctx, paymentRequest.Currency)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	payload := ctx.MustGet(authorizationPayloadKey).(*util.TokenPayload)
+	if !requireStepUp(ctx, payload, util.NewMoney(paymentRequest.Amount, currency)) {
+		return
+	}
+
+	toAccount, ok := server.resolveRecipient(ctx, RecipientRequest{ToUsername: paymentRequest.Requester}, paymentRequest.Currency)
+	if !ok {
+		return
+	}
+
+	actor := auditActor(ctx)
+	err, result := server.store.AcceptPaymentRequestTx(ctx, db.AcceptPaymentRequestTxParams{
+		ID:            paymentRequest.ID,
+		FromAccountID: fromAccount.ID,
+		ToAccountID:   toAccount.ID,
+		Audit:         &actor,
+		AfterAccept:   server.notifyPaymentRequest(ctx),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrPaymentRequestNotPending):
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+		case errors.Is(err, db.ErrInsufficientFunds):
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		case errors.Is(err, util.ErrCurrencyMismatch):
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+
+	paymentRequestResp, err := server.newPaymentRequestResponse(ctx, result.PaymentRequest)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	transfer, err := server.newTransferResponse(ctx, result.TransferTxResult, currency)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, AcceptPaymentRequestResponse{
+		PaymentRequest: paymentRequestResp,
+		Transfer:       transfer,
+	})
+}
+
+// DeclinePaymentRequest godoc
+// @Summary      DeclinePaymentRequest
+// @Description  turn down a pending payment request, the requester is notified
+// @Tags         payment requests
+// @Produce      json
+// @Param id path int true "Payment request ID"
+// @Success      200  {object} 	PaymentRequestResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      403  {object}  api.ErrorResponse
+// @Failure      404  {object}  api.ErrorResponse
+// @Failure      409  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /payment_requests/{id}/decline [post]
+func (server *Server) DeclinePaymentRequest(ctx *gin.Context) {
+	paymentRequest, ok := server.payerPaymentRequest(ctx)
+	if !ok {
+		return
+	}
+
+	err, result := server.store.DeclinePaymentRequestTx(ctx, db.DeclinePaymentRequestTxParams{
+		ID:           paymentRequest.ID,
+		AfterDecline: server.notifyPaymentRequest(ctx),
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrPaymentRequestNotPending) {
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp, err := server.newPaymentRequestResponse(ctx, result.PaymentRequest)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,448 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func randomPaymentRequest(requester string, payer string) db.PaymentRequest {
+	return db.PaymentRequest{
+		ID:        util.RandomInt(1, 1000),
+		Requester: requester,
+		Payer:     payer,
+		Amount:    util.RandomInt(100, 1000),
+		Currency:  util.USD,
+		Note:      util.RandomString(12),
+		Status:    db.PaymentRequestPending,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+		CreatedAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
+}
+
+func TestCreatePaymentRequest(t *testing.T) {
+	requester, _ := RandomUser(t)
+	payer, _ := RandomUser(t)
+	account := randomAccount(requester)
+	account.Currency = util.USD
+	currency := util.Currency{Code: util.USD, Exponent: 2}
+
+	testCases := []struct {
+		Name          string
+		Body          gin.H
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "OK",
+			Body: gin.H{"payer": payer.Username, "amount": "12.50", "currency": util.USD, "note": "dinner"},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccountByOwnerCurrency(gomock.Any(), gomock.Eq(db.GetAccountByOwnerCurrencyParams{Owner: requester.Username, Currency: util.USD})).
+					Times(1).Return(account, nil)
+				store.EXPECT().CreatePaymentRequestTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreatePaymentRequestTxParams) (error, db.CreatePaymentRequestTxResult) {
+						require.Equal(t, requester.Username, arg.Requester)
+						require.Equal(t, payer.Username, arg.Payer)
+						require.Equal(t, int64(1250), arg.Amount)
+						require.Equal(t, "dinner", arg.Note)
+						require.WithinDuration(t, time.Now().Add(defaultPaymentRequestDuration), arg.ExpiresAt.Time, time.Minute)
+						require.NotNil(t, arg.AfterCreate)
+						return nil, db.CreatePaymentRequestTxResult{PaymentRequest: db.PaymentRequest{
+							ID:        1,
+							Requester: arg.Requester,
+							Payer:     arg.Payer,
+							Amount:    arg.Amount,
+							Currency:  arg.Currency,
+							Note:      arg.Note,
+							Status:    db.PaymentRequestPending,
+							ExpiresAt: arg.ExpiresAt,
+						}}
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp PaymentRequestResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, util.NewMoney(1250, currency), resp.Amount)
+				require.Equal(t, db.PaymentRequestPending, resp.Status)
+				require.Nil(t, resp.TransferID)
+			},
+		},
+		{
+			Name: "PayerNotFound",
+			Body: gin.H{"payer": payer.Username, "amount": "12.50", "currency": util.USD},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(payer.Username)).Times(1).Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().CreatePaymentRequestTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			Name: "Self",
+			Body: gin.H{"payer": requester.Username, "amount": "12.50", "currency": util.USD},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CreatePaymentRequestTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name: "NoAccountInCurrency",
+			Body: gin.H{"payer": payer.Username, "amount": "12.50", "currency": util.USD},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccountByOwnerCurrency(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, pgx.ErrNoRows)
+				store.EXPECT().CreatePaymentRequestTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name: "InvalidAmount",
+			Body: gin.H{"payer": payer.Username, "amount": "-1.00", "currency": util.USD},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CreatePaymentRequestTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			tc.BuildStubs(store)
+			stubAuthUser(store)
+			stubCurrencies(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.Body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/payment_requests", bytes.NewReader(body))
+			require.NoError(t, err)
+			AddAuthorization(t, request, requester.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestListPaymentRequests(t *testing.T) {
+	user, _ := RandomUser(t)
+	other, _ := RandomUser(t)
+
+	expired := randomPaymentRequest(user.Username, other.Username)
+	expired.ExpiresAt = pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+	outgoing := []db.PaymentRequest{randomPaymentRequest(user.Username, other.Username), expired}
+	incoming := []db.PaymentRequest{randomPaymentRequest(other.Username, user.Username)}
+
+	testCases := []struct {
+		Name          string
+		Query         string
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:  "Incoming",
+			Query: "direction=incoming&pageSize=5&pageNumber=2",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ListIncomingPaymentRequests(gomock.Any(), gomock.Eq(db.ListIncomingPaymentRequestsParams{Payer: user.Username, Limit: 5, Offset: 5})).
+					Times(1).Return(incoming, nil)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp []PaymentRequestResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Len(t, resp, 1)
+				require.Equal(t, incoming[0].ID, resp[0].ID)
+			},
+		},
+		{
+			Name:  "Outgoing",
+			Query: "direction=outgoing&pageSize=5&pageNumber=1",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ListOutgoingPaymentRequests(gomock.Any(), gomock.Eq(db.ListOutgoingPaymentRequestsParams{Requester: user.Username, Limit: 5, Offset: 0})).
+					Times(1).Return(outgoing, nil)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp []PaymentRequestResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Len(t, resp, 2)
+				require.Equal(t, db.PaymentRequestPending, resp[0].Status)
+				// not swept yet, but past its expiry
+				require.Equal(t, db.PaymentRequestExpired, resp[1].Status)
+			},
+		},
+		{
+			Name:  "InvalidDirection",
+			Query: "direction=sideways&pageSize=5&pageNumber=1",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ListIncomingPaymentRequests(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().ListOutgoingPaymentRequests(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			stubAuthUser(store)
+			stubCurrencies(store)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/payment_requests?"+tc.Query, nil)
+			require.NoError(t, err)
+			AddAuthorization(t, request, user.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestAcceptPaymentRequest(t *testing.T) {
+	requester, _ := RandomUser(t)
+	payer, _ := RandomUser(t)
+	paymentRequest := randomPaymentRequest(requester.Username, payer.Username)
+	fromAccount := randomAccount(payer)
+	fromAccount.Currency = paymentRequest.Currency
+	toAccount := randomAccount(requester)
+	toAccount.Currency = paymentRequest.Currency
+	currency := util.Currency{Code: paymentRequest.Currency, Exponent: 2}
+
+	stubAccounts := func(store *mock.MockStore) {
+		store.EXPECT().GetAccountByOwnerCurrency(gomock.Any(), gomock.Eq(db.GetAccountByOwnerCurrencyParams{Owner: payer.Username, Currency: paymentRequest.Currency})).
+			Times(1).Return(fromAccount, nil)
+		store.EXPECT().GetAccountByOwnerCurrency(gomock.Any(), gomock.Eq(db.GetAccountByOwnerCurrencyParams{Owner: requester.Username, Currency: paymentRequest.Currency})).
+			Times(1).Return(toAccount, nil)
+	}
+
+	testCases := []struct {
+		Name          string
+		Username      string
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:     "OK",
+			Username: payer.Username,
+			BuildStubs: func(store *mock.MockStore) {
+				stubAccounts(store)
+				store.EXPECT().AcceptPaymentRequestTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.AcceptPaymentRequestTxParams) (error, db.AcceptPaymentRequestTxResult) {
+						require.Equal(t, paymentRequest.ID, arg.ID)
+						require.Equal(t, fromAccount.ID, arg.FromAccountID)
+						require.Equal(t, toAccount.ID, arg.ToAccountID)
+						require.Equal(t, payer.Username, arg.Audit.Actor)
+						require.NotNil(t, arg.AfterAccept)
+
+						result := db.AcceptPaymentRequestTxResult{PaymentRequest: paymentRequest}
+						result.PaymentRequest.Status = db.PaymentRequestAccepted
+						result.PaymentRequest.TransferID = pgtype.Int8{Int64: 7, Valid: true}
+						result.PaymentRequest.ResolvedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+						result.Transfer = db.Transfer{ID: 7, FromAccountID: fromAccount.ID, ToAccountID: toAccount.ID, Amount: paymentRequest.Amount}
+						result.FromAccount = fromAccount
+						result.ToAccount = toAccount
+						return nil, result
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp AcceptPaymentRequestResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, db.PaymentRequestAccepted, resp.PaymentRequest.Status)
+				require.Equal(t, int64(7), *resp.PaymentRequest.TransferID)
+				require.NotNil(t, resp.PaymentRequest.ResolvedAt)
+				require.Equal(t, util.NewMoney(paymentRequest.Amount, currency), resp.Transfer.Transfer.Amount)
+			},
+		},
+		{
+			Name:     "NotPending",
+			Username: payer.Username,
+			BuildStubs: func(store *mock.MockStore) {
+				stubAccounts(store)
+				store.EXPECT().AcceptPaymentRequestTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ErrPaymentRequestNotPending, db.AcceptPaymentRequestTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			Name:     "InsufficientFunds",
+			Username: payer.Username,
+			BuildStubs: func(store *mock.MockStore) {
+				stubAccounts(store)
+				store.EXPECT().AcceptPaymentRequestTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ErrInsufficientFunds, db.AcceptPaymentRequestTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			Name:     "NoAccountInCurrency",
+			Username: payer.Username,
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccountByOwnerCurrency(gomock.Any(), gomock.Any()).Times(1).Return(db.Account{}, pgx.ErrNoRows)
+				store.EXPECT().AcceptPaymentRequestTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name:     "NotPayer",
+			Username: requester.Username,
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().RecordAuditEventTx(gomock.Any(), auditEvent(requester.Username, db.AuditActionAuthorizationDenied)).Times(1).Return(nil, db.RecordAuditEventTxResult{})
+				store.EXPECT().AcceptPaymentRequestTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			stubAuthUser(store)
+			stubCurrencies(store)
+			stubHeldAmounts(store)
+			store.EXPECT().GetPaymentRequest(gomock.Any(), gomock.Eq(paymentRequest.ID)).Times(1).Return(paymentRequest, nil)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/payment_requests/%d/accept", paymentRequest.ID)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+			AddAuthorization(t, request, tc.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestDeclinePaymentRequest(t *testing.T) {
+	requester, _ := RandomUser(t)
+	payer, _ := RandomUser(t)
+	paymentRequest := randomPaymentRequest(requester.Username, payer.Username)
+
+	testCases := []struct {
+		Name          string
+		Username      string
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:     "OK",
+			Username: payer.Username,
+			BuildStubs: func(store *mock.MockStore) {
+				declined := paymentRequest
+				declined.Status = db.PaymentRequestDeclined
+				declined.ResolvedAt = pgtype.Timestamptz{Time: time.Now(), Valid: true}
+				store.EXPECT().DeclinePaymentRequestTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.DeclinePaymentRequestTxParams) (error, db.DeclinePaymentRequestTxResult) {
+						require.Equal(t, paymentRequest.ID, arg.ID)
+						require.NotNil(t, arg.AfterDecline)
+						return nil, db.DeclinePaymentRequestTxResult{PaymentRequest: declined}
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp PaymentRequestResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, db.PaymentRequestDeclined, resp.Status)
+				require.NotNil(t, resp.ResolvedAt)
+			},
+		},
+		{
+			Name:     "NotPending",
+			Username: payer.Username,
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().DeclinePaymentRequestTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ErrPaymentRequestNotPending, db.DeclinePaymentRequestTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			Name:     "NotPayer",
+			Username: "other_user",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().RecordAuditEventTx(gomock.Any(), auditEvent("other_user", db.AuditActionAuthorizationDenied)).Times(1).Return(nil, db.RecordAuditEventTxResult{})
+				store.EXPECT().DeclinePaymentRequestTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			stubAuthUser(store)
+			stubCurrencies(store)
+			store.EXPECT().GetPaymentRequest(gomock.Any(), gomock.Eq(paymentRequest.ID)).Times(1).Return(paymentRequest, nil)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/payment_requests/%d/decline", paymentRequest.ID)
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+			AddAuthorization(t, request, tc.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jxgzzztang/simplebank/ratelimit"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+// rateLimitMiddleware counts every request against a token bucket per route
+// and client. Behind authMiddleware the client is the user, elsewhere it is
+// the client IP.
+func rateLimitMiddleware(limiter ratelimit.Limiter) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		route := ctx.Request.Method + " " + ctx.FullPath()
+		policy := rateLimitPolicy(route)
+		if policy.Limit <= 0 || policy.Period <= 0 {
+			ctx.Next()
+			return
+		}
+
+		key := route + " ip:" + ctx.ClientIP()
+		if payload, ok := ctx.Get(authorizationPayloadKey); ok {
+			key = route + " user:" + payload.(*util.TokenPayload).Username
+		}
+
+		result, err := limiter.Allow(ctx, key, policy)
+		if err != nil {
+			// an unavailable limiter should not take the whole api down with it
+			_ = ctx.Error(err)
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		ctx.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		ctx.Header("RateLimit-Reset", strconv.Itoa(int((result.Reset+time.Second-1)/time.Second)))
+
+		if !result.Allowed {
+			setRetryAfter(ctx, result.RetryAfter)
+			ctx.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse(errors.New("rate limit exceeded, try again later")))
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// rateLimitPolicy returns the policy configured for a route, or the default
+// one when there is none.
+func rateLimitPolicy(route string) util.RateLimitPolicy {
+	for _, policy := range util.Config.RateLimit.Routes {
+		if policy.Route == route {
+			return policy
+		}
+	}
+	return util.Config.RateLimit.Default
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jxgzzztang/simplebank/db/mock"
+	"github.com/jxgzzztang/simplebank/ratelimit"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestRateLimitMiddleware(t *testing.T) {
+	config := util.Config.RateLimit
+	defer func() { util.Config.RateLimit = config }()
+	util.Config.RateLimit = util.RateLimit{
+		Default: util.RateLimitPolicy{Limit: 100, Period: time.Minute},
+		Routes: []util.RateLimitPolicy{
+			{Route: "GET /limited", Limit: 2, Period: time.Minute},
+			{Route: "GET /auth/limited", Limit: 1, Period: time.Minute},
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mock.NewMockStore(ctrl)
+	stubAuthUser(store)
+
+	router := gin.New()
+	limiter := ratelimit.NewMemoryLimiter()
+	ok := func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{})
+	}
+	router.GET("/limited", rateLimitMiddleware(limiter), ok)
+	router.GET("/unlimited", rateLimitMiddleware(limiter), ok)
+	router.GET("/auth/limited", authMiddleware(store), rateLimitMiddleware(limiter), ok)
+
+	serve := func(path string, remoteAddr string, setupAuth func(request *http.Request)) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, path, nil)
+		require.NoError(t, err)
+		request.RemoteAddr = remoteAddr
+		if setupAuth != nil {
+			setupAuth(request)
+		}
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	t.Run("route policy by ip", func(t *testing.T) {
+		recorder := serve("/limited", "10.0.0.1:1234", nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "2", recorder.Header().Get("RateLimit-Limit"))
+		require.Equal(t, "1", recorder.Header().Get("RateLimit-Remaining"))
+
+		recorder = serve("/limited", "10.0.0.1:1234", nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "0", recorder.Header().Get("RateLimit-Remaining"))
+
+		recorder = serve("/limited", "10.0.0.1:1234", nil)
+		require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+		require.Equal(t, "30", recorder.Header().Get("Retry-After"))
+		require.Equal(t, "60", recorder.Header().Get("RateLimit-Reset"))
+
+		// another client ip is counted separately
+		recorder = serve("/limited", "10.0.0.2:1234", nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("default policy", func(t *testing.T) {
+		recorder := serve("/unlimited", "10.0.0.1:1234", nil)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Equal(t, "100", recorder.Header().Get("RateLimit-Limit"))
+	})
+
+	t.Run("by user", func(t *testing.T) {
+		username := util.RandomOwner()
+		setupAuth := func(request *http.Request) {
+			AddAuthorization(t, request, username, time.Minute)
+		}
+
+		recorder := serve("/auth/limited", "10.0.0.1:1234", setupAuth)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		// the same user from another ip shares the bucket
+		recorder = serve("/auth/limited", "10.0.0.2:1234", setupAuth)
+		require.Equal(t, http.StatusTooManyRequests, recorder.Code)
+
+		// another user from the same ip does not
+		recorder = serve("/auth/limited", "10.0.0.1:1234", func(request *http.Request) {
+			AddAuthorization(t, request, util.RandomOwner(), time.Minute)
+		})
+		require.Equal(t, http.StatusOK, recorder.Code)
+	})
+}
+
+func TestRateLimitMiddlewareDisabled(t *testing.T) {
+	config := util.Config.RateLimit
+	defer func() { util.Config.RateLimit = config }()
+	util.Config.RateLimit = util.RateLimit{}
+
+	router := gin.New()
+	router.GET("/limited", rateLimitMiddleware(ratelimit.NewMemoryLimiter()), func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{})
+	})
+
+	for i := 0; i < 10; i++ {
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest(http.MethodGet, "/limited", nil)
+		require.NoError(t, err)
+		router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusOK, recorder.Code)
+		require.Empty(t, recorder.Header().Get("RateLimit-Limit"))
+	}
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const (
+	errCodeStepUpRequired = "step_up_required"
+
+	defaultStepUpMaxAge = 5 * time.Minute
+)
+
+func stepUpMaxAge() time.Duration {
+	if util.Config.StepUp.MaxAge > 0 {
+		return util.Config.StepUp.MaxAge
+	}
+	return defaultStepUpMaxAge
+}
+
+// stepUpThreshold parses the configured threshold. ok is false when the check
+// is disabled. A threshold that does not parse is treated as zero, so every
+// transfer needs a recent authentication rather than none.
+func stepUpThreshold() (threshold *big.Rat, ok bool) {
+	if util.Config.StepUp.Threshold == "" {
+		return nil, false
+	}
+	threshold, ok = new(big.Rat).SetString(util.Config.StepUp.Threshold)
+	if !ok {
+		return new(big.Rat), true
+	}
+	return threshold, threshold.Sign() > 0
+}
+
+// requireStepUp answers 401 with a step_up_required code and returns false
+// when the amount is above the configured threshold and the user has not
+// authenticated recently. The WWW-Authenticate header follows RFC 9470.
+func requireStepUp(ctx *gin.Context, payload *util.TokenPayload, amount util.Money) bool {
+	threshold, ok := stepUpThreshold()
+	if !ok {
+		return true
+	}
+	// compare in whole units, the amount is in minor units of its currency
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(amount.Currency.Exponent)), nil)
+	if new(big.Rat).SetFrac(big.NewInt(amount.Amount), scale).Cmp(threshold) <= 0 {
+		return true
+	}
+
+	maxAge := stepUpMaxAge()
+	if payload.AuthenticatedWithin(maxAge) {
+		return true
+	}
+
+	ctx.Header("WWW-Authenticate", fmt.Sprintf(
+		`Bearer error="insufficient_user_authentication", error_description="a more recent authentication is required", max_age=%d`,
+		int(maxAge.Seconds())))
+	ctx.JSON(http.StatusUnauthorized, ErrorResponse{
+		Error: fmt.Sprintf("transfers above %s %s require a re-authentication within %s",
+			threshold.FloatString(amount.Currency.Exponent), amount.Currency.Code, maxAge),
+		Code:  errCodeStepUpRequired,
+	})
+	return false
+}
+
+type ReauthRequest struct {
+	Password string `json:"password" binding:"required_without=Code"`
+	Code     string `json:"code" binding:"required_without=Password"`
+}
+
+// Reauth godoc
+// @Summary      Reauth
+// @Description  prove the identity again with the password or a TOTP code, returns an access token with a fresh auth_time
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param reauthData body ReauthRequest true "password or code"
+// @Success      200  {object} 	api.RenewAccessTokenResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      429  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /reauth [post]
+func (server *Server) Reauth(ctx *gin.Context) {
+	var req ReauthRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user := ctx.MustGet(authorizationUserKey).(db.User)
+
+	if !server.allowLoginAttempt(ctx, user.Username) {
+		return
+	}
+
+	var amr string
+	if req.Password != "" {
+		if err := checkPassword(ctx, req.Password, user.HashedPassword); err != nil {
+			server.rejectLogin(ctx, user.Username)
+			return
+		}
+		amr = util.AMRPassword
+	} else {
+		valid, err := server.verifySecondFactor(ctx, user.Username, req.Code)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if !valid {
+			server.rejectLogin(ctx, user.Username)
+			return
+		}
+		amr = util.AMROTP
+	}
+
+	if err := server.recordLoginAttempt(ctx, user.Username, loginSuccess); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	accessToken, accessTokenPayload, err := util.CreateToken(user.Username, util.Config.Jwt.ExpiresDuration,
+		util.WithAuthentication(time.Now(), amr))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, RenewAccessTokenResponse{
+		AccessToken:          accessToken,
+		AccessTokenExpiresAt: accessTokenPayload.ExpiresAt.Time,
+	})
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestReauth(t *testing.T) {
+	user, password := RandomUser(t)
+	userTOTP := randomUserTOTP(t, user.Username, true)
+
+	requireFreshToken := func(t *testing.T, recorder *httptest.ResponseRecorder, amr string) {
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var resp RenewAccessTokenResponse
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+		payload, ok := util.ParseToken(resp.AccessToken)
+		require.True(t, ok)
+		require.Equal(t, user.Username, payload.Username)
+		require.Equal(t, []string{amr}, payload.AMR)
+		require.True(t, payload.AuthenticatedWithin(time.Minute))
+	}
+
+	testCases := []struct {
+		Name          string
+		Body          gin.H
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "password",
+			Body: gin.H{"password": password},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginSuccess)).Times(1).Return(db.LoginAttempt{}, nil)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireFreshToken(t, recorder, util.AMRPassword)
+			},
+		},
+		{
+			Name: "totp",
+			Body: gin.H{"code": currentTOTPCode(t, userTOTP.Secret)},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserTOTP(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(userTOTP, nil)
+				store.EXPECT().UseTOTPStep(gomock.Any(), gomock.Any()).Times(1).Return(userTOTP, nil)
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginSuccess)).Times(1).Return(db.LoginAttempt{}, nil)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				requireFreshToken(t, recorder, util.AMROTP)
+			},
+		},
+		{
+			Name: "wrong password",
+			Body: gin.H{"password": "wrong-password"},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome(user.Username, loginInvalidCredentials)).Times(1).Return(db.LoginAttempt{}, nil)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			Name: "missing proof",
+			Body: gin.H{},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().CreateLoginAttempt(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			jwtConfig := util.Config.Jwt
+			util.Config.Jwt.ExpiresDuration = time.Minute
+			defer func() { util.Config.Jwt = jwtConfig }()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).AnyTimes().Return(user, nil)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.Body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/reauth", bytes.NewReader(body))
+			require.NoError(t, err)
+			AddAuthorization(t, request, user.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestRequireStepUpCurrencies(t *testing.T) {
+	stepUpConfig := util.Config.StepUp
+	util.Config.StepUp = util.StepUp{Threshold: "1000", MaxAge: 5 * time.Minute}
+	defer func() { util.Config.StepUp = stepUpConfig }()
+
+	usd := util.Currency{Code: util.USD, Exponent: 2}
+	jpy := util.Currency{Code: "JPY", Exponent: 0}
+	kwd := util.Currency{Code: "KWD", Exponent: 3}
+
+	// the same threshold in whole units, whatever the exponent of the currency
+	testCases := []struct {
+		Amount    util.Money
+		Allow     bool
+		Threshold string
+	}{
+		{Amount: util.NewMoney(100000, usd), Allow: true},
+		{Amount: util.NewMoney(100001, usd), Threshold: "1000.00 USD"},
+		{Amount: util.NewMoney(1000, jpy), Allow: true},
+		{Amount: util.NewMoney(1001, jpy), Threshold: "1000 JPY"},
+		{Amount: util.NewMoney(1000000, kwd), Allow: true},
+		{Amount: util.NewMoney(1000001, kwd), Threshold: "1000.000 KWD"},
+	}
+
+	payload := &util.TokenPayload{Username: util.RandomOwner()}
+	for _, tc := range testCases {
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		require.Equal(t, tc.Allow, requireStepUp(ctx, payload, tc.Amount), tc.Amount.String())
+		if !tc.Allow {
+			require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			require.Contains(t, recorder.Body.String(), "above "+tc.Threshold+" ")
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+// RecipientRequest names the recipient of money by exactly one of an account
+// id, a username or an alias. For a username or an alias the account of the
+// user in the currency of the transfer receives it.
+type RecipientRequest struct {
+	ToAccountID int64  `json:"to_account_id" form:"to_account_id"`
+	ToUsername  string `json:"to_username" form:"to_username"`
+	// ToAlias may start with an @ and is not case sensitive.
+	ToAlias string `json:"to_alias" form:"to_alias"`
+}
+
+type PreviewRecipientRequest struct {
+	RecipientRequest
+	Currency string `form:"currency" binding:"required,currency"`
+}
+
+// RecipientPreviewResponse shows enough of a recipient for the sender to
+// recognise them before confirming a transfer.
+type RecipientPreviewResponse struct {
+	MaskedName string `json:"masked_name"`
+	Currency   string `json:"currency"`
+}
+
+type SetAliasRequest struct {
+	// Alias is 3 to 30 lowercase letters, digits and underscores.
+	Alias string `json:"alias" binding:"required,alias"`
+}
+
+type AliasResponse struct {
+	Alias    string `json:"alias"`
+	Username string `json:"username"`
+}
+
+// resolveRecipient finds the account that receives money in currency. It
+// writes the error response and returns false when there is none or when it
+// cannot receive money.
+func (server *Server) resolveRecipient(ctx *gin.Context, recipient RecipientRequest, currency string) (db.Account, bool) {
+	given := 0
+	for _, ok := range []bool{recipient.ToAccountID != 0, recipient.ToUsername != "", recipient.ToAlias != ""} {
+		if ok {
+			given++
+		}
+	}
+	if given != 1 {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("exactly one of to_account_id, to_username and to_alias is required")))
+		return db.Account{}, false
+	}
+
+	if recipient.ToAccountID != 0 {
+		return server.validateCurrency(ctx, recipient.ToAccountID, currency)
+	}
+
+	owner := recipient.ToUsername
+	if recipient.ToAlias != "" {
+		alias, err := server.store.GetUserAlias(ctx, normalizeAlias(recipient.ToAlias))
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				ctx.JSON(http.StatusNotFound, errorResponse(errors.New("no user has this alias")))
+				return db.Account{}, false
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return db.Account{}, false
+		}
+		owner = alias.Username
+	}
+
+	// payments to a user go to their checking account in the currency, which
+	// owner_currency_type_key makes unique
+	account, err := server.store.GetAccountByOwnerCurrency(ctx, db.GetAccountByOwnerCurrencyParams{
+		Owner:    owner,
+		Currency: currency,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("recipient has no account in %s", currency)))
+			return account, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return account, false
+	}
+	if account.IsFrozen {
+		ctx.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("account [%d] is frozen", account.ID)))
+		return account, false
+	}
+	return account, true
+}
+
+func normalizeAlias(alias string) string {
+	return strings.ToLower(strings.TrimPrefix(alias, "@"))
+}
+
+// PreviewRecipient godoc
+// @Summary      PreviewRecipient
+// @Description  show the masked name of the recipient a transfer would go to
+// @Tags         accounts
+// @Produce      json
+// @Param to_account_id query int false "Account ID of the recipient"
+// @Param to_username query string false "Username of the recipient"
+// @Param to_alias query string false "Alias of the recipient"
+// @Param currency query string true "Currency of the transfer"
+// @Success      200  {object} 	RecipientPreviewResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      403  {object}  api.ErrorResponse
+// @Failure      404  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /recipients/preview [get]
+func (server *Server) PreviewRecipient(ctx *gin.Context) {
+	var req PreviewRecipientRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	account, ok := server.resolveRecipient(ctx, req.RecipientRequest, req.Currency)
+	if !ok {
+		return
+	}
+	user, err := server.store.GetUser(ctx, account.Owner)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, RecipientPreviewResponse{
+		MaskedName: util.MaskName(user.FullName),
+		Currency:   account.Currency,
+	})
+}
+
+// SetAlias godoc
+// @Summary      SetAlias
+// @Description  set the alias other users can send money to, replacing the current one
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param alias body SetAliasRequest true "new alias"
+// @Success      200  {object} 	AliasResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      409  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /users/me/alias [put]
+func (server *Server) SetAlias(ctx *gin.Context) {
+	var req SetAliasRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user := ctx.MustGet(authorizationUserKey).(db.User)
+	alias, err := server.store.SetUserAlias(ctx, db.SetUserAliasParams{
+		Alias:    req.Alias,
+		Username: user.Username,
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "user_aliases_pkey" {
+			ctx.JSON(http.StatusConflict, errorResponse(errors.New("alias is taken")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, AliasResponse{Alias: alias.Alias, Username: alias.Username})
+}
+
+// DeleteAlias godoc
+// @Summary      DeleteAlias
+// @Description  remove the alias of the current user
+// @Tags         users
+// @Success      204
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /users/me/alias [delete]
+func (server *Server) DeleteAlias(ctx *gin.Context) {
+	user := ctx.MustGet(authorizationUserKey).(db.User)
+	if err := server.store.DeleteUserAlias(ctx, user.Username); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
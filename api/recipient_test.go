@@ -0,0 +1,236 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestPreviewRecipient(t *testing.T) {
+	sender := util.RandomOwner()
+	recipient := db.User{Username: util.RandomOwner(), FullName: "John Doe", Role: util.DepositorRole, IsEmailVerified: true}
+	account := randomAccount(recipient)
+
+	byOwner := db.GetAccountByOwnerCurrencyParams{Owner: recipient.Username, Currency: account.Currency}
+
+	testCases := []struct {
+		Name          string
+		Query         url.Values
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:  "username",
+			Query: url.Values{"to_username": {recipient.Username}, "currency": {account.Currency}},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccountByOwnerCurrency(gomock.Any(), gomock.Eq(byOwner)).Times(1).Return(account, nil)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp RecipientPreviewResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, RecipientPreviewResponse{MaskedName: "J*** D**", Currency: account.Currency}, resp)
+				require.NotContains(t, recorder.Body.String(), recipient.Username)
+			},
+		},
+		{
+			Name:  "alias",
+			Query: url.Values{"to_alias": {"@John_Doe"}, "currency": {account.Currency}},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserAlias(gomock.Any(), gomock.Eq("john_doe")).Times(1).
+					Return(db.UserAlias{Alias: "john_doe", Username: recipient.Username}, nil)
+				store.EXPECT().GetAccountByOwnerCurrency(gomock.Any(), gomock.Eq(byOwner)).Times(1).Return(account, nil)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name:  "unknown alias",
+			Query: url.Values{"to_alias": {"nobody"}, "currency": {account.Currency}},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserAlias(gomock.Any(), gomock.Eq("nobody")).Times(1).Return(db.UserAlias{}, pgx.ErrNoRows)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			Name:  "no account in currency",
+			Query: url.Values{"to_username": {recipient.Username}, "currency": {account.Currency}},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccountByOwnerCurrency(gomock.Any(), gomock.Eq(byOwner)).Times(1).Return(db.Account{}, pgx.ErrNoRows)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			Name:  "frozen",
+			Query: url.Values{"to_username": {recipient.Username}, "currency": {account.Currency}},
+			BuildStubs: func(store *mock.MockStore) {
+				frozen := account
+				frozen.IsFrozen = true
+				store.EXPECT().GetAccountByOwnerCurrency(gomock.Any(), gomock.Eq(byOwner)).Times(1).Return(frozen, nil)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			Name:  "two recipients",
+			Query: url.Values{"to_username": {recipient.Username}, "to_alias": {"john_doe"}, "currency": {account.Currency}},
+			BuildStubs: func(store *mock.MockStore) {
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name:  "no recipient",
+			Query: url.Values{"currency": {account.Currency}},
+			BuildStubs: func(store *mock.MockStore) {
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(recipient.Username)).AnyTimes().Return(recipient, nil)
+			stubAuthUser(store)
+			stubCurrencies(store)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			request, err := http.NewRequest(http.MethodGet, "/recipients/preview?"+tc.Query.Encode(), nil)
+			require.NoError(t, err)
+			AddAuthorization(t, request, sender, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestSetAlias(t *testing.T) {
+	username := util.RandomOwner()
+
+	testCases := []struct {
+		Name          string
+		Alias         string
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:  "OK",
+			Alias: "john_doe",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().SetUserAlias(gomock.Any(), gomock.Eq(db.SetUserAliasParams{Alias: "john_doe", Username: username})).Times(1).
+					Return(db.UserAlias{Alias: "john_doe", Username: username}, nil)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp AliasResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, AliasResponse{Alias: "john_doe", Username: username}, resp)
+			},
+		},
+		{
+			Name:  "taken",
+			Alias: "john_doe",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().SetUserAlias(gomock.Any(), gomock.Any()).Times(1).
+					Return(db.UserAlias{}, &pgconn.PgError{Code: "23505", ConstraintName: "user_aliases_pkey"})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			Name:  "uppercase",
+			Alias: "John",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().SetUserAlias(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name:  "too short",
+			Alias: "jd",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().SetUserAlias(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			stubAuthUser(store)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{"alias": tc.Alias})
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPut, "/users/me/alias", bytes.NewReader(body))
+			require.NoError(t, err)
+			AddAuthorization(t, request, username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestDeleteAlias(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	username := util.RandomOwner()
+	store := mock.NewMockStore(ctrl)
+	stubAuthUser(store)
+	store.EXPECT().DeleteUserAlias(gomock.Any(), gomock.Eq(username)).Times(1).Return(nil)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodDelete, "/users/me/alias", nil)
+	require.NoError(t, err)
+	AddAuthorization(t, request, username, time.Minute)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusNoContent, recorder.Code)
+}
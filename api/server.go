@@ -1,11 +1,21 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/go-playground/validator/v10"
 	db "github.com/jxgzzztang/simplebank/db/sqlc"
 	"github.com/jxgzzztang/simplebank/docs"
+	"github.com/jxgzzztang/simplebank/metrics"
+	"github.com/jxgzzztang/simplebank/ratelimit"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/jxgzzztang/simplebank/worker"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -13,6 +23,10 @@ import (
 
 type Server struct {
 	store db.Store
+	taskDistributor worker.TaskDistributor
+	limiter ratelimit.Limiter
+	health *health
+	currencies *currencyRegistry
 	router *gin.Engine
 }
 
@@ -36,25 +50,52 @@ type Server struct {
 //	@externalDocs.description	OpenAPI
 //	@externalDocs.url			https://swagger.io/resources/open-api/
 
-func NewServer(store db.Store) Server {
+func NewServer(store db.Store, taskDistributor worker.TaskDistributor, limiter ratelimit.Limiter) Server {
 	server := Server{
 		store: store,
+		taskDistributor: taskDistributor,
+		limiter: limiter,
+		health: &health{checks: make(map[string]HealthCheck)},
+		currencies: newCurrencyRegistry(store),
 	}
-	router := gin.Default()
+	router := gin.New()
+	// lets the store layer see values of the request context, like its logger
+	router.ContextWithFallback = true
+	router.Use(requestIDMiddleware(slog.Default()), tracingMiddleware(), accessLogMiddleware(), metricsMiddleware(), gin.Recovery())
 
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
-		err := v.RegisterValidation("currency", currencyValidate)
+		err := v.RegisterValidation("currency", server.currencyValidate)
+		if err != nil {
+			return Server{}
+		}
+		err = v.RegisterValidation("alias", aliasValidate)
 		if err != nil {
 			return Server{}
 		}
 	}
+	// amounts in JSON take their exponent from the currencies table
+	util.SetCurrencyLookup(func(code string) (util.Currency, error) {
+		return server.currencies.currency(context.Background(), code)
+	})
 	docs.SwaggerInfo.Title = "Simplebank API"
 	docs.SwaggerInfo.Host = "localhost:8080"
 	docs.SwaggerInfo.BasePath = "/"
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-	router.POST("/login", server.Login)
-	router.POST("/createUser", server.CreateUser)
-	router.POST("/renewAccessToken", server.RenewAccessToken)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	router.GET("/healthz", server.Healthz)
+	router.GET("/readyz", server.Readyz)
+	publicGroup := router.Group("/")
+	publicGroup.Use(rateLimitMiddleware(server.limiter))
+	publicGroup.POST("/login", server.Login)
+	publicGroup.POST("/login/mfa", server.LoginMFA)
+	publicGroup.POST("/createUser", server.CreateUser)
+	publicGroup.POST("/renewAccessToken", server.RenewAccessToken)
+	publicGroup.GET("/verify_email", server.VerifyEmail)
+	publicGroup.POST("/verify_email/resend", server.ResendVerifyEmail)
+	publicGroup.GET("/currencies", server.ListCurrencies)
+	publicGroup.POST("/password/forgot", server.ForgotPassword)
+	publicGroup.GET("/password/reset", server.ResetPasswordPage)
+	publicGroup.POST("/password/reset", server.ResetPassword)
 	RouterGroup(router, server)
 	server.router = router
 	return server
@@ -62,21 +103,80 @@ func NewServer(store db.Store) Server {
 
 func RouterGroup(router *gin.Engine, server Server) {
 	routerGroup := router.Group("/")
-	routerGroup.Use(authMiddleware())
+	routerGroup.Use(authMiddleware(server.store), rateLimitMiddleware(server.limiter))
 	routerGroup.GET("/account/:id", server.GetAccount)
-	router.POST("/createAccount", server.CreateAccount)
+	routerGroup.POST("/createAccount", server.CreateAccount)
 	routerGroup.GET("/listAccounts", server.ListAccounts)
 	routerGroup.POST("/transfer", server.Transfer)
+	routerGroup.POST("/transfers/batch", server.BatchTransfer)
+	routerGroup.GET("/transfers/batch/:id", server.GetTransferBatch)
+	routerGroup.POST("/transfers/:id/reverse", server.ReverseTransfer)
+	routerGroup.GET("/recipients/preview", server.PreviewRecipient)
+	routerGroup.POST("/payment_requests", server.CreatePaymentRequest)
+	routerGroup.GET("/payment_requests", server.ListPaymentRequests)
+	routerGroup.POST("/payment_requests/:id/accept", server.AcceptPaymentRequest)
+	routerGroup.POST("/payment_requests/:id/decline", server.DeclinePaymentRequest)
+	routerGroup.POST("/holds", server.PlaceHold)
+	routerGroup.POST("/holds/:id/capture", server.CaptureHold)
+	routerGroup.POST("/holds/:id/release", server.ReleaseHold)
+	routerGroup.PATCH("/users/me", server.UpdateUser)
+	routerGroup.POST("/users/me/password", server.ChangePassword)
+	routerGroup.PUT("/users/me/alias", server.SetAlias)
+	routerGroup.DELETE("/users/me/alias", server.DeleteAlias)
+	routerGroup.POST("/users/me/totp", server.EnrollTOTP)
+	routerGroup.POST("/users/me/totp/confirm", server.ConfirmTOTP)
+	routerGroup.POST("/reauth", server.Reauth)
+
+	adminGroup := routerGroup.Group("/admin")
+	adminGroup.Use(roleMiddleware(&server, util.AdminRole))
+	adminGroup.GET("/audit_events", server.ListAuditEvents)
 } 
 
-func (server *Server) Start(address string) error {
+// Start serves until ctx is cancelled. It then fails readiness and keeps
+// serving for Shutdown.DrainDelay, so load balancers stop sending traffic
+// before the server shuts down gracefully.
+func (server *Server) Start(ctx context.Context, address string) error {
 	gin.SetMode(gin.DebugMode)
-	err := server.router.Run(address)
-	return err
+	httpServer := &http.Server{
+		Addr:    address,
+		Handler: server.router,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	config := util.Config.Shutdown
+	server.health.shuttingDown.Store(true)
+	slog.Info("shutting down server", "drain_delay", config.DrainDelay)
+	time.Sleep(config.DrainDelay)
+
+	shutdownCtx := context.Background()
+	if config.Timeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, config.Timeout)
+		defer cancel()
+	}
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Code identifies errors clients are expected to act on, like step_up_required.
+	Code string `json:"code,omitempty"`
 }
 
 func errorResponse(error error) ErrorResponse {
@@ -25,7 +25,7 @@ func (server *Server) RenewAccessToken(ctx *gin.Context) {
 	}
 
 	refreshTokenPayload, isValid := util.ParseToken(params.RefreshAccessToken)
-	if !isValid {
+	if !isValid || refreshTokenPayload.Purpose != "" {
 		validRefreshTokenError := errors.New("invalid refresh token")
 		ctx.JSON(http.StatusUnauthorized, errorResponse(validRefreshTokenError))
 		return
@@ -58,7 +58,12 @@ func (server *Server) RenewAccessToken(ctx *gin.Context) {
 		return
 	}
 
-	accessToken, accessTokenPayload,  err := util.CreateToken(session.Username, util.Config.Jwt.ExpiresDuration)
+	var opts []util.TokenOption
+	if refreshTokenPayload.AuthTime != nil {
+		opts = append(opts, util.WithAuthentication(refreshTokenPayload.AuthTime.Time, refreshTokenPayload.AMR...))
+	}
+
+	accessToken, accessTokenPayload,  err := util.CreateToken(session.Username, util.Config.Jwt.ExpiresDuration, opts...)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jxgzzztang/simplebank/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/jxgzzztang/simplebank/api")
+
+// tracingMiddleware starts a span for every request, continuing the trace of
+// the caller when it sends a traceparent header.
+func tracingMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		parent := otel.GetTextMapPropagator().Extract(ctx.Request.Context(), propagation.HeaderCarrier(ctx.Request.Header))
+
+		route := ctx.FullPath()
+		spanName := ctx.Request.Method
+		if route != "" {
+			spanName += " " + route
+		}
+
+		spanCtx, span := tracer.Start(parent, spanName,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(ctx.Request.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(ctx.Request.URL.Path),
+				semconv.ClientAddress(ctx.ClientIP()),
+				attribute.String("request_id", requestID(ctx)),
+			),
+		)
+		defer span.End()
+
+		ctx.Request = ctx.Request.WithContext(spanCtx)
+		ctx.Next()
+
+		status := ctx.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if payload, ok := ctx.Get(authorizationPayloadKey); ok {
+			span.SetAttributes(attribute.String("enduser.id", payload.(*util.TokenPayload).Username))
+		}
+		if len(ctx.Errors) > 0 {
+			span.RecordError(ctx.Errors.Last())
+		}
+		if status >= 500 {
+			span.SetStatus(codes.Error, fmt.Sprintf("status %d", status))
+		}
+	}
+}
+
+// hashPassword hashes a password in its own span, bcrypt is slow on purpose.
+func hashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracer.Start(ctx, "bcrypt.Hash")
+	defer span.End()
+	return util.HashPassword(password)
+}
+
+// checkPassword compares a password with its hash in its own span.
+func checkPassword(ctx context.Context, password string, hashedPassword string) error {
+	_, span := tracer.Start(ctx, "bcrypt.Compare")
+	defer span.End()
+	return util.CheckPassword(password, hashedPassword)
+}
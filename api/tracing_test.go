@@ -0,0 +1,59 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/mock/gomock"
+)
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	store := mock.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq("nobody")).Times(1).Return(db.User{}, pgx.ErrNoRows)
+	store.EXPECT().CreateLoginAttempt(gomock.Any(), loginAttemptOutcome("nobody", loginInvalidCredentials)).Times(1).Return(db.LoginAttempt{}, nil)
+
+	server := newTestServer(t, store)
+
+	body, err := json.Marshal(gin.H{"username": "nobody", "password": "secret"})
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "/login", bytes.NewReader(body))
+	require.NoError(t, err)
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	request.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+
+	response := httptest.NewRecorder()
+	server.router.ServeHTTP(response, request)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+
+	compare, serverSpan := spans[0], spans[1]
+	require.Equal(t, "bcrypt.Compare", compare.Name())
+	require.Equal(t, "POST /login", serverSpan.Name())
+	require.Equal(t, trace.SpanKindServer, serverSpan.SpanKind())
+
+	// the server span continues the caller's trace and parents the bcrypt span
+	require.Equal(t, traceID, serverSpan.SpanContext().TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", serverSpan.Parent().SpanID().String())
+	require.Equal(t, serverSpan.SpanContext().SpanID(), compare.Parent().SpanID())
+}
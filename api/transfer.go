@@ -1,22 +1,87 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	db "github.com/jxgzzztang/simplebank/db/sqlc"
 	"github.com/jxgzzztang/simplebank/util"
 )
 
 type TransferRequest struct {
 	FromAccountID int64 `json:"from_account_id" binding:"required"`
-	ToAccountID   int64 `json:"to_account_id" binding:"required"`
-	Amount        int64  `json:"amount" binding:"required,gt=0"`
+	RecipientRequest
+	// Amount is a decimal string with at most as many decimal places as the
+	// exponent of the currency, like "12.34".
+	Amount        string `json:"amount" binding:"required"`
 	Currency      string `json:"currency" binding:"required,currency"`
 }
 
+// TransferResponse is the result of a transfer with every amount in the
+// currency of the transfer.
+type TransferResponse struct {
+	Transfer    TransferInfoResponse `json:"transfer"`
+	FromAccount AccountResponse      `json:"from_account"`
+	ToAccount   AccountResponse      `json:"to_account"`
+	FromEntry   EntryResponse        `json:"from_entry"`
+	ToEntry     EntryResponse        `json:"to_entry"`
+}
+
+type TransferInfoResponse struct {
+	ID            int64     `json:"id"`
+	FromAccountID int64     `json:"from_account_id"`
+	ToAccountID   int64      `json:"to_account_id"`
+	Amount        util.Money `json:"amount" swaggertype:"object,string"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type EntryResponse struct {
+	ID        int64     `json:"id"`
+	AccountID int64      `json:"account_id"`
+	Amount    util.Money `json:"amount" swaggertype:"object,string"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (server *Server) newTransferResponse(ctx context.Context, result db.TransferTxResult, currency util.Currency) (TransferResponse, error) {
+	fromAccount, err := server.newAccountResponse(ctx, result.FromAccount)
+	if err != nil {
+		return TransferResponse{}, err
+	}
+	toAccount, err := server.newAccountResponse(ctx, result.ToAccount)
+	if err != nil {
+		return TransferResponse{}, err
+	}
+
+	return TransferResponse{
+		Transfer: TransferInfoResponse{
+			ID:            result.Transfer.ID,
+			FromAccountID: result.Transfer.FromAccountID,
+			ToAccountID:   result.Transfer.ToAccountID,
+			Amount:        util.NewMoney(result.Transfer.Amount, currency),
+			CreatedAt:     result.Transfer.CreatedAt.Time,
+		},
+		FromAccount: fromAccount,
+		ToAccount:   toAccount,
+		FromEntry:   newEntryResponse(result.FromEntry, currency),
+		ToEntry:     newEntryResponse(result.ToEntry, currency),
+	}, nil
+}
+
+func newEntryResponse(entry db.Entry, currency util.Currency) EntryResponse {
+	return EntryResponse{
+		ID:        entry.ID,
+		AccountID: entry.AccountID,
+		Amount:    util.NewMoney(entry.Amount, currency),
+		CreatedAt: entry.CreatedAt.Time,
+	}
+}
+
 
 // Transfer godoc
 // @Summary      Transfer
@@ -25,8 +90,10 @@ type TransferRequest struct {
 // @Accept       json
 // @Produce      json
 // @Param transferData body TransferRequest true "参数"
-// @Success      200  {object} 	db.TransferTxResult
+// @Success      200  {object} 	TransferResponse
 // @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      422  {object}  api.ErrorResponse
 // @Failure      500  {object}  api.ErrorResponse
 // @Router       /transfer [post]
 func (server *Server) Transfer(ctx *gin.Context)  {
@@ -35,6 +102,21 @@ func (server *Server) Transfer(ctx *gin.Context)  {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
+	currency, err := server.currencies.currency(ctx, req.Currency)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	amount, err := util.ParseMoney(req.Amount, currency)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if !amount.IsPositive() {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("amount must be positive")))
+		return
+	}
+
 	fromAccount, isValid := server.validateCurrency(ctx, req.FromAccountID, req.Currency)
 	if !isValid {
 		return
@@ -44,29 +126,227 @@ func (server *Server) Transfer(ctx *gin.Context)  {
 
 	if payload.Username != fromAccount.Owner {
 		err := errors.New("from account does not have permission to transfer")
+		server.auditDenied(ctx, accountTarget(fromAccount.ID), err)
 		ctx.JSON(http.StatusForbidden, errorResponse(err))
 		return
 	}
 
-	_, isValid = server.validateCurrency(ctx, req.ToAccountID, req.Currency)
+	if !requireStepUp(ctx, payload, amount) {
+		return
+	}
+
+	toAccount, isValid := server.resolveRecipient(ctx, req.RecipientRequest, req.Currency)
 
 	if !isValid {
 		return
 	}
 
+	actor := auditActor(ctx)
 	createTransfer := db.TransferTxParams{
 		FromAccountID: req.FromAccountID,
-		ToAccountID: req.ToAccountID,
-		Amount: req.Amount,
+		ToAccountID: toAccount.ID,
+		Amount: amount,
+		Audit: &actor,
 	}
 
 	err, result := server.store.TransferTx(ctx, createTransfer)
 
+	if err != nil {
+		if errors.Is(err, db.ErrInsufficientFunds) {
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return
+		}
+		if errors.Is(err, util.ErrCurrencyMismatch) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	resp, err := server.newTransferResponse(ctx, result, currency)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, resp)
+}
+
+type ReverseTransferURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type ReverseTransferRequest struct {
+	// Amount is a decimal string like "12.34". What is left of the transfer
+	// is refunded when it is empty.
+	Amount string `json:"amount"`
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+type TransferReversalResponse struct {
+	ID                 int64      `json:"id"`
+	OriginalTransferID int64      `json:"original_transfer_id"`
+	TransferID         int64      `json:"transfer_id"`
+	Amount             util.Money `json:"amount" swaggertype:"object,string"`
+	Reason             string     `json:"reason"`
+	InitiatedBy        string     `json:"initiated_by"`
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+type ReverseTransferResponse struct {
+	Reversal TransferReversalResponse `json:"reversal"`
+	// Transfer is the compensating transfer from the recipient to the sender.
+	Transfer TransferResponse `json:"transfer"`
+	// ReversedAmount is how much of the original transfer has been refunded.
+	ReversedAmount util.Money `json:"reversed_amount" swaggertype:"object,string"`
+}
+
+// ReverseTransfer godoc
+// @Summary      ReverseTransfer
+// @Description  refund all or part of a transfer, by an admin or the owner of the receiving account
+// @Tags         accounts
+// @Accept       json
+// @Produce      json
+// @Param id path int true "Transfer ID"
+// @Param reversal body ReverseTransferRequest true "amount and reason of the refund"
+// @Success      200  {object} 	ReverseTransferResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      403  {object}  api.ErrorResponse
+// @Failure      404  {object}  api.ErrorResponse
+// @Failure      422  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /transfers/{id}/reverse [post]
+func (server *Server) ReverseTransfer(ctx *gin.Context) {
+	var uri ReverseTransferURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	var req ReverseTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	original, err := server.store.GetTransfer(ctx, uri.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	recipient, err := server.store.GetAccount(ctx, original.ToAccountID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// admins can reverse any transfer, everyone else only refunds money they
+	// received
+	user := ctx.MustGet(authorizationUserKey).(db.User)
+	payload := ctx.MustGet(authorizationPayloadKey).(*util.TokenPayload)
+	if user.Role != util.AdminRole {
+		if payload.Username != recipient.Owner {
+			err := errors.New("only the recipient or an admin can reverse a transfer")
+			server.auditDenied(ctx, transferTarget(original.ID), err)
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		if recipient.IsFrozen {
+			ctx.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("account [%d] is frozen", recipient.ID)))
+			return
+		}
+		// the refund goes back into the sender's account
+		sender, err := server.store.GetAccount(ctx, original.FromAccountID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if sender.IsFrozen {
+			ctx.JSON(http.StatusForbidden, errorResponse(fmt.Errorf("account [%d] is frozen", sender.ID)))
+			return
+		}
+	}
+
+	currency, err := server.currencies.currency(ctx, recipient.Currency)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	ctx.JSON(http.StatusOK, result)
+	var amount util.Money
+	if req.Amount == "" {
+		reversed, err := server.store.GetReversedAmount(ctx, original.ID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		amount = util.NewMoney(original.Amount-reversed, currency)
+		if !amount.IsPositive() {
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(errors.New("transfer is already fully reversed")))
+			return
+		}
+	} else {
+		amount, err = util.ParseMoney(req.Amount, currency)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		if !amount.IsPositive() {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("amount must be positive")))
+			return
+		}
+	}
+
+	if !requireStepUp(ctx, payload, amount) {
+		return
+	}
+
+	actor := auditActor(ctx)
+	err, result := server.store.ReverseTransferTx(ctx, db.ReverseTransferTxParams{
+		TransferID:  original.ID,
+		Amount:      amount,
+		Reason:      req.Reason,
+		InitiatedBy: payload.Username,
+		Audit:       &actor,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrReversalExceedsTransfer), errors.Is(err, db.ErrInsufficientFunds):
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+		case errors.Is(err, util.ErrCurrencyMismatch):
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+
+	transfer, err := server.newTransferResponse(ctx, result.TransferTxResult, currency)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, ReverseTransferResponse{
+		Reversal: TransferReversalResponse{
+			ID:                 result.Reversal.ID,
+			OriginalTransferID: result.Reversal.OriginalTransferID,
+			TransferID:         result.Reversal.TransferID,
+			Amount:             util.NewMoney(result.Reversal.Amount, currency),
+			Reason:             result.Reversal.Reason,
+			InitiatedBy:        result.Reversal.InitiatedBy,
+			CreatedAt:          result.Reversal.CreatedAt.Time,
+		},
+		Transfer:       transfer,
+		ReversedAmount: util.NewMoney(result.ReversedAmount, currency),
+	})
+}
+
+// transferTarget names a transfer in the audit log.
+func transferTarget(id int64) string {
+	return "transfer:" + strconv.FormatInt(id, 10)
 }
 
 func (server *Server) validateCurrency(ctx *gin.Context, accountID int64, currency string) (db.Account, bool) {
@@ -76,6 +356,12 @@ func (server *Server) validateCurrency(ctx *gin.Context, accountID int64, curren
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return account, false
 	}
+	if account.IsFrozen {
+		customErr := fmt.Errorf("account [%d] is frozen", accountID)
+
+		ctx.JSON(http.StatusForbidden, errorResponse(customErr))
+		return account, false
+	}
 	if account.Currency != currency {
 		customErr := fmt.Errorf("valid currency is [%s], transfer [%s]", currency, account.Currency)
 
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+type BatchTransferItemRequest struct {
+	ToAccountID int64 `json:"to_account_id" binding:"required,min=1"`
+	// Amount is a decimal string like "12.34", see TransferRequest.
+	Amount string `json:"amount" binding:"required"`
+}
+
+type BatchTransferRequest struct {
+	FromAccountID int64  `json:"from_account_id" binding:"required,min=1"`
+	Currency      string `json:"currency" binding:"required,currency"`
+	// Mode is atomic to execute every item or none, best_effort to execute
+	// every item that can be.
+	Mode  string                     `json:"mode" binding:"required,oneof=atomic best_effort"`
+	Items []BatchTransferItemRequest `json:"items" binding:"required,min=1,max=500,dive"`
+}
+
+// BatchTransferErrorResponse rejects a batch before anything is executed. It
+// lists every invalid item, so they can be fixed at once.
+type BatchTransferErrorResponse struct {
+	Error string                   `json:"error"`
+	Items []BatchTransferItemError `json:"items"`
+}
+
+type BatchTransferItemError struct {
+	Position int    `json:"position"`
+	Error    string `json:"error"`
+}
+
+type TransferBatchURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type TransferBatchItemResponse struct {
+	Position    int32      `json:"position"`
+	ToAccountID int64      `json:"to_account_id"`
+	Amount      util.Money `json:"amount" swaggertype:"object,string"`
+	Status      string     `json:"status"`
+	TransferID  *int64     `json:"transfer_id,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+type TransferBatchResponse struct {
+	ID            int64  `json:"id"`
+	FromAccountID int64  `json:"from_account_id"`
+	Mode          string `json:"mode"`
+	// Status is completed when every item succeeded, partial when some did
+	// and failed when none did.
+	Status      string                      `json:"status"`
+	InitiatedBy string                      `json:"initiated_by"`
+	CreatedAt   time.Time                   `json:"created_at"`
+	Items       []TransferBatchItemResponse `json:"items"`
+}
+
+type BatchTransferResponse struct {
+	Batch TransferBatchResponse `json:"batch"`
+	// FromAccount is the source account after the batch.
+	FromAccount AccountResponse `json:"from_account"`
+}
+
+func newTransferBatchResponse(batch db.TransferBatch, items []db.TransferBatchItem, currency util.Currency) TransferBatchResponse {
+	resp := TransferBatchResponse{
+		ID:            batch.ID,
+		FromAccountID: batch.FromAccountID,
+		Mode:          batch.Mode,
+		Status:        batch.Status,
+		InitiatedBy:   batch.InitiatedBy,
+		CreatedAt:     batch.CreatedAt.Time,
+		Items:         make([]TransferBatchItemResponse, 0, len(items)),
+	}
+	for _, item := range items {
+		itemResp := TransferBatchItemResponse{
+			Position:    item.Position,
+			ToAccountID: item.ToAccountID,
+			Amount:      util.NewMoney(item.Amount, currency),
+			Status:      item.Status,
+			Error:       item.Error,
+		}
+		if item.TransferID.Valid {
+			itemResp.TransferID = &item.TransferID.Int64
+		}
+		resp.Items = append(resp.Items, itemResp)
+	}
+	return resp
+}
+
+// transferBatchTarget names a transfer batch in the audit log.
+func transferBatchTarget(id int64) string {
+	return "transfer_batch:" + strconv.FormatInt(id, 10)
+}
+
+// validateBatchItems checks every item of a batch from fromAccount up front
+// and returns the parsed items with the errors of the invalid ones.
+func (server *Server) validateBatchItems(ctx context.Context, fromAccount db.Account, reqItems []BatchTransferItemRequest, currency util.Currency) ([]db.BatchTransferItem, []BatchTransferItemError, error) {
+	ids := make([]int64, 0, len(reqItems))
+	for _, item := range reqItems {
+		ids = append(ids, item.ToAccountID)
+	}
+	accounts, err := server.store.ListAccountsByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+	accountsByID := make(map[int64]db.Account, len(accounts))
+	for _, account := range accounts {
+		accountsByID[account.ID] = account
+	}
+
+	items := make([]db.BatchTransferItem, 0, len(reqItems))
+	var itemErrors []BatchTransferItemError
+	for i, reqItem := range reqItems {
+		amount, err := util.ParseMoney(reqItem.Amount, currency)
+		if err == nil && !amount.IsPositive() {
+			err = errors.New("amount must be positive")
+		}
+		if err == nil {
+			err = batchRecipientError(fromAccount, accountsByID, reqItem.ToAccountID)
+		}
+		if err != nil {
+			itemErrors = append(itemErrors, BatchTransferItemError{Position: i, Error: err.Error()})
+			continue
+		}
+		items = append(items, db.BatchTransferItem{ToAccountID: reqItem.ToAccountID, Amount: amount})
+	}
+	return items, itemErrors, nil
+}
+
+func batchRecipientError(fromAccount db.Account, accountsByID map[int64]db.Account, toAccountID int64) error {
+	account, ok := accountsByID[toAccountID]
+	switch {
+	case !ok:
+		return fmt.Errorf("account [%d] does not exist", toAccountID)
+	case account.ID == fromAccount.ID:
+		return errors.New("cannot transfer to the source account")
+	case account.IsFrozen:
+		return fmt.Errorf("account [%d] is frozen", account.ID)
+	case account.Currency != fromAccount.Currency:
+		return fmt.Errorf("valid currency is [%s], transfer [%s]", fromAccount.Currency, account.Currency)
+	}
+	return nil
+}
+
+// BatchTransfer godoc
+// @Summary      BatchTransfer
+// @Description  transfer from one account to many, atomically or best effort, after validating every item
+// @Tags         accounts
+// @Accept       json
+// @Produce      json
+// @Param batch body BatchTransferRequest true "source account and the transfers to make from it"
+// @Success      200  {object} 	BatchTransferResponse
+// @Failure      400  {object}  api.BatchTransferErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      403  {object}  api.ErrorResponse
+// @Failure      422  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /transfers/batch [post]
+func (server *Server) BatchTransfer(ctx *gin.Context) {
+	var req BatchTransferRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	currency, err := server.currencies.currency(ctx, req.Currency)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	fromAccount, isValid := server.validateCurrency(ctx, req.FromAccountID, req.Currency)
+	if !isValid {
+		return
+	}
+
+	payload := ctx.MustGet(authorizationPayloadKey).(*util.TokenPayload)
+	if payload.Username != fromAccount.Owner {
+		err := errors.New("from account does not have permission to transfer")
+		server.auditDenied(ctx, accountTarget(fromAccount.ID), err)
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	items, itemErrors, err := server.validateBatchItems(ctx, fromAccount, req.Items, currency)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if len(itemErrors) > 0 {
+		ctx.JSON(http.StatusBadRequest, BatchTransferErrorResponse{
+			Error: fmt.Sprintf("%d of %d items are invalid", len(itemErrors), len(req.Items)),
+			Items: itemErrors,
+		})
+		return
+	}
+
+	total := util.NewMoney(0, currency)
+	for _, item := range items {
+		if total, err = total.Add(item.Amount); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+	// an atomic batch that cannot be covered would only be rolled back
+	if req.Mode == db.TransferBatchAtomic {
+		held, err := server.store.GetAccountHeldAmount(ctx, fromAccount.ID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		available := util.NewMoney(fromAccount.Balance+fromAccount.OverdraftLimit-held, currency)
+		if total.Amount > available.Amount {
+			err := fmt.Errorf("%w: the batch needs %s, %s is available", db.ErrInsufficientFunds, total, available)
+			ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			return
+		}
+	}
+	if !requireStepUp(ctx, payload, total) {
+		return
+	}
+
+	actor := auditActor(ctx)
+	err, result := server.store.BatchTransferTx(ctx, db.BatchTransferTxParams{
+		FromAccountID: fromAccount.ID,
+		Items:         items,
+		Mode:          req.Mode,
+		InitiatedBy:   payload.Username,
+		Audit:         &actor,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	fromAccountResp, err := server.newAccountResponse(ctx, result.FromAccount)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, BatchTransferResponse{
+		Batch:       newTransferBatchResponse(result.Batch, result.Items, currency),
+		FromAccount: fromAccountResp,
+	})
+}
+
+// GetTransferBatch godoc
+// @Summary      GetTransferBatch
+// @Description  get a transfer batch with the outcome of every item
+// @Tags         accounts
+// @Produce      json
+// @Param id path int true "Transfer batch ID"
+// @Success      200  {object} 	TransferBatchResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      403  {object}  api.ErrorResponse
+// @Failure      404  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /transfers/batch/{id} [get]
+func (server *Server) GetTransferBatch(ctx *gin.Context) {
+	var uri TransferBatchURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	batch, err := server.store.GetTransferBatch(ctx, uri.ID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusNotFound, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	fromAccount, err := server.store.GetAccount(ctx, batch.FromAccountID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	user := ctx.MustGet(authorizationUserKey).(db.User)
+	if user.Role != util.AdminRole && user.Username != fromAccount.Owner {
+		err := errors.New("only the owner of the source account or an admin can view a transfer batch")
+		server.auditDenied(ctx, transferBatchTarget(batch.ID), err)
+		ctx.JSON(http.StatusForbidden, errorResponse(err))
+		return
+	}
+
+	items, err := server.store.ListTransferBatchItems(ctx, batch.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	currency, err := server.currencies.currency(ctx, fromAccount.Currency)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, newTransferBatchResponse(batch, items, currency))
+}
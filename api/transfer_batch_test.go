@@ -0,0 +1,312 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestBatchTransfer(t *testing.T) {
+	user, _ := RandomUser(t)
+	other, _ := RandomUser(t)
+	fromAccount := db.Account{ID: 1, Owner: user.Username, Balance: 10000, Currency: util.USD}
+	toAccount1 := db.Account{ID: 2, Owner: other.Username, Currency: util.USD}
+	toAccount2 := db.Account{ID: 3, Owner: other.Username, Currency: util.USD}
+	eurAccount := db.Account{ID: 4, Owner: other.Username, Currency: util.EUR}
+	frozenAccount := db.Account{ID: 5, Owner: other.Username, Currency: util.USD, IsFrozen: true}
+	currency := util.Currency{Code: util.USD, Exponent: 2}
+
+	items := []gin.H{
+		{"to_account_id": toAccount1.ID, "amount": "10.00"},
+		{"to_account_id": toAccount2.ID, "amount": "2.50"},
+	}
+
+	testCases := []struct {
+		Name          string
+		Username      string
+		Body          gin.H
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:     "OK",
+			Username: user.Username,
+			Body:     gin.H{"from_account_id": fromAccount.ID, "currency": util.USD, "mode": db.TransferBatchAtomic, "items": items},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ListAccountsByIDs(gomock.Any(), gomock.Eq([]int64{toAccount1.ID, toAccount2.ID})).Times(1).
+					Return([]db.Account{toAccount1, toAccount2}, nil)
+				store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.BatchTransferTxParams) (error, db.BatchTransferTxResult) {
+						require.Equal(t, fromAccount.ID, arg.FromAccountID)
+						require.Equal(t, db.TransferBatchAtomic, arg.Mode)
+						require.Equal(t, user.Username, arg.InitiatedBy)
+						require.Equal(t, user.Username, arg.Audit.Actor)
+						require.Equal(t, []db.BatchTransferItem{
+							{ToAccountID: toAccount1.ID, Amount: util.NewMoney(1000, currency)},
+							{ToAccountID: toAccount2.ID, Amount: util.NewMoney(250, currency)},
+						}, arg.Items)
+
+						result := db.BatchTransferTxResult{
+							Batch:       db.TransferBatch{ID: 9, FromAccountID: arg.FromAccountID, Mode: arg.Mode, Status: db.TransferBatchCompleted, InitiatedBy: arg.InitiatedBy},
+							FromAccount: fromAccount,
+						}
+						result.FromAccount.Balance -= 1250
+						for i, item := range arg.Items {
+							result.Items = append(result.Items, db.TransferBatchItem{
+								BatchID:     9,
+								Position:    int32(i),
+								ToAccountID: item.ToAccountID,
+								Amount:      item.Amount.Amount,
+								Status:      db.TransferBatchItemSucceeded,
+								TransferID:  pgtype.Int8{Int64: int64(20 + i), Valid: true},
+							})
+						}
+						return nil, result
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp BatchTransferResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, db.TransferBatchCompleted, resp.Batch.Status)
+				require.Len(t, resp.Batch.Items, 2)
+				require.Equal(t, util.NewMoney(250, currency), resp.Batch.Items[1].Amount)
+				require.Equal(t, int64(21), *resp.Batch.Items[1].TransferID)
+				require.Equal(t, util.NewMoney(fromAccount.Balance-1250, currency), resp.FromAccount.Balance)
+			},
+		},
+		{
+			Name:     "Partial",
+			Username: user.Username,
+			Body:     gin.H{"from_account_id": fromAccount.ID, "currency": util.USD, "mode": db.TransferBatchBestEffort, "items": items},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ListAccountsByIDs(gomock.Any(), gomock.Any()).Times(1).Return([]db.Account{toAccount1, toAccount2}, nil)
+				store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(1).Return(nil, db.BatchTransferTxResult{
+					Batch: db.TransferBatch{ID: 9, FromAccountID: fromAccount.ID, Mode: db.TransferBatchBestEffort, Status: db.TransferBatchPartial},
+					Items: []db.TransferBatchItem{
+						{BatchID: 9, Position: 0, ToAccountID: toAccount1.ID, Amount: 1000, Status: db.TransferBatchItemSucceeded, TransferID: pgtype.Int8{Int64: 20, Valid: true}},
+						{BatchID: 9, Position: 1, ToAccountID: toAccount2.ID, Amount: 250, Status: db.TransferBatchItemFailed, Error: db.ErrInsufficientFunds.Error()},
+					},
+					FromAccount: fromAccount,
+				})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp BatchTransferResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, db.TransferBatchPartial, resp.Batch.Status)
+				require.Nil(t, resp.Batch.Items[1].TransferID)
+				require.Equal(t, db.ErrInsufficientFunds.Error(), resp.Batch.Items[1].Error)
+			},
+		},
+		{
+			Name:     "AtomicInsufficientFunds",
+			Username: user.Username,
+			Body:     gin.H{"from_account_id": fromAccount.ID, "currency": util.USD, "mode": db.TransferBatchAtomic, "items": items},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ListAccountsByIDs(gomock.Any(), gomock.Any()).Times(1).Return([]db.Account{toAccount1, toAccount2}, nil)
+				// holds leave 12.49 of the 12.50 the batch needs
+				store.EXPECT().GetAccountHeldAmount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount.Balance-1249, nil)
+				store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+				require.Contains(t, recorder.Body.String(), db.ErrInsufficientFunds.Error())
+			},
+		},
+		{
+			Name:     "InvalidItems",
+			Username: user.Username,
+			Body: gin.H{"from_account_id": fromAccount.ID, "currency": util.USD, "mode": db.TransferBatchAtomic, "items": []gin.H{
+				{"to_account_id": toAccount1.ID, "amount": "10.00"},
+				{"to_account_id": eurAccount.ID, "amount": "1.00"},
+				{"to_account_id": frozenAccount.ID, "amount": "1.00"},
+				{"to_account_id": 99, "amount": "1.00"},
+				{"to_account_id": fromAccount.ID, "amount": "1.00"},
+				{"to_account_id": toAccount2.ID, "amount": "0.001"},
+			}},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ListAccountsByIDs(gomock.Any(), gomock.Any()).Times(1).
+					Return([]db.Account{fromAccount, toAccount1, toAccount2, eurAccount, frozenAccount}, nil)
+				store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				var resp BatchTransferErrorResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Len(t, resp.Items, 5)
+				for i, itemError := range resp.Items {
+					require.Equal(t, i+1, itemError.Position)
+					require.NotEmpty(t, itemError.Error)
+				}
+			},
+		},
+		{
+			Name:     "InvalidMode",
+			Username: user.Username,
+			Body:     gin.H{"from_account_id": fromAccount.ID, "currency": util.USD, "mode": "eventually", "items": items},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name:     "NotOwner",
+			Username: other.Username,
+			Body:     gin.H{"from_account_id": fromAccount.ID, "currency": util.USD, "mode": db.TransferBatchAtomic, "items": items},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().RecordAuditEventTx(gomock.Any(), auditEvent(other.Username, db.AuditActionAuthorizationDenied)).Times(1).Return(nil, db.RecordAuditEventTxResult{})
+				store.EXPECT().BatchTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			tc.BuildStubs(store)
+			stubAuthUser(store)
+			stubCurrencies(store)
+			stubHeldAmounts(store)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).AnyTimes().Return(fromAccount, nil)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.Body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/transfers/batch", bytes.NewReader(body))
+			require.NoError(t, err)
+			AddAuthorization(t, request, tc.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestGetTransferBatch(t *testing.T) {
+	user, _ := RandomUser(t)
+	fromAccount := db.Account{ID: 1, Owner: user.Username, Currency: util.USD}
+	batch := db.TransferBatch{ID: 9, FromAccountID: fromAccount.ID, Mode: db.TransferBatchAtomic, Status: db.TransferBatchFailed, InitiatedBy: user.Username}
+	items := []db.TransferBatchItem{
+		{BatchID: batch.ID, Position: 0, ToAccountID: 2, Amount: 1000, Status: db.TransferBatchItemFailed, Error: db.ErrInsufficientFunds.Error()},
+	}
+
+	testCases := []struct {
+		Name          string
+		Username      string
+		BatchID       int64
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:     "OK",
+			Username: user.Username,
+			BatchID:  batch.ID,
+			BuildStubs: func(store *mock.MockStore) {
+				stubAuthUser(store)
+				store.EXPECT().GetTransferBatch(gomock.Any(), gomock.Eq(batch.ID)).Times(1).Return(batch, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				store.EXPECT().ListTransferBatchItems(gomock.Any(), gomock.Eq(batch.ID)).Times(1).Return(items, nil)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp TransferBatchResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, db.TransferBatchFailed, resp.Status)
+				require.Len(t, resp.Items, 1)
+				require.Equal(t, db.ErrInsufficientFunds.Error(), resp.Items[0].Error)
+			},
+		},
+		{
+			Name:     "Admin",
+			Username: "admin_user",
+			BatchID:  batch.ID,
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq("admin_user")).AnyTimes().
+					Return(db.User{Username: "admin_user", Role: util.AdminRole, IsEmailVerified: true}, nil)
+				store.EXPECT().GetTransferBatch(gomock.Any(), gomock.Eq(batch.ID)).Times(1).Return(batch, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				store.EXPECT().ListTransferBatchItems(gomock.Any(), gomock.Eq(batch.ID)).Times(1).Return(items, nil)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name:     "NotOwner",
+			Username: "other_user",
+			BatchID:  batch.ID,
+			BuildStubs: func(store *mock.MockStore) {
+				stubAuthUser(store)
+				store.EXPECT().GetTransferBatch(gomock.Any(), gomock.Eq(batch.ID)).Times(1).Return(batch, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+				store.EXPECT().RecordAuditEventTx(gomock.Any(), auditEvent("other_user", db.AuditActionAuthorizationDenied)).Times(1).Return(nil, db.RecordAuditEventTxResult{})
+				store.EXPECT().ListTransferBatchItems(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			Name:     "NotFound",
+			Username: user.Username,
+			BatchID:  batch.ID,
+			BuildStubs: func(store *mock.MockStore) {
+				stubAuthUser(store)
+				store.EXPECT().GetTransferBatch(gomock.Any(), gomock.Eq(batch.ID)).Times(1).Return(db.TransferBatch{}, pgx.ErrNoRows)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			stubCurrencies(store)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/transfers/batch/%d", tc.BatchID)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			AddAuthorization(t, request, tc.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,471 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestTransferStepUp(t *testing.T) {
+	user, _ := RandomUser(t)
+	account1 := randomAccount(user)
+	account2 := randomAccount(user)
+	account2.Currency = account1.Currency
+
+	stepUp := util.StepUp{Threshold: "10.00", MaxAge: 5 * time.Minute}
+	// the threshold in minor units of the two decimal test currencies
+	threshold := int64(1000)
+
+	testCases := []struct {
+		Name          string
+		Amount        int64
+		SetupAuth     func(t *testing.T, request *http.Request)
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:   "below threshold",
+			Amount: threshold,
+			SetupAuth: func(t *testing.T, request *http.Request) {
+				AddAuthorization(t, request, user.Username, time.Minute)
+			},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(nil, db.TransferTxResult{FromAccount: account1, ToAccount: account2})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name:   "insufficient funds",
+			Amount: threshold,
+			SetupAuth: func(t *testing.T, request *http.Request) {
+				AddAuthorization(t, request, user.Username, time.Minute)
+			},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ErrInsufficientFunds, db.TransferTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			Name:   "recent authentication",
+			Amount: threshold + 1,
+			SetupAuth: func(t *testing.T, request *http.Request) {
+				AddAuthorization(t, request, user.Username, time.Minute,
+					util.WithAuthentication(time.Now().Add(-time.Minute), util.AMRPassword))
+			},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).Return(nil, db.TransferTxResult{FromAccount: account1, ToAccount: account2})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name:   "stale authentication",
+			Amount: threshold + 1,
+			SetupAuth: func(t *testing.T, request *http.Request) {
+				AddAuthorization(t, request, user.Username, time.Minute,
+					util.WithAuthentication(time.Now().Add(-time.Hour), util.AMRPassword))
+			},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Header().Get("WWW-Authenticate"), `error="insufficient_user_authentication"`)
+				require.Contains(t, recorder.Header().Get("WWW-Authenticate"), "max_age=300")
+
+				var resp ErrorResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, errCodeStepUpRequired, resp.Code)
+			},
+		},
+		{
+			Name:   "no auth_time",
+			Amount: threshold + 1,
+			SetupAuth: func(t *testing.T, request *http.Request) {
+				AddAuthorization(t, request, user.Username, time.Minute)
+			},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			stepUpConfig := util.Config.StepUp
+			util.Config.StepUp = stepUp
+			defer func() { util.Config.StepUp = stepUpConfig }()
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			stubAuthUser(store)
+			stubCurrencies(store)
+			stubHeldAmounts(store)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          util.FormatAmount(tc.Amount, 2),
+				"currency":        account1.Currency,
+			})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/transfer", bytes.NewReader(body))
+			require.NoError(t, err)
+			tc.SetupAuth(t, request)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestTransferAmount(t *testing.T) {
+	user, _ := RandomUser(t)
+	account1 := randomAccount(user)
+	account2 := randomAccount(user)
+	account2.Currency = account1.Currency
+	currency := util.Currency{Code: account1.Currency, Exponent: 2}
+
+	testCases := []struct {
+		Name          string
+		Amount        string
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:   "decimal",
+			Amount: "10.5",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account1.ID)).Times(1).Return(account1, nil)
+				store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(account2.ID)).Times(1).Return(account2, nil)
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.TransferTxParams) (error, db.TransferTxResult) {
+						require.Equal(t, util.NewMoney(1050, currency), arg.Amount)
+						return nil, db.TransferTxResult{
+							Transfer:    db.Transfer{FromAccountID: account1.ID, ToAccountID: account2.ID, Amount: arg.Amount.Amount},
+							FromAccount: account1,
+							ToAccount:   account2,
+							FromEntry:   db.Entry{AccountID: account1.ID, Amount: -arg.Amount.Amount},
+							ToEntry:     db.Entry{AccountID: account2.ID, Amount: arg.Amount.Amount},
+						}
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp TransferResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, util.NewMoney(1050, currency), resp.Transfer.Amount)
+				require.Equal(t, util.NewMoney(-1050, currency), resp.FromEntry.Amount)
+				require.Equal(t, util.NewMoney(1050, currency), resp.ToEntry.Amount)
+				require.Equal(t, util.NewMoney(account1.Balance, currency), resp.FromAccount.Balance)
+				require.Contains(t, recorder.Body.String(), `"amount":{"amount":"10.50","currency":"`+currency.Code+`"}`)
+			},
+		},
+		{
+			Name:   "too many decimal places",
+			Amount: "10.001",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name:   "zero",
+			Amount: "0.00",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			stubAuthUser(store)
+			stubCurrencies(store)
+			stubHeldAmounts(store)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{
+				"from_account_id": account1.ID,
+				"to_account_id":   account2.ID,
+				"amount":          tc.Amount,
+				"currency":        account1.Currency,
+			})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/transfer", bytes.NewReader(body))
+			require.NoError(t, err)
+			AddAuthorization(t, request, user.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestReverseTransfer(t *testing.T) {
+	sender, _ := RandomUser(t)
+	recipient, _ := RandomUser(t)
+	admin := db.User{Username: util.RandomOwner(), Role: util.AdminRole, IsEmailVerified: true}
+	depositor := func(user db.User) db.User {
+		return db.User{Username: user.Username, Role: util.DepositorRole, IsEmailVerified: true}
+	}
+
+	fromAccount := randomAccount(sender)
+	toAccount := randomAccount(recipient)
+	toAccount.ID = fromAccount.ID + 1
+	toAccount.Currency = fromAccount.Currency
+	currency := util.Currency{Code: fromAccount.Currency, Exponent: 2}
+	original := db.Transfer{ID: 42, FromAccountID: fromAccount.ID, ToAccountID: toAccount.ID, Amount: 1000}
+
+	reversed := func(amount int64) func(ctx context.Context, arg db.ReverseTransferTxParams) (error, db.ReverseTransferTxResult) {
+		return func(ctx context.Context, arg db.ReverseTransferTxParams) (error, db.ReverseTransferTxResult) {
+			require.Equal(t, original.ID, arg.TransferID)
+			require.Equal(t, util.NewMoney(amount, currency), arg.Amount)
+			require.Equal(t, "wrong recipient", arg.Reason)
+
+			result := db.ReverseTransferTxResult{
+				Reversal:       db.TransferReversal{ID: 1, OriginalTransferID: original.ID, TransferID: 43, Amount: amount, Reason: arg.Reason, InitiatedBy: arg.InitiatedBy},
+				ReversedAmount: amount,
+			}
+			result.Transfer = db.Transfer{ID: 43, FromAccountID: toAccount.ID, ToAccountID: fromAccount.ID, Amount: amount}
+			result.FromAccount = toAccount
+			result.ToAccount = fromAccount
+			return nil, result
+		}
+	}
+
+	testCases := []struct {
+		Name          string
+		User          db.User
+		Amount        string
+		FrozenSender  bool
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "recipient refunds the rest",
+			User: depositor(recipient),
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetReversedAmount(gomock.Any(), gomock.Eq(original.ID)).Times(1).Return(int64(300), nil)
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(reversed(700))
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				var resp ReverseTransferResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resp))
+				require.Equal(t, original.ID, resp.Reversal.OriginalTransferID)
+				require.Equal(t, util.NewMoney(700, currency), resp.Reversal.Amount)
+				require.Equal(t, toAccount.ID, resp.Transfer.Transfer.FromAccountID)
+				require.Equal(t, recipient.Username, resp.Reversal.InitiatedBy)
+			},
+		},
+		{
+			Name:   "partial",
+			User:   depositor(recipient),
+			Amount: "2.50",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(reversed(250))
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name:   "admin",
+			User:   admin,
+			Amount: "2.50",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(reversed(250))
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name:   "sender",
+			User:   depositor(sender),
+			Amount: "2.50",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().RecordAuditEventTx(gomock.Any(), auditEvent(sender.Username, db.AuditActionAuthorizationDenied)).Times(1).Return(nil, db.RecordAuditEventTxResult{})
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			Name:         "frozen sender",
+			User:         depositor(recipient),
+			Amount:       "2.50",
+			FrozenSender: true,
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "is frozen")
+			},
+		},
+		{
+			Name:         "admin with a frozen sender",
+			User:         admin,
+			Amount:       "2.50",
+			FrozenSender: true,
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(1).DoAndReturn(reversed(250))
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name:   "more than transferred",
+			User:   depositor(recipient),
+			Amount: "10.01",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(1).Return(db.ErrReversalExceedsTransfer, db.ReverseTransferTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+		{
+			Name: "already fully reversed",
+			User: depositor(recipient),
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetReversedAmount(gomock.Any(), gomock.Eq(original.ID)).Times(1).Return(original.Amount, nil)
+				store.EXPECT().ReverseTransferTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(tc.User.Username)).AnyTimes().Return(tc.User, nil)
+			stubCurrencies(store)
+			stubHeldAmounts(store)
+			store.EXPECT().GetTransfer(gomock.Any(), gomock.Eq(original.ID)).Times(1).Return(original, nil)
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(toAccount.ID)).Times(1).Return(toAccount, nil)
+			sender := fromAccount
+			sender.IsFrozen = tc.FrozenSender
+			store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).AnyTimes().Return(sender, nil)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(gin.H{"amount": tc.Amount, "reason": "wrong recipient"})
+			require.NoError(t, err)
+			url := fmt.Sprintf("/transfers/%d/reverse", original.ID)
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			require.NoError(t, err)
+			AddAuthorization(t, request, tc.User.Username, time.Minute)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestTransferToUsername(t *testing.T) {
+	sender, _ := RandomUser(t)
+	recipient, _ := RandomUser(t)
+	fromAccount := randomAccount(sender)
+	toAccount := randomAccount(recipient)
+	toAccount.ID = fromAccount.ID + 1
+	toAccount.Currency = fromAccount.Currency
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mock.NewMockStore(ctrl)
+	stubAuthUser(store)
+	stubCurrencies(store)
+	stubHeldAmounts(store)
+	store.EXPECT().GetAccount(gomock.Any(), gomock.Eq(fromAccount.ID)).Times(1).Return(fromAccount, nil)
+	store.EXPECT().GetAccountByOwnerCurrency(gomock.Any(), gomock.Eq(db.GetAccountByOwnerCurrencyParams{
+		Owner:    recipient.Username,
+		Currency: fromAccount.Currency,
+	})).Times(1).Return(toAccount, nil)
+	store.EXPECT().TransferTx(gomock.Any(), gomock.Any()).Times(1).
+		DoAndReturn(func(ctx context.Context, arg db.TransferTxParams) (error, db.TransferTxResult) {
+			require.Equal(t, fromAccount.ID, arg.FromAccountID)
+			require.Equal(t, toAccount.ID, arg.ToAccountID)
+			return nil, db.TransferTxResult{FromAccount: fromAccount, ToAccount: toAccount}
+		})
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+
+	body, err := json.Marshal(gin.H{
+		"from_account_id": fromAccount.ID,
+		"to_username":     recipient.Username,
+		"amount":          "1.00",
+		"currency":        fromAccount.Currency,
+	})
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "/transfer", bytes.NewReader(body))
+	require.NoError(t, err)
+	AddAuthorization(t, request, sender.Username, time.Minute)
+
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+}
@@ -7,8 +7,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	db "github.com/jxgzzztang/simplebank/db/sqlc"
 	"github.com/jxgzzztang/simplebank/util"
+	"github.com/jxgzzztang/simplebank/worker"
 )
 
 type CreateUserRequest struct {
@@ -24,6 +26,7 @@ type UserInfoResponse struct {
 	Email    string `json:"email"`
 	CreateAt time.Time `json:"create_at"`
 	PasswordChangedAt time.Time `json:"password_change_at"`
+	IsEmailVerified bool `json:"is_email_verified"`
 }
 
 func CreateUserInfoResponse(user db.User) UserInfoResponse {
@@ -33,6 +36,7 @@ func CreateUserInfoResponse(user db.User) UserInfoResponse {
 		Email:    user.Email,
 		CreateAt: user.CreatedAt.Time,
 		PasswordChangedAt: user.PasswordChangedAt.Time,
+		IsEmailVerified: user.IsEmailVerified,
 	}
 }
 
@@ -54,7 +58,7 @@ func (server *Server) CreateUser(ctx *gin.Context)  {
 		return
 	}
 
-	hashPassword, err := util.HashPassword(reqParams.Password)
+	hashedPassword, err := hashPassword(ctx, reqParams.Password)
 
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
@@ -63,12 +67,21 @@ func (server *Server) CreateUser(ctx *gin.Context)  {
 
 	userArg := db.CreateUserParams{
 		Username: reqParams.Username,
-		HashedPassword: hashPassword,
+		HashedPassword: hashedPassword,
 		FullName: reqParams.FullName,
 		Email: reqParams.Email,
+		Role: util.DepositorRole,
 	}
 
-	user, err := server.store.CreateUser(ctx, userArg)
+	err, result := server.store.CreateUserTx(ctx, db.CreateUserTxParams{
+		CreateUserParams: userArg,
+		AfterCreate: func(q db.Querier, result db.CreateUserTxResult) error {
+			return server.taskDistributor.DistributeTaskSendVerifyEmail(ctx, q, &worker.PayloadSendVerifyEmail{
+				Username:      result.User.Username,
+				VerifyEmailID: result.VerifyEmail.ID,
+			})
+		},
+	})
 	if err != nil {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
@@ -79,6 +92,112 @@ func (server *Server) CreateUser(ctx *gin.Context)  {
 		return
 	}
 
-	resp := CreateUserInfoResponse(user)
+	resp := CreateUserInfoResponse(result.User)
 	ctx.JSON(http.StatusOK, resp)
-}
\ No newline at end of file
+}
+type UpdateUserRequest struct {
+	FullName *string `json:"full_name" binding:"omitempty,min=1"`
+	Email    *string `json:"email" binding:"omitempty,email"`
+}
+
+// UpdateUser godoc
+// @Summary      UpdateUser
+// @Description  update the full name or email of the current user, a new email has to be verified again
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param updateUserData body UpdateUserRequest true "fields to update"
+// @Success      200  {object} 	api.UserInfoResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      403  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /users/me [patch]
+func (server *Server) UpdateUser(ctx *gin.Context) {
+	var req UpdateUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user := ctx.MustGet(authorizationUserKey).(db.User)
+
+	arg := db.UpdateUserParams{
+		Username: user.Username,
+	}
+	if req.FullName != nil {
+		arg.FullName = pgtype.Text{String: *req.FullName, Valid: true}
+	}
+	if req.Email != nil {
+		arg.Email = pgtype.Text{String: *req.Email, Valid: true}
+	}
+
+	err, result := server.store.UpdateUserTx(ctx, db.UpdateUserTxParams{
+		UpdateUserParams: arg,
+		AfterUpdate: func(q db.Querier, result db.UpdateUserTxResult) error {
+			return server.taskDistributor.DistributeTaskSendVerifyEmail(ctx, q, &worker.PayloadSendVerifyEmail{
+				Username:      result.User.Username,
+				VerifyEmailID: result.VerifyEmail.ID,
+			})
+		},
+	})
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			ctx.JSON(http.StatusForbidden, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, CreateUserInfoResponse(result.User))
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// ChangePassword godoc
+// @Summary      ChangePassword
+// @Description  change the password of the current user, every token issued before is revoked
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param changePasswordData body ChangePasswordRequest true "current and new password"
+// @Success      200  {object} 	api.UserInfoResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      401  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /users/me/password [post]
+func (server *Server) ChangePassword(ctx *gin.Context) {
+	var req ChangePasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	user := ctx.MustGet(authorizationUserKey).(db.User)
+
+	if err := checkPassword(ctx, req.CurrentPassword, user.HashedPassword); err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("current password is incorrect")))
+		return
+	}
+
+	hashedPassword, err := hashPassword(ctx, req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err, result := server.store.ChangePasswordTx(ctx, db.ChangePasswordTxParams{
+		Username:       user.Username,
+		HashedPassword: hashedPassword,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, CreateUserInfoResponse(result.User))
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -10,11 +11,14 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jxgzzztang/simplebank/db/mock"
 	db "github.com/jxgzzztang/simplebank/db/sqlc"
 	"github.com/jxgzzztang/simplebank/util"
+	"github.com/jxgzzztang/simplebank/worker"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
@@ -31,6 +35,8 @@ func RandomUser(t *testing.T) (user db.User, password string) {
 		FullName: util.RandomOwner(),
 		Email:    util.RandomEmail(),
 		HashedPassword: hashPassword,
+		Role: util.DepositorRole,
+		IsEmailVerified: true,
 	}
 	return user, password
 }
@@ -41,10 +47,11 @@ type CreateUserParamsPwdMatcher struct {
 }
 
 func (c CreateUserParamsPwdMatcher) Matches (x interface{}) bool {
-	arg, ok := x.(db.CreateUserParams)
+	txArg, ok := x.(db.CreateUserTxParams)
 	if !ok {
 		return false
 	}
+	arg := txArg.CreateUserParams
 
 	err := util.CheckPassword(c.password, arg.HashedPassword)
 	if err != nil {
@@ -87,8 +94,18 @@ func TestCreateUser(t *testing.T) {
 					Username: user.Username,
 					FullName: user.FullName,
 					Email: user.Email,
+					Role: util.DepositorRole,
 				}
-				store.EXPECT().CreateUser(gomock.Any(), CheckPasswordMatcher(arg, password)).Times(1).Return(user, nil)
+				store.EXPECT().CreateUserTx(gomock.Any(), CheckPasswordMatcher(arg, password)).Times(1).
+					DoAndReturn(func(ctx context.Context, txArg db.CreateUserTxParams) (error, db.CreateUserTxResult) {
+						result := db.CreateUserTxResult{User: user}
+						return txArg.AfterCreate(store, result), result
+					})
+				store.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, taskArg db.CreateTaskParams) (db.Task, error) {
+						require.Equal(t, worker.TaskSendVerifyEmail, taskArg.Type)
+						return db.Task{}, nil
+					})
 			},
 			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusOK, response.Code)
@@ -104,7 +121,7 @@ func TestCreateUser(t *testing.T) {
 				"password": password,
 			},
 			BuildStubs: func(store *mock.MockStore) {
-				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateUserTx(gomock.Any(), gomock.Any()).Times(0)
 			},
 			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, response.Code)
@@ -119,7 +136,7 @@ func TestCreateUser(t *testing.T) {
 				"password": password,
 			},
 			BuildStubs: func(store *mock.MockStore) {
-				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateUserTx(gomock.Any(), gomock.Any()).Times(0)
 			},
 			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, response.Code)
@@ -134,7 +151,7 @@ func TestCreateUser(t *testing.T) {
 				"password": "12345",
 			},
 			BuildStubs: func(store *mock.MockStore) {
-				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Times(0)
+				store.EXPECT().CreateUserTx(gomock.Any(), gomock.Any()).Times(0)
 			},
 			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusBadRequest, response.Code)
@@ -149,7 +166,7 @@ func TestCreateUser(t *testing.T) {
 				"password": password,
 			},
 			BuildStubs: func(store *mock.MockStore) {
-				store.EXPECT().CreateUser(gomock.Any(),gomock.Any()).Times(1).Return(db.User{}, sql.ErrConnDone)
+				store.EXPECT().CreateUserTx(gomock.Any(),gomock.Any()).Times(1).Return(sql.ErrConnDone, db.CreateUserTxResult{})
 			},
 			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
 				require.Equal(t, http.StatusInternalServerError, response.Code)
@@ -166,7 +183,7 @@ func TestCreateUser(t *testing.T) {
 			mockStore := mock.NewMockStore(controller)
 			tc.BuildStubs(mockStore)
 
-			server := NewServer(mockStore)
+			server := newTestServer(t, mockStore)
 			recorder := httptest.NewRecorder()
 
 			body, err := json.Marshal(tc.Body)
@@ -193,4 +210,190 @@ func requireMatchCreateUserRequestBody(t *testing.T, body *bytes.Buffer, user db
     require.Equal(t, user.FullName, actual.FullName)
     require.Equal(t, user.Email, actual.Email)
     require.Empty(t, actual.HashedPassword)
-}
\ No newline at end of file
+}
+
+func TestUpdateUser(t *testing.T) {
+	user, _ := RandomUser(t)
+	newName := util.RandomOwner()
+	newEmail := util.RandomEmail()
+
+	testCases := []struct {
+		Name          string
+		Body          gin.H
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, response *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "update full name",
+			Body: gin.H{"full_name": newName},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().UpdateUserTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, txArg db.UpdateUserTxParams) (error, db.UpdateUserTxResult) {
+						require.Equal(t, user.Username, txArg.Username)
+						require.Equal(t, pgtype.Text{String: newName, Valid: true}, txArg.FullName)
+						require.False(t, txArg.Email.Valid)
+						updated := user
+						updated.FullName = newName
+						return nil, db.UpdateUserTxResult{User: updated}
+					})
+				store.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, response.Code)
+				updated := user
+				updated.FullName = newName
+				requireMatchCreateUserRequestBody(t, response.Body, updated)
+			},
+		},
+		{
+			Name: "update email",
+			Body: gin.H{"email": newEmail},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().UpdateUserTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, txArg db.UpdateUserTxParams) (error, db.UpdateUserTxResult) {
+						require.False(t, txArg.FullName.Valid)
+						require.Equal(t, pgtype.Text{String: newEmail, Valid: true}, txArg.Email)
+						updated := user
+						updated.Email = newEmail
+						updated.IsEmailVerified = false
+						result := db.UpdateUserTxResult{User: updated}
+						return txArg.AfterUpdate(store, result), result
+					})
+				store.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, taskArg db.CreateTaskParams) (db.Task, error) {
+						require.Equal(t, worker.TaskSendVerifyEmail, taskArg.Type)
+						return db.Task{}, nil
+					})
+			},
+			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, response.Code)
+			},
+		},
+		{
+			Name: "bad request - invalid email",
+			Body: gin.H{"email": "invalid"},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().UpdateUserTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, response.Code)
+			},
+		},
+		{
+			Name: "internal server error",
+			Body: gin.H{"full_name": newName},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().UpdateUserTx(gomock.Any(), gomock.Any()).Times(1).Return(sql.ErrConnDone, db.UpdateUserTxResult{})
+			},
+			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, response.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			mockStore := mock.NewMockStore(controller)
+			mockStore.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).AnyTimes().Return(user, nil)
+			tc.BuildStubs(mockStore)
+
+			server := newTestServer(t, mockStore)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.Body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPatch, "/users/me", bytes.NewReader(body))
+			require.NoError(t, err)
+			AddAuthorization(t, request, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	user, password := RandomUser(t)
+	newPassword := util.RandomString(8)
+
+	testCases := []struct {
+		Name          string
+		Body          gin.H
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, response *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "ok",
+			Body: gin.H{"current_password": password, "new_password": newPassword},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ChangePasswordTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, txArg db.ChangePasswordTxParams) (error, db.ChangePasswordTxResult) {
+						require.Equal(t, user.Username, txArg.Username)
+						require.NoError(t, util.CheckPassword(newPassword, txArg.HashedPassword))
+						return nil, db.ChangePasswordTxResult{User: user}
+					})
+			},
+			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, response.Code)
+			},
+		},
+		{
+			Name: "wrong current password",
+			Body: gin.H{"current_password": "wrong-password", "new_password": newPassword},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ChangePasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, response.Code)
+			},
+		},
+		{
+			Name: "bad request - short password",
+			Body: gin.H{"current_password": password, "new_password": "12345"},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ChangePasswordTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, response.Code)
+			},
+		},
+		{
+			Name: "internal server error",
+			Body: gin.H{"current_password": password, "new_password": newPassword},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().ChangePasswordTx(gomock.Any(), gomock.Any()).Times(1).Return(sql.ErrConnDone, db.ChangePasswordTxResult{})
+			},
+			CheckResponse: func(t *testing.T, response *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, response.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			mockStore := mock.NewMockStore(controller)
+			mockStore.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).AnyTimes().Return(user, nil)
+			tc.BuildStubs(mockStore)
+
+			server := newTestServer(t, mockStore)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.Body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/users/me/password", bytes.NewReader(body))
+			require.NoError(t, err)
+			AddAuthorization(t, request, user.Username, time.Minute)
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
@@ -1,16 +1,35 @@
 package api
 
 import (
+	"context"
+	"log/slog"
+	"regexp"
+
 	"github.com/go-playground/validator/v10"
-	"github.com/jxgzzztang/simplebank/util"
 )
 
-var currencyValidate validator.Func = func(fl validator.FieldLevel) bool {
-	currency, ok := fl.Field().Interface().(string)
+// currencyValidate accepts the codes of enabled currencies. Validators do not
+// see the request, so the registry is read without its context.
+func (server *Server) currencyValidate(fl validator.FieldLevel) bool {
+	code, ok := fl.Field().Interface().(string)
 
 	if !ok {
 		return false
 	}
 
-	return util.IsValidCurrency(currency)
+	currency, ok, err := server.currencies.lookup(context.Background(), code)
+	if err != nil {
+		slog.Error("cannot load currencies", "error", err)
+		return false
+	}
+	return ok && currency.Enabled
+}
+
+var aliasPattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+
+// aliasValidate accepts aliases of 3 to 30 lowercase letters, digits and
+// underscores, the same rule as the user_aliases table.
+func aliasValidate(fl validator.FieldLevel) bool {
+	alias, ok := fl.Field().Interface().(string)
+	return ok && aliasPattern.MatchString(alias)
 }
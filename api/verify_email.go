@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/worker"
+)
+
+type VerifyEmailRequest struct {
+	EmailID    int64  `form:"email_id" binding:"required,min=1"`
+	SecretCode string `form:"secret_code" binding:"required,len=32"`
+}
+
+type VerifyEmailResponse struct {
+	IsVerified bool `json:"is_verified"`
+}
+
+// VerifyEmail godoc
+// @Summary      VerifyEmail
+// @Description  verify the email address of a user with the code sent to it
+// @Tags         users
+// @Produce      json
+// @Param 		email_id  query  int true "verify email id"
+// @Param 		secret_code  query  string true "secret code"
+// @Success      200  {object} 	api.VerifyEmailResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /verify_email [get]
+func (server *Server) VerifyEmail(ctx *gin.Context) {
+	var req VerifyEmailRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	err, result := server.store.VerifyEmailTx(ctx, db.VerifyEmailTxParams{
+		EmailID:    req.EmailID,
+		SecretCode: req.SecretCode,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid or expired verification code")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, VerifyEmailResponse{
+		IsVerified: result.User.IsEmailVerified,
+	})
+}
+
+type ResendVerifyEmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResendVerifyEmailResponse struct {
+	Message string `json:"message"`
+}
+
+// ResendVerifyEmail godoc
+// @Summary      ResendVerifyEmail
+// @Description  email a new verification code to an address that is not verified yet
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Param resendVerifyEmailData body ResendVerifyEmailRequest true "email"
+// @Success      200  {object} 	api.ResendVerifyEmailResponse
+// @Failure      400  {object}  api.ErrorResponse
+// @Failure      500  {object}  api.ErrorResponse
+// @Router       /verify_email/resend [post]
+func (server *Server) ResendVerifyEmail(ctx *gin.Context) {
+	var req ResendVerifyEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// like ForgotPassword, the response does not tell whether the email is
+	// registered or already verified
+	resp := ResendVerifyEmailResponse{
+		Message: "if the email belongs to an unverified account, a verification link has been sent to it",
+	}
+
+	user, err := server.store.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			ctx.JSON(http.StatusOK, resp)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if user.IsEmailVerified {
+		ctx.JSON(http.StatusOK, resp)
+		return
+	}
+
+	err, _ = server.store.ResendVerifyEmailTx(ctx, db.ResendVerifyEmailTxParams{
+		Username: user.Username,
+		Email:    user.Email,
+		AfterCreate: func(q db.Querier, result db.ResendVerifyEmailTxResult) error {
+			return server.taskDistributor.DistributeTaskSendVerifyEmail(ctx, q, &worker.PayloadSendVerifyEmail{
+				Username:      user.Username,
+				VerifyEmailID: result.VerifyEmail.ID,
+			})
+		},
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/jxgzzztang/simplebank/worker"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	user, _ := RandomUser(t)
+	emailID := util.RandomInt(1, 1000)
+	secretCode := util.RandomString(32)
+
+	testCases := []struct {
+		Name          string
+		EmailID       int64
+		SecretCode    string
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name:       "ok",
+			EmailID:    emailID,
+			SecretCode: secretCode,
+			BuildStubs: func(store *mock.MockStore) {
+				arg := db.VerifyEmailTxParams{
+					EmailID:    emailID,
+					SecretCode: secretCode,
+				}
+				store.EXPECT().VerifyEmailTx(gomock.Any(), gomock.Eq(arg)).Times(1).Return(nil, db.VerifyEmailTxResult{User: user})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, `{"is_verified": true}`, recorder.Body.String())
+			},
+		},
+		{
+			Name:       "invalid code",
+			EmailID:    emailID,
+			SecretCode: secretCode,
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().VerifyEmailTx(gomock.Any(), gomock.Any()).Times(1).Return(pgx.ErrNoRows, db.VerifyEmailTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name:       "short code",
+			EmailID:    emailID,
+			SecretCode: "abc",
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().VerifyEmailTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name:       "internal server error",
+			EmailID:    emailID,
+			SecretCode: secretCode,
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().VerifyEmailTx(gomock.Any(), gomock.Any()).Times(1).Return(pgx.ErrTxClosed, db.VerifyEmailTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			url := fmt.Sprintf("/verify_email?email_id=%d&secret_code=%s", tc.EmailID, tc.SecretCode)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
+
+func TestResendVerifyEmail(t *testing.T) {
+	user, _ := RandomUser(t)
+	user.IsEmailVerified = false
+	verified, _ := RandomUser(t)
+
+	testCases := []struct {
+		Name          string
+		Body          gin.H
+		BuildStubs    func(store *mock.MockStore)
+		CheckResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			Name: "ok",
+			Body: gin.H{"email": user.Email},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).Times(1).Return(user, nil)
+				store.EXPECT().ResendVerifyEmailTx(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.ResendVerifyEmailTxParams) (error, db.ResendVerifyEmailTxResult) {
+						require.Equal(t, user.Username, arg.Username)
+						require.Equal(t, user.Email, arg.Email)
+						result := db.ResendVerifyEmailTxResult{VerifyEmail: db.VerifyEmail{ID: 7, Username: user.Username}}
+						return arg.AfterCreate(store, result), result
+					})
+				store.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateTaskParams) (db.Task, error) {
+						require.Equal(t, worker.TaskSendVerifyEmail, arg.Type)
+						var payload worker.PayloadSendVerifyEmail
+						require.NoError(t, json.Unmarshal(arg.Payload, &payload))
+						require.Equal(t, int64(7), payload.VerifyEmailID)
+						return db.Task{}, nil
+					})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name: "already verified",
+			Body: gin.H{"email": verified.Email},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(verified.Email)).Times(1).Return(verified, nil)
+				store.EXPECT().ResendVerifyEmailTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name: "unknown email",
+			Body: gin.H{"email": user.Email},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Eq(user.Email)).Times(1).Return(db.User{}, pgx.ErrNoRows)
+				store.EXPECT().ResendVerifyEmailTx(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			Name: "invalid email",
+			Body: gin.H{"email": "invalid"},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(0)
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			Name: "internal server error",
+			Body: gin.H{"email": user.Email},
+			BuildStubs: func(store *mock.MockStore) {
+				store.EXPECT().GetUserByEmail(gomock.Any(), gomock.Any()).Times(1).Return(user, nil)
+				store.EXPECT().ResendVerifyEmailTx(gomock.Any(), gomock.Any()).Times(1).Return(pgx.ErrTxClosed, db.ResendVerifyEmailTxResult{})
+			},
+			CheckResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mock.NewMockStore(ctrl)
+			tc.BuildStubs(store)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			body, err := json.Marshal(tc.Body)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "/verify_email/resend", bytes.NewReader(body))
+			require.NoError(t, err)
+
+			server.router.ServeHTTP(recorder, request)
+			tc.CheckResponse(t, recorder)
+		})
+	}
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "admin_adjustments";
+
+DROP INDEX IF EXISTS "sessions_username_idx";
+
+ALTER TABLE IF EXISTS "accounts" DROP COLUMN IF EXISTS "is_frozen";
+
+ALTER TABLE IF EXISTS "users" DROP COLUMN IF EXISTS "role";
@@ -0,0 +1,15 @@
+ALTER TABLE "users" ADD COLUMN "role" varchar NOT NULL DEFAULT 'depositor';
+
+ALTER TABLE "accounts" ADD COLUMN "is_frozen" boolean NOT NULL DEFAULT false;
+
+CREATE TABLE "admin_adjustments" (
+    "id" bigserial PRIMARY KEY,
+    "transfer_id" bigint NOT NULL,
+    "operator" varchar NOT NULL,
+    "reason" varchar NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "sessions" ("username");
+
+ALTER TABLE "admin_adjustments" ADD FOREIGN KEY ("transfer_id") REFERENCES "transfers" ("id");
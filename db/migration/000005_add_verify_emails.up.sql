@@ -0,0 +1,18 @@
+ALTER TABLE "users" ADD COLUMN "is_email_verified" boolean NOT NULL DEFAULT false;
+
+-- users from before verification was required keep being able to log in
+UPDATE "users" SET "is_email_verified" = true;
+
+CREATE TABLE "verify_emails" (
+    "id" bigserial PRIMARY KEY,
+    "username" varchar NOT NULL,
+    "email" varchar NOT NULL,
+    "secret_code" varchar NOT NULL,
+    "is_used" boolean NOT NULL DEFAULT false,
+    "created_at" timestamptz NOT NULL DEFAULT (now()),
+    "expired_at" timestamptz NOT NULL DEFAULT (now() + interval '15 minutes')
+);
+
+CREATE INDEX ON "verify_emails" ("username");
+
+ALTER TABLE "verify_emails" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
@@ -0,0 +1,17 @@
+CREATE TABLE "tasks" (
+    "id" bigserial PRIMARY KEY,
+    "type" varchar NOT NULL,
+    "payload" jsonb NOT NULL,
+    "status" varchar NOT NULL DEFAULT 'pending',
+    "attempts" integer NOT NULL DEFAULT 0,
+    "max_attempts" integer NOT NULL,
+    "last_error" varchar NOT NULL DEFAULT '',
+    "scheduled_at" timestamptz NOT NULL DEFAULT (now()),
+    "locked_until" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT (now()),
+    "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "tasks" ("status", "scheduled_at");
+
+COMMENT ON COLUMN "tasks"."status" IS 'pending, running, done or dead';
@@ -0,0 +1,14 @@
+CREATE TABLE "password_resets" (
+    "id" bigserial PRIMARY KEY,
+    "username" varchar NOT NULL,
+    "token_hash" varchar UNIQUE NOT NULL,
+    "is_used" boolean NOT NULL DEFAULT false,
+    "created_at" timestamptz NOT NULL DEFAULT (now()),
+    "expired_at" timestamptz NOT NULL
+);
+
+CREATE INDEX ON "password_resets" ("username");
+
+COMMENT ON COLUMN "password_resets"."token_hash" IS 'sha256 of the token sent to the user';
+
+ALTER TABLE "password_resets" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
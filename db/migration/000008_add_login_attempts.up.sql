@@ -0,0 +1,16 @@
+CREATE TABLE "login_attempts" (
+    "id" bigserial PRIMARY KEY,
+    "username" varchar NOT NULL,
+    "client_ip" varchar NOT NULL,
+    "user_agent" varchar NOT NULL,
+    "outcome" varchar NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "login_attempts" ("username", "created_at");
+
+CREATE INDEX ON "login_attempts" ("client_ip", "created_at");
+
+COMMENT ON COLUMN "login_attempts"."username" IS 'as submitted, the user may not exist';
+
+COMMENT ON COLUMN "login_attempts"."outcome" IS 'success, invalid_credentials or locked';
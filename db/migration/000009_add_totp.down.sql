@@ -0,0 +1,5 @@
+COMMENT ON COLUMN "login_attempts"."outcome" IS 'success, invalid_credentials or locked';
+
+DROP TABLE IF EXISTS "recovery_codes";
+
+DROP TABLE IF EXISTS "user_totps";
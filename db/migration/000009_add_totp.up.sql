@@ -0,0 +1,29 @@
+CREATE TABLE "user_totps" (
+    "username" varchar PRIMARY KEY,
+    "secret" varchar NOT NULL,
+    "last_used_step" bigint NOT NULL DEFAULT 0,
+    "confirmed_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+COMMENT ON COLUMN "user_totps"."last_used_step" IS 'time step of the last accepted code, codes are single use';
+
+COMMENT ON COLUMN "user_totps"."confirmed_at" IS 'two-factor login is enforced once set';
+
+ALTER TABLE "user_totps" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+CREATE TABLE "recovery_codes" (
+    "id" bigserial PRIMARY KEY,
+    "username" varchar NOT NULL,
+    "code_hash" varchar NOT NULL,
+    "used_at" timestamptz,
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE UNIQUE INDEX ON "recovery_codes" ("username", "code_hash");
+
+COMMENT ON COLUMN "recovery_codes"."code_hash" IS 'sha256 of the normalized code';
+
+ALTER TABLE "recovery_codes" ADD FOREIGN KEY ("username") REFERENCES "users" ("username");
+
+COMMENT ON COLUMN "login_attempts"."outcome" IS 'success, invalid_credentials, mfa_challenge or locked';
@@ -0,0 +1,12 @@
+CREATE UNLOGGED TABLE "rate_limit_buckets" (
+    "key" varchar PRIMARY KEY,
+    "tokens" double precision NOT NULL,
+    "allowed" boolean NOT NULL,
+    "rate" double precision NOT NULL,
+    "burst" double precision NOT NULL,
+    "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+COMMENT ON COLUMN "rate_limit_buckets"."allowed" IS 'whether the last request took a token';
+
+COMMENT ON COLUMN "rate_limit_buckets"."rate" IS 'tokens added per second';
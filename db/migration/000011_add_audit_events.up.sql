@@ -0,0 +1,42 @@
+CREATE TABLE "audit_events" (
+    "id" bigserial PRIMARY KEY,
+    "actor" varchar NOT NULL,
+    "action" varchar NOT NULL,
+    "target" varchar NOT NULL,
+    "client_ip" varchar NOT NULL,
+    "user_agent" varchar NOT NULL,
+    "request_id" varchar NOT NULL,
+    "before" json,
+    "after" json,
+    "prev_hash" varchar NOT NULL,
+    "hash" varchar UNIQUE NOT NULL,
+    "created_at" timestamptz NOT NULL
+);
+
+CREATE INDEX ON "audit_events" ("actor");
+
+CREATE INDEX ON "audit_events" ("action");
+
+CREATE INDEX ON "audit_events" ("target");
+
+COMMENT ON COLUMN "audit_events"."actor" IS 'username or operator, empty when unauthenticated';
+
+COMMENT ON COLUMN "audit_events"."before" IS 'json rather than jsonb so the hashed text is kept as written';
+
+COMMENT ON COLUMN "audit_events"."prev_hash" IS 'hash of the previous event, empty for the first one';
+
+COMMENT ON COLUMN "audit_events"."hash" IS 'sha256 over prev_hash and the fields of this event';
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update
+    BEFORE UPDATE OR DELETE ON "audit_events"
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON "audit_events"
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
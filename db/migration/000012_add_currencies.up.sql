@@ -0,0 +1,25 @@
+CREATE TABLE "currencies" (
+    "code" varchar(3) PRIMARY KEY CHECK ("code" ~ '^[A-Z]{3}$'),
+    "exponent" smallint NOT NULL CHECK ("exponent" BETWEEN 0 AND 4),
+    "enabled" boolean NOT NULL DEFAULT true,
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+COMMENT ON COLUMN "currencies"."code" IS 'ISO 4217 alphabetic code';
+
+COMMENT ON COLUMN "currencies"."exponent" IS 'digits after the decimal separator, amounts are stored in minor units';
+
+COMMENT ON COLUMN "currencies"."enabled" IS 'whether new accounts and transfers may use the currency';
+
+INSERT INTO "currencies" ("code", "exponent") VALUES
+    ('USD', 2),
+    ('EUR', 2),
+    ('CNY', 2);
+
+-- accounts in any other currency were opened outside the api, keep them
+-- valid without making the currency available
+INSERT INTO "currencies" ("code", "exponent", "enabled")
+SELECT DISTINCT "currency", 2, false FROM "accounts"
+ON CONFLICT ("code") DO NOTHING;
+
+ALTER TABLE "accounts" ADD CONSTRAINT "accounts_currency_fkey" FOREIGN KEY ("currency") REFERENCES "currencies" ("code");
@@ -0,0 +1,23 @@
+CREATE TABLE "holds" (
+    "id" bigserial PRIMARY KEY,
+    "account_id" bigint NOT NULL REFERENCES "accounts" ("id"),
+    "amount" bigint NOT NULL CHECK ("amount" > 0),
+    "reference" varchar NOT NULL,
+    "status" varchar NOT NULL DEFAULT 'active' CHECK ("status" IN ('active', 'captured', 'released', 'expired')),
+    "captured_amount" bigint NOT NULL DEFAULT 0,
+    "transfer_id" bigint REFERENCES "transfers" ("id"),
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT (now()),
+    "closed_at" timestamptz,
+    CONSTRAINT "holds_account_id_reference_key" UNIQUE ("account_id", "reference")
+);
+
+CREATE INDEX ON "holds" ("account_id") WHERE "status" = 'active';
+
+CREATE INDEX ON "holds" ("expires_at") WHERE "status" = 'active';
+
+COMMENT ON COLUMN "holds"."reference" IS 'chosen by the client, unique per account so placing a hold can be retried';
+
+COMMENT ON COLUMN "holds"."captured_amount" IS 'part of amount moved by the capture transfer, the rest was released';
+
+COMMENT ON COLUMN "holds"."expires_at" IS 'an active hold stops reserving funds once expired, even before it is swept';
@@ -0,0 +1,13 @@
+CREATE TABLE "transfer_reversals" (
+    "id" bigserial PRIMARY KEY,
+    "original_transfer_id" bigint NOT NULL REFERENCES "transfers" ("id"),
+    "transfer_id" bigint UNIQUE NOT NULL REFERENCES "transfers" ("id"),
+    "amount" bigint NOT NULL CHECK ("amount" > 0),
+    "reason" varchar NOT NULL,
+    "initiated_by" varchar NOT NULL REFERENCES "users" ("username"),
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "transfer_reversals" ("original_transfer_id");
+
+COMMENT ON COLUMN "transfer_reversals"."transfer_id" IS 'compensating transfer from the recipient back to the sender';
@@ -0,0 +1,7 @@
+CREATE TABLE "user_aliases" (
+    "alias" varchar PRIMARY KEY CHECK ("alias" ~ '^[a-z0-9_]{3,30}$'),
+    "username" varchar UNIQUE NOT NULL REFERENCES "users" ("username"),
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+COMMENT ON COLUMN "user_aliases"."alias" IS 'handle other users can send money to, lowercase';
@@ -0,0 +1,22 @@
+CREATE TABLE "payment_requests" (
+    "id" bigserial PRIMARY KEY,
+    "requester" varchar NOT NULL REFERENCES "users" ("username"),
+    "payer" varchar NOT NULL REFERENCES "users" ("username"),
+    "amount" bigint NOT NULL CHECK ("amount" > 0),
+    "currency" varchar NOT NULL REFERENCES "currencies" ("code"),
+    "note" varchar NOT NULL DEFAULT '',
+    "status" varchar NOT NULL DEFAULT 'pending' CHECK ("status" IN ('pending', 'accepted', 'declined', 'expired')),
+    "transfer_id" bigint REFERENCES "transfers" ("id"),
+    "expires_at" timestamptz NOT NULL,
+    "created_at" timestamptz NOT NULL DEFAULT (now()),
+    "resolved_at" timestamptz,
+    CHECK ("requester" <> "payer")
+);
+
+CREATE INDEX ON "payment_requests" ("payer", "id") WHERE "status" = 'pending';
+
+CREATE INDEX ON "payment_requests" ("requester", "id");
+
+CREATE INDEX ON "payment_requests" ("expires_at") WHERE "status" = 'pending';
+
+COMMENT ON COLUMN "payment_requests"."transfer_id" IS 'transfer paying the request once accepted';
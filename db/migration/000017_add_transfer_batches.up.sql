@@ -0,0 +1,27 @@
+CREATE TABLE "transfer_batches" (
+    "id" bigserial PRIMARY KEY,
+    "from_account_id" bigint NOT NULL REFERENCES "accounts" ("id"),
+    "mode" varchar NOT NULL CHECK ("mode" IN ('atomic', 'best_effort')),
+    "status" varchar NOT NULL CHECK ("status" IN ('completed', 'partial', 'failed')),
+    "initiated_by" varchar NOT NULL REFERENCES "users" ("username"),
+    "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "transfer_batches" ("from_account_id");
+
+CREATE TABLE "transfer_batch_items" (
+    "batch_id" bigint NOT NULL REFERENCES "transfer_batches" ("id"),
+    "position" int NOT NULL,
+    "to_account_id" bigint NOT NULL REFERENCES "accounts" ("id"),
+    "amount" bigint NOT NULL CHECK ("amount" > 0),
+    "status" varchar NOT NULL CHECK ("status" IN ('succeeded', 'failed')),
+    "transfer_id" bigint UNIQUE REFERENCES "transfers" ("id"),
+    "error" varchar NOT NULL DEFAULT '',
+    PRIMARY KEY ("batch_id", "position")
+);
+
+COMMENT ON COLUMN "transfer_batches"."status" IS 'completed when every item succeeded, failed when none did';
+
+COMMENT ON COLUMN "transfer_batch_items"."position" IS 'index of the item in the submitted batch';
+
+COMMENT ON COLUMN "transfer_batch_items"."error" IS 'why the item failed, empty when it succeeded';
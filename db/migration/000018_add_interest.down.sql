@@ -0,0 +1,35 @@
+DROP TABLE IF EXISTS "interest_postings";
+DROP TABLE IF EXISTS "interest_accruals";
+
+-- only one account per owner and currency is allowed again, so a savings
+-- account next to a checking account is merged into it: its entries,
+-- transfers, holds and batches move over together with its balance. Transfers
+-- between the two become transfers from the account to itself. Savings
+-- accounts without a checking account simply become checking accounts.
+CREATE VIEW "merged_savings" AS
+SELECT s."id" AS "savings_id", c."id" AS "checking_id", s."balance"
+FROM "accounts" s
+JOIN "accounts" c ON c."owner" = s."owner" AND c."currency" = s."currency" AND c."type" = 'checking'
+WHERE s."type" = 'savings';
+
+UPDATE "entries" SET "account_id" = m."checking_id"
+FROM "merged_savings" m WHERE "entries"."account_id" = m."savings_id";
+UPDATE "transfers" SET "from_account_id" = m."checking_id"
+FROM "merged_savings" m WHERE "transfers"."from_account_id" = m."savings_id";
+UPDATE "transfers" SET "to_account_id" = m."checking_id"
+FROM "merged_savings" m WHERE "transfers"."to_account_id" = m."savings_id";
+UPDATE "holds" SET "account_id" = m."checking_id"
+FROM "merged_savings" m WHERE "holds"."account_id" = m."savings_id";
+UPDATE "transfer_batches" SET "from_account_id" = m."checking_id"
+FROM "merged_savings" m WHERE "transfer_batches"."from_account_id" = m."savings_id";
+UPDATE "transfer_batch_items" SET "to_account_id" = m."checking_id"
+FROM "merged_savings" m WHERE "transfer_batch_items"."to_account_id" = m."savings_id";
+UPDATE "accounts" SET "balance" = "accounts"."balance" + m."balance"
+FROM "merged_savings" m WHERE "accounts"."id" = m."checking_id";
+DELETE FROM "accounts" USING "merged_savings" m WHERE "accounts"."id" = m."savings_id";
+
+DROP VIEW "merged_savings";
+
+ALTER TABLE "accounts" DROP CONSTRAINT IF EXISTS "owner_currency_type_key";
+ALTER TABLE "accounts" ADD CONSTRAINT "owner_currency_key" UNIQUE ("owner", "currency");
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "type";
@@ -0,0 +1,34 @@
+ALTER TABLE "accounts" ADD COLUMN "type" varchar NOT NULL DEFAULT 'checking' CHECK ("type" IN ('checking', 'savings'));
+
+-- a user can keep a savings account next to the checking account in a currency
+ALTER TABLE "accounts" DROP CONSTRAINT "owner_currency_key";
+ALTER TABLE "accounts" ADD CONSTRAINT "owner_currency_type_key" UNIQUE ("owner", "currency", "type");
+
+CREATE TABLE "interest_accruals" (
+    "account_id" bigint NOT NULL REFERENCES "accounts" ("id"),
+    "accrual_date" date NOT NULL,
+    "balance" bigint NOT NULL,
+    "annual_rate_bps" int NOT NULL,
+    "amount_micros" bigint NOT NULL CHECK ("amount_micros" >= 0),
+    "created_at" timestamptz NOT NULL DEFAULT (now()),
+    PRIMARY KEY ("account_id", "accrual_date")
+);
+
+CREATE TABLE "interest_postings" (
+    "account_id" bigint NOT NULL REFERENCES "accounts" ("id"),
+    "period" date NOT NULL,
+    "amount" bigint NOT NULL CHECK ("amount" >= 0),
+    "transfer_id" bigint UNIQUE REFERENCES "transfers" ("id"),
+    "created_at" timestamptz NOT NULL DEFAULT (now()),
+    PRIMARY KEY ("account_id", "period")
+);
+
+COMMENT ON COLUMN "interest_accruals"."balance" IS 'balance at the end of the accrual date';
+
+COMMENT ON COLUMN "interest_accruals"."annual_rate_bps" IS 'annual rate in basis points, 150 for 1.5%';
+
+COMMENT ON COLUMN "interest_accruals"."amount_micros" IS 'interest for the day in millionths of a minor unit';
+
+COMMENT ON COLUMN "interest_postings"."period" IS 'first day of the month the interest was accrued in';
+
+COMMENT ON COLUMN "interest_postings"."transfer_id" IS 'transfer from the interest-expense account, empty when nothing was due';
@@ -0,0 +1,13 @@
+DELETE FROM "interest_postings" WHERE "kind" = 'overdraft';
+ALTER TABLE "interest_postings" DROP CONSTRAINT IF EXISTS "interest_postings_pkey";
+ALTER TABLE "interest_postings" ADD PRIMARY KEY ("account_id", "period");
+ALTER TABLE "interest_postings" DROP COLUMN IF EXISTS "kind";
+
+DELETE FROM "interest_accruals" WHERE "kind" = 'overdraft';
+ALTER TABLE "interest_accruals" DROP CONSTRAINT IF EXISTS "interest_accruals_pkey";
+ALTER TABLE "interest_accruals" ADD PRIMARY KEY ("account_id", "accrual_date");
+ALTER TABLE "interest_accruals" DROP COLUMN IF EXISTS "kind";
+
+COMMENT ON COLUMN "interest_postings"."transfer_id" IS 'transfer from the interest-expense account, empty when nothing was due';
+
+ALTER TABLE "accounts" DROP COLUMN IF EXISTS "overdraft_limit";
@@ -0,0 +1,19 @@
+ALTER TABLE "accounts" ADD COLUMN "overdraft_limit" bigint NOT NULL DEFAULT 0 CHECK ("overdraft_limit" >= 0);
+
+-- overdrawn accounts accrue interest they are charged next to the interest
+-- savings accounts earn
+ALTER TABLE "interest_accruals" ADD COLUMN "kind" varchar NOT NULL DEFAULT 'savings' CHECK ("kind" IN ('savings', 'overdraft'));
+ALTER TABLE "interest_accruals" DROP CONSTRAINT "interest_accruals_pkey";
+ALTER TABLE "interest_accruals" ADD PRIMARY KEY ("account_id", "accrual_date", "kind");
+
+ALTER TABLE "interest_postings" ADD COLUMN "kind" varchar NOT NULL DEFAULT 'savings' CHECK ("kind" IN ('savings', 'overdraft'));
+ALTER TABLE "interest_postings" DROP CONSTRAINT "interest_postings_pkey";
+ALTER TABLE "interest_postings" ADD PRIMARY KEY ("account_id", "period", "kind");
+
+COMMENT ON COLUMN "accounts"."overdraft_limit" IS 'how far below zero transfers may take the balance';
+
+COMMENT ON COLUMN "interest_accruals"."kind" IS 'savings interest is paid to the account, overdraft interest is charged to it';
+
+COMMENT ON COLUMN "interest_postings"."kind" IS 'savings interest is paid to the account, overdraft interest is charged to it';
+
+COMMENT ON COLUMN "interest_postings"."transfer_id" IS 'transfer paying or charging the interest, empty when nothing was due';
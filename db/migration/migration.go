@@ -0,0 +1,164 @@
+package migration
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed *.sql
+var files embed.FS
+
+// Status describes the schema version of a database compared to the
+// migrations embedded in the binary.
+type Status struct {
+	Version uint `json:"version"`
+	Dirty   bool `json:"dirty"`
+	Latest  uint `json:"latest"`
+}
+
+// UpToDate reports whether every embedded migration has been applied cleanly.
+func (s Status) UpToDate() bool {
+	return !s.Dirty && s.Version == s.Latest
+}
+
+// newMigrate builds a migrator over the embedded files. The pgx driver holds a
+// postgres advisory lock for the duration of every Up/Down/Steps call, so
+// several replicas starting at once apply each migration exactly once.
+func newMigrate(dbSource string) (*migrate.Migrate, source.Driver, error) {
+	src, err := iofs.New(files, ".")
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", src, databaseURL(dbSource))
+	if err != nil {
+		return nil, nil, fmt.Errorf("open migration database: %w", err)
+	}
+	return m, src, nil
+}
+
+// databaseURL rewrites a libpq style url to the scheme of the pgx/v5 driver.
+func databaseURL(dbSource string) string {
+	for _, scheme := range []string{"postgresql://", "postgres://"} {
+		if strings.HasPrefix(dbSource, scheme) {
+			return "pgx5://" + strings.TrimPrefix(dbSource, scheme)
+		}
+	}
+	return dbSource
+}
+
+func closeMigrate(m *migrate.Migrate, err error) error {
+	srcErr, dbErr := m.Close()
+	return errors.Join(err, srcErr, dbErr)
+}
+
+// Up applies all pending migrations. It is a no-op when the schema is current.
+func Up(dbSource string) error {
+	m, _, err := newMigrate(dbSource)
+	if err != nil {
+		return err
+	}
+	err = m.Up()
+	if errors.Is(err, migrate.ErrNoChange) {
+		err = nil
+	}
+	return closeMigrate(m, err)
+}
+
+// Down rolls back the given number of applied migrations.
+func Down(dbSource string, steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("invalid number of steps: %d", steps)
+	}
+	m, _, err := newMigrate(dbSource)
+	if err != nil {
+		return err
+	}
+	err = m.Steps(-steps)
+	if errors.Is(err, migrate.ErrNoChange) {
+		err = nil
+	}
+	return closeMigrate(m, err)
+}
+
+// GetStatus returns the applied version together with the latest embedded one.
+func GetStatus(dbSource string) (Status, error) {
+	m, src, err := newMigrate(dbSource)
+	if err != nil {
+		return Status{}, err
+	}
+
+	var status Status
+	status.Latest, err = latestVersion(src)
+	if err != nil {
+		return status, closeMigrate(m, err)
+	}
+
+	status.Version, status.Dirty, err = m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		err = nil
+	}
+	return status, closeMigrate(m, err)
+}
+
+// Queryer is the part of a pgx connection or pool that CurrentStatus needs.
+type Queryer interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// embeddedVersion is the latest embedded version, read from the files once.
+var embeddedVersion = sync.OnceValues(func() (uint, error) {
+	src, err := iofs.New(files, ".")
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	return latestVersion(src)
+})
+
+// CurrentStatus is GetStatus read through an existing connection. Unlike
+// GetStatus it does not open a connection of its own, so it is cheap enough
+// for readiness probes.
+func CurrentStatus(ctx context.Context, conn Queryer) (Status, error) {
+	var status Status
+	var err error
+	status.Latest, err = embeddedVersion()
+	if err != nil {
+		return status, err
+	}
+
+	var version int64
+	err = conn.QueryRow(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &status.Dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return status, nil
+	}
+	status.Version = uint(version)
+	return status, err
+}
+
+func latestVersion(src source.Driver) (uint, error) {
+	version, err := src.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := src.Next(version)
+		if errors.Is(err, fs.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
@@ -0,0 +1,74 @@
+package migration
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseURL(t *testing.T) {
+	require.Equal(t, "pgx5://root@localhost:5432/simple_bank", databaseURL("postgres://root@localhost:5432/simple_bank"))
+	require.Equal(t, "pgx5://root@localhost:5432/simple_bank", databaseURL("postgresql://root@localhost:5432/simple_bank"))
+	require.Equal(t, "pgx5://root@localhost:5432/simple_bank", databaseURL("pgx5://root@localhost:5432/simple_bank"))
+}
+
+func TestLatestVersion(t *testing.T) {
+	src, err := iofs.New(files, ".")
+	require.NoError(t, err)
+	defer src.Close()
+
+	ups, err := fs.Glob(files, "*.up.sql")
+	require.NoError(t, err)
+
+	version, err := latestVersion(src)
+	require.NoError(t, err)
+	require.Equal(t, uint(len(ups)), version)
+}
+
+type fakeRow struct {
+	version int64
+	dirty   bool
+	err     error
+}
+
+func (row fakeRow) Scan(dest ...any) error {
+	if row.err != nil {
+		return row.err
+	}
+	*dest[0].(*int64) = row.version
+	*dest[1].(*bool) = row.dirty
+	return nil
+}
+
+type fakeQueryer struct {
+	row fakeRow
+}
+
+func (q fakeQueryer) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return q.row
+}
+
+func TestCurrentStatus(t *testing.T) {
+	ups, err := fs.Glob(files, "*.up.sql")
+	require.NoError(t, err)
+	latest := uint(len(ups))
+
+	status, err := CurrentStatus(context.Background(), fakeQueryer{fakeRow{version: int64(latest)}})
+	require.NoError(t, err)
+	require.Equal(t, Status{Version: latest, Latest: latest}, status)
+	require.True(t, status.UpToDate())
+
+	status, err = CurrentStatus(context.Background(), fakeQueryer{fakeRow{version: int64(latest), dirty: true}})
+	require.NoError(t, err)
+	require.False(t, status.UpToDate())
+
+	// nothing has been applied yet
+	status, err = CurrentStatus(context.Background(), fakeQueryer{fakeRow{err: pgx.ErrNoRows}})
+	require.NoError(t, err)
+	require.Equal(t, uint(0), status.Version)
+	require.False(t, status.UpToDate())
+}
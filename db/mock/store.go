@@ -42,6 +42,21 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// AcceptPaymentRequestTx mocks base method.
+func (m *MockStore) AcceptPaymentRequestTx(ctx context.Context, acceptPaymentRequestParams db.AcceptPaymentRequestTxParams) (error, db.AcceptPaymentRequestTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AcceptPaymentRequestTx", ctx, acceptPaymentRequestParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.AcceptPaymentRequestTxResult)
+	return ret0, ret1
+}
+
+// AcceptPaymentRequestTx indicates an expected call of AcceptPaymentRequestTx.
+func (mr *MockStoreMockRecorder) AcceptPaymentRequestTx(ctx, acceptPaymentRequestParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AcceptPaymentRequestTx", reflect.TypeOf((*MockStore)(nil).AcceptPaymentRequestTx), ctx, acceptPaymentRequestParams)
+}
+
 // AddAccountBalance mocks base method.
 func (m *MockStore) AddAccountBalance(ctx context.Context, arg db.AddAccountBalanceParams) (db.Account, error) {
 	m.ctrl.T.Helper()
@@ -57,6 +72,199 @@ func (mr *MockStoreMockRecorder) AddAccountBalance(ctx, arg any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAccountBalance", reflect.TypeOf((*MockStore)(nil).AddAccountBalance), ctx, arg)
 }
 
+// AdminTransferTx mocks base method.
+func (m *MockStore) AdminTransferTx(ctx context.Context, adminTransferParams db.AdminTransferTxParams) (error, db.AdminTransferTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdminTransferTx", ctx, adminTransferParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.AdminTransferTxResult)
+	return ret0, ret1
+}
+
+// AdminTransferTx indicates an expected call of AdminTransferTx.
+func (mr *MockStoreMockRecorder) AdminTransferTx(ctx, adminTransferParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdminTransferTx", reflect.TypeOf((*MockStore)(nil).AdminTransferTx), ctx, adminTransferParams)
+}
+
+// BatchTransferTx mocks base method.
+func (m *MockStore) BatchTransferTx(ctx context.Context, batchTransferParams db.BatchTransferTxParams) (error, db.BatchTransferTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BatchTransferTx", ctx, batchTransferParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.BatchTransferTxResult)
+	return ret0, ret1
+}
+
+// BatchTransferTx indicates an expected call of BatchTransferTx.
+func (mr *MockStoreMockRecorder) BatchTransferTx(ctx, batchTransferParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BatchTransferTx", reflect.TypeOf((*MockStore)(nil).BatchTransferTx), ctx, batchTransferParams)
+}
+
+// BlockSession mocks base method.
+func (m *MockStore) BlockSession(ctx context.Context, id pgtype.UUID) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockSession", ctx, id)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BlockSession indicates an expected call of BlockSession.
+func (mr *MockStoreMockRecorder) BlockSession(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockSession", reflect.TypeOf((*MockStore)(nil).BlockSession), ctx, id)
+}
+
+// BlockUserSessions mocks base method.
+func (m *MockStore) BlockUserSessions(ctx context.Context, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BlockUserSessions", ctx, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// BlockUserSessions indicates an expected call of BlockUserSessions.
+func (mr *MockStoreMockRecorder) BlockUserSessions(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BlockUserSessions", reflect.TypeOf((*MockStore)(nil).BlockUserSessions), ctx, username)
+}
+
+// CaptureHoldTx mocks base method.
+func (m *MockStore) CaptureHoldTx(ctx context.Context, captureHoldParams db.CaptureHoldTxParams) (error, db.CaptureHoldTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CaptureHoldTx", ctx, captureHoldParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.CaptureHoldTxResult)
+	return ret0, ret1
+}
+
+// CaptureHoldTx indicates an expected call of CaptureHoldTx.
+func (mr *MockStoreMockRecorder) CaptureHoldTx(ctx, captureHoldParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CaptureHoldTx", reflect.TypeOf((*MockStore)(nil).CaptureHoldTx), ctx, captureHoldParams)
+}
+
+// ChangePasswordTx mocks base method.
+func (m *MockStore) ChangePasswordTx(ctx context.Context, changePasswordParams db.ChangePasswordTxParams) (error, db.ChangePasswordTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePasswordTx", ctx, changePasswordParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.ChangePasswordTxResult)
+	return ret0, ret1
+}
+
+// ChangePasswordTx indicates an expected call of ChangePasswordTx.
+func (mr *MockStoreMockRecorder) ChangePasswordTx(ctx, changePasswordParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePasswordTx", reflect.TypeOf((*MockStore)(nil).ChangePasswordTx), ctx, changePasswordParams)
+}
+
+// ClaimTasks mocks base method.
+func (m *MockStore) ClaimTasks(ctx context.Context, arg db.ClaimTasksParams) ([]db.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimTasks", ctx, arg)
+	ret0, _ := ret[0].([]db.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimTasks indicates an expected call of ClaimTasks.
+func (mr *MockStoreMockRecorder) ClaimTasks(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimTasks", reflect.TypeOf((*MockStore)(nil).ClaimTasks), ctx, arg)
+}
+
+// CloseHold mocks base method.
+func (m *MockStore) CloseHold(ctx context.Context, arg db.CloseHoldParams) (db.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CloseHold", ctx, arg)
+	ret0, _ := ret[0].(db.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CloseHold indicates an expected call of CloseHold.
+func (mr *MockStoreMockRecorder) CloseHold(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CloseHold", reflect.TypeOf((*MockStore)(nil).CloseHold), ctx, arg)
+}
+
+// CompleteTask mocks base method.
+func (m *MockStore) CompleteTask(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteTask", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteTask indicates an expected call of CompleteTask.
+func (mr *MockStoreMockRecorder) CompleteTask(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteTask", reflect.TypeOf((*MockStore)(nil).CompleteTask), ctx, id)
+}
+
+// ConfirmTOTPTx mocks base method.
+func (m *MockStore) ConfirmTOTPTx(ctx context.Context, confirmTOTPParams db.ConfirmTOTPTxParams) (error, db.ConfirmTOTPTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTPTx", ctx, confirmTOTPParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.ConfirmTOTPTxResult)
+	return ret0, ret1
+}
+
+// ConfirmTOTPTx indicates an expected call of ConfirmTOTPTx.
+func (mr *MockStoreMockRecorder) ConfirmTOTPTx(ctx, confirmTOTPParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTPTx", reflect.TypeOf((*MockStore)(nil).ConfirmTOTPTx), ctx, confirmTOTPParams)
+}
+
+// ConfirmUserTOTP mocks base method.
+func (m *MockStore) ConfirmUserTOTP(ctx context.Context, username string) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmUserTOTP", ctx, username)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmUserTOTP indicates an expected call of ConfirmUserTOTP.
+func (mr *MockStoreMockRecorder) ConfirmUserTOTP(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmUserTOTP", reflect.TypeOf((*MockStore)(nil).ConfirmUserTOTP), ctx, username)
+}
+
+// CountIPLoginFailures mocks base method.
+func (m *MockStore) CountIPLoginFailures(ctx context.Context, arg db.CountIPLoginFailuresParams) (db.CountIPLoginFailuresRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountIPLoginFailures", ctx, arg)
+	ret0, _ := ret[0].(db.CountIPLoginFailuresRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountIPLoginFailures indicates an expected call of CountIPLoginFailures.
+func (mr *MockStoreMockRecorder) CountIPLoginFailures(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountIPLoginFailures", reflect.TypeOf((*MockStore)(nil).CountIPLoginFailures), ctx, arg)
+}
+
+// CountUserLoginFailures mocks base method.
+func (m *MockStore) CountUserLoginFailures(ctx context.Context, arg db.CountUserLoginFailuresParams) (db.CountUserLoginFailuresRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountUserLoginFailures", ctx, arg)
+	ret0, _ := ret[0].(db.CountUserLoginFailuresRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountUserLoginFailures indicates an expected call of CountUserLoginFailures.
+func (mr *MockStoreMockRecorder) CountUserLoginFailures(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountUserLoginFailures", reflect.TypeOf((*MockStore)(nil).CountUserLoginFailures), ctx, arg)
+}
+
 // CreateAccount mocks base method.
 func (m *MockStore) CreateAccount(ctx context.Context, arg db.CreateAccountParams) (db.Account, error) {
 	m.ctrl.T.Helper()
@@ -72,6 +280,66 @@ func (mr *MockStoreMockRecorder) CreateAccount(ctx, arg any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccount", reflect.TypeOf((*MockStore)(nil).CreateAccount), ctx, arg)
 }
 
+// CreateAccountTx mocks base method.
+func (m *MockStore) CreateAccountTx(ctx context.Context, createAccountParams db.CreateAccountTxParams) (error, db.CreateAccountTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAccountTx", ctx, createAccountParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.CreateAccountTxResult)
+	return ret0, ret1
+}
+
+// CreateAccountTx indicates an expected call of CreateAccountTx.
+func (mr *MockStoreMockRecorder) CreateAccountTx(ctx, createAccountParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAccountTx", reflect.TypeOf((*MockStore)(nil).CreateAccountTx), ctx, createAccountParams)
+}
+
+// CreateAdminAdjustment mocks base method.
+func (m *MockStore) CreateAdminAdjustment(ctx context.Context, arg db.CreateAdminAdjustmentParams) (db.AdminAdjustment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAdminAdjustment", ctx, arg)
+	ret0, _ := ret[0].(db.AdminAdjustment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAdminAdjustment indicates an expected call of CreateAdminAdjustment.
+func (mr *MockStoreMockRecorder) CreateAdminAdjustment(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAdminAdjustment", reflect.TypeOf((*MockStore)(nil).CreateAdminAdjustment), ctx, arg)
+}
+
+// CreateAuditEvent mocks base method.
+func (m *MockStore) CreateAuditEvent(ctx context.Context, arg db.CreateAuditEventParams) (db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAuditEvent", ctx, arg)
+	ret0, _ := ret[0].(db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAuditEvent indicates an expected call of CreateAuditEvent.
+func (mr *MockStoreMockRecorder) CreateAuditEvent(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAuditEvent", reflect.TypeOf((*MockStore)(nil).CreateAuditEvent), ctx, arg)
+}
+
+// CreateCurrency mocks base method.
+func (m *MockStore) CreateCurrency(ctx context.Context, arg db.CreateCurrencyParams) (db.Currency, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCurrency", ctx, arg)
+	ret0, _ := ret[0].(db.Currency)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCurrency indicates an expected call of CreateCurrency.
+func (mr *MockStoreMockRecorder) CreateCurrency(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCurrency", reflect.TypeOf((*MockStore)(nil).CreateCurrency), ctx, arg)
+}
+
 // CreateEntry mocks base method.
 func (m *MockStore) CreateEntry(ctx context.Context, arg db.CreateEntryParams) (db.Entry, error) {
 	m.ctrl.T.Helper()
@@ -87,166 +355,1434 @@ func (mr *MockStoreMockRecorder) CreateEntry(ctx, arg any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEntry", reflect.TypeOf((*MockStore)(nil).CreateEntry), ctx, arg)
 }
 
-// CreateSessions mocks base method.
-func (m *MockStore) CreateSessions(ctx context.Context, arg db.CreateSessionsParams) (db.Session, error) {
+// CreateHold mocks base method.
+func (m *MockStore) CreateHold(ctx context.Context, arg db.CreateHoldParams) (db.Hold, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateSessions", ctx, arg)
-	ret0, _ := ret[0].(db.Session)
+	ret := m.ctrl.Call(m, "CreateHold", ctx, arg)
+	ret0, _ := ret[0].(db.Hold)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateSessions indicates an expected call of CreateSessions.
-func (mr *MockStoreMockRecorder) CreateSessions(ctx, arg any) *gomock.Call {
+// CreateHold indicates an expected call of CreateHold.
+func (mr *MockStoreMockRecorder) CreateHold(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSessions", reflect.TypeOf((*MockStore)(nil).CreateSessions), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateHold", reflect.TypeOf((*MockStore)(nil).CreateHold), ctx, arg)
 }
 
-// CreateTransfer mocks base method.
-func (m *MockStore) CreateTransfer(ctx context.Context, arg db.CreateTransferParams) (db.Transfer, error) {
+// CreateInterestAccrual mocks base method.
+func (m *MockStore) CreateInterestAccrual(ctx context.Context, arg db.CreateInterestAccrualParams) (int64, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateTransfer", ctx, arg)
-	ret0, _ := ret[0].(db.Transfer)
+	ret := m.ctrl.Call(m, "CreateInterestAccrual", ctx, arg)
+	ret0, _ := ret[0].(int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateTransfer indicates an expected call of CreateTransfer.
-func (mr *MockStoreMockRecorder) CreateTransfer(ctx, arg any) *gomock.Call {
+// CreateInterestAccrual indicates an expected call of CreateInterestAccrual.
+func (mr *MockStoreMockRecorder) CreateInterestAccrual(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransfer", reflect.TypeOf((*MockStore)(nil).CreateTransfer), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateInterestAccrual", reflect.TypeOf((*MockStore)(nil).CreateInterestAccrual), ctx, arg)
 }
 
-// CreateUser mocks base method.
-func (m *MockStore) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+// CreateInterestPosting mocks base method.
+func (m *MockStore) CreateInterestPosting(ctx context.Context, arg db.CreateInterestPostingParams) (db.InterestPosting, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateUser", ctx, arg)
-	ret0, _ := ret[0].(db.User)
+	ret := m.ctrl.Call(m, "CreateInterestPosting", ctx, arg)
+	ret0, _ := ret[0].(db.InterestPosting)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// CreateUser indicates an expected call of CreateUser.
-func (mr *MockStoreMockRecorder) CreateUser(ctx, arg any) *gomock.Call {
+// CreateInterestPosting indicates an expected call of CreateInterestPosting.
+func (mr *MockStoreMockRecorder) CreateInterestPosting(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStore)(nil).CreateUser), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateInterestPosting", reflect.TypeOf((*MockStore)(nil).CreateInterestPosting), ctx, arg)
 }
 
-// DeleteAccount mocks base method.
-func (m *MockStore) DeleteAccount(ctx context.Context, id int64) error {
+// CreateLoginAttempt mocks base method.
+func (m *MockStore) CreateLoginAttempt(ctx context.Context, arg db.CreateLoginAttemptParams) (db.LoginAttempt, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteAccount", ctx, id)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "CreateLoginAttempt", ctx, arg)
+	ret0, _ := ret[0].(db.LoginAttempt)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// DeleteAccount indicates an expected call of DeleteAccount.
-func (mr *MockStoreMockRecorder) DeleteAccount(ctx, id any) *gomock.Call {
+// CreateLoginAttempt indicates an expected call of CreateLoginAttempt.
+func (mr *MockStoreMockRecorder) CreateLoginAttempt(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockStore)(nil).DeleteAccount), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLoginAttempt", reflect.TypeOf((*MockStore)(nil).CreateLoginAttempt), ctx, arg)
 }
 
-// GetAccount mocks base method.
-func (m *MockStore) GetAccount(ctx context.Context, id int64) (db.Account, error) {
+// CreatePasswordReset mocks base method.
+func (m *MockStore) CreatePasswordReset(ctx context.Context, arg db.CreatePasswordResetParams) (db.PasswordReset, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccount", ctx, id)
-	ret0, _ := ret[0].(db.Account)
+	ret := m.ctrl.Call(m, "CreatePasswordReset", ctx, arg)
+	ret0, _ := ret[0].(db.PasswordReset)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAccount indicates an expected call of GetAccount.
-func (mr *MockStoreMockRecorder) GetAccount(ctx, id any) *gomock.Call {
+// CreatePasswordReset indicates an expected call of CreatePasswordReset.
+func (mr *MockStoreMockRecorder) CreatePasswordReset(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockStore)(nil).GetAccount), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePasswordReset", reflect.TypeOf((*MockStore)(nil).CreatePasswordReset), ctx, arg)
 }
 
-// GetAccountForUpdate mocks base method.
-func (m *MockStore) GetAccountForUpdate(ctx context.Context, id int64) (db.Account, error) {
+// CreatePaymentRequest mocks base method.
+func (m *MockStore) CreatePaymentRequest(ctx context.Context, arg db.CreatePaymentRequestParams) (db.PaymentRequest, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAccountForUpdate", ctx, id)
-	ret0, _ := ret[0].(db.Account)
+	ret := m.ctrl.Call(m, "CreatePaymentRequest", ctx, arg)
+	ret0, _ := ret[0].(db.PaymentRequest)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetAccountForUpdate indicates an expected call of GetAccountForUpdate.
-func (mr *MockStoreMockRecorder) GetAccountForUpdate(ctx, id any) *gomock.Call {
+// CreatePaymentRequest indicates an expected call of CreatePaymentRequest.
+func (mr *MockStoreMockRecorder) CreatePaymentRequest(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountForUpdate", reflect.TypeOf((*MockStore)(nil).GetAccountForUpdate), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePaymentRequest", reflect.TypeOf((*MockStore)(nil).CreatePaymentRequest), ctx, arg)
 }
 
-// GetSessions mocks base method.
-func (m *MockStore) GetSessions(ctx context.Context, id pgtype.UUID) (db.Session, error) {
+// CreatePaymentRequestTx mocks base method.
+func (m *MockStore) CreatePaymentRequestTx(ctx context.Context, createPaymentRequestParams db.CreatePaymentRequestTxParams) (error, db.CreatePaymentRequestTxResult) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetSessions", ctx, id)
+	ret := m.ctrl.Call(m, "CreatePaymentRequestTx", ctx, createPaymentRequestParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.CreatePaymentRequestTxResult)
+	return ret0, ret1
+}
+
+// CreatePaymentRequestTx indicates an expected call of CreatePaymentRequestTx.
+func (mr *MockStoreMockRecorder) CreatePaymentRequestTx(ctx, createPaymentRequestParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePaymentRequestTx", reflect.TypeOf((*MockStore)(nil).CreatePaymentRequestTx), ctx, createPaymentRequestParams)
+}
+
+// CreateRecoveryCode mocks base method.
+func (m *MockStore) CreateRecoveryCode(ctx context.Context, arg db.CreateRecoveryCodeParams) (db.RecoveryCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRecoveryCode", ctx, arg)
+	ret0, _ := ret[0].(db.RecoveryCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateRecoveryCode indicates an expected call of CreateRecoveryCode.
+func (mr *MockStoreMockRecorder) CreateRecoveryCode(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRecoveryCode", reflect.TypeOf((*MockStore)(nil).CreateRecoveryCode), ctx, arg)
+}
+
+// CreateSessions mocks base method.
+func (m *MockStore) CreateSessions(ctx context.Context, arg db.CreateSessionsParams) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSessions", ctx, arg)
 	ret0, _ := ret[0].(db.Session)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetSessions indicates an expected call of GetSessions.
-func (mr *MockStoreMockRecorder) GetSessions(ctx, id any) *gomock.Call {
+// CreateSessions indicates an expected call of CreateSessions.
+func (mr *MockStoreMockRecorder) CreateSessions(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessions", reflect.TypeOf((*MockStore)(nil).GetSessions), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSessions", reflect.TypeOf((*MockStore)(nil).CreateSessions), ctx, arg)
 }
 
-// GetUser mocks base method.
-func (m *MockStore) GetUser(ctx context.Context, username string) (db.User, error) {
+// CreateTask mocks base method.
+func (m *MockStore) CreateTask(ctx context.Context, arg db.CreateTaskParams) (db.Task, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUser", ctx, username)
-	ret0, _ := ret[0].(db.User)
+	ret := m.ctrl.Call(m, "CreateTask", ctx, arg)
+	ret0, _ := ret[0].(db.Task)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUser indicates an expected call of GetUser.
-func (mr *MockStoreMockRecorder) GetUser(ctx, username any) *gomock.Call {
+// CreateTask indicates an expected call of CreateTask.
+func (mr *MockStoreMockRecorder) CreateTask(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockStore)(nil).GetUser), ctx, username)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTask", reflect.TypeOf((*MockStore)(nil).CreateTask), ctx, arg)
 }
 
-// ListAccount mocks base method.
-func (m *MockStore) ListAccount(ctx context.Context, arg db.ListAccountParams) ([]db.Account, error) {
+// CreateTransfer mocks base method.
+func (m *MockStore) CreateTransfer(ctx context.Context, arg db.CreateTransferParams) (db.Transfer, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ListAccount", ctx, arg)
-	ret0, _ := ret[0].([]db.Account)
+	ret := m.ctrl.Call(m, "CreateTransfer", ctx, arg)
+	ret0, _ := ret[0].(db.Transfer)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// ListAccount indicates an expected call of ListAccount.
-func (mr *MockStoreMockRecorder) ListAccount(ctx, arg any) *gomock.Call {
+// CreateTransfer indicates an expected call of CreateTransfer.
+func (mr *MockStoreMockRecorder) CreateTransfer(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccount", reflect.TypeOf((*MockStore)(nil).ListAccount), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransfer", reflect.TypeOf((*MockStore)(nil).CreateTransfer), ctx, arg)
 }
 
-// TransferTx mocks base method.
-func (m *MockStore) TransferTx(ctx context.Context, transferParams db.TransferTxParams) (error, db.TransferTxResult) {
+// CreateTransferBatch mocks base method.
+func (m *MockStore) CreateTransferBatch(ctx context.Context, arg db.CreateTransferBatchParams) (db.TransferBatch, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "TransferTx", ctx, transferParams)
-	ret0, _ := ret[0].(error)
-	ret1, _ := ret[1].(db.TransferTxResult)
+	ret := m.ctrl.Call(m, "CreateTransferBatch", ctx, arg)
+	ret0, _ := ret[0].(db.TransferBatch)
+	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// TransferTx indicates an expected call of TransferTx.
-func (mr *MockStoreMockRecorder) TransferTx(ctx, transferParams any) *gomock.Call {
+// CreateTransferBatch indicates an expected call of CreateTransferBatch.
+func (mr *MockStoreMockRecorder) CreateTransferBatch(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferTx", reflect.TypeOf((*MockStore)(nil).TransferTx), ctx, transferParams)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransferBatch", reflect.TypeOf((*MockStore)(nil).CreateTransferBatch), ctx, arg)
 }
 
-// UpdateAccount mocks base method.
-func (m *MockStore) UpdateAccount(ctx context.Context, arg db.UpdateAccountParams) (db.Account, error) {
+// CreateTransferBatchItem mocks base method.
+func (m *MockStore) CreateTransferBatchItem(ctx context.Context, arg db.CreateTransferBatchItemParams) (db.TransferBatchItem, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateAccount", ctx, arg)
-	ret0, _ := ret[0].(db.Account)
+	ret := m.ctrl.Call(m, "CreateTransferBatchItem", ctx, arg)
+	ret0, _ := ret[0].(db.TransferBatchItem)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// UpdateAccount indicates an expected call of UpdateAccount.
-func (mr *MockStoreMockRecorder) UpdateAccount(ctx, arg any) *gomock.Call {
+// CreateTransferBatchItem indicates an expected call of CreateTransferBatchItem.
+func (mr *MockStoreMockRecorder) CreateTransferBatchItem(ctx, arg any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccount", reflect.TypeOf((*MockStore)(nil).UpdateAccount), ctx, arg)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransferBatchItem", reflect.TypeOf((*MockStore)(nil).CreateTransferBatchItem), ctx, arg)
+}
+
+// CreateTransferReversal mocks base method.
+func (m *MockStore) CreateTransferReversal(ctx context.Context, arg db.CreateTransferReversalParams) (db.TransferReversal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTransferReversal", ctx, arg)
+	ret0, _ := ret[0].(db.TransferReversal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTransferReversal indicates an expected call of CreateTransferReversal.
+func (mr *MockStoreMockRecorder) CreateTransferReversal(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTransferReversal", reflect.TypeOf((*MockStore)(nil).CreateTransferReversal), ctx, arg)
+}
+
+// CreateUser mocks base method.
+func (m *MockStore) CreateUser(ctx context.Context, arg db.CreateUserParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUser", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateUser indicates an expected call of CreateUser.
+func (mr *MockStoreMockRecorder) CreateUser(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStore)(nil).CreateUser), ctx, arg)
+}
+
+// CreateUserTx mocks base method.
+func (m *MockStore) CreateUserTx(ctx context.Context, createUserParams db.CreateUserTxParams) (error, db.CreateUserTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateUserTx", ctx, createUserParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.CreateUserTxResult)
+	return ret0, ret1
+}
+
+// CreateUserTx indicates an expected call of CreateUserTx.
+func (mr *MockStoreMockRecorder) CreateUserTx(ctx, createUserParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUserTx", reflect.TypeOf((*MockStore)(nil).CreateUserTx), ctx, createUserParams)
+}
+
+// CreateVerifyEmail mocks base method.
+func (m *MockStore) CreateVerifyEmail(ctx context.Context, arg db.CreateVerifyEmailParams) (db.VerifyEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateVerifyEmail", ctx, arg)
+	ret0, _ := ret[0].(db.VerifyEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateVerifyEmail indicates an expected call of CreateVerifyEmail.
+func (mr *MockStoreMockRecorder) CreateVerifyEmail(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateVerifyEmail", reflect.TypeOf((*MockStore)(nil).CreateVerifyEmail), ctx, arg)
+}
+
+// DeclinePaymentRequestTx mocks base method.
+func (m *MockStore) DeclinePaymentRequestTx(ctx context.Context, declinePaymentRequestParams db.DeclinePaymentRequestTxParams) (error, db.DeclinePaymentRequestTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeclinePaymentRequestTx", ctx, declinePaymentRequestParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.DeclinePaymentRequestTxResult)
+	return ret0, ret1
+}
+
+// DeclinePaymentRequestTx indicates an expected call of DeclinePaymentRequestTx.
+func (mr *MockStoreMockRecorder) DeclinePaymentRequestTx(ctx, declinePaymentRequestParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeclinePaymentRequestTx", reflect.TypeOf((*MockStore)(nil).DeclinePaymentRequestTx), ctx, declinePaymentRequestParams)
+}
+
+// DeleteAccount mocks base method.
+func (m *MockStore) DeleteAccount(ctx context.Context, id int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAccount", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAccount indicates an expected call of DeleteAccount.
+func (mr *MockStoreMockRecorder) DeleteAccount(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAccount", reflect.TypeOf((*MockStore)(nil).DeleteAccount), ctx, id)
+}
+
+// DeleteFullRateLimitBuckets mocks base method.
+func (m *MockStore) DeleteFullRateLimitBuckets(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFullRateLimitBuckets", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteFullRateLimitBuckets indicates an expected call of DeleteFullRateLimitBuckets.
+func (mr *MockStoreMockRecorder) DeleteFullRateLimitBuckets(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFullRateLimitBuckets", reflect.TypeOf((*MockStore)(nil).DeleteFullRateLimitBuckets), ctx)
+}
+
+// DeleteRecoveryCodes mocks base method.
+func (m *MockStore) DeleteRecoveryCodes(ctx context.Context, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteRecoveryCodes", ctx, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteRecoveryCodes indicates an expected call of DeleteRecoveryCodes.
+func (mr *MockStoreMockRecorder) DeleteRecoveryCodes(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRecoveryCodes", reflect.TypeOf((*MockStore)(nil).DeleteRecoveryCodes), ctx, username)
+}
+
+// DeleteUserAlias mocks base method.
+func (m *MockStore) DeleteUserAlias(ctx context.Context, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteUserAlias", ctx, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteUserAlias indicates an expected call of DeleteUserAlias.
+func (mr *MockStoreMockRecorder) DeleteUserAlias(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUserAlias", reflect.TypeOf((*MockStore)(nil).DeleteUserAlias), ctx, username)
+}
+
+// ExpireHolds mocks base method.
+func (m *MockStore) ExpireHolds(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpireHolds", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpireHolds indicates an expected call of ExpireHolds.
+func (mr *MockStoreMockRecorder) ExpireHolds(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireHolds", reflect.TypeOf((*MockStore)(nil).ExpireHolds), ctx)
+}
+
+// ExpirePaymentRequests mocks base method.
+func (m *MockStore) ExpirePaymentRequests(ctx context.Context) ([]db.PaymentRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePaymentRequests", ctx)
+	ret0, _ := ret[0].([]db.PaymentRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ExpirePaymentRequests indicates an expected call of ExpirePaymentRequests.
+func (mr *MockStoreMockRecorder) ExpirePaymentRequests(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePaymentRequests", reflect.TypeOf((*MockStore)(nil).ExpirePaymentRequests), ctx)
+}
+
+// ExpirePaymentRequestsTx mocks base method.
+func (m *MockStore) ExpirePaymentRequestsTx(ctx context.Context, expirePaymentRequestsParams db.ExpirePaymentRequestsTxParams) (error, db.ExpirePaymentRequestsTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePaymentRequestsTx", ctx, expirePaymentRequestsParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.ExpirePaymentRequestsTxResult)
+	return ret0, ret1
+}
+
+// ExpirePaymentRequestsTx indicates an expected call of ExpirePaymentRequestsTx.
+func (mr *MockStoreMockRecorder) ExpirePaymentRequestsTx(ctx, expirePaymentRequestsParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePaymentRequestsTx", reflect.TypeOf((*MockStore)(nil).ExpirePaymentRequestsTx), ctx, expirePaymentRequestsParams)
+}
+
+// FailTask mocks base method.
+func (m *MockStore) FailTask(ctx context.Context, arg db.FailTaskParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailTask", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FailTask indicates an expected call of FailTask.
+func (mr *MockStoreMockRecorder) FailTask(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailTask", reflect.TypeOf((*MockStore)(nil).FailTask), ctx, arg)
+}
+
+// GetAccount mocks base method.
+func (m *MockStore) GetAccount(ctx context.Context, id int64) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccount", ctx, id)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccount indicates an expected call of GetAccount.
+func (mr *MockStoreMockRecorder) GetAccount(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccount", reflect.TypeOf((*MockStore)(nil).GetAccount), ctx, id)
+}
+
+// GetAccountByOwnerCurrency mocks base method.
+func (m *MockStore) GetAccountByOwnerCurrency(ctx context.Context, arg db.GetAccountByOwnerCurrencyParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountByOwnerCurrency", ctx, arg)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountByOwnerCurrency indicates an expected call of GetAccountByOwnerCurrency.
+func (mr *MockStoreMockRecorder) GetAccountByOwnerCurrency(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountByOwnerCurrency", reflect.TypeOf((*MockStore)(nil).GetAccountByOwnerCurrency), ctx, arg)
+}
+
+// GetAccountForUpdate mocks base method.
+func (m *MockStore) GetAccountForUpdate(ctx context.Context, id int64) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountForUpdate", ctx, id)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountForUpdate indicates an expected call of GetAccountForUpdate.
+func (mr *MockStoreMockRecorder) GetAccountForUpdate(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountForUpdate", reflect.TypeOf((*MockStore)(nil).GetAccountForUpdate), ctx, id)
+}
+
+// GetAccountHeldAmount mocks base method.
+func (m *MockStore) GetAccountHeldAmount(ctx context.Context, accountID int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccountHeldAmount", ctx, accountID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccountHeldAmount indicates an expected call of GetAccountHeldAmount.
+func (mr *MockStoreMockRecorder) GetAccountHeldAmount(ctx, accountID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccountHeldAmount", reflect.TypeOf((*MockStore)(nil).GetAccountHeldAmount), ctx, accountID)
+}
+
+// GetAccruedInterest mocks base method.
+func (m *MockStore) GetAccruedInterest(ctx context.Context, arg db.GetAccruedInterestParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAccruedInterest", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAccruedInterest indicates an expected call of GetAccruedInterest.
+func (mr *MockStoreMockRecorder) GetAccruedInterest(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAccruedInterest", reflect.TypeOf((*MockStore)(nil).GetAccruedInterest), ctx, arg)
+}
+
+// GetCurrency mocks base method.
+func (m *MockStore) GetCurrency(ctx context.Context, code string) (db.Currency, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCurrency", ctx, code)
+	ret0, _ := ret[0].(db.Currency)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCurrency indicates an expected call of GetCurrency.
+func (mr *MockStoreMockRecorder) GetCurrency(ctx, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCurrency", reflect.TypeOf((*MockStore)(nil).GetCurrency), ctx, code)
+}
+
+// GetHold mocks base method.
+func (m *MockStore) GetHold(ctx context.Context, id int64) (db.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHold", ctx, id)
+	ret0, _ := ret[0].(db.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHold indicates an expected call of GetHold.
+func (mr *MockStoreMockRecorder) GetHold(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHold", reflect.TypeOf((*MockStore)(nil).GetHold), ctx, id)
+}
+
+// GetHoldForUpdate mocks base method.
+func (m *MockStore) GetHoldForUpdate(ctx context.Context, id int64) (db.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetHoldForUpdate", ctx, id)
+	ret0, _ := ret[0].(db.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetHoldForUpdate indicates an expected call of GetHoldForUpdate.
+func (mr *MockStoreMockRecorder) GetHoldForUpdate(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetHoldForUpdate", reflect.TypeOf((*MockStore)(nil).GetHoldForUpdate), ctx, id)
+}
+
+// GetInterestPosting mocks base method.
+func (m *MockStore) GetInterestPosting(ctx context.Context, arg db.GetInterestPostingParams) (db.InterestPosting, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetInterestPosting", ctx, arg)
+	ret0, _ := ret[0].(db.InterestPosting)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetInterestPosting indicates an expected call of GetInterestPosting.
+func (mr *MockStoreMockRecorder) GetInterestPosting(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInterestPosting", reflect.TypeOf((*MockStore)(nil).GetInterestPosting), ctx, arg)
+}
+
+// GetLastAuditEventHash mocks base method.
+func (m *MockStore) GetLastAuditEventHash(ctx context.Context) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLastAuditEventHash", ctx)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLastAuditEventHash indicates an expected call of GetLastAuditEventHash.
+func (mr *MockStoreMockRecorder) GetLastAuditEventHash(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLastAuditEventHash", reflect.TypeOf((*MockStore)(nil).GetLastAuditEventHash), ctx)
+}
+
+// GetLatestInterestAccrualDate mocks base method.
+func (m *MockStore) GetLatestInterestAccrualDate(ctx context.Context) (pgtype.Date, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLatestInterestAccrualDate", ctx)
+	ret0, _ := ret[0].(pgtype.Date)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLatestInterestAccrualDate indicates an expected call of GetLatestInterestAccrualDate.
+func (mr *MockStoreMockRecorder) GetLatestInterestAccrualDate(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLatestInterestAccrualDate", reflect.TypeOf((*MockStore)(nil).GetLatestInterestAccrualDate), ctx)
+}
+
+// GetPaymentRequest mocks base method.
+func (m *MockStore) GetPaymentRequest(ctx context.Context, id int64) (db.PaymentRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPaymentRequest", ctx, id)
+	ret0, _ := ret[0].(db.PaymentRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPaymentRequest indicates an expected call of GetPaymentRequest.
+func (mr *MockStoreMockRecorder) GetPaymentRequest(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaymentRequest", reflect.TypeOf((*MockStore)(nil).GetPaymentRequest), ctx, id)
+}
+
+// GetPaymentRequestForUpdate mocks base method.
+func (m *MockStore) GetPaymentRequestForUpdate(ctx context.Context, id int64) (db.PaymentRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPaymentRequestForUpdate", ctx, id)
+	ret0, _ := ret[0].(db.PaymentRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPaymentRequestForUpdate indicates an expected call of GetPaymentRequestForUpdate.
+func (mr *MockStoreMockRecorder) GetPaymentRequestForUpdate(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPaymentRequestForUpdate", reflect.TypeOf((*MockStore)(nil).GetPaymentRequestForUpdate), ctx, id)
+}
+
+// GetPostedInterest mocks base method.
+func (m *MockStore) GetPostedInterest(ctx context.Context, arg db.GetPostedInterestParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPostedInterest", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPostedInterest indicates an expected call of GetPostedInterest.
+func (mr *MockStoreMockRecorder) GetPostedInterest(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPostedInterest", reflect.TypeOf((*MockStore)(nil).GetPostedInterest), ctx, arg)
+}
+
+// GetReversedAmount mocks base method.
+func (m *MockStore) GetReversedAmount(ctx context.Context, originalTransferID int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReversedAmount", ctx, originalTransferID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReversedAmount indicates an expected call of GetReversedAmount.
+func (mr *MockStoreMockRecorder) GetReversedAmount(ctx, originalTransferID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReversedAmount", reflect.TypeOf((*MockStore)(nil).GetReversedAmount), ctx, originalTransferID)
+}
+
+// GetSessions mocks base method.
+func (m *MockStore) GetSessions(ctx context.Context, id pgtype.UUID) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessions", ctx, id)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessions indicates an expected call of GetSessions.
+func (mr *MockStoreMockRecorder) GetSessions(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessions", reflect.TypeOf((*MockStore)(nil).GetSessions), ctx, id)
+}
+
+// GetTask mocks base method.
+func (m *MockStore) GetTask(ctx context.Context, id int64) (db.Task, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTask", ctx, id)
+	ret0, _ := ret[0].(db.Task)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTask indicates an expected call of GetTask.
+func (mr *MockStoreMockRecorder) GetTask(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTask", reflect.TypeOf((*MockStore)(nil).GetTask), ctx, id)
+}
+
+// GetTransfer mocks base method.
+func (m *MockStore) GetTransfer(ctx context.Context, id int64) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransfer", ctx, id)
+	ret0, _ := ret[0].(db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransfer indicates an expected call of GetTransfer.
+func (mr *MockStoreMockRecorder) GetTransfer(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransfer", reflect.TypeOf((*MockStore)(nil).GetTransfer), ctx, id)
+}
+
+// GetTransferBatch mocks base method.
+func (m *MockStore) GetTransferBatch(ctx context.Context, id int64) (db.TransferBatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferBatch", ctx, id)
+	ret0, _ := ret[0].(db.TransferBatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferBatch indicates an expected call of GetTransferBatch.
+func (mr *MockStoreMockRecorder) GetTransferBatch(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferBatch", reflect.TypeOf((*MockStore)(nil).GetTransferBatch), ctx, id)
+}
+
+// GetTransferForUpdate mocks base method.
+func (m *MockStore) GetTransferForUpdate(ctx context.Context, id int64) (db.Transfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferForUpdate", ctx, id)
+	ret0, _ := ret[0].(db.Transfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferForUpdate indicates an expected call of GetTransferForUpdate.
+func (mr *MockStoreMockRecorder) GetTransferForUpdate(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferForUpdate", reflect.TypeOf((*MockStore)(nil).GetTransferForUpdate), ctx, id)
+}
+
+// GetUser mocks base method.
+func (m *MockStore) GetUser(ctx context.Context, username string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUser", ctx, username)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUser indicates an expected call of GetUser.
+func (mr *MockStoreMockRecorder) GetUser(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockStore)(nil).GetUser), ctx, username)
+}
+
+// GetUserAlias mocks base method.
+func (m *MockStore) GetUserAlias(ctx context.Context, alias string) (db.UserAlias, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserAlias", ctx, alias)
+	ret0, _ := ret[0].(db.UserAlias)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserAlias indicates an expected call of GetUserAlias.
+func (mr *MockStoreMockRecorder) GetUserAlias(ctx, alias any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserAlias", reflect.TypeOf((*MockStore)(nil).GetUserAlias), ctx, alias)
+}
+
+// GetUserByEmail mocks base method.
+func (m *MockStore) GetUserByEmail(ctx context.Context, email string) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByEmail", ctx, email)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByEmail indicates an expected call of GetUserByEmail.
+func (mr *MockStoreMockRecorder) GetUserByEmail(ctx, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByEmail", reflect.TypeOf((*MockStore)(nil).GetUserByEmail), ctx, email)
+}
+
+// GetUserTOTP mocks base method.
+func (m *MockStore) GetUserTOTP(ctx context.Context, username string) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserTOTP", ctx, username)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserTOTP indicates an expected call of GetUserTOTP.
+func (mr *MockStoreMockRecorder) GetUserTOTP(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTOTP", reflect.TypeOf((*MockStore)(nil).GetUserTOTP), ctx, username)
+}
+
+// GetVerifyEmail mocks base method.
+func (m *MockStore) GetVerifyEmail(ctx context.Context, id int64) (db.VerifyEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetVerifyEmail", ctx, id)
+	ret0, _ := ret[0].(db.VerifyEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetVerifyEmail indicates an expected call of GetVerifyEmail.
+func (mr *MockStoreMockRecorder) GetVerifyEmail(ctx, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetVerifyEmail", reflect.TypeOf((*MockStore)(nil).GetVerifyEmail), ctx, id)
+}
+
+// InvalidatePasswordResets mocks base method.
+func (m *MockStore) InvalidatePasswordResets(ctx context.Context, username string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InvalidatePasswordResets", ctx, username)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InvalidatePasswordResets indicates an expected call of InvalidatePasswordResets.
+func (mr *MockStoreMockRecorder) InvalidatePasswordResets(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InvalidatePasswordResets", reflect.TypeOf((*MockStore)(nil).InvalidatePasswordResets), ctx, username)
+}
+
+// ListAccount mocks base method.
+func (m *MockStore) ListAccount(ctx context.Context, arg db.ListAccountParams) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccount", ctx, arg)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccount indicates an expected call of ListAccount.
+func (mr *MockStoreMockRecorder) ListAccount(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccount", reflect.TypeOf((*MockStore)(nil).ListAccount), ctx, arg)
+}
+
+// ListAccountBalanceMismatches mocks base method.
+func (m *MockStore) ListAccountBalanceMismatches(ctx context.Context) ([]db.ListAccountBalanceMismatchesRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccountBalanceMismatches", ctx)
+	ret0, _ := ret[0].([]db.ListAccountBalanceMismatchesRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccountBalanceMismatches indicates an expected call of ListAccountBalanceMismatches.
+func (mr *MockStoreMockRecorder) ListAccountBalanceMismatches(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccountBalanceMismatches", reflect.TypeOf((*MockStore)(nil).ListAccountBalanceMismatches), ctx)
+}
+
+// ListAccountsByIDs mocks base method.
+func (m *MockStore) ListAccountsByIDs(ctx context.Context, ids []int64) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAccountsByIDs", ctx, ids)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAccountsByIDs indicates an expected call of ListAccountsByIDs.
+func (mr *MockStoreMockRecorder) ListAccountsByIDs(ctx, ids any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAccountsByIDs", reflect.TypeOf((*MockStore)(nil).ListAccountsByIDs), ctx, ids)
+}
+
+// ListAllAccounts mocks base method.
+func (m *MockStore) ListAllAccounts(ctx context.Context, arg db.ListAllAccountsParams) ([]db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAllAccounts", ctx, arg)
+	ret0, _ := ret[0].([]db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAllAccounts indicates an expected call of ListAllAccounts.
+func (mr *MockStoreMockRecorder) ListAllAccounts(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAllAccounts", reflect.TypeOf((*MockStore)(nil).ListAllAccounts), ctx, arg)
+}
+
+// ListAuditEvents mocks base method.
+func (m *MockStore) ListAuditEvents(ctx context.Context, arg db.ListAuditEventsParams) ([]db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAuditEvents", ctx, arg)
+	ret0, _ := ret[0].([]db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAuditEvents indicates an expected call of ListAuditEvents.
+func (mr *MockStoreMockRecorder) ListAuditEvents(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAuditEvents", reflect.TypeOf((*MockStore)(nil).ListAuditEvents), ctx, arg)
+}
+
+// ListAuditEventsAfter mocks base method.
+func (m *MockStore) ListAuditEventsAfter(ctx context.Context, arg db.ListAuditEventsAfterParams) ([]db.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAuditEventsAfter", ctx, arg)
+	ret0, _ := ret[0].([]db.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAuditEventsAfter indicates an expected call of ListAuditEventsAfter.
+func (mr *MockStoreMockRecorder) ListAuditEventsAfter(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAuditEventsAfter", reflect.TypeOf((*MockStore)(nil).ListAuditEventsAfter), ctx, arg)
+}
+
+// ListCurrencies mocks base method.
+func (m *MockStore) ListCurrencies(ctx context.Context) ([]db.Currency, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCurrencies", ctx)
+	ret0, _ := ret[0].([]db.Currency)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCurrencies indicates an expected call of ListCurrencies.
+func (mr *MockStoreMockRecorder) ListCurrencies(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCurrencies", reflect.TypeOf((*MockStore)(nil).ListCurrencies), ctx)
+}
+
+// ListIncomingPaymentRequests mocks base method.
+func (m *MockStore) ListIncomingPaymentRequests(ctx context.Context, arg db.ListIncomingPaymentRequestsParams) ([]db.PaymentRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListIncomingPaymentRequests", ctx, arg)
+	ret0, _ := ret[0].([]db.PaymentRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListIncomingPaymentRequests indicates an expected call of ListIncomingPaymentRequests.
+func (mr *MockStoreMockRecorder) ListIncomingPaymentRequests(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListIncomingPaymentRequests", reflect.TypeOf((*MockStore)(nil).ListIncomingPaymentRequests), ctx, arg)
+}
+
+// ListInterestAccountsToPost mocks base method.
+func (m *MockStore) ListInterestAccountsToPost(ctx context.Context, arg db.ListInterestAccountsToPostParams) ([]db.ListInterestAccountsToPostRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListInterestAccountsToPost", ctx, arg)
+	ret0, _ := ret[0].([]db.ListInterestAccountsToPostRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListInterestAccountsToPost indicates an expected call of ListInterestAccountsToPost.
+func (mr *MockStoreMockRecorder) ListInterestAccountsToPost(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListInterestAccountsToPost", reflect.TypeOf((*MockStore)(nil).ListInterestAccountsToPost), ctx, arg)
+}
+
+// ListOutgoingPaymentRequests mocks base method.
+func (m *MockStore) ListOutgoingPaymentRequests(ctx context.Context, arg db.ListOutgoingPaymentRequestsParams) ([]db.PaymentRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOutgoingPaymentRequests", ctx, arg)
+	ret0, _ := ret[0].([]db.PaymentRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOutgoingPaymentRequests indicates an expected call of ListOutgoingPaymentRequests.
+func (mr *MockStoreMockRecorder) ListOutgoingPaymentRequests(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOutgoingPaymentRequests", reflect.TypeOf((*MockStore)(nil).ListOutgoingPaymentRequests), ctx, arg)
+}
+
+// ListOverdrawnBalancesAt mocks base method.
+func (m *MockStore) ListOverdrawnBalancesAt(ctx context.Context, at pgtype.Timestamptz) ([]db.ListOverdrawnBalancesAtRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListOverdrawnBalancesAt", ctx, at)
+	ret0, _ := ret[0].([]db.ListOverdrawnBalancesAtRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListOverdrawnBalancesAt indicates an expected call of ListOverdrawnBalancesAt.
+func (mr *MockStoreMockRecorder) ListOverdrawnBalancesAt(ctx, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListOverdrawnBalancesAt", reflect.TypeOf((*MockStore)(nil).ListOverdrawnBalancesAt), ctx, at)
+}
+
+// ListSavingsBalancesAt mocks base method.
+func (m *MockStore) ListSavingsBalancesAt(ctx context.Context, at pgtype.Timestamptz) ([]db.ListSavingsBalancesAtRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSavingsBalancesAt", ctx, at)
+	ret0, _ := ret[0].([]db.ListSavingsBalancesAtRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSavingsBalancesAt indicates an expected call of ListSavingsBalancesAt.
+func (mr *MockStoreMockRecorder) ListSavingsBalancesAt(ctx, at any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSavingsBalancesAt", reflect.TypeOf((*MockStore)(nil).ListSavingsBalancesAt), ctx, at)
+}
+
+// ListSessions mocks base method.
+func (m *MockStore) ListSessions(ctx context.Context, username string) ([]db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSessions", ctx, username)
+	ret0, _ := ret[0].([]db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSessions indicates an expected call of ListSessions.
+func (mr *MockStoreMockRecorder) ListSessions(ctx, username any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSessions", reflect.TypeOf((*MockStore)(nil).ListSessions), ctx, username)
+}
+
+// ListTransferBatchItems mocks base method.
+func (m *MockStore) ListTransferBatchItems(ctx context.Context, batchID int64) ([]db.TransferBatchItem, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransferBatchItems", ctx, batchID)
+	ret0, _ := ret[0].([]db.TransferBatchItem)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransferBatchItems indicates an expected call of ListTransferBatchItems.
+func (mr *MockStoreMockRecorder) ListTransferBatchItems(ctx, batchID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransferBatchItems", reflect.TypeOf((*MockStore)(nil).ListTransferBatchItems), ctx, batchID)
+}
+
+// ListTransferReversals mocks base method.
+func (m *MockStore) ListTransferReversals(ctx context.Context, originalTransferID int64) ([]db.TransferReversal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTransferReversals", ctx, originalTransferID)
+	ret0, _ := ret[0].([]db.TransferReversal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTransferReversals indicates an expected call of ListTransferReversals.
+func (mr *MockStoreMockRecorder) ListTransferReversals(ctx, originalTransferID any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTransferReversals", reflect.TypeOf((*MockStore)(nil).ListTransferReversals), ctx, originalTransferID)
+}
+
+// LockAuditChain mocks base method.
+func (m *MockStore) LockAuditChain(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockAuditChain", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockAuditChain indicates an expected call of LockAuditChain.
+func (mr *MockStoreMockRecorder) LockAuditChain(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockAuditChain", reflect.TypeOf((*MockStore)(nil).LockAuditChain), ctx)
+}
+
+// PlaceHoldTx mocks base method.
+func (m *MockStore) PlaceHoldTx(ctx context.Context, placeHoldParams db.PlaceHoldTxParams) (error, db.PlaceHoldTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PlaceHoldTx", ctx, placeHoldParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.PlaceHoldTxResult)
+	return ret0, ret1
+}
+
+// PlaceHoldTx indicates an expected call of PlaceHoldTx.
+func (mr *MockStoreMockRecorder) PlaceHoldTx(ctx, placeHoldParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PlaceHoldTx", reflect.TypeOf((*MockStore)(nil).PlaceHoldTx), ctx, placeHoldParams)
+}
+
+// PostInterestTx mocks base method.
+func (m *MockStore) PostInterestTx(ctx context.Context, postInterestParams db.PostInterestTxParams) (error, db.PostInterestTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PostInterestTx", ctx, postInterestParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.PostInterestTxResult)
+	return ret0, ret1
+}
+
+// PostInterestTx indicates an expected call of PostInterestTx.
+func (mr *MockStoreMockRecorder) PostInterestTx(ctx, postInterestParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostInterestTx", reflect.TypeOf((*MockStore)(nil).PostInterestTx), ctx, postInterestParams)
+}
+
+// RecordAuditEventTx mocks base method.
+func (m *MockStore) RecordAuditEventTx(ctx context.Context, auditEventParams db.AuditEventParams) (error, db.RecordAuditEventTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordAuditEventTx", ctx, auditEventParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.RecordAuditEventTxResult)
+	return ret0, ret1
+}
+
+// RecordAuditEventTx indicates an expected call of RecordAuditEventTx.
+func (mr *MockStoreMockRecorder) RecordAuditEventTx(ctx, auditEventParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordAuditEventTx", reflect.TypeOf((*MockStore)(nil).RecordAuditEventTx), ctx, auditEventParams)
+}
+
+// ReleaseHoldTx mocks base method.
+func (m *MockStore) ReleaseHoldTx(ctx context.Context, releaseHoldParams db.ReleaseHoldTxParams) (error, db.ReleaseHoldTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseHoldTx", ctx, releaseHoldParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.ReleaseHoldTxResult)
+	return ret0, ret1
+}
+
+// ReleaseHoldTx indicates an expected call of ReleaseHoldTx.
+func (mr *MockStoreMockRecorder) ReleaseHoldTx(ctx, releaseHoldParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseHoldTx", reflect.TypeOf((*MockStore)(nil).ReleaseHoldTx), ctx, releaseHoldParams)
+}
+
+// ResendVerifyEmailTx mocks base method.
+func (m *MockStore) ResendVerifyEmailTx(ctx context.Context, resendParams db.ResendVerifyEmailTxParams) (error, db.ResendVerifyEmailTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResendVerifyEmailTx", ctx, resendParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.ResendVerifyEmailTxResult)
+	return ret0, ret1
+}
+
+// ResendVerifyEmailTx indicates an expected call of ResendVerifyEmailTx.
+func (mr *MockStoreMockRecorder) ResendVerifyEmailTx(ctx, resendParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResendVerifyEmailTx", reflect.TypeOf((*MockStore)(nil).ResendVerifyEmailTx), ctx, resendParams)
+}
+
+// ResetPasswordTx mocks base method.
+func (m *MockStore) ResetPasswordTx(ctx context.Context, resetPasswordParams db.ResetPasswordTxParams) (error, db.ResetPasswordTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPasswordTx", ctx, resetPasswordParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.ResetPasswordTxResult)
+	return ret0, ret1
+}
+
+// ResetPasswordTx indicates an expected call of ResetPasswordTx.
+func (mr *MockStoreMockRecorder) ResetPasswordTx(ctx, resetPasswordParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPasswordTx", reflect.TypeOf((*MockStore)(nil).ResetPasswordTx), ctx, resetPasswordParams)
+}
+
+// ResolvePaymentRequest mocks base method.
+func (m *MockStore) ResolvePaymentRequest(ctx context.Context, arg db.ResolvePaymentRequestParams) (db.PaymentRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResolvePaymentRequest", ctx, arg)
+	ret0, _ := ret[0].(db.PaymentRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ResolvePaymentRequest indicates an expected call of ResolvePaymentRequest.
+func (mr *MockStoreMockRecorder) ResolvePaymentRequest(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResolvePaymentRequest", reflect.TypeOf((*MockStore)(nil).ResolvePaymentRequest), ctx, arg)
+}
+
+// RetryTask mocks base method.
+func (m *MockStore) RetryTask(ctx context.Context, arg db.RetryTaskParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryTask", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryTask indicates an expected call of RetryTask.
+func (mr *MockStoreMockRecorder) RetryTask(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryTask", reflect.TypeOf((*MockStore)(nil).RetryTask), ctx, arg)
+}
+
+// ReverseTransferTx mocks base method.
+func (m *MockStore) ReverseTransferTx(ctx context.Context, reverseTransferParams db.ReverseTransferTxParams) (error, db.ReverseTransferTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReverseTransferTx", ctx, reverseTransferParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.ReverseTransferTxResult)
+	return ret0, ret1
+}
+
+// ReverseTransferTx indicates an expected call of ReverseTransferTx.
+func (mr *MockStoreMockRecorder) ReverseTransferTx(ctx, reverseTransferParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReverseTransferTx", reflect.TypeOf((*MockStore)(nil).ReverseTransferTx), ctx, reverseTransferParams)
+}
+
+// SetHoldTransfer mocks base method.
+func (m *MockStore) SetHoldTransfer(ctx context.Context, arg db.SetHoldTransferParams) (db.Hold, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetHoldTransfer", ctx, arg)
+	ret0, _ := ret[0].(db.Hold)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetHoldTransfer indicates an expected call of SetHoldTransfer.
+func (mr *MockStoreMockRecorder) SetHoldTransfer(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetHoldTransfer", reflect.TypeOf((*MockStore)(nil).SetHoldTransfer), ctx, arg)
+}
+
+// SetUserAlias mocks base method.
+func (m *MockStore) SetUserAlias(ctx context.Context, arg db.SetUserAliasParams) (db.UserAlias, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserAlias", ctx, arg)
+	ret0, _ := ret[0].(db.UserAlias)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetUserAlias indicates an expected call of SetUserAlias.
+func (mr *MockStoreMockRecorder) SetUserAlias(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserAlias", reflect.TypeOf((*MockStore)(nil).SetUserAlias), ctx, arg)
+}
+
+// TakeRateLimitToken mocks base method.
+func (m *MockStore) TakeRateLimitToken(ctx context.Context, arg db.TakeRateLimitTokenParams) (db.TakeRateLimitTokenRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeRateLimitToken", ctx, arg)
+	ret0, _ := ret[0].(db.TakeRateLimitTokenRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeRateLimitToken indicates an expected call of TakeRateLimitToken.
+func (mr *MockStoreMockRecorder) TakeRateLimitToken(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeRateLimitToken", reflect.TypeOf((*MockStore)(nil).TakeRateLimitToken), ctx, arg)
+}
+
+// TransferTx mocks base method.
+func (m *MockStore) TransferTx(ctx context.Context, transferParams db.TransferTxParams) (error, db.TransferTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TransferTx", ctx, transferParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.TransferTxResult)
+	return ret0, ret1
+}
+
+// TransferTx indicates an expected call of TransferTx.
+func (mr *MockStoreMockRecorder) TransferTx(ctx, transferParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TransferTx", reflect.TypeOf((*MockStore)(nil).TransferTx), ctx, transferParams)
+}
+
+// UpdateAccount mocks base method.
+func (m *MockStore) UpdateAccount(ctx context.Context, arg db.UpdateAccountParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccount", ctx, arg)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAccount indicates an expected call of UpdateAccount.
+func (mr *MockStoreMockRecorder) UpdateAccount(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccount", reflect.TypeOf((*MockStore)(nil).UpdateAccount), ctx, arg)
+}
+
+// UpdateAccountFrozen mocks base method.
+func (m *MockStore) UpdateAccountFrozen(ctx context.Context, arg db.UpdateAccountFrozenParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccountFrozen", ctx, arg)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAccountFrozen indicates an expected call of UpdateAccountFrozen.
+func (mr *MockStoreMockRecorder) UpdateAccountFrozen(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountFrozen", reflect.TypeOf((*MockStore)(nil).UpdateAccountFrozen), ctx, arg)
+}
+
+// UpdateAccountOverdraftLimit mocks base method.
+func (m *MockStore) UpdateAccountOverdraftLimit(ctx context.Context, arg db.UpdateAccountOverdraftLimitParams) (db.Account, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAccountOverdraftLimit", ctx, arg)
+	ret0, _ := ret[0].(db.Account)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAccountOverdraftLimit indicates an expected call of UpdateAccountOverdraftLimit.
+func (mr *MockStoreMockRecorder) UpdateAccountOverdraftLimit(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAccountOverdraftLimit", reflect.TypeOf((*MockStore)(nil).UpdateAccountOverdraftLimit), ctx, arg)
+}
+
+// UpdateCurrencyEnabled mocks base method.
+func (m *MockStore) UpdateCurrencyEnabled(ctx context.Context, arg db.UpdateCurrencyEnabledParams) (db.Currency, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCurrencyEnabled", ctx, arg)
+	ret0, _ := ret[0].(db.Currency)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateCurrencyEnabled indicates an expected call of UpdateCurrencyEnabled.
+func (mr *MockStoreMockRecorder) UpdateCurrencyEnabled(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCurrencyEnabled", reflect.TypeOf((*MockStore)(nil).UpdateCurrencyEnabled), ctx, arg)
+}
+
+// UpdateOverdraftLimitTx mocks base method.
+func (m *MockStore) UpdateOverdraftLimitTx(ctx context.Context, updateOverdraftLimitParams db.UpdateOverdraftLimitTxParams) (error, db.UpdateOverdraftLimitTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOverdraftLimitTx", ctx, updateOverdraftLimitParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.UpdateOverdraftLimitTxResult)
+	return ret0, ret1
+}
+
+// UpdateOverdraftLimitTx indicates an expected call of UpdateOverdraftLimitTx.
+func (mr *MockStoreMockRecorder) UpdateOverdraftLimitTx(ctx, updateOverdraftLimitParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOverdraftLimitTx", reflect.TypeOf((*MockStore)(nil).UpdateOverdraftLimitTx), ctx, updateOverdraftLimitParams)
+}
+
+// UpdateUser mocks base method.
+func (m *MockStore) UpdateUser(ctx context.Context, arg db.UpdateUserParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUser", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUser indicates an expected call of UpdateUser.
+func (mr *MockStoreMockRecorder) UpdateUser(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockStore)(nil).UpdateUser), ctx, arg)
+}
+
+// UpdateUserPassword mocks base method.
+func (m *MockStore) UpdateUserPassword(ctx context.Context, arg db.UpdateUserPasswordParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserPassword", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserPassword indicates an expected call of UpdateUserPassword.
+func (mr *MockStoreMockRecorder) UpdateUserPassword(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserPassword", reflect.TypeOf((*MockStore)(nil).UpdateUserPassword), ctx, arg)
+}
+
+// UpdateUserTx mocks base method.
+func (m *MockStore) UpdateUserTx(ctx context.Context, updateUserParams db.UpdateUserTxParams) (error, db.UpdateUserTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserTx", ctx, updateUserParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.UpdateUserTxResult)
+	return ret0, ret1
+}
+
+// UpdateUserTx indicates an expected call of UpdateUserTx.
+func (mr *MockStoreMockRecorder) UpdateUserTx(ctx, updateUserParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserTx", reflect.TypeOf((*MockStore)(nil).UpdateUserTx), ctx, updateUserParams)
+}
+
+// UpdateVerifyEmail mocks base method.
+func (m *MockStore) UpdateVerifyEmail(ctx context.Context, arg db.UpdateVerifyEmailParams) (db.VerifyEmail, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateVerifyEmail", ctx, arg)
+	ret0, _ := ret[0].(db.VerifyEmail)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateVerifyEmail indicates an expected call of UpdateVerifyEmail.
+func (mr *MockStoreMockRecorder) UpdateVerifyEmail(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateVerifyEmail", reflect.TypeOf((*MockStore)(nil).UpdateVerifyEmail), ctx, arg)
+}
+
+// UpsertUserTOTP mocks base method.
+func (m *MockStore) UpsertUserTOTP(ctx context.Context, arg db.UpsertUserTOTPParams) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertUserTOTP", ctx, arg)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertUserTOTP indicates an expected call of UpsertUserTOTP.
+func (mr *MockStoreMockRecorder) UpsertUserTOTP(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertUserTOTP", reflect.TypeOf((*MockStore)(nil).UpsertUserTOTP), ctx, arg)
+}
+
+// UsePasswordReset mocks base method.
+func (m *MockStore) UsePasswordReset(ctx context.Context, tokenHash string) (db.PasswordReset, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UsePasswordReset", ctx, tokenHash)
+	ret0, _ := ret[0].(db.PasswordReset)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UsePasswordReset indicates an expected call of UsePasswordReset.
+func (mr *MockStoreMockRecorder) UsePasswordReset(ctx, tokenHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UsePasswordReset", reflect.TypeOf((*MockStore)(nil).UsePasswordReset), ctx, tokenHash)
+}
+
+// UseRecoveryCode mocks base method.
+func (m *MockStore) UseRecoveryCode(ctx context.Context, arg db.UseRecoveryCodeParams) (db.RecoveryCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseRecoveryCode", ctx, arg)
+	ret0, _ := ret[0].(db.RecoveryCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseRecoveryCode indicates an expected call of UseRecoveryCode.
+func (mr *MockStoreMockRecorder) UseRecoveryCode(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseRecoveryCode", reflect.TypeOf((*MockStore)(nil).UseRecoveryCode), ctx, arg)
+}
+
+// UseTOTPStep mocks base method.
+func (m *MockStore) UseTOTPStep(ctx context.Context, arg db.UseTOTPStepParams) (db.UserTotp, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseTOTPStep", ctx, arg)
+	ret0, _ := ret[0].(db.UserTotp)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseTOTPStep indicates an expected call of UseTOTPStep.
+func (mr *MockStoreMockRecorder) UseTOTPStep(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseTOTPStep", reflect.TypeOf((*MockStore)(nil).UseTOTPStep), ctx, arg)
+}
+
+// VerifyEmailTx mocks base method.
+func (m *MockStore) VerifyEmailTx(ctx context.Context, verifyEmailParams db.VerifyEmailTxParams) (error, db.VerifyEmailTxResult) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyEmailTx", ctx, verifyEmailParams)
+	ret0, _ := ret[0].(error)
+	ret1, _ := ret[1].(db.VerifyEmailTxResult)
+	return ret0, ret1
+}
+
+// VerifyEmailTx indicates an expected call of VerifyEmailTx.
+func (mr *MockStoreMockRecorder) VerifyEmailTx(ctx, verifyEmailParams any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyEmailTx", reflect.TypeOf((*MockStore)(nil).VerifyEmailTx), ctx, verifyEmailParams)
+}
+
+// VerifyUserEmail mocks base method.
+func (m *MockStore) VerifyUserEmail(ctx context.Context, arg db.VerifyUserEmailParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyUserEmail", ctx, arg)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyUserEmail indicates an expected call of VerifyUserEmail.
+func (mr *MockStoreMockRecorder) VerifyUserEmail(ctx, arg any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyUserEmail", reflect.TypeOf((*MockStore)(nil).VerifyUserEmail), ctx, arg)
 }
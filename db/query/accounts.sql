@@ -2,15 +2,20 @@
 INSERT INTO accounts (
     owner,
     balance,
-    currency
+    currency,
+    type
 ) VALUES (
-             $1, $2, $3
+             $1, $2, $3, $4
          ) RETURNING *;
 
 -- name: GetAccount :one
 SELECT * FROM accounts
 WHERE id = $1 LIMIT 1;
 
+-- name: GetAccountByOwnerCurrency :one
+SELECT * FROM accounts
+WHERE owner = $1 AND currency = $2 AND type = 'checking' LIMIT 1;
+
 -- name: GetAccountForUpdate :one
 SELECT * FROM accounts
 WHERE id = $1 LIMIT 1
@@ -38,4 +43,35 @@ WHERE id = sqlc.arg(id)
 
 -- name: DeleteAccount :exec
 DELETE FROM accounts
-WHERE id = $1;
\ No newline at end of file
+WHERE id = $1;
+
+-- name: ListAllAccounts :many
+SELECT * FROM accounts
+ORDER BY id
+    LIMIT $1
+OFFSET $2;
+
+-- name: UpdateAccountFrozen :one
+UPDATE accounts
+SET is_frozen = $2
+WHERE id = $1
+    RETURNING *;
+
+-- name: UpdateAccountOverdraftLimit :one
+UPDATE accounts
+SET overdraft_limit = $2
+WHERE id = $1
+    RETURNING *;
+
+-- name: ListAccountBalanceMismatches :many
+SELECT a.id, a.owner, a.currency, a.balance, COALESCE(SUM(e.amount), 0)::bigint AS entries_total
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id
+GROUP BY a.id
+HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY a.id;
+
+-- name: ListAccountsByIDs :many
+SELECT * FROM accounts
+WHERE id = ANY(@ids::bigint[])
+ORDER BY id;
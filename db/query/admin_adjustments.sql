@@ -0,0 +1,6 @@
+-- name: CreateAdminAdjustment :one
+INSERT INTO admin_adjustments (
+    transfer_id,
+    operator,
+    reason
+) VALUES ($1, $2, $3) RETURNING *;
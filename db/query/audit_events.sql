@@ -0,0 +1,39 @@
+-- name: LockAuditChain :exec
+SELECT pg_advisory_xact_lock(hashtext('audit_events'));
+
+-- name: GetLastAuditEventHash :one
+SELECT hash FROM audit_events
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: CreateAuditEvent :one
+INSERT INTO audit_events (
+    actor,
+    action,
+    target,
+    client_ip,
+    user_agent,
+    request_id,
+    before,
+    after,
+    prev_hash,
+    hash,
+    created_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+) RETURNING *;
+
+-- name: ListAuditEvents :many
+SELECT * FROM audit_events
+WHERE (sqlc.narg(actor)::varchar IS NULL OR actor = sqlc.narg(actor))
+    AND (sqlc.narg(action)::varchar IS NULL OR action = sqlc.narg(action))
+    AND (sqlc.narg(target)::varchar IS NULL OR target = sqlc.narg(target))
+ORDER BY id DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: ListAuditEventsAfter :many
+SELECT * FROM audit_events
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
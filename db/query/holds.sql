@@ -0,0 +1,43 @@
+-- name: CloseHold :one
+UPDATE holds
+SET status = $2,
+    closed_at = now()
+WHERE id = $1 AND status = 'active' AND expires_at > now()
+RETURNING *;
+
+-- name: CreateHold :one
+INSERT INTO holds (
+    account_id,
+    amount,
+    reference,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: ExpireHolds :execrows
+UPDATE holds
+SET status = 'expired',
+    closed_at = now()
+WHERE status = 'active' AND expires_at <= now();
+
+-- name: GetAccountHeldAmount :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS held_amount
+FROM holds
+WHERE account_id = $1 AND status = 'active' AND expires_at > now();
+
+-- name: GetHold :one
+SELECT * FROM holds
+WHERE id = $1 LIMIT 1;
+
+-- name: GetHoldForUpdate :one
+SELECT * FROM holds
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: SetHoldTransfer :one
+UPDATE holds
+SET captured_amount = $2,
+    transfer_id = $3
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,65 @@
+-- name: CreateInterestAccrual :execrows
+INSERT INTO interest_accruals (
+    account_id,
+    accrual_date,
+    kind,
+    balance,
+    annual_rate_bps,
+    amount_micros
+) VALUES ($1, $2, $3, $4, $5, $6)
+ON CONFLICT (account_id, accrual_date, kind) DO NOTHING;
+
+-- name: CreateInterestPosting :one
+INSERT INTO interest_postings (
+    account_id,
+    period,
+    kind,
+    amount,
+    transfer_id
+) VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: GetAccruedInterest :one
+SELECT COALESCE(SUM(amount_micros), 0)::bigint AS amount_micros
+FROM interest_accruals
+WHERE account_id = $1 AND kind = $2 AND accrual_date < sqlc.arg(before);
+
+-- name: GetInterestPosting :one
+SELECT * FROM interest_postings
+WHERE account_id = $1 AND period = $2 AND kind = $3 LIMIT 1;
+
+-- name: GetLatestInterestAccrualDate :one
+SELECT MAX(accrual_date)::date AS accrual_date
+FROM interest_accruals;
+
+-- name: GetPostedInterest :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS amount
+FROM interest_postings
+WHERE account_id = $1 AND kind = $2;
+
+-- name: ListInterestAccountsToPost :many
+SELECT DISTINCT a.id, a.currency, ia.kind
+FROM interest_accruals ia
+JOIN accounts a ON a.id = ia.account_id
+WHERE ia.accrual_date < sqlc.arg(before)
+  AND NOT EXISTS (
+    SELECT 1 FROM interest_postings ip
+    WHERE ip.account_id = a.id AND ip.period = sqlc.arg(period) AND ip.kind = ia.kind
+  )
+ORDER BY a.id, ia.kind;
+
+-- name: ListOverdrawnBalancesAt :many
+SELECT a.id, a.currency, (a.balance - COALESCE(SUM(e.amount), 0))::bigint AS balance
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id AND e.created_at >= sqlc.arg(at)
+WHERE a.overdraft_limit > 0 AND a.created_at < sqlc.arg(at)
+GROUP BY a.id
+HAVING a.balance - COALESCE(SUM(e.amount), 0) < 0
+ORDER BY a.id;
+
+-- name: ListSavingsBalancesAt :many
+SELECT a.id, a.currency, (a.balance - COALESCE(SUM(e.amount), 0))::bigint AS balance
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id AND e.created_at >= sqlc.arg(at)
+WHERE a.type = 'savings' AND a.created_at < sqlc.arg(at)
+GROUP BY a.id
+ORDER BY a.id;
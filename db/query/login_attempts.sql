@@ -0,0 +1,27 @@
+-- name: CreateLoginAttempt :one
+INSERT INTO login_attempts (
+    username,
+    client_ip,
+    user_agent,
+    outcome
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: CountUserLoginFailures :one
+SELECT count(*) AS failures, max(created_at)::timestamptz AS last_failed_at
+FROM login_attempts
+WHERE username = @username
+    AND outcome = 'invalid_credentials'
+    AND created_at > @since
+    AND created_at > COALESCE((
+        SELECT max(created_at) FROM login_attempts
+        WHERE username = @username AND outcome = 'success'
+    ), '-infinity');
+
+-- name: CountIPLoginFailures :one
+SELECT count(*) AS failures, max(created_at)::timestamptz AS last_failed_at
+FROM login_attempts
+WHERE client_ip = @client_ip
+    AND outcome = 'invalid_credentials'
+    AND created_at > @since;
@@ -0,0 +1,22 @@
+-- name: CreatePasswordReset :one
+INSERT INTO password_resets (
+    username,
+    token_hash,
+    expired_at
+) VALUES (
+    $1, $2, $3
+) RETURNING *;
+
+-- name: UsePasswordReset :one
+UPDATE password_resets
+SET is_used = TRUE
+WHERE token_hash = $1
+    AND is_used = FALSE
+    AND expired_at > now()
+RETURNING *;
+
+-- name: InvalidatePasswordResets :exec
+UPDATE password_resets
+SET is_used = TRUE
+WHERE username = $1
+    AND is_used = FALSE;
@@ -0,0 +1,21 @@
+-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets AS b (
+    key,
+    tokens,
+    allowed,
+    rate,
+    burst
+) VALUES (
+    @key, @burst::float8 - 1, TRUE, @rate::float8, @burst::float8
+) ON CONFLICT (key) DO UPDATE
+SET tokens = LEAST(EXCLUDED.burst, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * EXCLUDED.rate)
+        - CASE WHEN LEAST(EXCLUDED.burst, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * EXCLUDED.rate) >= 1 THEN 1 ELSE 0 END,
+    allowed = LEAST(EXCLUDED.burst, b.tokens + EXTRACT(EPOCH FROM now() - b.updated_at) * EXCLUDED.rate) >= 1,
+    rate = EXCLUDED.rate,
+    burst = EXCLUDED.burst,
+    updated_at = now()
+RETURNING tokens, allowed;
+
+-- name: DeleteFullRateLimitBuckets :exec
+DELETE FROM rate_limit_buckets
+WHERE updated_at + make_interval(secs => (burst - tokens) / rate) < now();
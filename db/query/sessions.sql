@@ -12,4 +12,20 @@ INSERT INTO sessions (
 ) RETURNING *;
 
 -- name: GetSessions :one
-SELECT * FROM sessions WHERE id = $1 LIMIT 1;
\ No newline at end of file
+SELECT * FROM sessions WHERE id = $1 LIMIT 1;
+
+-- name: ListSessions :many
+SELECT * FROM sessions
+WHERE username = $1
+ORDER BY created_at DESC;
+
+-- name: BlockSession :one
+UPDATE sessions
+SET is_blocked = true
+WHERE id = $1
+RETURNING *;
+
+-- name: BlockUserSessions :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE username = $1;
@@ -0,0 +1,53 @@
+-- name: CreateTask :one
+INSERT INTO tasks (
+    type,
+    payload,
+    max_attempts,
+    scheduled_at
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: GetTask :one
+SELECT * FROM tasks
+WHERE id = $1 LIMIT 1;
+
+-- name: ClaimTasks :many
+UPDATE tasks
+SET status = 'running',
+    attempts = attempts + 1,
+    locked_until = now() + sqlc.arg(lease)::interval,
+    updated_at = now()
+WHERE id IN (
+    SELECT id FROM tasks
+    WHERE (tasks.status = 'pending' AND tasks.scheduled_at <= now())
+       OR (tasks.status = 'running' AND tasks.locked_until < now())
+    ORDER BY tasks.scheduled_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteTask :exec
+UPDATE tasks
+SET status = 'done',
+    locked_until = NULL,
+    updated_at = now()
+WHERE id = $1;
+
+-- name: RetryTask :exec
+UPDATE tasks
+SET status = 'pending',
+    last_error = $2,
+    scheduled_at = $3,
+    locked_until = NULL,
+    updated_at = now()
+WHERE id = $1;
+
+-- name: FailTask :exec
+UPDATE tasks
+SET status = 'dead',
+    last_error = $2,
+    locked_until = NULL,
+    updated_at = now()
+WHERE id = $1;
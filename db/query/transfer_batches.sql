@@ -0,0 +1,27 @@
+-- name: CreateTransferBatch :one
+INSERT INTO transfer_batches (
+    from_account_id,
+    mode,
+    status,
+    initiated_by
+) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: CreateTransferBatchItem :one
+INSERT INTO transfer_batch_items (
+    batch_id,
+    position,
+    to_account_id,
+    amount,
+    status,
+    transfer_id,
+    error
+) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING *;
+
+-- name: GetTransferBatch :one
+SELECT * FROM transfer_batches
+WHERE id = $1 LIMIT 1;
+
+-- name: ListTransferBatchItems :many
+SELECT * FROM transfer_batch_items
+WHERE batch_id = $1
+ORDER BY position;
@@ -0,0 +1,18 @@
+-- name: CreateTransferReversal :one
+INSERT INTO transfer_reversals (
+    original_transfer_id,
+    transfer_id,
+    amount,
+    reason,
+    initiated_by
+) VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: GetReversedAmount :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS reversed_amount
+FROM transfer_reversals
+WHERE original_transfer_id = $1;
+
+-- name: ListTransferReversals :many
+SELECT * FROM transfer_reversals
+WHERE original_transfer_id = $1
+ORDER BY id;
@@ -3,4 +3,13 @@ INSERT INTO transfers (
     from_account_id,
     to_account_id,
     amount
-) VALUES ($1, $2, $3) RETURNING *;
\ No newline at end of file
+) VALUES ($1, $2, $3) RETURNING *;
+
+-- name: GetTransfer :one
+SELECT * FROM transfers
+WHERE id = $1 LIMIT 1;
+
+-- name: GetTransferForUpdate :one
+SELECT * FROM transfers
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
@@ -0,0 +1,18 @@
+-- name: DeleteUserAlias :exec
+DELETE FROM user_aliases
+WHERE username = $1;
+
+-- name: GetUserAlias :one
+SELECT * FROM user_aliases
+WHERE alias = $1 LIMIT 1;
+
+-- name: SetUserAlias :one
+INSERT INTO user_aliases (
+    alias,
+    username
+) VALUES (
+    $1, $2
+) ON CONFLICT (username) DO UPDATE
+SET alias = EXCLUDED.alias,
+    created_at = now()
+RETURNING *;
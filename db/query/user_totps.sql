@@ -0,0 +1,30 @@
+-- name: UpsertUserTOTP :one
+INSERT INTO user_totps (
+    username,
+    secret
+) VALUES (
+    $1, $2
+) ON CONFLICT (username) DO UPDATE
+SET secret = EXCLUDED.secret,
+    last_used_step = 0,
+    created_at = now()
+WHERE user_totps.confirmed_at IS NULL
+RETURNING *;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totps
+WHERE username = $1 LIMIT 1;
+
+-- name: ConfirmUserTOTP :one
+UPDATE user_totps
+SET confirmed_at = now()
+WHERE username = $1
+    AND confirmed_at IS NULL
+RETURNING *;
+
+-- name: UseTOTPStep :one
+UPDATE user_totps
+SET last_used_step = @step
+WHERE username = @username
+    AND last_used_step < @step
+RETURNING *;
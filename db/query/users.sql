@@ -4,11 +4,40 @@ INSERT INTO users (
     username,
     hashed_password,
     full_name,
-    email
+    email,
+    role,
+    is_email_verified
 ) VALUES (
-             $1, $2, $3, $4
+             $1, $2, $3, $4, $5, $6
          ) RETURNING *;
 
 -- name: GetUser :one
 SELECT * FROM users
-WHERE username = $1 LIMIT 1;
\ No newline at end of file
+WHERE username = $1 LIMIT 1;
+
+-- name: VerifyUserEmail :one
+UPDATE users
+SET is_email_verified = TRUE
+WHERE username = $1
+    AND email = $2
+RETURNING *;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1 LIMIT 1;
+
+-- name: UpdateUserPassword :one
+UPDATE users
+SET hashed_password = $2,
+    password_changed_at = now()
+WHERE username = $1
+RETURNING *;
+
+-- name: UpdateUser :one
+UPDATE users
+SET
+    full_name = COALESCE(sqlc.narg(full_name), full_name),
+    email = COALESCE(sqlc.narg(email), email),
+    is_email_verified = is_email_verified AND COALESCE(sqlc.narg(email) = email, TRUE)
+WHERE username = sqlc.arg(username)
+RETURNING *;
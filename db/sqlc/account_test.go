@@ -2,20 +2,28 @@ package db
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jxgzzztang/simplebank/util"
 	"github.com/stretchr/testify/require"
+	"strconv"
 	"testing"
 	"time"
 )
 
 func RandomAccount(t *testing.T) Account {
+	return randomAccountIn(t, util.RandomCurrency())
+}
+
+func randomAccountIn(t *testing.T, currency string) Account {
 	ctx := context.Background()
 	user := RandomUser(t)
 	accountsParams := CreateAccountParams{
 		Owner:    user.Username,
 		Balance:  util.RandomMoney(),
-		Currency: util.RandomCurrency(),
+		Currency: currency,
+		Type:     util.CheckingAccount,
 	}
 
 	result, err := testQuery.CreateAccount(ctx, accountsParams)
@@ -99,3 +107,115 @@ func TestListAccounts(t *testing.T) {
 	}
 
 }
+
+func TestUpdateAccountFrozen(t *testing.T) {
+	account1 := RandomAccount(t)
+	require.False(t, account1.IsFrozen)
+
+	account2, err := testQuery.UpdateAccountFrozen(context.Background(), UpdateAccountFrozenParams{
+		ID:       account1.ID,
+		IsFrozen: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, account1.ID, account2.ID)
+	require.True(t, account2.IsFrozen)
+	require.Equal(t, account1.Balance, account2.Balance)
+}
+
+func TestUpdateAccountOverdraftLimit(t *testing.T) {
+	account1 := RandomAccount(t)
+	require.Zero(t, account1.OverdraftLimit)
+
+	account2, err := testQuery.UpdateAccountOverdraftLimit(context.Background(), UpdateAccountOverdraftLimitParams{
+		ID:             account1.ID,
+		OverdraftLimit: 500,
+	})
+	require.NoError(t, err)
+	require.Equal(t, account1.ID, account2.ID)
+	require.Equal(t, int64(500), account2.OverdraftLimit)
+	require.Equal(t, account1.Balance, account2.Balance)
+
+	_, err = testQuery.UpdateAccountOverdraftLimit(context.Background(), UpdateAccountOverdraftLimitParams{
+		ID:             account1.ID,
+		OverdraftLimit: -1,
+	})
+	require.Error(t, err)
+}
+
+func TestCreateAccountTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := RandomUser(t)
+	actor := AuditActor{Actor: user.Username}
+
+	err, result := store.CreateAccountTx(context.Background(), CreateAccountTxParams{
+		CreateAccountParams: CreateAccountParams{Owner: user.Username, Currency: util.USD, Type: util.CheckingAccount},
+		Audit:               actor,
+	})
+	require.NoError(t, err)
+	require.Equal(t, user.Username, result.Account.Owner)
+
+	events, err := testQuery.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Actor:  pgtype.Text{String: actor.Actor, Valid: true},
+		Action: pgtype.Text{String: AuditActionAccountCreate, Valid: true},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "account:"+strconv.FormatInt(result.Account.ID, 10), events[0].Target)
+
+	// a duplicate account leaves no event behind
+	err, _ = store.CreateAccountTx(context.Background(), CreateAccountTxParams{
+		CreateAccountParams: CreateAccountParams{Owner: user.Username, Currency: util.USD, Type: util.CheckingAccount},
+		Audit:               actor,
+	})
+	require.Error(t, err)
+	events, err = testQuery.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Actor: pgtype.Text{String: actor.Actor, Valid: true},
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+}
+
+func TestUpdateOverdraftLimitTx(t *testing.T) {
+	store := NewStore(testDB)
+	account := RandomAccount(t)
+	operator := util.RandomOwner()
+
+	err, result := store.UpdateOverdraftLimitTx(context.Background(), UpdateOverdraftLimitTxParams{
+		AccountID:      account.ID,
+		OverdraftLimit: 500,
+		Operator:       operator,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(500), result.Account.OverdraftLimit)
+
+	events, err := testQuery.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Actor:  pgtype.Text{String: operator, Valid: true},
+		Action: pgtype.Text{String: AuditActionAccountOverdraftSet, Valid: true},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "account:"+strconv.FormatInt(account.ID, 10), events[0].Target)
+
+	var before, after Account
+	require.NoError(t, json.Unmarshal(events[0].Before, &before))
+	require.NoError(t, json.Unmarshal(events[0].After, &after))
+	require.Zero(t, before.OverdraftLimit)
+	require.Equal(t, int64(500), after.OverdraftLimit)
+
+	// a rejected limit leaves no event behind
+	err, _ = store.UpdateOverdraftLimitTx(context.Background(), UpdateOverdraftLimitTxParams{
+		AccountID:      account.ID,
+		OverdraftLimit: -1,
+		Operator:       operator,
+	})
+	require.Error(t, err)
+	events, err = testQuery.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Actor: pgtype.Text{String: operator, Valid: true},
+		Limit: 10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+}
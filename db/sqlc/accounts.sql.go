@@ -13,7 +13,7 @@ const addAccountBalance = `-- name: AddAccountBalance :one
 UPDATE accounts
 SET balance = balance + $1
 WHERE id = $2
-    RETURNING id, owner, balance, currency, created_at
+    RETURNING id, owner, balance, currency, created_at, is_frozen, type, overdraft_limit
 `
 
 type AddAccountBalanceParams struct {
@@ -30,6 +30,9 @@ func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalancePa
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.Type,
+		&i.OverdraftLimit,
 	)
 	return i, err
 }
@@ -38,20 +41,27 @@ const createAccount = `-- name: CreateAccount :one
 INSERT INTO accounts (
     owner,
     balance,
-    currency
+    currency,
+    type
 ) VALUES (
-             $1, $2, $3
-         ) RETURNING id, owner, balance, currency, created_at
+             $1, $2, $3, $4
+         ) RETURNING id, owner, balance, currency, created_at, is_frozen, type, overdraft_limit
 `
 
 type CreateAccountParams struct {
 	Owner    string `json:"owner"`
 	Balance  int64  `json:"balance"`
 	Currency string `json:"currency"`
+	Type     string `json:"type"`
 }
 
 func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error) {
-	row := q.db.QueryRow(ctx, createAccount, arg.Owner, arg.Balance, arg.Currency)
+	row := q.db.QueryRow(ctx, createAccount,
+		arg.Owner,
+		arg.Balance,
+		arg.Currency,
+		arg.Type,
+	)
 	var i Account
 	err := row.Scan(
 		&i.ID,
@@ -59,6 +69,9 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.Type,
+		&i.OverdraftLimit,
 	)
 	return i, err
 }
@@ -74,7 +87,7 @@ func (q *Queries) DeleteAccount(ctx context.Context, id int64) error {
 }
 
 const getAccount = `-- name: GetAccount :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, is_frozen, type, overdraft_limit FROM accounts
 WHERE id = $1 LIMIT 1
 `
 
@@ -87,12 +100,41 @@ func (q *Queries) GetAccount(ctx context.Context, id int64) (Account, error) {
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.Type,
+		&i.OverdraftLimit,
+	)
+	return i, err
+}
+
+const getAccountByOwnerCurrency = `-- name: GetAccountByOwnerCurrency :one
+SELECT id, owner, balance, currency, created_at, is_frozen, type, overdraft_limit FROM accounts
+WHERE owner = $1 AND currency = $2 AND type = 'checking' LIMIT 1
+`
+
+type GetAccountByOwnerCurrencyParams struct {
+	Owner    string `json:"owner"`
+	Currency string `json:"currency"`
+}
+
+func (q *Queries) GetAccountByOwnerCurrency(ctx context.Context, arg GetAccountByOwnerCurrencyParams) (Account, error) {
+	row := q.db.QueryRow(ctx, getAccountByOwnerCurrency, arg.Owner, arg.Currency)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.Type,
+		&i.OverdraftLimit,
 	)
 	return i, err
 }
 
 const getAccountForUpdate = `-- name: GetAccountForUpdate :one
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, is_frozen, type, overdraft_limit FROM accounts
 WHERE id = $1 LIMIT 1
 FOR NO KEY UPDATE
 `
@@ -106,12 +148,15 @@ func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, e
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.Type,
+		&i.OverdraftLimit,
 	)
 	return i, err
 }
 
 const listAccount = `-- name: ListAccount :many
-SELECT id, owner, balance, currency, created_at FROM accounts
+SELECT id, owner, balance, currency, created_at, is_frozen, type, overdraft_limit FROM accounts
 WHERE owner = $1
 ORDER BY id
     LIMIT $2
@@ -139,6 +184,128 @@ func (q *Queries) ListAccount(ctx context.Context, arg ListAccountParams) ([]Acc
 			&i.Balance,
 			&i.Currency,
 			&i.CreatedAt,
+			&i.IsFrozen,
+			&i.Type,
+			&i.OverdraftLimit,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccountBalanceMismatches = `-- name: ListAccountBalanceMismatches :many
+SELECT a.id, a.owner, a.currency, a.balance, COALESCE(SUM(e.amount), 0)::bigint AS entries_total
+FROM accounts a
+LEFT JOIN entries e ON e.account_id = a.id
+GROUP BY a.id
+HAVING a.balance <> COALESCE(SUM(e.amount), 0)
+ORDER BY a.id
+`
+
+type ListAccountBalanceMismatchesRow struct {
+	ID           int64  `json:"id"`
+	Owner        string `json:"owner"`
+	Currency     string `json:"currency"`
+	Balance      int64  `json:"balance"`
+	EntriesTotal int64  `json:"entries_total"`
+}
+
+func (q *Queries) ListAccountBalanceMismatches(ctx context.Context) ([]ListAccountBalanceMismatchesRow, error) {
+	rows, err := q.db.Query(ctx, listAccountBalanceMismatches)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAccountBalanceMismatchesRow{}
+	for rows.Next() {
+		var i ListAccountBalanceMismatchesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Currency,
+			&i.Balance,
+			&i.EntriesTotal,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccountsByIDs = `-- name: ListAccountsByIDs :many
+SELECT id, owner, balance, currency, created_at, is_frozen, type, overdraft_limit FROM accounts
+WHERE id = ANY($1::bigint[])
+ORDER BY id
+`
+
+func (q *Queries) ListAccountsByIDs(ctx context.Context, ids []int64) ([]Account, error) {
+	rows, err := q.db.Query(ctx, listAccountsByIDs, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.IsFrozen,
+			&i.Type,
+			&i.OverdraftLimit,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAllAccounts = `-- name: ListAllAccounts :many
+SELECT id, owner, balance, currency, created_at, is_frozen, type, overdraft_limit FROM accounts
+ORDER BY id
+    LIMIT $1
+OFFSET $2
+`
+
+type ListAllAccountsParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+func (q *Queries) ListAllAccounts(ctx context.Context, arg ListAllAccountsParams) ([]Account, error) {
+	rows, err := q.db.Query(ctx, listAllAccounts, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.Owner,
+			&i.Balance,
+			&i.Currency,
+			&i.CreatedAt,
+			&i.IsFrozen,
+			&i.Type,
+			&i.OverdraftLimit,
 		); err != nil {
 			return nil, err
 		}
@@ -154,7 +321,7 @@ const updateAccount = `-- name: UpdateAccount :one
 UPDATE accounts
 SET balance = $2
 WHERE id = $1
-    RETURNING id, owner, balance, currency, created_at
+    RETURNING id, owner, balance, currency, created_at, is_frozen, type, overdraft_limit
 `
 
 type UpdateAccountParams struct {
@@ -171,6 +338,65 @@ func (q *Queries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (A
 		&i.Balance,
 		&i.Currency,
 		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.Type,
+		&i.OverdraftLimit,
+	)
+	return i, err
+}
+
+const updateAccountFrozen = `-- name: UpdateAccountFrozen :one
+UPDATE accounts
+SET is_frozen = $2
+WHERE id = $1
+    RETURNING id, owner, balance, currency, created_at, is_frozen, type, overdraft_limit
+`
+
+type UpdateAccountFrozenParams struct {
+	ID       int64 `json:"id"`
+	IsFrozen bool  `json:"is_frozen"`
+}
+
+func (q *Queries) UpdateAccountFrozen(ctx context.Context, arg UpdateAccountFrozenParams) (Account, error) {
+	row := q.db.QueryRow(ctx, updateAccountFrozen, arg.ID, arg.IsFrozen)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.Type,
+		&i.OverdraftLimit,
+	)
+	return i, err
+}
+
+const updateAccountOverdraftLimit = `-- name: UpdateAccountOverdraftLimit :one
+UPDATE accounts
+SET overdraft_limit = $2
+WHERE id = $1
+    RETURNING id, owner, balance, currency, created_at, is_frozen, type, overdraft_limit
+`
+
+type UpdateAccountOverdraftLimitParams struct {
+	ID             int64 `json:"id"`
+	OverdraftLimit int64 `json:"overdraft_limit"`
+}
+
+func (q *Queries) UpdateAccountOverdraftLimit(ctx context.Context, arg UpdateAccountOverdraftLimitParams) (Account, error) {
+	row := q.db.QueryRow(ctx, updateAccountOverdraftLimit, arg.ID, arg.OverdraftLimit)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.Owner,
+		&i.Balance,
+		&i.Currency,
+		&i.CreatedAt,
+		&i.IsFrozen,
+		&i.Type,
+		&i.OverdraftLimit,
 	)
 	return i, err
 }
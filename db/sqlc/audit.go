@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const (
+	AuditActionLogin                = "user.login"
+	AuditActionAccountCreate        = "account.create"
+	AuditActionAccountOverdraft     = "account.overdraft"
+	AuditActionAccountOverdraftSet  = "account.overdraft_limit"
+	AuditActionTransferCreate       = "transfer.create"
+	AuditActionTransferReverse      = "transfer.reverse"
+	AuditActionTransferBatchCreate  = "transfer_batch.create"
+	AuditActionAdjustmentCreate     = "adjustment.create"
+	AuditActionHoldPlace            = "hold.place"
+	AuditActionHoldCapture          = "hold.capture"
+	AuditActionHoldRelease          = "hold.release"
+	AuditActionPaymentRequestAccept = "payment_request.accept"
+	AuditActionAuthorizationDenied  = "authorization.denied"
+)
+
+// AuditActor identifies who performed an audited action and from where.
+type AuditActor struct {
+	Actor     string `json:"actor"`
+	ClientIp  string `json:"client_ip"`
+	UserAgent string `json:"user_agent"`
+	RequestID string `json:"request_id"`
+}
+
+type AuditEventParams struct {
+	AuditActor
+	Action string
+	Target string
+	// Before and After are stored as json, nil leaves them empty.
+	Before any
+	After  any
+}
+
+// AuditEventHash returns the hash chaining an event to the one before it.
+// Every field but the id is covered, so editing a row or removing one from
+// the middle of the chain breaks every hash after it.
+func AuditEventHash(event AuditEvent) string {
+	h := sha256.New()
+	for _, field := range []string{
+		event.PrevHash,
+		event.Actor,
+		event.Action,
+		event.Target,
+		event.ClientIp,
+		event.UserAgent,
+		event.RequestID,
+		string(event.Before),
+		string(event.After),
+		event.CreatedAt.Time.UTC().Format(time.RFC3339Nano),
+	} {
+		// the length prefix keeps fields from running into each other
+		fmt.Fprintf(h, "%d:%s", len(field), field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordAuditEvent appends an event to the audit log in the transaction of q.
+// Writers hold an advisory lock on the chain until the transaction ends so
+// events are chained in the order they commit, which is why callers record
+// events as the last step of their transaction.
+func recordAuditEvent(ctx context.Context, q *Queries, params AuditEventParams) (AuditEvent, error) {
+	before, err := marshalAuditState(params.Before)
+	if err != nil {
+		return AuditEvent{}, err
+	}
+	after, err := marshalAuditState(params.After)
+	if err != nil {
+		return AuditEvent{}, err
+	}
+
+	if err := q.LockAuditChain(ctx); err != nil {
+		return AuditEvent{}, err
+	}
+	prevHash, err := q.GetLastAuditEventHash(ctx)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return AuditEvent{}, err
+	}
+
+	event := AuditEvent{
+		Actor:     params.Actor,
+		Action:    params.Action,
+		Target:    params.Target,
+		ClientIp:  params.ClientIp,
+		UserAgent: params.UserAgent,
+		RequestID: params.RequestID,
+		Before:    before,
+		After:     after,
+		PrevHash:  prevHash,
+		// postgres keeps microseconds, the hash must survive the round trip
+		CreatedAt: pgtype.Timestamptz{Time: time.Now().UTC().Truncate(time.Microsecond), Valid: true},
+	}
+
+	return q.CreateAuditEvent(ctx, CreateAuditEventParams{
+		Actor:     event.Actor,
+		Action:    event.Action,
+		Target:    event.Target,
+		ClientIp:  event.ClientIp,
+		UserAgent: event.UserAgent,
+		RequestID: event.RequestID,
+		Before:    event.Before,
+		After:     event.After,
+		PrevHash:  event.PrevHash,
+		Hash:      AuditEventHash(event),
+		CreatedAt: event.CreatedAt,
+	})
+}
+
+// recordAuditEvents records events in order, see recordAuditEvent.
+func recordAuditEvents(ctx context.Context, q *Queries, events []AuditEventParams) error {
+	for _, params := range events {
+		if _, err := recordAuditEvent(ctx, q, params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func marshalAuditState(state any) ([]byte, error) {
+	if state == nil {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
+
+// VerifyAuditChain checks that events, in id order, link to each other and
+// to prevHash, and that none was modified. It returns the hash of the last
+// event so a long log can be checked page by page.
+func VerifyAuditChain(prevHash string, events []AuditEvent) (string, error) {
+	for _, event := range events {
+		if event.PrevHash != prevHash {
+			return prevHash, fmt.Errorf("audit event %d does not follow the previous event", event.ID)
+		}
+		if AuditEventHash(event) != event.Hash {
+			return prevHash, fmt.Errorf("audit event %d was modified", event.ID)
+		}
+		prevHash = event.Hash
+	}
+	return prevHash, nil
+}
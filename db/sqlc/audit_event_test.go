@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAuditEventTx(t *testing.T) {
+	store := NewStore(testDB)
+	actor := AuditActor{
+		Actor:     util.RandomOwner(),
+		ClientIp:  "127.0.0.1",
+		UserAgent: "test",
+		RequestID: util.RandomString(16),
+	}
+
+	err, first := store.RecordAuditEventTx(context.Background(), AuditEventParams{
+		AuditActor: actor,
+		Action:     AuditActionAccountCreate,
+		Target:     "account:1",
+		After:      map[string]any{"owner": actor.Actor, "balance": 0},
+	})
+	require.NoError(t, err)
+	require.NotZero(t, first.Event.ID)
+	require.Equal(t, actor.RequestID, first.Event.RequestID)
+	require.Nil(t, first.Event.Before)
+	require.JSONEq(t, `{"owner":"`+actor.Actor+`","balance":0}`, string(first.Event.After))
+	require.Equal(t, AuditEventHash(first.Event), first.Event.Hash)
+
+	err, second := store.RecordAuditEventTx(context.Background(), AuditEventParams{
+		AuditActor: actor,
+		Action:     AuditActionAuthorizationDenied,
+		Target:     "account:2",
+	})
+	require.NoError(t, err)
+
+	events, err := testQuery.ListAuditEventsAfter(context.Background(), ListAuditEventsAfterParams{
+		ID:    first.Event.ID - 1,
+		Limit: 100,
+	})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(events), 2)
+	require.Equal(t, first.Event, events[0])
+
+	last, err := VerifyAuditChain(first.Event.PrevHash, events)
+	require.NoError(t, err)
+	require.Equal(t, events[len(events)-1].Hash, last)
+	require.Contains(t, events, second.Event)
+}
+
+func TestRecordAuditEventTxConcurrent(t *testing.T) {
+	store := NewStore(testDB)
+	actor := AuditActor{Actor: util.RandomOwner()}
+
+	n := 10
+	errs := make(chan error, n)
+	results := make(chan RecordAuditEventTxResult, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			err, result := store.RecordAuditEventTx(context.Background(), AuditEventParams{
+				AuditActor: actor,
+				Action:     AuditActionAccountCreate,
+				Target:     "account:1",
+			})
+			errs <- err
+			results <- result
+		}()
+	}
+
+	firstID := int64(0)
+	for i := 0; i < n; i++ {
+		require.NoError(t, <-errs)
+		result := <-results
+		if firstID == 0 || result.Event.ID < firstID {
+			firstID = result.Event.ID
+		}
+	}
+
+	// concurrent writers still form a single chain
+	events, err := testQuery.ListAuditEventsAfter(context.Background(), ListAuditEventsAfterParams{
+		ID:    firstID - 1,
+		Limit: 100,
+	})
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(events), n)
+	_, err = VerifyAuditChain(events[0].PrevHash, events)
+	require.NoError(t, err)
+}
+
+func TestAuditEventsAppendOnly(t *testing.T) {
+	store := NewStore(testDB)
+	err, result := store.RecordAuditEventTx(context.Background(), AuditEventParams{
+		AuditActor: AuditActor{Actor: util.RandomOwner()},
+		Action:     AuditActionAccountCreate,
+		Target:     "account:1",
+	})
+	require.NoError(t, err)
+
+	_, err = testDB.Exec(context.Background(), "UPDATE audit_events SET actor = 'mallory' WHERE id = $1", result.Event.ID)
+	require.ErrorContains(t, err, "append-only")
+
+	_, err = testDB.Exec(context.Background(), "DELETE FROM audit_events WHERE id = $1", result.Event.ID)
+	require.ErrorContains(t, err, "append-only")
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	first := AuditEvent{ID: 1, Actor: "alice", Action: AuditActionAccountCreate, Target: "account:1"}
+	first.Hash = AuditEventHash(first)
+	second := AuditEvent{ID: 2, Actor: "alice", Action: AuditActionTransferCreate, Target: "transfer:1", After: []byte(`{"amount":10}`), PrevHash: first.Hash}
+	second.Hash = AuditEventHash(second)
+
+	last, err := VerifyAuditChain("", []AuditEvent{first, second})
+	require.NoError(t, err)
+	require.Equal(t, second.Hash, last)
+
+	tampered := second
+	tampered.After = []byte(`{"amount":1000}`)
+	_, err = VerifyAuditChain("", []AuditEvent{first, tampered})
+	require.ErrorContains(t, err, "audit event 2 was modified")
+
+	_, err = VerifyAuditChain("", []AuditEvent{second})
+	require.ErrorContains(t, err, "audit event 2 does not follow")
+}
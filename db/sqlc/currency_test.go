@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListCurrencies(t *testing.T) {
+	currencies, err := testQuery.ListCurrencies(context.Background())
+	require.NoError(t, err)
+
+	codes := make(map[string]Currency)
+	for _, currency := range currencies {
+		codes[currency.Code] = currency
+	}
+	for _, code := range []string{util.USD, util.EUR, util.CNY} {
+		require.Contains(t, codes, code)
+		require.Equal(t, int16(2), codes[code].Exponent)
+	}
+}
+
+func TestUpdateCurrencyEnabled(t *testing.T) {
+	code := "X" + strings.ToUpper(util.RandomString(2))
+
+	currency, err := testQuery.CreateCurrency(context.Background(), CreateCurrencyParams{
+		Code:     code,
+		Exponent: 0,
+		Enabled:  false,
+	})
+	if err != nil {
+		// the code was taken by an earlier run
+		currency, err = testQuery.GetCurrency(context.Background(), code)
+	}
+	require.NoError(t, err)
+
+	currency, err = testQuery.UpdateCurrencyEnabled(context.Background(), UpdateCurrencyEnabledParams{Code: code, Enabled: true})
+	require.NoError(t, err)
+	require.True(t, currency.Enabled)
+
+	currency, err = testQuery.GetCurrency(context.Background(), code)
+	require.NoError(t, err)
+	require.True(t, currency.Enabled)
+	require.Equal(t, int16(0), currency.Exponent)
+}
+
+func TestAccountCurrencyMustExist(t *testing.T) {
+	user := RandomUser(t)
+	_, err := testQuery.CreateAccount(context.Background(), CreateAccountParams{
+		Owner: user.Username,
+		// never a currency, codes are upper case
+		Currency: "xyz",
+		Type:     util.CheckingAccount,
+	})
+	var pgErr *pgconn.PgError
+	require.ErrorAs(t, err, &pgErr)
+	require.Equal(t, "accounts_currency_fkey", pgErr.ConstraintName)
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func placeHold(t *testing.T, account Account, amount int64) Hold {
+	store := NewStore(testDB)
+	err, result := store.PlaceHoldTx(context.Background(), PlaceHoldTxParams{
+		AccountID: account.ID,
+		Amount:    testMoney(amount, account.Currency),
+		Reference: util.RandomString(12),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Equal(t, HoldActive, result.Hold.Status)
+	return result.Hold
+}
+
+func TestPlaceHoldTx(t *testing.T) {
+	store := NewStore(testDB)
+	account := fundedAccount(t, 100)
+
+	hold := placeHold(t, account, 60)
+	held, err := testQuery.GetAccountHeldAmount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(60), held)
+
+	// the hold reserves funds, so a second one has less to work with
+	err, _ = store.PlaceHoldTx(context.Background(), PlaceHoldTxParams{
+		AccountID: account.ID,
+		Amount:    testMoney(account.Balance-59, account.Currency),
+		Reference: util.RandomString(12),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// as does a transfer
+	other := randomAccountIn(t, account.Currency)
+	err, _ = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account.ID,
+		ToAccountID:   other.ID,
+		Amount:        testMoney(account.Balance-59, account.Currency),
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// the same reference cannot be used twice on an account
+	err, _ = store.PlaceHoldTx(context.Background(), PlaceHoldTxParams{
+		AccountID: account.ID,
+		Amount:    testMoney(1, account.Currency),
+		Reference: hold.Reference,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.Error(t, err)
+}
+
+func TestPlaceHoldTxOverdraft(t *testing.T) {
+	store := NewStore(testDB)
+	account := overdraftAccount(t, util.USD, 50)
+
+	// the overdraft limit can be held as well
+	placeHold(t, account, account.Balance+50)
+
+	err, _ := store.PlaceHoldTx(context.Background(), PlaceHoldTxParams{
+		AccountID: account.ID,
+		Amount:    testMoney(1, account.Currency),
+		Reference: util.RandomString(12),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+}
+
+func TestCaptureHoldTx(t *testing.T) {
+	store := NewStore(testDB)
+	account := fundedAccount(t, 100)
+	merchant := randomAccountIn(t, account.Currency)
+	hold := placeHold(t, account, 60)
+
+	err, _ := store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{
+		HoldID:      hold.ID,
+		ToAccountID: merchant.ID,
+		Amount:      testMoney(61, account.Currency),
+	})
+	require.ErrorIs(t, err, ErrCaptureExceedsHold)
+
+	// capturing can spend the funds the hold reserved
+	err, result := store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{
+		HoldID:      hold.ID,
+		ToAccountID: merchant.ID,
+		Amount:      testMoney(40, account.Currency),
+	})
+	require.NoError(t, err)
+	require.Equal(t, HoldCaptured, result.Hold.Status)
+	require.Equal(t, int64(40), result.Hold.CapturedAmount)
+	require.Equal(t, result.Transfer.ID, result.Hold.TransferID.Int64)
+	require.Equal(t, account.Balance-40, result.FromAccount.Balance)
+	require.True(t, result.Hold.ClosedAt.Valid)
+
+	// the rest of the hold is released
+	held, err := testQuery.GetAccountHeldAmount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Zero(t, held)
+
+	err, _ = store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{
+		HoldID:      hold.ID,
+		ToAccountID: merchant.ID,
+		Amount:      testMoney(1, account.Currency),
+	})
+	require.ErrorIs(t, err, ErrHoldNotActive)
+}
+
+func TestCloseHold(t *testing.T) {
+	account := fundedAccount(t, 100)
+	hold := placeHold(t, account, 60)
+
+	released, err := testQuery.CloseHold(context.Background(), CloseHoldParams{ID: hold.ID, Status: HoldReleased})
+	require.NoError(t, err)
+	require.Equal(t, HoldReleased, released.Status)
+	require.True(t, released.ClosedAt.Valid)
+
+	_, err = testQuery.CloseHold(context.Background(), CloseHoldParams{ID: hold.ID, Status: HoldReleased})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestCloseExpiredHold(t *testing.T) {
+	store := NewStore(testDB)
+	account := fundedAccount(t, 100)
+	merchant := randomAccountIn(t, account.Currency)
+	hold, err := testQuery.CreateHold(context.Background(), CreateHoldParams{
+		AccountID: account.ID,
+		Amount:    60,
+		Reference: util.RandomString(12),
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Second), Valid: true},
+	})
+	require.NoError(t, err)
+
+	// an expired hold the sweeper has not got to yet is still active, but it
+	// can no longer be captured or released
+	_, err = testQuery.CloseHold(context.Background(), CloseHoldParams{ID: hold.ID, Status: HoldReleased})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	err, _ = store.CaptureHoldTx(context.Background(), CaptureHoldTxParams{
+		HoldID:      hold.ID,
+		ToAccountID: merchant.ID,
+		Amount:      testMoney(10, account.Currency),
+	})
+	require.ErrorIs(t, err, ErrHoldNotActive)
+
+	err, _ = store.ReleaseHoldTx(context.Background(), ReleaseHoldTxParams{HoldID: hold.ID})
+	require.ErrorIs(t, err, ErrHoldNotActive)
+
+	hold, err = testQuery.GetHold(context.Background(), hold.ID)
+	require.NoError(t, err)
+	require.Equal(t, HoldActive, hold.Status)
+	require.False(t, hold.ClosedAt.Valid)
+}
+
+func TestReleaseHoldTx(t *testing.T) {
+	store := NewStore(testDB)
+	account := fundedAccount(t, 100)
+	hold := placeHold(t, account, 60)
+	actor := AuditActor{Actor: util.RandomOwner()}
+
+	err, result := store.ReleaseHoldTx(context.Background(), ReleaseHoldTxParams{HoldID: hold.ID, Audit: &actor})
+	require.NoError(t, err)
+	require.Equal(t, HoldReleased, result.Hold.Status)
+	require.True(t, result.Hold.ClosedAt.Valid)
+
+	held, err := testQuery.GetAccountHeldAmount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Zero(t, held)
+
+	// the release and its audit event commit together
+	events, err := testQuery.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Actor:  pgtype.Text{String: actor.Actor, Valid: true},
+		Action: pgtype.Text{String: AuditActionHoldRelease, Valid: true},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "hold:"+strconv.FormatInt(hold.ID, 10), events[0].Target)
+
+	err, _ = store.ReleaseHoldTx(context.Background(), ReleaseHoldTxParams{HoldID: hold.ID, Audit: &actor})
+	require.ErrorIs(t, err, ErrHoldNotActive)
+}
+
+func TestExpireHolds(t *testing.T) {
+	account := fundedAccount(t, 100)
+	hold, err := testQuery.CreateHold(context.Background(), CreateHoldParams{
+		AccountID: account.ID,
+		Amount:    60,
+		Reference: util.RandomString(12),
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(-time.Second), Valid: true},
+	})
+	require.NoError(t, err)
+
+	// expired holds stop reserving funds before they are swept
+	held, err := testQuery.GetAccountHeldAmount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Zero(t, held)
+
+	expired, err := testQuery.ExpireHolds(context.Background())
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, expired, int64(1))
+
+	hold, err = testQuery.GetHold(context.Background(), hold.ID)
+	require.NoError(t, err)
+	require.Equal(t, HoldExpired, hold.Status)
+}
@@ -0,0 +1,259 @@
+package db
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func savingsAccount(t *testing.T, currency string) Account {
+	user := RandomUser(t)
+	account, err := testQuery.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    user.Username,
+		Balance:  0,
+		Currency: currency,
+		Type:     util.SavingsAccount,
+	})
+	require.NoError(t, err)
+	require.Equal(t, util.SavingsAccount, account.Type)
+	return account
+}
+
+func accrueInterest(t *testing.T, account Account, kind string, date time.Time, amountMicros int64) {
+	n, err := testQuery.CreateInterestAccrual(context.Background(), CreateInterestAccrualParams{
+		AccountID:     account.ID,
+		AccrualDate:   pgtype.Date{Time: date, Valid: true},
+		Kind:          kind,
+		Balance:       account.Balance,
+		AnnualRateBps: 150,
+		AmountMicros:  amountMicros,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+}
+
+func TestDailyInterestMicros(t *testing.T) {
+	// 3.65% of 100.00 a year is a cent a day
+	for _, tc := range []struct {
+		Balance int64
+		Bps     int32
+		Micros  int64
+	}{
+		{Balance: 10000, Bps: 365, Micros: 1_000_000},
+		{Balance: 10000, Bps: 150, Micros: 410_958},
+		{Balance: -10000, Bps: 150},
+		{Balance: 10000, Bps: 0},
+		// the product overflows int64, the interest itself does not
+		{Balance: math.MaxInt64 / 1000, Bps: 100, Micros: 252_695_124_297_391_095},
+	} {
+		micros, err := DailyInterestMicros(tc.Balance, tc.Bps)
+		require.NoError(t, err)
+		require.Equal(t, tc.Micros, micros)
+	}
+
+	_, err := DailyInterestMicros(math.MaxInt64, 10000)
+	require.ErrorIs(t, err, util.ErrAmountOverflow)
+}
+
+func TestSavingsNextToChecking(t *testing.T) {
+	checking := RandomAccount(t)
+	savings, err := testQuery.CreateAccount(context.Background(), CreateAccountParams{
+		Owner:    checking.Owner,
+		Currency: checking.Currency,
+		Type:     util.SavingsAccount,
+	})
+	require.NoError(t, err)
+
+	// payments by username go to the checking account
+	account, err := testQuery.GetAccountByOwnerCurrency(context.Background(), GetAccountByOwnerCurrencyParams{
+		Owner:    checking.Owner,
+		Currency: checking.Currency,
+	})
+	require.NoError(t, err)
+	require.Equal(t, checking.ID, account.ID)
+	require.NotEqual(t, savings.ID, account.ID)
+}
+
+func TestCreateInterestAccrualOncePerDay(t *testing.T) {
+	account := savingsAccount(t, util.USD)
+	date := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	accrueInterest(t, account, InterestSavings, date, 100)
+
+	n, err := testQuery.CreateInterestAccrual(context.Background(), CreateInterestAccrualParams{
+		AccountID:     account.ID,
+		AccrualDate:   pgtype.Date{Time: date, Valid: true},
+		Kind:          InterestSavings,
+		AnnualRateBps: 150,
+		AmountMicros:  100,
+	})
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// overdraft interest is accrued apart
+	accrueInterest(t, account, InterestOverdraft, date, 100)
+}
+
+func TestGetLatestInterestAccrualDate(t *testing.T) {
+	account := savingsAccount(t, util.USD)
+	date := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(util.RandomInt(0, 3650)))
+	accrueInterest(t, account, InterestSavings, date, 100)
+
+	latest, err := testQuery.GetLatestInterestAccrualDate(context.Background())
+	require.NoError(t, err)
+	require.True(t, latest.Valid)
+	require.False(t, latest.Time.Before(date))
+}
+
+func TestPostInterestTx(t *testing.T) {
+	store := NewStore(testDB)
+	account := savingsAccount(t, util.USD)
+	expense := randomAccountIn(t, util.USD)
+
+	january := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	february := january.AddDate(0, 1, 0)
+	accrueInterest(t, account, InterestSavings, january, 1_500_000)
+	accrueInterest(t, account, InterestSavings, january.AddDate(0, 0, 30), 1_000_000)
+	// accrued in february, so not posted for january
+	accrueInterest(t, account, InterestSavings, february, 600_000)
+
+	arg := PostInterestTxParams{AccountID: account.ID, Kind: InterestSavings, SystemAccountID: expense.ID, Period: january}
+	err, result := store.PostInterestTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), result.Posting.Amount)
+	require.Equal(t, result.Transfer.ID, result.Posting.TransferID.Int64)
+	require.Equal(t, account.Balance+2, result.ToAccount.Balance)
+	require.Equal(t, expense.Balance-2, result.FromAccount.Balance)
+
+	// posting a month again changes nothing
+	err, again := store.PostInterestTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, result.Posting, again.Posting)
+	require.Zero(t, again.Transfer.ID)
+
+	// the half unit left from january is paid with february
+	arg.Period = february
+	err, result = store.PostInterestTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result.Posting.Amount)
+	require.Equal(t, account.Balance+3, result.ToAccount.Balance)
+
+	// nothing is due for march, which is recorded so it is not tried again
+	arg.Period = february.AddDate(0, 1, 0)
+	err, result = store.PostInterestTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Zero(t, result.Posting.Amount)
+	require.False(t, result.Posting.TransferID.Valid)
+}
+
+func TestPostInterestTxOverdrawsExpenseAccount(t *testing.T) {
+	store := NewStore(testDB)
+	account := savingsAccount(t, util.USD)
+	expense := randomAccountIn(t, util.USD)
+
+	january := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	accrueInterest(t, account, InterestSavings, january, (expense.Balance+1)*1_000_000)
+
+	err, result := store.PostInterestTx(context.Background(), PostInterestTxParams{
+		AccountID:       account.ID,
+		Kind:            InterestSavings,
+		SystemAccountID: expense.ID,
+		Period:          january,
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), result.FromAccount.Balance)
+}
+
+func TestPostOverdraftInterestTx(t *testing.T) {
+	store := NewStore(testDB)
+	account := overdraftAccount(t, util.USD, 100)
+	income := randomAccountIn(t, util.USD)
+
+	january := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	accrueInterest(t, account, InterestOverdraft, january, 2_500_000)
+	// savings interest is posted on its own
+	accrueInterest(t, account, InterestSavings, january, 7_000_000)
+
+	err, result := store.PostInterestTx(context.Background(), PostInterestTxParams{
+		AccountID:       account.ID,
+		Kind:            InterestOverdraft,
+		SystemAccountID: income.ID,
+		Period:          january,
+	})
+	require.NoError(t, err)
+	require.Equal(t, InterestOverdraft, result.Posting.Kind)
+	require.Equal(t, int64(2), result.Posting.Amount)
+	require.Equal(t, account.ID, result.FromAccount.ID)
+	require.Equal(t, account.Balance-2, result.FromAccount.Balance)
+	require.Equal(t, income.Balance+2, result.ToAccount.Balance)
+}
+
+func TestListOverdrawnBalancesAt(t *testing.T) {
+	store := NewStore(testDB)
+	account := overdraftAccount(t, util.USD, 100)
+	other := randomAccountIn(t, util.USD)
+
+	err, _ := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account.ID,
+		ToAccountID:   other.ID,
+		Amount:        testMoney(account.Balance+30, util.USD),
+	})
+	require.NoError(t, err)
+
+	balances, err := testQuery.ListOverdrawnBalancesAt(context.Background(), pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true})
+	require.NoError(t, err)
+	var found bool
+	for _, balance := range balances {
+		require.Negative(t, balance.Balance)
+		// accounts without a limit are not listed
+		require.NotEqual(t, other.ID, balance.ID)
+		if balance.ID == account.ID {
+			require.Equal(t, int64(-30), balance.Balance)
+			found = true
+		}
+	}
+	require.True(t, found)
+}
+
+func TestListSavingsBalancesAt(t *testing.T) {
+	store := NewStore(testDB)
+	account := savingsAccount(t, util.USD)
+	funding := randomAccountIn(t, util.USD)
+	funding, err := testQuery.AddAccountBalance(context.Background(), AddAccountBalanceParams{ID: funding.ID, Amount: 10})
+	require.NoError(t, err)
+
+	before := time.Now()
+	err, _ = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: funding.ID,
+		ToAccountID:   account.ID,
+		Amount:        testMoney(10, util.USD),
+	})
+	require.NoError(t, err)
+
+	balanceAt := func(at time.Time) (int64, bool) {
+		balances, err := testQuery.ListSavingsBalancesAt(context.Background(), pgtype.Timestamptz{Time: at, Valid: true})
+		require.NoError(t, err)
+		for _, balance := range balances {
+			if balance.ID == account.ID {
+				return balance.Balance, true
+			}
+		}
+		return 0, false
+	}
+
+	balance, ok := balanceAt(time.Now().Add(time.Hour))
+	require.True(t, ok)
+	require.Equal(t, int64(10), balance)
+
+	// the transfer came after, the account was there already
+	balance, ok = balanceAt(before)
+	require.True(t, ok)
+	require.Zero(t, balance)
+
+	_, ok = balanceAt(account.CreatedAt.Time.Add(-time.Minute))
+	require.False(t, ok)
+}
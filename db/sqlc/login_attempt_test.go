@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomLoginAttempt(t *testing.T, username string, clientIP string, outcome string) LoginAttempt {
+	attempt, err := testQuery.CreateLoginAttempt(context.Background(), CreateLoginAttemptParams{
+		Username:  username,
+		ClientIp:  clientIP,
+		UserAgent: "test",
+		Outcome:   outcome,
+	})
+	require.NoError(t, err)
+	require.NotZero(t, attempt.ID)
+	require.Equal(t, outcome, attempt.Outcome)
+	return attempt
+}
+
+func TestCountLoginFailures(t *testing.T) {
+	username := util.RandomOwner()
+	clientIP := util.RandomString(12)
+	since := pgtype.Timestamptz{Time: time.Now().Add(-time.Minute), Valid: true}
+
+	randomLoginAttempt(t, username, clientIP, "invalid_credentials")
+	randomLoginAttempt(t, username, clientIP, "success")
+	randomLoginAttempt(t, username, clientIP, "invalid_credentials")
+	last := randomLoginAttempt(t, username, clientIP, "invalid_credentials")
+	randomLoginAttempt(t, username, clientIP, "locked")
+
+	// failures before the last success are forgiven for the username
+	userFailures, err := testQuery.CountUserLoginFailures(context.Background(), CountUserLoginFailuresParams{
+		Username: username,
+		Since:    since,
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, userFailures.Failures)
+	require.WithinDuration(t, last.CreatedAt.Time, userFailures.LastFailedAt.Time, time.Millisecond)
+
+	// but not for the client ip
+	ipFailures, err := testQuery.CountIPLoginFailures(context.Background(), CountIPLoginFailuresParams{
+		ClientIp: clientIP,
+		Since:    since,
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, ipFailures.Failures)
+}
@@ -14,6 +14,47 @@ type Account struct {
 	Balance   int64              `json:"balance"`
 	Currency  string             `json:"currency"`
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	IsFrozen  bool               `json:"is_frozen"`
+	Type      string             `json:"type"`
+	// how far below zero transfers may take the balance
+	OverdraftLimit int64 `json:"overdraft_limit"`
+}
+
+type AdminAdjustment struct {
+	ID         int64              `json:"id"`
+	TransferID int64              `json:"transfer_id"`
+	Operator   string             `json:"operator"`
+	Reason     string             `json:"reason"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+}
+
+type AuditEvent struct {
+	ID int64 `json:"id"`
+	// username or operator, empty when unauthenticated
+	Actor     string `json:"actor"`
+	Action    string `json:"action"`
+	Target    string `json:"target"`
+	ClientIp  string `json:"client_ip"`
+	UserAgent string `json:"user_agent"`
+	RequestID string `json:"request_id"`
+	// json rather than jsonb so the hashed text is kept as written
+	Before []byte `json:"before"`
+	After  []byte `json:"after"`
+	// hash of the previous event, empty for the first one
+	PrevHash string `json:"prev_hash"`
+	// sha256 over prev_hash and the fields of this event
+	Hash      string             `json:"hash"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type Currency struct {
+	// ISO 4217 alphabetic code
+	Code string `json:"code"`
+	// digits after the decimal separator, amounts are stored in minor units
+	Exponent int16 `json:"exponent"`
+	// whether new accounts and transfers may use the currency
+	Enabled   bool               `json:"enabled"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
 
 type Entry struct {
@@ -24,6 +65,104 @@ type Entry struct {
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
 
+type Hold struct {
+	ID        int64 `json:"id"`
+	AccountID int64 `json:"account_id"`
+	Amount    int64 `json:"amount"`
+	// chosen by the client, unique per account so placing a hold can be retried
+	Reference string `json:"reference"`
+	Status    string `json:"status"`
+	// part of amount moved by the capture transfer, the rest was released
+	CapturedAmount int64       `json:"captured_amount"`
+	TransferID     pgtype.Int8 `json:"transfer_id"`
+	// an active hold stops reserving funds once expired, even before it is swept
+	ExpiresAt pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	ClosedAt  pgtype.Timestamptz `json:"closed_at"`
+}
+
+type InterestAccrual struct {
+	AccountID   int64       `json:"account_id"`
+	AccrualDate pgtype.Date `json:"accrual_date"`
+	// balance at the end of the accrual date
+	Balance int64 `json:"balance"`
+	// annual rate in basis points, 150 for 1.5%
+	AnnualRateBps int32 `json:"annual_rate_bps"`
+	// interest for the day in millionths of a minor unit
+	AmountMicros int64              `json:"amount_micros"`
+	CreatedAt    pgtype.Timestamptz `json:"created_at"`
+	// savings interest is paid to the account, overdraft interest is charged to it
+	Kind string `json:"kind"`
+}
+
+type InterestPosting struct {
+	AccountID int64 `json:"account_id"`
+	// first day of the month the interest was accrued in
+	Period pgtype.Date `json:"period"`
+	Amount int64       `json:"amount"`
+	// transfer paying or charging the interest, empty when nothing was due
+	TransferID pgtype.Int8        `json:"transfer_id"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	// savings interest is paid to the account, overdraft interest is charged to it
+	Kind string `json:"kind"`
+}
+
+type LoginAttempt struct {
+	ID int64 `json:"id"`
+	// as submitted, the user may not exist
+	Username  string `json:"username"`
+	ClientIp  string `json:"client_ip"`
+	UserAgent string `json:"user_agent"`
+	// success, invalid_credentials, mfa_challenge or locked
+	Outcome   string             `json:"outcome"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type PasswordReset struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	// sha256 of the token sent to the user
+	TokenHash string             `json:"token_hash"`
+	IsUsed    bool               `json:"is_used"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+	ExpiredAt pgtype.Timestamptz `json:"expired_at"`
+}
+
+type PaymentRequest struct {
+	ID        int64  `json:"id"`
+	Requester string `json:"requester"`
+	Payer     string `json:"payer"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+	Note      string `json:"note"`
+	Status    string `json:"status"`
+	// transfer paying the request once accepted
+	TransferID pgtype.Int8        `json:"transfer_id"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	ResolvedAt pgtype.Timestamptz `json:"resolved_at"`
+}
+
+type RateLimitBucket struct {
+	Key    string  `json:"key"`
+	Tokens float64 `json:"tokens"`
+	// whether the last request took a token
+	Allowed bool `json:"allowed"`
+	// tokens added per second
+	Rate      float64            `json:"rate"`
+	Burst     float64            `json:"burst"`
+	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+}
+
+type RecoveryCode struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	// sha256 of the normalized code
+	CodeHash  string             `json:"code_hash"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
 type Session struct {
 	ID           pgtype.UUID        `json:"id"`
 	Username     string             `json:"username"`
@@ -35,6 +174,54 @@ type Session struct {
 	CreatedAt    pgtype.Timestamptz `json:"created_at"`
 }
 
+type Task struct {
+	ID      int64  `json:"id"`
+	Type    string `json:"type"`
+	Payload []byte `json:"payload"`
+	// pending, running, done or dead
+	Status      string             `json:"status"`
+	Attempts    int32              `json:"attempts"`
+	MaxAttempts int32              `json:"max_attempts"`
+	LastError   string             `json:"last_error"`
+	ScheduledAt pgtype.Timestamptz `json:"scheduled_at"`
+	LockedUntil pgtype.Timestamptz `json:"locked_until"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt   pgtype.Timestamptz `json:"updated_at"`
+}
+
+type TransferBatchItem struct {
+	BatchID int64 `json:"batch_id"`
+	// index of the item in the submitted batch
+	Position    int32       `json:"position"`
+	ToAccountID int64       `json:"to_account_id"`
+	Amount      int64       `json:"amount"`
+	Status      string      `json:"status"`
+	TransferID  pgtype.Int8 `json:"transfer_id"`
+	// why the item failed, empty when it succeeded
+	Error string `json:"error"`
+}
+
+type TransferBatch struct {
+	ID            int64  `json:"id"`
+	FromAccountID int64  `json:"from_account_id"`
+	Mode          string `json:"mode"`
+	// completed when every item succeeded, failed when none did
+	Status      string             `json:"status"`
+	InitiatedBy string             `json:"initiated_by"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
+type TransferReversal struct {
+	ID                 int64 `json:"id"`
+	OriginalTransferID int64 `json:"original_transfer_id"`
+	// compensating transfer from the recipient back to the sender
+	TransferID  int64              `json:"transfer_id"`
+	Amount      int64              `json:"amount"`
+	Reason      string             `json:"reason"`
+	InitiatedBy string             `json:"initiated_by"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
 type Transfer struct {
 	ID            int64 `json:"id"`
 	FromAccountID int64 `json:"from_account_id"`
@@ -44,6 +231,23 @@ type Transfer struct {
 	CreatedAt pgtype.Timestamptz `json:"created_at"`
 }
 
+type UserAlias struct {
+	// handle other users can send money to, lowercase
+	Alias     string             `json:"alias"`
+	Username  string             `json:"username"`
+	CreatedAt pgtype.Timestamptz `json:"created_at"`
+}
+
+type UserTotp struct {
+	Username string `json:"username"`
+	Secret   string `json:"secret"`
+	// time step of the last accepted code, codes are single use
+	LastUsedStep int64 `json:"last_used_step"`
+	// two-factor login is enforced once set
+	ConfirmedAt pgtype.Timestamptz `json:"confirmed_at"`
+	CreatedAt   pgtype.Timestamptz `json:"created_at"`
+}
+
 type User struct {
 	Username          string             `json:"username"`
 	HashedPassword    string             `json:"hashed_password"`
@@ -51,4 +255,16 @@ type User struct {
 	Email             string             `json:"email"`
 	PasswordChangedAt pgtype.Timestamptz `json:"password_changed_at"`
 	CreatedAt         pgtype.Timestamptz `json:"created_at"`
+	Role              string             `json:"role"`
+	IsEmailVerified   bool               `json:"is_email_verified"`
+}
+
+type VerifyEmail struct {
+	ID         int64              `json:"id"`
+	Username   string             `json:"username"`
+	Email      string             `json:"email"`
+	SecretCode string             `json:"secret_code"`
+	IsUsed     bool               `json:"is_used"`
+	CreatedAt  pgtype.Timestamptz `json:"created_at"`
+	ExpiredAt  pgtype.Timestamptz `json:"expired_at"`
 }
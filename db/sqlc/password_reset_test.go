@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResetPasswordTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := RandomUser(t)
+	token := util.RandomString(32)
+
+	_, err := testQuery.CreatePasswordReset(context.Background(), CreatePasswordResetParams{
+		Username:  user.Username,
+		TokenHash: util.HashToken(token),
+		ExpiredAt: pgtype.Timestamptz{Time: time.Now().Add(time.Minute), Valid: true},
+	})
+	require.NoError(t, err)
+
+	hashPassword, err := util.HashPassword(util.RandomString(8))
+	require.NoError(t, err)
+
+	err, result := store.ResetPasswordTx(context.Background(), ResetPasswordTxParams{
+		TokenHash:      util.HashToken(token),
+		HashedPassword: hashPassword,
+	})
+	require.NoError(t, err)
+	require.Equal(t, user.Username, result.User.Username)
+	require.Equal(t, hashPassword, result.User.HashedPassword)
+	require.True(t, result.User.PasswordChangedAt.Time.After(user.PasswordChangedAt.Time))
+
+	// a token can only be used once
+	err, _ = store.ResetPasswordTx(context.Background(), ResetPasswordTxParams{
+		TokenHash:      util.HashToken(token),
+		HashedPassword: hashPassword,
+	})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func requestPayment(t *testing.T, requester Account, payer Account, amount int64, expiresAt time.Time) PaymentRequest {
+	store := NewStore(testDB)
+
+	var notified []PaymentRequest
+	err, result := store.CreatePaymentRequestTx(context.Background(), CreatePaymentRequestTxParams{
+		CreatePaymentRequestParams: CreatePaymentRequestParams{
+			Requester: requester.Owner,
+			Payer:     payer.Owner,
+			Amount:    amount,
+			Currency:  requester.Currency,
+			Note:      "lunch",
+			ExpiresAt: pgtype.Timestamptz{Time: expiresAt, Valid: true},
+		},
+		AfterCreate: func(q Querier, paymentRequest PaymentRequest) error {
+			notified = append(notified, paymentRequest)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, PaymentRequestPending, result.PaymentRequest.Status)
+	require.Equal(t, []PaymentRequest{result.PaymentRequest}, notified)
+	return result.PaymentRequest
+}
+
+func TestAcceptPaymentRequestTx(t *testing.T) {
+	store := NewStore(testDB)
+	payer := fundedAccount(t, 100)
+	requester := randomAccountIn(t, payer.Currency)
+	paymentRequest := requestPayment(t, requester, payer, 40, time.Now().Add(time.Hour))
+
+	incoming, err := testQuery.ListIncomingPaymentRequests(context.Background(), ListIncomingPaymentRequestsParams{
+		Payer:  payer.Owner,
+		Limit:  10,
+		Offset: 0,
+	})
+	require.NoError(t, err)
+	require.Len(t, incoming, 1)
+	require.Equal(t, paymentRequest.ID, incoming[0].ID)
+
+	var notified []PaymentRequest
+	arg := AcceptPaymentRequestTxParams{
+		ID:            paymentRequest.ID,
+		FromAccountID: payer.ID,
+		ToAccountID:   requester.ID,
+		AfterAccept: func(q Querier, paymentRequest PaymentRequest) error {
+			notified = append(notified, paymentRequest)
+			return nil
+		},
+	}
+	err, result := store.AcceptPaymentRequestTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, PaymentRequestAccepted, result.PaymentRequest.Status)
+	require.Equal(t, result.Transfer.ID, result.PaymentRequest.TransferID.Int64)
+	require.True(t, result.PaymentRequest.ResolvedAt.Valid)
+	require.Equal(t, int64(40), result.Transfer.Amount)
+	require.Equal(t, payer.Balance-40, result.FromAccount.Balance)
+	require.Equal(t, requester.Balance+40, result.ToAccount.Balance)
+	require.Equal(t, []PaymentRequest{result.PaymentRequest}, notified)
+
+	// a request is paid once
+	err, _ = store.AcceptPaymentRequestTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrPaymentRequestNotPending)
+	err, _ = store.DeclinePaymentRequestTx(context.Background(), DeclinePaymentRequestTxParams{ID: paymentRequest.ID})
+	require.ErrorIs(t, err, ErrPaymentRequestNotPending)
+
+	incoming, err = testQuery.ListIncomingPaymentRequests(context.Background(), ListIncomingPaymentRequestsParams{
+		Payer:  payer.Owner,
+		Limit:  10,
+		Offset: 0,
+	})
+	require.NoError(t, err)
+	require.Empty(t, incoming)
+}
+
+func TestAcceptPaymentRequestTxInsufficientFunds(t *testing.T) {
+	store := NewStore(testDB)
+	payer := fundedAccount(t, 0)
+	requester := randomAccountIn(t, payer.Currency)
+	paymentRequest := requestPayment(t, requester, payer, payer.Balance+1, time.Now().Add(time.Hour))
+
+	err, _ := store.AcceptPaymentRequestTx(context.Background(), AcceptPaymentRequestTxParams{
+		ID:            paymentRequest.ID,
+		FromAccountID: payer.ID,
+		ToAccountID:   requester.ID,
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// the failed payment leaves the request pending
+	paymentRequest, err = testQuery.GetPaymentRequest(context.Background(), paymentRequest.ID)
+	require.NoError(t, err)
+	require.Equal(t, PaymentRequestPending, paymentRequest.Status)
+}
+
+func TestDeclinePaymentRequestTx(t *testing.T) {
+	store := NewStore(testDB)
+	payer := fundedAccount(t, 100)
+	requester := randomAccountIn(t, payer.Currency)
+	paymentRequest := requestPayment(t, requester, payer, 40, time.Now().Add(time.Hour))
+
+	err, result := store.DeclinePaymentRequestTx(context.Background(), DeclinePaymentRequestTxParams{ID: paymentRequest.ID})
+	require.NoError(t, err)
+	require.Equal(t, PaymentRequestDeclined, result.PaymentRequest.Status)
+	require.False(t, result.PaymentRequest.TransferID.Valid)
+	require.True(t, result.PaymentRequest.ResolvedAt.Valid)
+
+	err, _ = store.AcceptPaymentRequestTx(context.Background(), AcceptPaymentRequestTxParams{
+		ID:            paymentRequest.ID,
+		FromAccountID: payer.ID,
+		ToAccountID:   requester.ID,
+	})
+	require.ErrorIs(t, err, ErrPaymentRequestNotPending)
+}
+
+func TestExpirePaymentRequestsTx(t *testing.T) {
+	store := NewStore(testDB)
+	payer := fundedAccount(t, 100)
+	requester := randomAccountIn(t, payer.Currency)
+	expired := requestPayment(t, requester, payer, 40, time.Now().Add(-time.Minute))
+	pending := requestPayment(t, requester, payer, 40, time.Now().Add(time.Hour))
+
+	// an expired request cannot be paid even before it is swept
+	err, _ := store.AcceptPaymentRequestTx(context.Background(), AcceptPaymentRequestTxParams{
+		ID:            expired.ID,
+		FromAccountID: payer.ID,
+		ToAccountID:   requester.ID,
+	})
+	require.ErrorIs(t, err, ErrPaymentRequestNotPending)
+
+	var notified []int64
+	err, result := store.ExpirePaymentRequestsTx(context.Background(), ExpirePaymentRequestsTxParams{
+		AfterExpire: func(q Querier, paymentRequest PaymentRequest) error {
+			notified = append(notified, paymentRequest.ID)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.Contains(t, notified, expired.ID)
+	require.NotContains(t, notified, pending.ID)
+	for _, paymentRequest := range result.PaymentRequests {
+		require.Equal(t, PaymentRequestExpired, paymentRequest.Status)
+	}
+
+	outgoing, err := testQuery.ListOutgoingPaymentRequests(context.Background(), ListOutgoingPaymentRequestsParams{
+		Requester: requester.Owner,
+		Limit:     10,
+		Offset:    0,
+	})
+	require.NoError(t, err)
+	require.Len(t, outgoing, 2)
+}
+
+func TestAcceptPaymentRequestTxOverdraftWarning(t *testing.T) {
+	store := NewStore(testDB)
+	payer := overdraftAccount(t, util.USD, 100)
+	requester := randomAccountIn(t, util.USD)
+	actor := AuditActor{Actor: payer.Owner}
+
+	accept := func(amount int64) AcceptPaymentRequestTxResult {
+		paymentRequest := requestPayment(t, requester, payer, amount, time.Now().Add(time.Hour))
+		err, result := store.AcceptPaymentRequestTx(context.Background(), AcceptPaymentRequestTxParams{
+			ID:            paymentRequest.ID,
+			FromAccountID: payer.ID,
+			ToAccountID:   requester.ID,
+			Audit:         &actor,
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	// into the overdraft
+	result := accept(payer.Balance + 10)
+	require.Equal(t, int64(-10), result.FromAccount.Balance)
+	warnings := overdraftWarnings(t, payer)
+	require.Len(t, warnings, 1)
+	require.Equal(t, actor.Actor, warnings[0].Actor)
+
+	// staying in it does not warn again
+	result = accept(20)
+	require.Equal(t, int64(-30), result.FromAccount.Balance)
+	require.Len(t, overdraftWarnings(t, payer), 1)
+}
@@ -12,18 +12,100 @@ import (
 
 type Querier interface {
 	AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error)
+	BlockSession(ctx context.Context, id pgtype.UUID) (Session, error)
+	BlockUserSessions(ctx context.Context, username string) error
+	ClaimTasks(ctx context.Context, arg ClaimTasksParams) ([]Task, error)
+	CloseHold(ctx context.Context, arg CloseHoldParams) (Hold, error)
+	CompleteTask(ctx context.Context, id int64) error
+	ConfirmUserTOTP(ctx context.Context, username string) (UserTotp, error)
+	CountIPLoginFailures(ctx context.Context, arg CountIPLoginFailuresParams) (CountIPLoginFailuresRow, error)
+	CountUserLoginFailures(ctx context.Context, arg CountUserLoginFailuresParams) (CountUserLoginFailuresRow, error)
 	CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error)
+	CreateAdminAdjustment(ctx context.Context, arg CreateAdminAdjustmentParams) (AdminAdjustment, error)
+	CreateAuditEvent(ctx context.Context, arg CreateAuditEventParams) (AuditEvent, error)
+	CreateCurrency(ctx context.Context, arg CreateCurrencyParams) (Currency, error)
 	CreateEntry(ctx context.Context, arg CreateEntryParams) (Entry, error)
+	CreateHold(ctx context.Context, arg CreateHoldParams) (Hold, error)
+	CreateInterestAccrual(ctx context.Context, arg CreateInterestAccrualParams) (int64, error)
+	CreateInterestPosting(ctx context.Context, arg CreateInterestPostingParams) (InterestPosting, error)
+	CreateLoginAttempt(ctx context.Context, arg CreateLoginAttemptParams) (LoginAttempt, error)
+	CreatePasswordReset(ctx context.Context, arg CreatePasswordResetParams) (PasswordReset, error)
+	CreatePaymentRequest(ctx context.Context, arg CreatePaymentRequestParams) (PaymentRequest, error)
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) (RecoveryCode, error)
 	CreateSessions(ctx context.Context, arg CreateSessionsParams) (Session, error)
+	CreateTask(ctx context.Context, arg CreateTaskParams) (Task, error)
 	CreateTransfer(ctx context.Context, arg CreateTransferParams) (Transfer, error)
+	CreateTransferBatch(ctx context.Context, arg CreateTransferBatchParams) (TransferBatch, error)
+	CreateTransferBatchItem(ctx context.Context, arg CreateTransferBatchItemParams) (TransferBatchItem, error)
+	CreateTransferReversal(ctx context.Context, arg CreateTransferReversalParams) (TransferReversal, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	CreateVerifyEmail(ctx context.Context, arg CreateVerifyEmailParams) (VerifyEmail, error)
 	DeleteAccount(ctx context.Context, id int64) error
+	DeleteFullRateLimitBuckets(ctx context.Context) error
+	DeleteRecoveryCodes(ctx context.Context, username string) error
+	DeleteUserAlias(ctx context.Context, username string) error
+	ExpireHolds(ctx context.Context) (int64, error)
+	ExpirePaymentRequests(ctx context.Context) ([]PaymentRequest, error)
+	FailTask(ctx context.Context, arg FailTaskParams) error
 	GetAccount(ctx context.Context, id int64) (Account, error)
+	GetAccountByOwnerCurrency(ctx context.Context, arg GetAccountByOwnerCurrencyParams) (Account, error)
 	GetAccountForUpdate(ctx context.Context, id int64) (Account, error)
+	GetAccountHeldAmount(ctx context.Context, accountID int64) (int64, error)
+	GetAccruedInterest(ctx context.Context, arg GetAccruedInterestParams) (int64, error)
+	GetCurrency(ctx context.Context, code string) (Currency, error)
+	GetHold(ctx context.Context, id int64) (Hold, error)
+	GetHoldForUpdate(ctx context.Context, id int64) (Hold, error)
+	GetInterestPosting(ctx context.Context, arg GetInterestPostingParams) (InterestPosting, error)
+	GetLastAuditEventHash(ctx context.Context) (string, error)
+	GetLatestInterestAccrualDate(ctx context.Context) (pgtype.Date, error)
+	GetPaymentRequest(ctx context.Context, id int64) (PaymentRequest, error)
+	GetPaymentRequestForUpdate(ctx context.Context, id int64) (PaymentRequest, error)
+	GetPostedInterest(ctx context.Context, arg GetPostedInterestParams) (int64, error)
+	GetReversedAmount(ctx context.Context, originalTransferID int64) (int64, error)
 	GetSessions(ctx context.Context, id pgtype.UUID) (Session, error)
+	GetTask(ctx context.Context, id int64) (Task, error)
+	GetTransfer(ctx context.Context, id int64) (Transfer, error)
+	GetTransferBatch(ctx context.Context, id int64) (TransferBatch, error)
+	GetTransferForUpdate(ctx context.Context, id int64) (Transfer, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	GetUserAlias(ctx context.Context, alias string) (UserAlias, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	GetUserTOTP(ctx context.Context, username string) (UserTotp, error)
+	GetVerifyEmail(ctx context.Context, id int64) (VerifyEmail, error)
+	InvalidatePasswordResets(ctx context.Context, username string) error
 	ListAccount(ctx context.Context, arg ListAccountParams) ([]Account, error)
+	ListAccountBalanceMismatches(ctx context.Context) ([]ListAccountBalanceMismatchesRow, error)
+	ListAccountsByIDs(ctx context.Context, ids []int64) ([]Account, error)
+	ListAllAccounts(ctx context.Context, arg ListAllAccountsParams) ([]Account, error)
+	ListAuditEvents(ctx context.Context, arg ListAuditEventsParams) ([]AuditEvent, error)
+	ListAuditEventsAfter(ctx context.Context, arg ListAuditEventsAfterParams) ([]AuditEvent, error)
+	ListCurrencies(ctx context.Context) ([]Currency, error)
+	ListIncomingPaymentRequests(ctx context.Context, arg ListIncomingPaymentRequestsParams) ([]PaymentRequest, error)
+	ListInterestAccountsToPost(ctx context.Context, arg ListInterestAccountsToPostParams) ([]ListInterestAccountsToPostRow, error)
+	ListOutgoingPaymentRequests(ctx context.Context, arg ListOutgoingPaymentRequestsParams) ([]PaymentRequest, error)
+	ListOverdrawnBalancesAt(ctx context.Context, at pgtype.Timestamptz) ([]ListOverdrawnBalancesAtRow, error)
+	ListSavingsBalancesAt(ctx context.Context, at pgtype.Timestamptz) ([]ListSavingsBalancesAtRow, error)
+	ListSessions(ctx context.Context, username string) ([]Session, error)
+	ListTransferBatchItems(ctx context.Context, batchID int64) ([]TransferBatchItem, error)
+	ListTransferReversals(ctx context.Context, originalTransferID int64) ([]TransferReversal, error)
+	LockAuditChain(ctx context.Context) error
+	ResolvePaymentRequest(ctx context.Context, arg ResolvePaymentRequestParams) (PaymentRequest, error)
+	RetryTask(ctx context.Context, arg RetryTaskParams) error
+	SetHoldTransfer(ctx context.Context, arg SetHoldTransferParams) (Hold, error)
+	SetUserAlias(ctx context.Context, arg SetUserAliasParams) (UserAlias, error)
+	TakeRateLimitToken(ctx context.Context, arg TakeRateLimitTokenParams) (TakeRateLimitTokenRow, error)
 	UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Account, error)
+	UpdateAccountFrozen(ctx context.Context, arg UpdateAccountFrozenParams) (Account, error)
+	UpdateAccountOverdraftLimit(ctx context.Context, arg UpdateAccountOverdraftLimitParams) (Account, error)
+	UpdateCurrencyEnabled(ctx context.Context, arg UpdateCurrencyEnabledParams) (Currency, error)
+	UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error)
+	UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) (User, error)
+	UpdateVerifyEmail(ctx context.Context, arg UpdateVerifyEmailParams) (VerifyEmail, error)
+	UpsertUserTOTP(ctx context.Context, arg UpsertUserTOTPParams) (UserTotp, error)
+	UsePasswordReset(ctx context.Context, tokenHash string) (PasswordReset, error)
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (RecoveryCode, error)
+	UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (UserTotp, error)
+	VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (User, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTakeRateLimitToken(t *testing.T) {
+	arg := TakeRateLimitTokenParams{
+		Key:   util.RandomString(16),
+		Burst: 2,
+		Rate:  0.001,
+	}
+
+	bucket, err := testQuery.TakeRateLimitToken(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, bucket.Allowed)
+	require.InDelta(t, 1, bucket.Tokens, 0.01)
+
+	bucket, err = testQuery.TakeRateLimitToken(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, bucket.Allowed)
+	require.InDelta(t, 0, bucket.Tokens, 0.01)
+
+	bucket, err = testQuery.TakeRateLimitToken(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, bucket.Allowed)
+	require.Less(t, bucket.Tokens, 1.0)
+
+	// a full bucket is dropped, one still refilling is kept
+	full := TakeRateLimitTokenParams{Key: util.RandomString(16), Burst: 1, Rate: 1000}
+	_, err = testQuery.TakeRateLimitToken(context.Background(), full)
+	require.NoError(t, err)
+
+	require.NoError(t, testQuery.DeleteFullRateLimitBuckets(context.Background()))
+
+	bucket, err = testQuery.TakeRateLimitToken(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, bucket.Allowed)
+}
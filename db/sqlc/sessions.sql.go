@@ -11,6 +11,40 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const blockSession = `-- name: BlockSession :one
+UPDATE sessions
+SET is_blocked = true
+WHERE id = $1
+RETURNING id, username, refresh_token, client_ip, user_agent, is_blocked, expires_at, created_at
+`
+
+func (q *Queries) BlockSession(ctx context.Context, id pgtype.UUID) (Session, error) {
+	row := q.db.QueryRow(ctx, blockSession, id)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.RefreshToken,
+		&i.ClientIp,
+		&i.UserAgent,
+		&i.IsBlocked,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const blockUserSessions = `-- name: BlockUserSessions :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE username = $1
+`
+
+func (q *Queries) BlockUserSessions(ctx context.Context, username string) error {
+	_, err := q.db.Exec(ctx, blockUserSessions, username)
+	return err
+}
+
 const createSessions = `-- name: CreateSessions :one
 INSERT INTO sessions (
     id,
@@ -78,3 +112,38 @@ func (q *Queries) GetSessions(ctx context.Context, id pgtype.UUID) (Session, err
 	)
 	return i, err
 }
+
+const listSessions = `-- name: ListSessions :many
+SELECT id, username, refresh_token, client_ip, user_agent, is_blocked, expires_at, created_at FROM sessions
+WHERE username = $1
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListSessions(ctx context.Context, username string) ([]Session, error) {
+	rows, err := q.db.Query(ctx, listSessions, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Session{}
+	for rows.Next() {
+		var i Session
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.RefreshToken,
+			&i.ClientIp,
+			&i.UserAgent,
+			&i.IsBlocked,
+			&i.ExpiresAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -2,13 +2,51 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"time"
+
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jxgzzztang/simplebank/metrics"
+	"github.com/jxgzzztang/simplebank/util"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/jxgzzztang/simplebank/db")
+
+// ErrInsufficientFunds is returned by transfers and holds that would leave
+// the source account with a negative available balance.
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
 type Store interface {
 	TransferTx(ctx context.Context, transferParams TransferTxParams) (error, TransferTxResult)
+	AdminTransferTx(ctx context.Context, adminTransferParams AdminTransferTxParams) (error, AdminTransferTxResult)
+	UpdateOverdraftLimitTx(ctx context.Context, updateOverdraftLimitParams UpdateOverdraftLimitTxParams) (error, UpdateOverdraftLimitTxResult)
+	CreateAccountTx(ctx context.Context, createAccountParams CreateAccountTxParams) (error, CreateAccountTxResult)
+	CreateUserTx(ctx context.Context, createUserParams CreateUserTxParams) (error, CreateUserTxResult)
+	VerifyEmailTx(ctx context.Context, verifyEmailParams VerifyEmailTxParams) (error, VerifyEmailTxResult)
+	ResendVerifyEmailTx(ctx context.Context, resendParams ResendVerifyEmailTxParams) (error, ResendVerifyEmailTxResult)
+	ResetPasswordTx(ctx context.Context, resetPasswordParams ResetPasswordTxParams) (error, ResetPasswordTxResult)
+	UpdateUserTx(ctx context.Context, updateUserParams UpdateUserTxParams) (error, UpdateUserTxResult)
+	ChangePasswordTx(ctx context.Context, changePasswordParams ChangePasswordTxParams) (error, ChangePasswordTxResult)
+	ConfirmTOTPTx(ctx context.Context, confirmTOTPParams ConfirmTOTPTxParams) (error, ConfirmTOTPTxResult)
+	RecordAuditEventTx(ctx context.Context, auditEventParams AuditEventParams) (error, RecordAuditEventTxResult)
+	PlaceHoldTx(ctx context.Context, placeHoldParams PlaceHoldTxParams) (error, PlaceHoldTxResult)
+	CaptureHoldTx(ctx context.Context, captureHoldParams CaptureHoldTxParams) (error, CaptureHoldTxResult)
+	ReleaseHoldTx(ctx context.Context, releaseHoldParams ReleaseHoldTxParams) (error, ReleaseHoldTxResult)
+	ReverseTransferTx(ctx context.Context, reverseTransferParams ReverseTransferTxParams) (error, ReverseTransferTxResult)
+	CreatePaymentRequestTx(ctx context.Context, createPaymentRequestParams CreatePaymentRequestTxParams) (error, CreatePaymentRequestTxResult)
+	AcceptPaymentRequestTx(ctx context.Context, acceptPaymentRequestParams AcceptPaymentRequestTxParams) (error, AcceptPaymentRequestTxResult)
+	DeclinePaymentRequestTx(ctx context.Context, declinePaymentRequestParams DeclinePaymentRequestTxParams) (error, DeclinePaymentRequestTxResult)
+	ExpirePaymentRequestsTx(ctx context.Context, expirePaymentRequestsParams ExpirePaymentRequestsTxParams) (error, ExpirePaymentRequestsTxResult)
+	BatchTransferTx(ctx context.Context, batchTransferParams BatchTransferTxParams) (error, BatchTransferTxResult)
+	PostInterestTx(ctx context.Context, postInterestParams PostInterestTxParams) (error, PostInterestTxResult)
 	Querier
 }
 
@@ -24,9 +62,59 @@ func NewStore(db *pgxpool.Pool) Store {
 	}
 }
 
-func (store *SQLStore) execTx(ctx context.Context, fn func(query *Queries) error) error {
+// execTx runs fn in a transaction and commits it. Transactions failing with
+// a deadlock or a serialization failure are rolled back and run again after a
+// jittered backoff, so fn must only have side effects through q and must not
+// keep anything an earlier attempt assigned.
+func (store *SQLStore) execTx(ctx context.Context, fn func(query *Queries) error, opts ...txOption) (err error) {
+	options := txOptions{isoLevel: pgx.ReadCommitted}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	config := transactionConfig()
+
+	ctx, span := tracer.Start(ctx, "db.execTx", trace.WithAttributes(attribute.String("db.isolation_level", string(options.isoLevel))))
+	attempts := 0
+	defer func() {
+		if options.attempts != nil {
+			*options.attempts = attempts
+		}
+		metrics.ObserveTxAttempts(attempts)
+		span.SetAttributes(attribute.Int("db.tx.attempts", attempts))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	for {
+		attempts++
+		err = store.runTx(ctx, options.isoLevel, fn)
+		code, ok := retryableError(err)
+		if !ok || attempts >= config.MaxAttempts {
+			return err
+		}
+
+		delay := txRetryDelay(attempts, config)
+		metrics.ObserveTxRetry(code)
+		span.AddEvent("retry", trace.WithAttributes(attribute.String("db.error_code", code), attribute.Int("db.tx.attempt", attempts)))
+		util.Logger(ctx).Warn("retrying transaction", "attempt", attempts, "code", code, "delay", delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// runTx makes a single attempt at running fn in a transaction at isoLevel.
+func (store *SQLStore) runTx(ctx context.Context, isoLevel pgx.TxIsoLevel, fn func(query *Queries) error) error {
 	tx, err := store.db.BeginTx(ctx, pgx.TxOptions{
-		IsoLevel:   pgx.ReadCommitted,
+		IsoLevel:   isoLevel,
 		AccessMode: pgx.ReadWrite,
 	})
 	if err != nil {
@@ -39,17 +127,43 @@ func (store *SQLStore) execTx(ctx context.Context, fn func(query *Queries) error
 
 	if err != nil {
 		if rbErr := tx.Rollback(ctx); rbErr != nil {
+			util.Logger(ctx).Error("cannot roll back transaction", "error", rbErr)
 			return fmt.Errorf("fn error %v;tx rollback failed: %v", err, rbErr)
 		}
+		util.Logger(ctx).Debug("rolled back transaction", "error", err)
 		return err
 	}
 	return tx.Commit(ctx)
 }
 
+// savepoint runs fn in a nested transaction of q, so the changes of a failing
+// fn are undone without aborting the transaction q runs in.
+func savepoint(ctx context.Context, q *Queries, fn func(query *Queries) error) error {
+	tx, ok := q.db.(pgx.Tx)
+	if !ok {
+		return errors.New("savepoint outside of a transaction")
+	}
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(q.WithTx(sp)); err != nil {
+		if rbErr := sp.Rollback(ctx); rbErr != nil {
+			return fmt.Errorf("fn error %v;savepoint rollback failed: %v", err, rbErr)
+		}
+		return err
+	}
+	return sp.Commit(ctx)
+}
+
 type TransferTxParams struct {
 	FromAccountID int64 `json:"from_account_id"`
 	ToAccountID   int64 `json:"to_account_id"`
-	Amount        int64 `json:"amount"`
+	// Amount has to be positive and in the currency of both accounts.
+	Amount util.Money `json:"amount"`
+	// Audit, when set, records the transfer in the audit log as part of it.
+	Audit *AuditActor `json:"-"`
 }
 
 type TransferTxResult struct {
@@ -58,57 +172,225 @@ type TransferTxResult struct {
 	ToAccount   Account  `json:"to_account"`
 	FromEntry   Entry    `json:"from_entry"`
 	ToEntry     Entry    `json:"to_entry"`
+	// Attempts is how often the transaction ran, more than once after a
+	// deadlock or serialization failure.
+	Attempts int `json:"-"`
 }
 
 func (store *SQLStore) TransferTx(ctx context.Context, transferParams TransferTxParams) (error, TransferTxResult) {
 	var transferResult TransferTxResult
+	start := time.Now()
+
+	ctx, span := tracer.Start(ctx, "TransferTx", trace.WithAttributes(transferAttributes(transferParams)...))
+	defer span.End()
 
+	var attempts int
 	err := store.execTx(ctx, func(q *Queries) error {
 		var err error
-		transferResult.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
-			FromAccountID: transferParams.FromAccountID,
-			ToAccountID:   transferParams.ToAccountID,
-			Amount:        transferParams.Amount,
-		})
+		var events []AuditEventParams
+		transferResult, events, err = transfer(ctx, q, transferParams)
 		if err != nil {
 			return err
 		}
-		transferResult.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: transferParams.FromAccountID,
-			Amount:    -transferParams.Amount,
-		})
-		if err != nil {
-			return err
-		}
-		transferResult.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
-			AccountID: transferParams.ToAccountID,
-			Amount:    transferParams.Amount,
-		})
-		if err != nil {
-			return err
+
+		if transferParams.Audit != nil {
+			events = append(events, AuditEventParams{
+				AuditActor: *transferParams.Audit,
+				Action:     AuditActionTransferCreate,
+				Target:     "transfer:" + strconv.FormatInt(transferResult.Transfer.ID, 10),
+				After:      transferResult.Transfer,
+			})
 		}
+		return recordAuditEvents(ctx, q, events)
+	}, withAttempts(&attempts))
+	transferResult.Attempts = attempts
 
-		//TODO: update account balance
+	outcome := transferOutcome(err)
+	span.SetAttributes(attribute.String("transfer.outcome", outcome))
+	metrics.ObserveTransferTx(outcome, time.Since(start))
+	return err, transferResult
 
-		transferResult.FromAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-			ID: transferParams.FromAccountID,
-			Amount: -transferParams.Amount,
-		})
+}
+
+func transferAttributes(transferParams TransferTxParams) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.Int64("transfer.from_account_id", transferParams.FromAccountID),
+		attribute.Int64("transfer.to_account_id", transferParams.ToAccountID),
+		attribute.Int64("transfer.amount", transferParams.Amount.Amount),
+		attribute.String("transfer.currency", transferParams.Amount.Currency.Code),
+	}
+}
+
+// transferOutcome classifies the result of a transfer for the metrics.
+func transferOutcome(err error) string {
+	var pgErr *pgconn.PgError
+	switch {
+	case err == nil:
+		return metrics.TransferSuccess
+	case errors.Is(err, ErrInsufficientFunds):
+		return metrics.TransferInsufficientFunds
+	case errors.As(err, &pgErr) && pgErr.Code == "40P01":
+		return metrics.TransferDeadlock
+	default:
+		return metrics.TransferError
+	}
+}
+
+// transfer moves money between two accounts using the given transaction
+// queries. It returns the overdraft warning to record when the transfer takes
+// the source account below zero, with transferParams.Audit as its actor.
+// Callers record it together with their own events at the end of their
+// transaction, see recordAuditEvent.
+func transfer(ctx context.Context, q *Queries, transferParams TransferTxParams) (TransferTxResult, []AuditEventParams, error) {
+	transferResult, err := postTransfer(ctx, q, transferParams)
+	if err != nil {
+		return transferResult, nil, err
+	}
+
+	// the row is locked by the update, so neither the balance nor the holds
+	// on it can change under us
+	held, err := q.GetAccountHeldAmount(ctx, transferParams.FromAccountID)
+	if err != nil {
+		return transferResult, nil, err
+	}
+	fromAccount := transferResult.FromAccount
+	if fromAccount.Balance+fromAccount.OverdraftLimit < held {
+		return transferResult, nil, ErrInsufficientFunds
+	}
+
+	// warn once when the transfer takes the account below zero, not on every
+	// transfer while it stays there
+	if fromAccount.Balance >= 0 || fromAccount.Balance+transferParams.Amount.Amount < 0 {
+		return transferResult, nil, nil
+	}
+	var actor AuditActor
+	if transferParams.Audit != nil {
+		actor = *transferParams.Audit
+	}
+	before := fromAccount
+	before.Balance += transferParams.Amount.Amount
+	return transferResult, []AuditEventParams{{
+		AuditActor: actor,
+		Action:     AuditActionAccountOverdraft,
+		Target:     "account:" + strconv.FormatInt(fromAccount.ID, 10),
+		Before:     before,
+		After:      fromAccount,
+	}}, nil
+}
+
+// postTransfer moves money between two accounts without checking that the
+// source account can afford it, which system accounts like the interest
+// expense account rely on.
+func postTransfer(ctx context.Context, q *Queries, transferParams TransferTxParams) (TransferTxResult, error) {
+	var transferResult TransferTxResult
+
+	amount := transferParams.Amount
+	if !amount.IsPositive() {
+		return transferResult, fmt.Errorf("transfer amount must be positive, got %s", amount)
+	}
+	debit, err := amount.Neg()
+	if err != nil {
+		return transferResult, err
+	}
+
+	transferResult.Transfer, err = q.CreateTransfer(ctx, CreateTransferParams{
+		FromAccountID: transferParams.FromAccountID,
+		ToAccountID:   transferParams.ToAccountID,
+		Amount:        amount.Amount,
+	})
+	if err != nil {
+		return transferResult, err
+	}
+	transferResult.FromEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID: transferParams.FromAccountID,
+		Amount:    debit.Amount,
+	})
+	if err != nil {
+		return transferResult, err
+	}
+	transferResult.ToEntry, err = q.CreateEntry(ctx, CreateEntryParams{
+		AccountID: transferParams.ToAccountID,
+		Amount:    amount.Amount,
+	})
+	if err != nil {
+		return transferResult, err
+	}
+
+	transferResult.FromAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
+		ID:     transferParams.FromAccountID,
+		Amount: debit.Amount,
+	})
+	if err != nil {
+		return transferResult, err
+	}
+	if err := requireCurrency(transferResult.FromAccount, amount); err != nil {
+		return transferResult, err
+	}
+
+	transferResult.ToAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
+		ID:     transferParams.ToAccountID,
+		Amount: amount.Amount,
+	})
+	if err != nil {
+		return transferResult, err
+	}
+	return transferResult, requireCurrency(transferResult.ToAccount, amount)
+}
+
+// requireCurrency fails when money is not in the currency of the account.
+func requireCurrency(account Account, money util.Money) error {
+	if account.Currency != money.Currency.Code {
+		return fmt.Errorf("%w: account %d holds %s, not %s", util.ErrCurrencyMismatch, account.ID, account.Currency, money.Currency.Code)
+	}
+	return nil
+}
+
+type AdminTransferTxParams struct {
+	TransferTxParams
+	Operator string `json:"operator"`
+	Reason   string `json:"reason"`
+}
+
+type AdminTransferTxResult struct {
+	TransferTxResult
+	Adjustment AdminAdjustment `json:"adjustment"`
+}
+
+// AdminTransferTx performs a transfer on behalf of an operator and records the
+// reason for it and an audit event in the same transaction.
+func (store *SQLStore) AdminTransferTx(ctx context.Context, adminTransferParams AdminTransferTxParams) (error, AdminTransferTxResult) {
+	var result AdminTransferTxResult
+
+	ctx, span := tracer.Start(ctx, "AdminTransferTx", trace.WithAttributes(transferAttributes(adminTransferParams.TransferTxParams)...))
+	defer span.End()
+
+	var attempts int
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = AdminTransferTxResult{}
+		var err error
+		var events []AuditEventParams
+		result.TransferTxResult, events, err = transfer(ctx, q, adminTransferParams.TransferTxParams)
 		if err != nil {
 			return err
 		}
 
-		transferResult.ToAccount, err = q.AddAccountBalance(ctx, AddAccountBalanceParams{
-			ID: transferParams.ToAccountID,
-			Amount: transferParams.Amount,
+		result.Adjustment, err = q.CreateAdminAdjustment(ctx, CreateAdminAdjustmentParams{
+			TransferID: result.Transfer.ID,
+			Operator:   adminTransferParams.Operator,
+			Reason:     adminTransferParams.Reason,
 		})
 		if err != nil {
 			return err
 		}
 
-		return nil
-	})
-
-	return err, transferResult
+		return recordAuditEvents(ctx, q, append(events, AuditEventParams{
+			AuditActor: AuditActor{Actor: adminTransferParams.Operator},
+			Action:     AuditActionAdjustmentCreate,
+			Target:     "transfer:" + strconv.FormatInt(result.Transfer.ID, 10),
+			After:      result.Adjustment,
+		}))
+	}, withAttempts(&attempts))
+	result.Attempts = attempts
 
+	return err, result
 }
@@ -2,15 +2,18 @@ package db
 
 import (
 	"context"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/util"
 	"github.com/stretchr/testify/require"
+	"strconv"
 	"testing"
 )
 
 func TestTransferTx(t *testing.T) {
 	store := NewStore(testDB)
 
-	account1 := RandomAccount(t)
-	account2 := RandomAccount(t)
+	account1 := fundedAccount(t, 100)
+	account2 := randomAccountIn(t, account1.Currency)
 
 	n := 5
 
@@ -24,7 +27,7 @@ func TestTransferTx(t *testing.T) {
 			err, result := store.TransferTx(context.Background(), TransferTxParams{
 				FromAccountID: account1.ID,
 				ToAccountID:   account2.ID,
-				Amount:        amount,
+				Amount:        testMoney(amount, account1.Currency),
 			})
 			errors <- err
 			results <- result
@@ -90,3 +93,186 @@ func TestTransferTx(t *testing.T) {
 	require.Equal(t, account1.Balance - int64(n) * amount, updateAccount1.Balance)
 	require.Equal(t, account2.Balance + int64(n) * amount, updateAccount2.Balance)
 }
+
+func TestAdminTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := fundedAccount(t, 100)
+	account2 := randomAccountIn(t, account1.Currency)
+	amount := int64(10)
+
+	err, result := store.AdminTransferTx(context.Background(), AdminTransferTxParams{
+		TransferTxParams: TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        testMoney(amount, account1.Currency),
+		},
+		Operator: "operator",
+		Reason:   "correct a misrouted payment",
+	})
+	require.NoError(t, err)
+	require.NotZero(t, result.Transfer.ID)
+	require.Equal(t, account1.Balance-amount, result.FromAccount.Balance)
+	require.Equal(t, account2.Balance+amount, result.ToAccount.Balance)
+
+	require.NotZero(t, result.Adjustment.ID)
+	require.Equal(t, result.Transfer.ID, result.Adjustment.TransferID)
+	require.Equal(t, "operator", result.Adjustment.Operator)
+	require.Equal(t, "correct a misrouted payment", result.Adjustment.Reason)
+}
+
+// fundedAccount creates an account holding at least amount.
+func fundedAccount(t *testing.T, amount int64) Account {
+	account := RandomAccount(t)
+	account, err := testQuery.AddAccountBalance(context.Background(), AddAccountBalanceParams{
+		ID:     account.ID,
+		Amount: amount,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+func TestTransferTxInsufficientFunds(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := RandomAccount(t)
+	account2 := randomAccountIn(t, account1.Currency)
+
+	err, _ := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        testMoney(account1.Balance+1, account1.Currency),
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+
+	// nothing of the transfer is kept
+	updateAccount1, err := testQuery.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updateAccount1.Balance)
+
+	updateAccount2, err := testQuery.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance, updateAccount2.Balance)
+}
+
+func TestTransferTxCurrencyMismatch(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := randomAccountIn(t, util.USD)
+	account2 := randomAccountIn(t, util.CNY)
+
+	err, _ := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        testMoney(1, util.USD),
+	})
+	require.ErrorIs(t, err, util.ErrCurrencyMismatch)
+
+	// neither balance changed
+	updateAccount1, err := testQuery.GetAccount(context.Background(), account1.ID)
+	require.NoError(t, err)
+	require.Equal(t, account1.Balance, updateAccount1.Balance)
+
+	updateAccount2, err := testQuery.GetAccount(context.Background(), account2.ID)
+	require.NoError(t, err)
+	require.Equal(t, account2.Balance, updateAccount2.Balance)
+}
+
+// testMoney is an amount in one of the currencies every database starts with.
+func testMoney(amount int64, currency string) util.Money {
+	return util.NewMoney(amount, util.Currency{Code: currency, Exponent: 2})
+}
+
+// overdraftAccount is a random account whose balance may go down to -limit.
+func overdraftAccount(t *testing.T, currency string, limit int64) Account {
+	account := randomAccountIn(t, currency)
+	account, err := testQuery.UpdateAccountOverdraftLimit(context.Background(), UpdateAccountOverdraftLimitParams{
+		ID:             account.ID,
+		OverdraftLimit: limit,
+	})
+	require.NoError(t, err)
+	return account
+}
+
+// overdraftWarnings lists the warnings recorded for account going below zero.
+func overdraftWarnings(t *testing.T, account Account) []AuditEvent {
+	events, err := testQuery.ListAuditEvents(context.Background(), ListAuditEventsParams{
+		Action: pgtype.Text{String: AuditActionAccountOverdraft, Valid: true},
+		Target: pgtype.Text{String: "account:" + strconv.FormatInt(account.ID, 10), Valid: true},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	return events
+}
+
+func TestTransferLeavesOverdraftWarningToCaller(t *testing.T) {
+	store := NewStore(testDB).(*SQLStore)
+	account1 := overdraftAccount(t, util.USD, 100)
+	account2 := randomAccountIn(t, util.USD)
+
+	// the warning is handed back instead of taking the audit chain lock while
+	// the transaction of the caller can still lock more rows
+	var events []AuditEventParams
+	err := store.execTx(context.Background(), func(q *Queries) error {
+		var err error
+		_, events, err = transfer(context.Background(), q, TransferTxParams{
+			FromAccountID: account1.ID,
+			ToAccountID:   account2.ID,
+			Amount:        testMoney(account1.Balance+10, util.USD),
+		})
+		return err
+	})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, AuditActionAccountOverdraft, events[0].Action)
+	require.Empty(t, overdraftWarnings(t, account1))
+}
+
+func TestTransferTxOverdraft(t *testing.T) {
+	store := NewStore(testDB)
+
+	account1 := overdraftAccount(t, util.USD, 100)
+	account2 := randomAccountIn(t, util.USD)
+	warnings := func() []AuditEvent {
+		return overdraftWarnings(t, account1)
+	}
+
+	// past the limit
+	err, _ := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        testMoney(account1.Balance+101, util.USD),
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+	require.Empty(t, warnings())
+
+	// into the overdraft
+	err, result := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        testMoney(account1.Balance+60, util.USD),
+		Audit:         &AuditActor{Actor: account1.Owner},
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(-60), result.FromAccount.Balance)
+	events := warnings()
+	require.Len(t, events, 1)
+	require.Equal(t, account1.Owner, events[0].Actor)
+
+	// staying in it does not warn again
+	err, result = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        testMoney(40, util.USD),
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(-100), result.FromAccount.Balance)
+	require.Len(t, warnings(), 1)
+
+	err, _ = store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: account1.ID,
+		ToAccountID:   account2.ID,
+		Amount:        testMoney(1, util.USD),
+	})
+	require.ErrorIs(t, err, ErrInsufficientFunds)
+}
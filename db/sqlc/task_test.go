@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomTask(t *testing.T) Task {
+	arg := CreateTaskParams{
+		Type:        "task:" + util.RandomString(6),
+		Payload:     []byte(`{"value":1}`),
+		MaxAttempts: 3,
+		// scheduled at the epoch so that it is the first task to be claimed
+		ScheduledAt: pgtype.Timestamptz{Time: time.Unix(0, 0), Valid: true},
+	}
+
+	task, err := testQuery.CreateTask(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotZero(t, task.ID)
+	require.Equal(t, arg.Type, task.Type)
+	require.JSONEq(t, string(arg.Payload), string(task.Payload))
+	require.Equal(t, "pending", task.Status)
+	require.Zero(t, task.Attempts)
+	require.Equal(t, arg.MaxAttempts, task.MaxAttempts)
+	return task
+}
+
+func TestClaimTasks(t *testing.T) {
+	task := createRandomTask(t)
+	lease := pgtype.Interval{Microseconds: time.Minute.Microseconds(), Valid: true}
+
+	claimed, err := testQuery.ClaimTasks(context.Background(), ClaimTasksParams{Lease: lease, BatchSize: 1})
+	require.NoError(t, err)
+	require.Len(t, claimed, 1)
+	require.Equal(t, task.ID, claimed[0].ID)
+	require.Equal(t, "running", claimed[0].Status)
+	require.EqualValues(t, 1, claimed[0].Attempts)
+	require.True(t, claimed[0].LockedUntil.Valid)
+
+	retryAt := time.Now().Add(time.Hour)
+	err = testQuery.RetryTask(context.Background(), RetryTaskParams{
+		ID:          task.ID,
+		LastError:   "failed",
+		ScheduledAt: pgtype.Timestamptz{Time: retryAt, Valid: true},
+	})
+	require.NoError(t, err)
+
+	retried, err := testQuery.GetTask(context.Background(), task.ID)
+	require.NoError(t, err)
+	require.Equal(t, "pending", retried.Status)
+	require.Equal(t, "failed", retried.LastError)
+	require.WithinDuration(t, retryAt, retried.ScheduledAt.Time, time.Second)
+	require.False(t, retried.LockedUntil.Valid)
+
+	err = testQuery.CompleteTask(context.Background(), task.ID)
+	require.NoError(t, err)
+
+	completed, err := testQuery.GetTask(context.Background(), task.ID)
+	require.NoError(t, err)
+	require.Equal(t, "done", completed.Status)
+}
+
+func TestFailTask(t *testing.T) {
+	task := createRandomTask(t)
+
+	err := testQuery.FailTask(context.Background(), FailTaskParams{ID: task.ID, LastError: "permanent"})
+	require.NoError(t, err)
+
+	failed, err := testQuery.GetTask(context.Background(), task.ID)
+	require.NoError(t, err)
+	require.Equal(t, "dead", failed.Status)
+	require.Equal(t, "permanent", failed.LastError)
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchTransferTxAtomic(t *testing.T) {
+	store := NewStore(testDB)
+	fromAccount := fundedAccount(t, 0)
+	toAccount1 := randomAccountIn(t, fromAccount.Currency)
+	toAccount2 := randomAccountIn(t, fromAccount.Currency)
+
+	arg := BatchTransferTxParams{
+		FromAccountID: fromAccount.ID,
+		Items: []BatchTransferItem{
+			{ToAccountID: toAccount1.ID, Amount: testMoney(10, fromAccount.Currency)},
+			{ToAccountID: toAccount2.ID, Amount: testMoney(20, fromAccount.Currency)},
+		},
+		Mode:        TransferBatchAtomic,
+		InitiatedBy: fromAccount.Owner,
+	}
+	err, result := store.BatchTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, TransferBatchCompleted, result.Batch.Status)
+	require.Len(t, result.Items, 2)
+	for _, item := range result.Items {
+		require.Equal(t, TransferBatchItemSucceeded, item.Status)
+		require.True(t, item.TransferID.Valid)
+	}
+	require.Equal(t, fromAccount.Balance-30, result.FromAccount.Balance)
+
+	// the second item overdraws the account, so the first is rolled back too
+	arg.Items[1].Amount = testMoney(result.FromAccount.Balance, fromAccount.Currency)
+	err, result = store.BatchTransferTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, TransferBatchFailed, result.Batch.Status)
+	require.Equal(t, fromAccount.Balance-30, result.FromAccount.Balance)
+	require.Equal(t, ErrInsufficientFunds.Error(), result.Items[1].Error)
+	require.NotEmpty(t, result.Items[0].Error)
+	require.False(t, result.Items[0].TransferID.Valid)
+
+	// the failed batch is recorded
+	batch, err := testQuery.GetTransferBatch(context.Background(), result.Batch.ID)
+	require.NoError(t, err)
+	require.Equal(t, TransferBatchFailed, batch.Status)
+	items, err := testQuery.ListTransferBatchItems(context.Background(), batch.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.Items, items)
+}
+
+func TestBatchTransferTxBestEffort(t *testing.T) {
+	store := NewStore(testDB)
+	fromAccount := fundedAccount(t, 0)
+	toAccount := randomAccountIn(t, fromAccount.Currency)
+
+	err, result := store.BatchTransferTx(context.Background(), BatchTransferTxParams{
+		FromAccountID: fromAccount.ID,
+		Items: []BatchTransferItem{
+			{ToAccountID: toAccount.ID, Amount: testMoney(10, fromAccount.Currency)},
+			{ToAccountID: toAccount.ID, Amount: testMoney(fromAccount.Balance, fromAccount.Currency)},
+			{ToAccountID: toAccount.ID, Amount: testMoney(5, fromAccount.Currency)},
+		},
+		Mode:        TransferBatchBestEffort,
+		InitiatedBy: fromAccount.Owner,
+	})
+	require.NoError(t, err)
+	require.Equal(t, TransferBatchPartial, result.Batch.Status)
+	require.Equal(t, TransferBatchItemSucceeded, result.Items[0].Status)
+	require.Equal(t, TransferBatchItemFailed, result.Items[1].Status)
+	require.Equal(t, ErrInsufficientFunds.Error(), result.Items[1].Error)
+	require.Equal(t, TransferBatchItemSucceeded, result.Items[2].Status)
+	require.Equal(t, fromAccount.Balance-15, result.FromAccount.Balance)
+
+	updated, err := testQuery.GetAccount(context.Background(), toAccount.ID)
+	require.NoError(t, err)
+	require.Equal(t, toAccount.Balance+15, updated.Balance)
+}
+
+func TestBatchTransferTxOverdraftWarning(t *testing.T) {
+	store := NewStore(testDB)
+	fromAccount := overdraftAccount(t, util.USD, 100)
+	toAccount := randomAccountIn(t, util.USD)
+	actor := AuditActor{Actor: fromAccount.Owner}
+
+	// the first item takes the account below zero, the second keeps it there
+	err, result := store.BatchTransferTx(context.Background(), BatchTransferTxParams{
+		FromAccountID: fromAccount.ID,
+		Items: []BatchTransferItem{
+			{ToAccountID: toAccount.ID, Amount: testMoney(fromAccount.Balance+10, util.USD)},
+			{ToAccountID: toAccount.ID, Amount: testMoney(20, util.USD)},
+		},
+		Mode:        TransferBatchAtomic,
+		InitiatedBy: fromAccount.Owner,
+		Audit:       &actor,
+	})
+	require.NoError(t, err)
+	require.Equal(t, TransferBatchCompleted, result.Batch.Status)
+	require.Equal(t, int64(-30), result.FromAccount.Balance)
+
+	warnings := overdraftWarnings(t, fromAccount)
+	require.Len(t, warnings, 1)
+	require.Equal(t, actor.Actor, warnings[0].Actor)
+}
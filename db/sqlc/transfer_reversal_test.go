@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReverseTransferTx(t *testing.T) {
+	store := NewStore(testDB)
+
+	sender := fundedAccount(t, 100)
+	recipient := randomAccountIn(t, sender.Currency)
+	err, original := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: sender.ID,
+		ToAccountID:   recipient.ID,
+		Amount:        testMoney(100, sender.Currency),
+	})
+	require.NoError(t, err)
+
+	err, result := store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID:  original.Transfer.ID,
+		Amount:      testMoney(40, sender.Currency),
+		Reason:      "partial refund",
+		InitiatedBy: recipient.Owner,
+	})
+	require.NoError(t, err)
+	require.Equal(t, original.Transfer.ID, result.Reversal.OriginalTransferID)
+	require.Equal(t, result.Transfer.ID, result.Reversal.TransferID)
+	require.Equal(t, recipient.ID, result.Transfer.FromAccountID)
+	require.Equal(t, sender.ID, result.Transfer.ToAccountID)
+	require.Equal(t, int64(40), result.ReversedAmount)
+	require.Equal(t, original.ToAccount.Balance-40, result.FromAccount.Balance)
+	require.Equal(t, original.FromAccount.Balance+40, result.ToAccount.Balance)
+
+	err, _ = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+		TransferID:  original.Transfer.ID,
+		Amount:      testMoney(61, sender.Currency),
+		Reason:      "too much",
+		InitiatedBy: recipient.Owner,
+	})
+	require.ErrorIs(t, err, ErrReversalExceedsTransfer)
+
+	reversals, err := testQuery.ListTransferReversals(context.Background(), original.Transfer.ID)
+	require.NoError(t, err)
+	require.Len(t, reversals, 1)
+}
+
+func TestReverseTransferTxConcurrent(t *testing.T) {
+	store := NewStore(testDB)
+
+	sender := fundedAccount(t, 100)
+	recipient := randomAccountIn(t, sender.Currency)
+	err, original := store.TransferTx(context.Background(), TransferTxParams{
+		FromAccountID: sender.ID,
+		ToAccountID:   recipient.ID,
+		Amount:        testMoney(100, sender.Currency),
+	})
+	require.NoError(t, err)
+
+	// refunds racing each other never add up to more than the transfer
+	n := 5
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i], _ = store.ReverseTransferTx(context.Background(), ReverseTransferTxParams{
+				TransferID:  original.Transfer.ID,
+				Amount:      testMoney(30, sender.Currency),
+				Reason:      "refund",
+				InitiatedBy: recipient.Owner,
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		require.ErrorIs(t, err, ErrReversalExceedsTransfer)
+	}
+	require.Equal(t, 3, succeeded)
+
+	reversed, err := testQuery.GetReversedAmount(context.Background(), original.Transfer.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(90), reversed)
+}
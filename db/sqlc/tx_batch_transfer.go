@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const (
+	// TransferBatchAtomic batches either execute every item or none.
+	TransferBatchAtomic = "atomic"
+	// TransferBatchBestEffort batches execute every item they can.
+	TransferBatchBestEffort = "best_effort"
+
+	TransferBatchCompleted = "completed"
+	TransferBatchPartial   = "partial"
+	TransferBatchFailed    = "failed"
+
+	TransferBatchItemSucceeded = "succeeded"
+	TransferBatchItemFailed    = "failed"
+)
+
+type BatchTransferItem struct {
+	ToAccountID int64 `json:"to_account_id"`
+	// Amount has to be positive and in the currency of both accounts.
+	Amount util.Money `json:"amount"`
+}
+
+type BatchTransferTxParams struct {
+	FromAccountID int64               `json:"from_account_id"`
+	Items         []BatchTransferItem `json:"items"`
+	Mode          string              `json:"mode"`
+	InitiatedBy   string              `json:"initiated_by"`
+	// Audit, when set, records the batch in the audit log as part of it.
+	Audit *AuditActor `json:"-"`
+}
+
+type BatchTransferTxResult struct {
+	Batch TransferBatch       `json:"batch"`
+	Items []TransferBatchItem `json:"items"`
+	// FromAccount is the source account after the batch.
+	FromAccount Account `json:"from_account"`
+	// Attempts is how often the transaction ran, more than once after a
+	// deadlock or serialization failure.
+	Attempts int `json:"-"`
+}
+
+// isBatchItemError reports whether err fails a single item of a batch rather
+// than the whole batch.
+func isBatchItemError(err error) bool {
+	return errors.Is(err, ErrInsufficientFunds) || errors.Is(err, util.ErrCurrencyMismatch)
+}
+
+// BatchTransferTx executes many transfers from one account and records them as
+// a batch. Atomic batches roll back every transfer once one of them fails,
+// best effort batches only the failing one. Either way the batch and the
+// outcome of every item are recorded, so a failed batch can be looked up too.
+func (store *SQLStore) BatchTransferTx(ctx context.Context, batchTransferParams BatchTransferTxParams) (error, BatchTransferTxResult) {
+	var result BatchTransferTxResult
+
+	var attempts int
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = BatchTransferTxResult{}
+		items := batchTransferParams.Items
+		transfers := make([]Transfer, len(items))
+		failures := make([]string, len(items))
+		warnings := make([][]AuditEventParams, len(items))
+
+		execute := func(q *Queries, i int) error {
+			var err error
+			var transferResult TransferTxResult
+			transferResult, warnings[i], err = transfer(ctx, q, TransferTxParams{
+				FromAccountID: batchTransferParams.FromAccountID,
+				ToAccountID:   items[i].ToAccountID,
+				Amount:        items[i].Amount,
+				Audit:         batchTransferParams.Audit,
+			})
+			transfers[i] = transferResult.Transfer
+			return err
+		}
+
+		switch batchTransferParams.Mode {
+		case TransferBatchAtomic:
+			failed := -1
+			err := savepoint(ctx, q, func(q *Queries) error {
+				for i := range items {
+					if err := execute(q, i); err != nil {
+						failed = i
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				if !isBatchItemError(err) {
+					return err
+				}
+				for i := range items {
+					transfers[i] = Transfer{}
+					warnings[i] = nil
+					failures[i] = fmt.Sprintf("rolled back, item %d failed", failed)
+				}
+				failures[failed] = err.Error()
+			}
+		case TransferBatchBestEffort:
+			for i := range items {
+				err := savepoint(ctx, q, func(q *Queries) error {
+					return execute(q, i)
+				})
+				if err != nil {
+					if !isBatchItemError(err) {
+						return err
+					}
+					transfers[i] = Transfer{}
+					warnings[i] = nil
+					failures[i] = err.Error()
+				}
+			}
+		default:
+			return fmt.Errorf("unknown batch mode %q", batchTransferParams.Mode)
+		}
+
+		succeeded := 0
+		for _, failure := range failures {
+			if failure == "" {
+				succeeded++
+			}
+		}
+		status := TransferBatchPartial
+		switch succeeded {
+		case len(items):
+			status = TransferBatchCompleted
+		case 0:
+			status = TransferBatchFailed
+		}
+
+		var err error
+		result.Batch, err = q.CreateTransferBatch(ctx, CreateTransferBatchParams{
+			FromAccountID: batchTransferParams.FromAccountID,
+			Mode:          batchTransferParams.Mode,
+			Status:        status,
+			InitiatedBy:   batchTransferParams.InitiatedBy,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Items = make([]TransferBatchItem, len(items))
+		for i, item := range items {
+			arg := CreateTransferBatchItemParams{
+				BatchID:     result.Batch.ID,
+				Position:    int32(i),
+				ToAccountID: item.ToAccountID,
+				Amount:      item.Amount.Amount,
+				Status:      TransferBatchItemSucceeded,
+				Error:       failures[i],
+			}
+			if failures[i] != "" {
+				arg.Status = TransferBatchItemFailed
+			} else {
+				arg.TransferID = pgtype.Int8{Int64: transfers[i].ID, Valid: true}
+			}
+			result.Items[i], err = q.CreateTransferBatchItem(ctx, arg)
+			if err != nil {
+				return err
+			}
+		}
+
+		result.FromAccount, err = q.GetAccount(ctx, batchTransferParams.FromAccountID)
+		if err != nil {
+			return err
+		}
+
+		var events []AuditEventParams
+		for _, itemWarnings := range warnings {
+			events = append(events, itemWarnings...)
+		}
+		if batchTransferParams.Audit != nil {
+			events = append(events, AuditEventParams{
+				AuditActor: *batchTransferParams.Audit,
+				Action:     AuditActionTransferBatchCreate,
+				Target:     "transfer_batch:" + strconv.FormatInt(result.Batch.ID, 10),
+				After:      result.Batch,
+			})
+		}
+		return recordAuditEvents(ctx, q, events)
+	}, withAttempts(&attempts))
+	result.Attempts = attempts
+
+	return err, result
+}
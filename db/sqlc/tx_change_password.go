@@ -0,0 +1,35 @@
+package db
+
+import (
+	"context"
+)
+
+type ChangePasswordTxParams struct {
+	Username       string
+	HashedPassword string
+}
+
+type ChangePasswordTxResult struct {
+	User User `json:"user"`
+}
+
+// ChangePasswordTx stores a new password and blocks every session of the
+// user, so refresh tokens issued with the old password stop working too.
+func (store *SQLStore) ChangePasswordTx(ctx context.Context, changePasswordParams ChangePasswordTxParams) (error, ChangePasswordTxResult) {
+	var result ChangePasswordTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = ChangePasswordTxResult{}
+		var err error
+		result.User, err = q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			Username:       changePasswordParams.Username,
+			HashedPassword: changePasswordParams.HashedPassword,
+		})
+		if err != nil {
+			return err
+		}
+		return q.BlockUserSessions(ctx, changePasswordParams.Username)
+	})
+
+	return err, result
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+)
+
+type ConfirmTOTPTxParams struct {
+	Username string
+	// RecoveryCodeHashes replace any recovery codes the user had before.
+	RecoveryCodeHashes []string
+}
+
+type ConfirmTOTPTxResult struct {
+	UserTOTP      UserTotp       `json:"user_totp"`
+	RecoveryCodes []RecoveryCode `json:"recovery_codes"`
+}
+
+// ConfirmTOTPTx turns on two-factor login for the user and stores a fresh set
+// of recovery codes. It returns pgx.ErrNoRows when there is no pending secret.
+func (store *SQLStore) ConfirmTOTPTx(ctx context.Context, confirmTOTPParams ConfirmTOTPTxParams) (error, ConfirmTOTPTxResult) {
+	var result ConfirmTOTPTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = ConfirmTOTPTxResult{}
+		var err error
+		result.UserTOTP, err = q.ConfirmUserTOTP(ctx, confirmTOTPParams.Username)
+		if err != nil {
+			return err
+		}
+
+		if err := q.DeleteRecoveryCodes(ctx, confirmTOTPParams.Username); err != nil {
+			return err
+		}
+		for _, codeHash := range confirmTOTPParams.RecoveryCodeHashes {
+			code, err := q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{
+				Username: confirmTOTPParams.Username,
+				CodeHash: codeHash,
+			})
+			if err != nil {
+				return err
+			}
+			result.RecoveryCodes = append(result.RecoveryCodes, code)
+		}
+		return nil
+	})
+
+	return err, result
+}
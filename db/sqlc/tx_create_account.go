@@ -0,0 +1,40 @@
+package db
+
+import (
+	"context"
+	"strconv"
+)
+
+type CreateAccountTxParams struct {
+	CreateAccountParams
+	Audit AuditActor
+}
+
+type CreateAccountTxResult struct {
+	Account Account `json:"account"`
+}
+
+// CreateAccountTx creates an account and records it in the audit log in the
+// same transaction, so no account exists without its creation event.
+func (store *SQLStore) CreateAccountTx(ctx context.Context, createAccountParams CreateAccountTxParams) (error, CreateAccountTxResult) {
+	var result CreateAccountTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = CreateAccountTxResult{}
+		var err error
+		result.Account, err = q.CreateAccount(ctx, createAccountParams.CreateAccountParams)
+		if err != nil {
+			return err
+		}
+
+		_, err = recordAuditEvent(ctx, q, AuditEventParams{
+			AuditActor: createAccountParams.Audit,
+			Action:     AuditActionAccountCreate,
+			Target:     "account:" + strconv.FormatInt(result.Account.ID, 10),
+			After:      result.Account,
+		})
+		return err
+	})
+
+	return err, result
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+type CreateUserTxParams struct {
+	CreateUserParams
+	// AfterCreate runs inside the transaction once the user exists, so work
+	// it enqueues through q is committed or rolled back with the user. It runs
+	// again when the transaction is retried.
+	AfterCreate func(q Querier, result CreateUserTxResult) error
+}
+
+type CreateUserTxResult struct {
+	User        User        `json:"user"`
+	VerifyEmail VerifyEmail `json:"verify_email"`
+}
+
+// CreateUserTx creates a user together with the one-time code used to verify
+// the email address of that user.
+func (store *SQLStore) CreateUserTx(ctx context.Context, createUserParams CreateUserTxParams) (error, CreateUserTxResult) {
+	var result CreateUserTxResult
+
+	secretCode, err := util.RandomSecretCode(32)
+	if err != nil {
+		return err, result
+	}
+
+	err = store.execTx(ctx, func(q *Queries) error {
+		result = CreateUserTxResult{}
+		var err error
+		result.User, err = q.CreateUser(ctx, createUserParams.CreateUserParams)
+		if err != nil {
+			return err
+		}
+
+		result.VerifyEmail, err = q.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+			Username:   result.User.Username,
+			Email:      result.User.Email,
+			SecretCode: secretCode,
+		})
+		if err != nil {
+			return err
+		}
+
+		if createUserParams.AfterCreate != nil {
+			return createUserParams.AfterCreate(q, result)
+		}
+		return nil
+	})
+
+	return err, result
+}
@@ -0,0 +1,224 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const (
+	HoldActive   = "active"
+	HoldCaptured = "captured"
+	HoldReleased = "released"
+	HoldExpired  = "expired"
+)
+
+// ErrHoldNotActive is returned when capturing a hold that was already
+// captured, released or has expired.
+var ErrHoldNotActive = errors.New("hold is not active")
+
+// ErrCaptureExceedsHold is returned when capturing more than a hold reserves.
+var ErrCaptureExceedsHold = errors.New("capture exceeds the held amount")
+
+type PlaceHoldTxParams struct {
+	AccountID int64
+	// Amount has to be positive and in the currency of the account.
+	Amount    util.Money
+	Reference string
+	ExpiresAt time.Time
+	// Audit, when set, records the hold in the audit log as part of it.
+	Audit *AuditActor
+}
+
+type PlaceHoldTxResult struct {
+	Hold    Hold    `json:"hold"`
+	Account Account `json:"account"`
+	// HeldAmount is the total of the active holds on the account, this one
+	// included.
+	HeldAmount int64 `json:"held_amount"`
+}
+
+// PlaceHoldTx reserves part of the available balance of an account until the
+// hold is captured, released or expires. It returns ErrInsufficientFunds when
+// the available balance does not cover the hold.
+func (store *SQLStore) PlaceHoldTx(ctx context.Context, placeHoldParams PlaceHoldTxParams) (error, PlaceHoldTxResult) {
+	var result PlaceHoldTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = PlaceHoldTxResult{}
+		amount := placeHoldParams.Amount
+		if !amount.IsPositive() {
+			return fmt.Errorf("hold amount must be positive, got %s", amount)
+		}
+
+		var err error
+		// locking the account serializes holds with transfers out of it
+		result.Account, err = q.GetAccountForUpdate(ctx, placeHoldParams.AccountID)
+		if err != nil {
+			return err
+		}
+		if err := requireCurrency(result.Account, amount); err != nil {
+			return err
+		}
+
+		held, err := q.GetAccountHeldAmount(ctx, placeHoldParams.AccountID)
+		if err != nil {
+			return err
+		}
+		if result.Account.Balance+result.Account.OverdraftLimit-held < amount.Amount {
+			return ErrInsufficientFunds
+		}
+
+		result.Hold, err = q.CreateHold(ctx, CreateHoldParams{
+			AccountID: placeHoldParams.AccountID,
+			Amount:    amount.Amount,
+			Reference: placeHoldParams.Reference,
+			ExpiresAt: pgtype.Timestamptz{Time: placeHoldParams.ExpiresAt, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		result.HeldAmount = held + amount.Amount
+
+		if placeHoldParams.Audit == nil {
+			return nil
+		}
+		_, err = recordAuditEvent(ctx, q, AuditEventParams{
+			AuditActor: *placeHoldParams.Audit,
+			Action:     AuditActionHoldPlace,
+			Target:     "hold:" + strconv.FormatInt(result.Hold.ID, 10),
+			After:      result.Hold,
+		})
+		return err
+	})
+
+	return err, result
+}
+
+type CaptureHoldTxParams struct {
+	HoldID      int64
+	ToAccountID int64
+	// Amount is moved to ToAccountID, at most the held amount. The rest of
+	// the hold is released.
+	Amount util.Money
+	// Audit, when set, records the capture in the audit log as part of it.
+	Audit *AuditActor
+}
+
+type CaptureHoldTxResult struct {
+	TransferTxResult
+	Hold Hold `json:"hold"`
+}
+
+// CaptureHoldTx closes an active hold and transfers the captured amount out
+// of the held account. It returns ErrHoldNotActive when the hold is no longer
+// active and ErrCaptureExceedsHold when the amount is more than it reserves.
+func (store *SQLStore) CaptureHoldTx(ctx context.Context, captureHoldParams CaptureHoldTxParams) (error, CaptureHoldTxResult) {
+	var result CaptureHoldTxResult
+
+	var attempts int
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = CaptureHoldTxResult{}
+		hold, err := q.GetHoldForUpdate(ctx, captureHoldParams.HoldID)
+		if err != nil {
+			return err
+		}
+		if hold.Status != HoldActive || !hold.ExpiresAt.Time.After(time.Now()) {
+			return ErrHoldNotActive
+		}
+		if captureHoldParams.Amount.Amount > hold.Amount {
+			return ErrCaptureExceedsHold
+		}
+
+		// closing the hold first frees the funds it reserved for the transfer
+		_, err = q.CloseHold(ctx, CloseHoldParams{ID: hold.ID, Status: HoldCaptured})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrHoldNotActive
+		}
+		if err != nil {
+			return err
+		}
+		var events []AuditEventParams
+		result.TransferTxResult, events, err = transfer(ctx, q, TransferTxParams{
+			FromAccountID: hold.AccountID,
+			ToAccountID:   captureHoldParams.ToAccountID,
+			Amount:        captureHoldParams.Amount,
+			Audit:         captureHoldParams.Audit,
+		})
+		if err != nil {
+			return err
+		}
+		result.Hold, err = q.SetHoldTransfer(ctx, SetHoldTransferParams{
+			ID:             hold.ID,
+			CapturedAmount: captureHoldParams.Amount.Amount,
+			TransferID:     pgtype.Int8{Int64: result.Transfer.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		if captureHoldParams.Audit != nil {
+			events = append(events, AuditEventParams{
+				AuditActor: *captureHoldParams.Audit,
+				Action:     AuditActionHoldCapture,
+				Target:     "hold:" + strconv.FormatInt(hold.ID, 10),
+				Before:     hold,
+				After:      result.Hold,
+			})
+		}
+		return recordAuditEvents(ctx, q, events)
+	}, withAttempts(&attempts))
+	result.Attempts = attempts
+
+	return err, result
+}
+
+type ReleaseHoldTxParams struct {
+	HoldID int64
+	// Audit, when set, records the release in the audit log as part of it.
+	Audit *AuditActor
+}
+
+type ReleaseHoldTxResult struct {
+	Hold Hold `json:"hold"`
+}
+
+// ReleaseHoldTx closes an active hold without moving any money, giving the
+// funds it reserved back to the account. It returns ErrHoldNotActive when the
+// hold was already captured, released or has expired.
+func (store *SQLStore) ReleaseHoldTx(ctx context.Context, releaseHoldParams ReleaseHoldTxParams) (error, ReleaseHoldTxResult) {
+	var result ReleaseHoldTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = ReleaseHoldTxResult{}
+		hold, err := q.GetHoldForUpdate(ctx, releaseHoldParams.HoldID)
+		if err != nil {
+			return err
+		}
+
+		result.Hold, err = q.CloseHold(ctx, CloseHoldParams{ID: hold.ID, Status: HoldReleased})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrHoldNotActive
+		}
+		if err != nil || releaseHoldParams.Audit == nil {
+			return err
+		}
+
+		_, err = recordAuditEvent(ctx, q, AuditEventParams{
+			AuditActor: *releaseHoldParams.Audit,
+			Action:     AuditActionHoldRelease,
+			Target:     "hold:" + strconv.FormatInt(hold.ID, 10),
+			Before:     hold,
+			After:      result.Hold,
+		})
+		return err
+	})
+
+	return err, result
+}
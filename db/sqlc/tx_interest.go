@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+// microsPerUnit is how many accrued millionths make a minor unit.
+const microsPerUnit = 1_000_000
+
+const (
+	// InterestSavings is paid to savings accounts on positive balances.
+	InterestSavings = "savings"
+	// InterestOverdraft is charged to accounts on negative balances.
+	InterestOverdraft = "overdraft"
+)
+
+// DailyInterestMicros is the interest a balance earns in a day at an annual
+// rate in basis points, in millionths of a minor unit. Years count 365 days
+// and balances that are not positive earn nothing. It returns
+// util.ErrAmountOverflow when the interest does not fit an int64.
+func DailyInterestMicros(balance int64, annualRateBps int32) (int64, error) {
+	if balance <= 0 || annualRateBps <= 0 {
+		return 0, nil
+	}
+	// balance * bps / 10_000 / 365 * 1_000_000, the product overflows int64
+	// long before the quotient does
+	micros := new(big.Int).Mul(big.NewInt(balance), big.NewInt(int64(annualRateBps)*100))
+	micros.Quo(micros, big.NewInt(365))
+	if !micros.IsInt64() {
+		return 0, fmt.Errorf("%w: daily interest on %d at %d bps", util.ErrAmountOverflow, balance, annualRateBps)
+	}
+	return micros.Int64(), nil
+}
+
+type PostInterestTxParams struct {
+	AccountID int64 `json:"account_id"`
+	// Kind is InterestSavings or InterestOverdraft.
+	Kind string `json:"kind"`
+	// SystemAccountID is the system account in the currency of the account
+	// that pays savings interest, which may go negative, or that receives
+	// overdraft interest.
+	SystemAccountID int64 `json:"system_account_id"`
+	// Period is the first day of the month to post, the interest accrued in
+	// and before it is posted.
+	Period time.Time `json:"period"`
+}
+
+type PostInterestTxResult struct {
+	Posting InterestPosting `json:"posting"`
+	// TransferTxResult is empty when no whole minor unit was due.
+	TransferTxResult
+}
+
+// PostInterestTx pays the savings interest accrued on an account up to the
+// end of a month, or charges the overdraft interest. Fractions of a minor
+// unit are carried into the next month. Posting a month again returns the
+// earlier posting.
+func (store *SQLStore) PostInterestTx(ctx context.Context, postInterestParams PostInterestTxParams) (error, PostInterestTxResult) {
+	var result PostInterestTxResult
+
+	period := pgtype.Date{Time: postInterestParams.Period, Valid: true}
+	var attempts int
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = PostInterestTxResult{}
+		// locking the account keeps concurrent runs from posting twice
+		account, err := q.GetAccountForUpdate(ctx, postInterestParams.AccountID)
+		if err != nil {
+			return err
+		}
+
+		result.Posting, err = q.GetInterestPosting(ctx, GetInterestPostingParams{
+			AccountID: account.ID,
+			Period:    period,
+			Kind:      postInterestParams.Kind,
+		})
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+
+		accrued, err := q.GetAccruedInterest(ctx, GetAccruedInterestParams{
+			AccountID: account.ID,
+			Kind:      postInterestParams.Kind,
+			Before:    pgtype.Date{Time: postInterestParams.Period.AddDate(0, 1, 0), Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+		posted, err := q.GetPostedInterest(ctx, GetPostedInterestParams{
+			AccountID: account.ID,
+			Kind:      postInterestParams.Kind,
+		})
+		if err != nil {
+			return err
+		}
+
+		arg := CreateInterestPostingParams{
+			AccountID: account.ID,
+			Period:    period,
+			Kind:      postInterestParams.Kind,
+			Amount:    accrued/microsPerUnit - posted,
+		}
+		if arg.Amount > 0 {
+			currency, err := q.GetCurrency(ctx, account.Currency)
+			if err != nil {
+				return err
+			}
+			transferParams := TransferTxParams{
+				FromAccountID: postInterestParams.SystemAccountID,
+				ToAccountID:   account.ID,
+				Amount: util.NewMoney(arg.Amount, util.Currency{
+					Code:     currency.Code,
+					Exponent: int(currency.Exponent),
+				}),
+			}
+			if postInterestParams.Kind == InterestOverdraft {
+				// the charge is due even when it takes the account past its limit
+				transferParams.FromAccountID, transferParams.ToAccountID = account.ID, postInterestParams.SystemAccountID
+			}
+			result.TransferTxResult, err = postTransfer(ctx, q, transferParams)
+			if err != nil {
+				return err
+			}
+			arg.TransferID = pgtype.Int8{Int64: result.Transfer.ID, Valid: true}
+		} else {
+			arg.Amount = 0
+		}
+
+		result.Posting, err = q.CreateInterestPosting(ctx, arg)
+		return err
+	}, withAttempts(&attempts))
+	result.Attempts = attempts
+
+	return err, result
+}
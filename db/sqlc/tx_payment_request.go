@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const (
+	PaymentRequestPending  = "pending"
+	PaymentRequestAccepted = "accepted"
+	PaymentRequestDeclined = "declined"
+	PaymentRequestExpired  = "expired"
+)
+
+// ErrPaymentRequestNotPending is returned when resolving a payment request
+// that was already accepted, declined or has expired.
+var ErrPaymentRequestNotPending = errors.New("payment request is not pending")
+
+type CreatePaymentRequestTxParams struct {
+	CreatePaymentRequestParams
+	// AfterCreate runs inside the transaction once the request exists, so
+	// the payer is only notified of requests that are committed. It runs
+	// again when the transaction is retried.
+	AfterCreate func(q Querier, paymentRequest PaymentRequest) error
+}
+
+type CreatePaymentRequestTxResult struct {
+	PaymentRequest PaymentRequest `json:"payment_request"`
+}
+
+// CreatePaymentRequestTx asks the payer for money on behalf of the requester.
+func (store *SQLStore) CreatePaymentRequestTx(ctx context.Context, createPaymentRequestParams CreatePaymentRequestTxParams) (error, CreatePaymentRequestTxResult) {
+	var result CreatePaymentRequestTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = CreatePaymentRequestTxResult{}
+		var err error
+		result.PaymentRequest, err = q.CreatePaymentRequest(ctx, createPaymentRequestParams.CreatePaymentRequestParams)
+		if err != nil {
+			return err
+		}
+
+		if createPaymentRequestParams.AfterCreate != nil {
+			return createPaymentRequestParams.AfterCreate(q, result.PaymentRequest)
+		}
+		return nil
+	})
+
+	return err, result
+}
+
+type AcceptPaymentRequestTxParams struct {
+	ID int64
+	// FromAccountID and ToAccountID are the accounts of the payer and the
+	// requester in the currency of the request.
+	FromAccountID int64
+	ToAccountID   int64
+	// Audit, when set, records the payment in the audit log as part of it.
+	Audit *AuditActor
+	// AfterAccept runs inside the transaction once the request is paid. It
+	// runs again when the transaction is retried.
+	AfterAccept func(q Querier, paymentRequest PaymentRequest) error
+}
+
+type AcceptPaymentRequestTxResult struct {
+	TransferTxResult
+	PaymentRequest PaymentRequest `json:"payment_request"`
+}
+
+// AcceptPaymentRequestTx pays a pending payment request with a transfer from
+// the payer to the requester. It returns ErrPaymentRequestNotPending when the
+// request is no longer pending or has expired.
+func (store *SQLStore) AcceptPaymentRequestTx(ctx context.Context, acceptPaymentRequestParams AcceptPaymentRequestTxParams) (error, AcceptPaymentRequestTxResult) {
+	var result AcceptPaymentRequestTxResult
+
+	var attempts int
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = AcceptPaymentRequestTxResult{}
+		// locking the request keeps it from being paid twice
+		paymentRequest, err := q.GetPaymentRequestForUpdate(ctx, acceptPaymentRequestParams.ID)
+		if err != nil {
+			return err
+		}
+		if paymentRequest.Status != PaymentRequestPending || !paymentRequest.ExpiresAt.Time.After(time.Now()) {
+			return ErrPaymentRequestNotPending
+		}
+		currency, err := q.GetCurrency(ctx, paymentRequest.Currency)
+		if err != nil {
+			return err
+		}
+
+		var events []AuditEventParams
+		result.TransferTxResult, events, err = transfer(ctx, q, TransferTxParams{
+			FromAccountID: acceptPaymentRequestParams.FromAccountID,
+			ToAccountID:   acceptPaymentRequestParams.ToAccountID,
+			Amount: util.NewMoney(paymentRequest.Amount, util.Currency{
+				Code:     currency.Code,
+				Exponent: int(currency.Exponent),
+			}),
+			Audit: acceptPaymentRequestParams.Audit,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.PaymentRequest, err = q.ResolvePaymentRequest(ctx, ResolvePaymentRequestParams{
+			ID:         paymentRequest.ID,
+			Status:     PaymentRequestAccepted,
+			TransferID: pgtype.Int8{Int64: result.Transfer.ID, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		if acceptPaymentRequestParams.AfterAccept != nil {
+			if err := acceptPaymentRequestParams.AfterAccept(q, result.PaymentRequest); err != nil {
+				return err
+			}
+		}
+
+		if acceptPaymentRequestParams.Audit != nil {
+			events = append(events, AuditEventParams{
+				AuditActor: *acceptPaymentRequestParams.Audit,
+				Action:     AuditActionPaymentRequestAccept,
+				Target:     "payment_request:" + strconv.FormatInt(paymentRequest.ID, 10),
+				Before:     paymentRequest,
+				After:      result.PaymentRequest,
+			})
+		}
+		return recordAuditEvents(ctx, q, events)
+	}, withAttempts(&attempts))
+	result.Attempts = attempts
+
+	return err, result
+}
+
+type DeclinePaymentRequestTxParams struct {
+	ID int64
+	// AfterDecline runs inside the transaction once the request is declined.
+	// It runs again when the transaction is retried.
+	AfterDecline func(q Querier, paymentRequest PaymentRequest) error
+}
+
+type DeclinePaymentRequestTxResult struct {
+	PaymentRequest PaymentRequest `json:"payment_request"`
+}
+
+// DeclinePaymentRequestTx turns down a pending payment request. It returns
+// ErrPaymentRequestNotPending when the request is no longer pending.
+func (store *SQLStore) DeclinePaymentRequestTx(ctx context.Context, declinePaymentRequestParams DeclinePaymentRequestTxParams) (error, DeclinePaymentRequestTxResult) {
+	var result DeclinePaymentRequestTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = DeclinePaymentRequestTxResult{}
+		var err error
+		result.PaymentRequest, err = q.ResolvePaymentRequest(ctx, ResolvePaymentRequestParams{
+			ID:     declinePaymentRequestParams.ID,
+			Status: PaymentRequestDeclined,
+		})
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrPaymentRequestNotPending
+		}
+		if err != nil {
+			return err
+		}
+
+		if declinePaymentRequestParams.AfterDecline != nil {
+			return declinePaymentRequestParams.AfterDecline(q, result.PaymentRequest)
+		}
+		return nil
+	})
+
+	return err, result
+}
+
+type ExpirePaymentRequestsTxParams struct {
+	// AfterExpire runs inside the transaction for every expired request. It
+	// runs again when the transaction is retried.
+	AfterExpire func(q Querier, paymentRequest PaymentRequest) error
+}
+
+type ExpirePaymentRequestsTxResult struct {
+	PaymentRequests []PaymentRequest `json:"payment_requests"`
+}
+
+// ExpirePaymentRequestsTx marks pending payment requests past their expiry as
+// expired.
+func (store *SQLStore) ExpirePaymentRequestsTx(ctx context.Context, expirePaymentRequestsParams ExpirePaymentRequestsTxParams) (error, ExpirePaymentRequestsTxResult) {
+	var result ExpirePaymentRequestsTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = ExpirePaymentRequestsTxResult{}
+		var err error
+		result.PaymentRequests, err = q.ExpirePaymentRequests(ctx)
+		if err != nil {
+			return err
+		}
+
+		if expirePaymentRequestsParams.AfterExpire == nil {
+			return nil
+		}
+		for _, paymentRequest := range result.PaymentRequests {
+			if err := expirePaymentRequestsParams.AfterExpire(q, paymentRequest); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return err, result
+}
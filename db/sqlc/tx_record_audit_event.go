@@ -0,0 +1,24 @@
+package db
+
+import (
+	"context"
+)
+
+type RecordAuditEventTxResult struct {
+	Event AuditEvent `json:"event"`
+}
+
+// RecordAuditEventTx appends a single event to the audit log, for actions
+// that do not run in a transaction of their own.
+func (store *SQLStore) RecordAuditEventTx(ctx context.Context, auditEventParams AuditEventParams) (error, RecordAuditEventTxResult) {
+	var result RecordAuditEventTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = RecordAuditEventTxResult{}
+		var err error
+		result.Event, err = recordAuditEvent(ctx, q, auditEventParams)
+		return err
+	})
+
+	return err, result
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+)
+
+type ResetPasswordTxParams struct {
+	TokenHash      string
+	HashedPassword string
+}
+
+type ResetPasswordTxResult struct {
+	User User `json:"user"`
+}
+
+// ResetPasswordTx consumes a password reset token, stores the new password and
+// blocks every session of the user. It returns pgx.ErrNoRows when the token is
+// unknown, used or expired.
+func (store *SQLStore) ResetPasswordTx(ctx context.Context, resetPasswordParams ResetPasswordTxParams) (error, ResetPasswordTxResult) {
+	var result ResetPasswordTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = ResetPasswordTxResult{}
+		passwordReset, err := q.UsePasswordReset(ctx, resetPasswordParams.TokenHash)
+		if err != nil {
+			return err
+		}
+
+		result.User, err = q.UpdateUserPassword(ctx, UpdateUserPasswordParams{
+			Username:       passwordReset.Username,
+			HashedPassword: resetPasswordParams.HashedPassword,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := q.InvalidatePasswordResets(ctx, passwordReset.Username); err != nil {
+			return err
+		}
+		return q.BlockUserSessions(ctx, passwordReset.Username)
+	})
+
+	return err, result
+}
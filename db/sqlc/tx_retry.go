@@ -0,0 +1,84 @@
+package db
+
+import (
+	"errors"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const (
+	defaultTxMaxAttempts   = 3
+	defaultTxMinRetryDelay = 10 * time.Millisecond
+	defaultTxMaxRetryDelay = 500 * time.Millisecond
+)
+
+// Postgres error codes of transactions that can succeed when run again.
+const (
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
+type txOptions struct {
+	isoLevel pgx.TxIsoLevel
+	attempts *int
+}
+
+type txOption func(options *txOptions)
+
+// withIsolation runs the transaction at the given isolation level instead of
+// read committed.
+func withIsolation(isoLevel pgx.TxIsoLevel) txOption {
+	return func(options *txOptions) {
+		options.isoLevel = isoLevel
+	}
+}
+
+// withAttempts stores how often the transaction ran in attempts.
+func withAttempts(attempts *int) txOption {
+	return func(options *txOptions) {
+		options.attempts = attempts
+	}
+}
+
+func transactionConfig() util.Transaction {
+	config := util.Config.Transaction
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultTxMaxAttempts
+	}
+	if config.MinRetryDelay <= 0 {
+		config.MinRetryDelay = defaultTxMinRetryDelay
+	}
+	if config.MaxRetryDelay <= 0 {
+		config.MaxRetryDelay = defaultTxMaxRetryDelay
+	}
+	return config
+}
+
+// retryableError returns the postgres error code of err when the transaction
+// failed only because of other transactions running at the same time.
+func retryableError(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return "", false
+	}
+	switch pgErr.Code {
+	case serializationFailure, deadlockDetected:
+		return pgErr.Code, true
+	}
+	return "", false
+}
+
+// txRetryDelay doubles the delay with every attempt and picks a random point
+// in the upper half of it, so the transactions that conflicted do not meet
+// again on their next attempt.
+func txRetryDelay(attempts int, config util.Transaction) time.Duration {
+	delay := config.MaxRetryDelay
+	if attempts < 32 {
+		delay = min(config.MinRetryDelay<<max(attempts-1, 0), config.MaxRetryDelay)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryableError(t *testing.T) {
+	code, ok := retryableError(&pgconn.PgError{Code: serializationFailure})
+	require.True(t, ok)
+	require.Equal(t, serializationFailure, code)
+
+	code, ok = retryableError(fmt.Errorf("transfer: %w", &pgconn.PgError{Code: deadlockDetected}))
+	require.True(t, ok)
+	require.Equal(t, deadlockDetected, code)
+
+	_, ok = retryableError(&pgconn.PgError{Code: "23505"})
+	require.False(t, ok)
+	_, ok = retryableError(ErrInsufficientFunds)
+	require.False(t, ok)
+	_, ok = retryableError(nil)
+	require.False(t, ok)
+}
+
+func TestTxRetryDelay(t *testing.T) {
+	config := util.Transaction{MinRetryDelay: 10 * time.Millisecond, MaxRetryDelay: 100 * time.Millisecond}
+
+	for attempts, want := range map[int]time.Duration{
+		1:  10 * time.Millisecond,
+		2:  20 * time.Millisecond,
+		4:  80 * time.Millisecond,
+		5:  100 * time.Millisecond,
+		64: 100 * time.Millisecond,
+	} {
+		delay := txRetryDelay(attempts, config)
+		require.GreaterOrEqual(t, delay, want/2)
+		require.LessOrEqual(t, delay, want)
+	}
+}
+
+func TestExecTxRetry(t *testing.T) {
+	store := NewStore(testDB).(*SQLStore)
+	account := RandomAccount(t)
+
+	var attempts int
+	calls := 0
+	err := store.execTx(context.Background(), func(q *Queries) error {
+		calls++
+		if _, err := q.AddAccountBalance(context.Background(), AddAccountBalanceParams{ID: account.ID, Amount: 10}); err != nil {
+			return err
+		}
+		if calls == 1 {
+			return &pgconn.PgError{Code: serializationFailure}
+		}
+		return nil
+	}, withAttempts(&attempts))
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+	require.Equal(t, 2, attempts)
+
+	// only the committed attempt changed the balance
+	updatedAccount, err := testQuery.GetAccount(context.Background(), account.ID)
+	require.NoError(t, err)
+	require.Equal(t, account.Balance+10, updatedAccount.Balance)
+}
+
+func TestExecTxGivesUp(t *testing.T) {
+	store := NewStore(testDB).(*SQLStore)
+
+	var attempts int
+	err := store.execTx(context.Background(), func(q *Queries) error {
+		return &pgconn.PgError{Code: deadlockDetected}
+	}, withAttempts(&attempts))
+	_, ok := retryableError(err)
+	require.True(t, ok)
+	require.Equal(t, transactionConfig().MaxAttempts, attempts)
+
+	failure := errors.New("not retryable")
+	err = store.execTx(context.Background(), func(q *Queries) error {
+		return failure
+	}, withAttempts(&attempts))
+	require.ErrorIs(t, err, failure)
+	require.Equal(t, 1, attempts)
+}
+
+func TestExecTxIsolation(t *testing.T) {
+	store := NewStore(testDB).(*SQLStore)
+
+	for isoLevel, want := range map[pgx.TxIsoLevel]string{
+		"":                 "read committed",
+		pgx.RepeatableRead: "repeatable read",
+		pgx.Serializable:   "serializable",
+	} {
+		var opts []txOption
+		if isoLevel != "" {
+			opts = append(opts, withIsolation(isoLevel))
+		}
+
+		var got string
+		err := store.execTx(context.Background(), func(q *Queries) error {
+			return q.db.QueryRow(context.Background(), "SHOW transaction_isolation").Scan(&got)
+		}, opts...)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+}
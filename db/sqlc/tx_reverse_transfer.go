@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+// ErrReversalExceedsTransfer is returned when the reversals of a transfer
+// would add up to more than the transfer moved.
+var ErrReversalExceedsTransfer = errors.New("reversal exceeds the transferred amount")
+
+type ReverseTransferTxParams struct {
+	TransferID int64
+	// Amount is moved back from the recipient to the sender. Together with
+	// earlier reversals it can be at most the amount of the transfer.
+	Amount      util.Money
+	Reason      string
+	InitiatedBy string
+	// Audit, when set, records the reversal in the audit log as part of it.
+	Audit *AuditActor
+}
+
+type ReverseTransferTxResult struct {
+	TransferTxResult
+	Reversal TransferReversal `json:"reversal"`
+	// ReversedAmount is the total reversed of the transfer, this reversal
+	// included.
+	ReversedAmount int64 `json:"reversed_amount"`
+}
+
+// ReverseTransferTx refunds all or part of a transfer with a compensating
+// transfer linked to it. It returns ErrReversalExceedsTransfer when more
+// would be refunded than was transferred.
+func (store *SQLStore) ReverseTransferTx(ctx context.Context, reverseTransferParams ReverseTransferTxParams) (error, ReverseTransferTxResult) {
+	var result ReverseTransferTxResult
+
+	var attempts int
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = ReverseTransferTxResult{}
+		// locking the transfer serializes its reversals
+		original, err := q.GetTransferForUpdate(ctx, reverseTransferParams.TransferID)
+		if err != nil {
+			return err
+		}
+		reversed, err := q.GetReversedAmount(ctx, original.ID)
+		if err != nil {
+			return err
+		}
+		if reverseTransferParams.Amount.Amount > original.Amount-reversed {
+			return ErrReversalExceedsTransfer
+		}
+
+		var events []AuditEventParams
+		result.TransferTxResult, events, err = transfer(ctx, q, TransferTxParams{
+			FromAccountID: original.ToAccountID,
+			ToAccountID:   original.FromAccountID,
+			Amount:        reverseTransferParams.Amount,
+			Audit:         reverseTransferParams.Audit,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Reversal, err = q.CreateTransferReversal(ctx, CreateTransferReversalParams{
+			OriginalTransferID: original.ID,
+			TransferID:         result.Transfer.ID,
+			Amount:             reverseTransferParams.Amount.Amount,
+			Reason:             reverseTransferParams.Reason,
+			InitiatedBy:        reverseTransferParams.InitiatedBy,
+		})
+		if err != nil {
+			return err
+		}
+		result.ReversedAmount = reversed + reverseTransferParams.Amount.Amount
+
+		if reverseTransferParams.Audit != nil {
+			events = append(events, AuditEventParams{
+				AuditActor: *reverseTransferParams.Audit,
+				Action:     AuditActionTransferReverse,
+				Target:     "transfer:" + strconv.FormatInt(original.ID, 10),
+				After:      result.Reversal,
+			})
+		}
+		return recordAuditEvents(ctx, q, events)
+	}, withAttempts(&attempts))
+	result.Attempts = attempts
+
+	return err, result
+}
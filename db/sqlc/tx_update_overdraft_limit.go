@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"strconv"
+)
+
+type UpdateOverdraftLimitTxParams struct {
+	AccountID int64
+	// OverdraftLimit is how far below zero the balance may go, 0 to remove
+	// the overdraft.
+	OverdraftLimit int64
+	Operator       string
+}
+
+type UpdateOverdraftLimitTxResult struct {
+	Account Account `json:"account"`
+}
+
+// UpdateOverdraftLimitTx changes the overdraft limit of an account on behalf
+// of an operator and records the old and the new limit in the audit log in
+// the same transaction.
+func (store *SQLStore) UpdateOverdraftLimitTx(ctx context.Context, updateOverdraftLimitParams UpdateOverdraftLimitTxParams) (error, UpdateOverdraftLimitTxResult) {
+	var result UpdateOverdraftLimitTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = UpdateOverdraftLimitTxResult{}
+		before, err := q.GetAccountForUpdate(ctx, updateOverdraftLimitParams.AccountID)
+		if err != nil {
+			return err
+		}
+		result.Account, err = q.UpdateAccountOverdraftLimit(ctx, UpdateAccountOverdraftLimitParams{
+			ID:             before.ID,
+			OverdraftLimit: updateOverdraftLimitParams.OverdraftLimit,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = recordAuditEvent(ctx, q, AuditEventParams{
+			AuditActor: AuditActor{Actor: updateOverdraftLimitParams.Operator},
+			Action:     AuditActionAccountOverdraftSet,
+			Target:     "account:" + strconv.FormatInt(before.ID, 10),
+			Before:     before,
+			After:      result.Account,
+		})
+		return err
+	})
+
+	return err, result
+}
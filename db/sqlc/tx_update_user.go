@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+type UpdateUserTxParams struct {
+	UpdateUserParams
+	// AfterUpdate runs inside the transaction when the email address changed,
+	// once the new verification code exists. It runs again when the
+	// transaction is retried.
+	AfterUpdate func(q Querier, result UpdateUserTxResult) error
+}
+
+type UpdateUserTxResult struct {
+	User        User        `json:"user"`
+	VerifyEmail VerifyEmail `json:"verify_email"`
+}
+
+// UpdateUserTx applies a partial update to a user. Setting a new email marks
+// the address as unverified and creates a code to verify it.
+func (store *SQLStore) UpdateUserTx(ctx context.Context, updateUserParams UpdateUserTxParams) (error, UpdateUserTxResult) {
+	var result UpdateUserTxResult
+
+	secretCode, err := util.RandomSecretCode(32)
+	if err != nil {
+		return err, result
+	}
+
+	err = store.execTx(ctx, func(q *Queries) error {
+		result = UpdateUserTxResult{}
+		var err error
+		result.User, err = q.UpdateUser(ctx, updateUserParams.UpdateUserParams)
+		if err != nil {
+			return err
+		}
+
+		if !updateUserParams.Email.Valid || result.User.IsEmailVerified {
+			return nil
+		}
+
+		result.VerifyEmail, err = q.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+			Username:   result.User.Username,
+			Email:      result.User.Email,
+			SecretCode: secretCode,
+		})
+		if err != nil {
+			return err
+		}
+
+		if updateUserParams.AfterUpdate != nil {
+			return updateUserParams.AfterUpdate(q, result)
+		}
+		return nil
+	})
+
+	return err, result
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+type VerifyEmailTxParams struct {
+	EmailID    int64
+	SecretCode string
+}
+
+type VerifyEmailTxResult struct {
+	User        User        `json:"user"`
+	VerifyEmail VerifyEmail `json:"verify_email"`
+}
+
+// VerifyEmailTx consumes a verification code and marks the address it was sent
+// to as verified. It returns pgx.ErrNoRows when the code is unknown, used or
+// expired, or when the user has changed their email since.
+func (store *SQLStore) VerifyEmailTx(ctx context.Context, verifyEmailParams VerifyEmailTxParams) (error, VerifyEmailTxResult) {
+	var result VerifyEmailTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		result = VerifyEmailTxResult{}
+		var err error
+		result.VerifyEmail, err = q.UpdateVerifyEmail(ctx, UpdateVerifyEmailParams{
+			ID:         verifyEmailParams.EmailID,
+			SecretCode: verifyEmailParams.SecretCode,
+		})
+		if err != nil {
+			return err
+		}
+
+		result.User, err = q.VerifyUserEmail(ctx, VerifyUserEmailParams{
+			Username: result.VerifyEmail.Username,
+			Email:    result.VerifyEmail.Email,
+		})
+		return err
+	})
+
+	return err, result
+}
+
+type ResendVerifyEmailTxParams struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	// AfterCreate runs inside the transaction once the new code exists. It
+	// runs again when the transaction is retried.
+	AfterCreate func(q Querier, result ResendVerifyEmailTxResult) error
+}
+
+type ResendVerifyEmailTxResult struct {
+	VerifyEmail VerifyEmail `json:"verify_email"`
+}
+
+// ResendVerifyEmailTx creates a new code to verify the email address of a
+// user whose earlier code was lost or expired. Earlier codes stay valid until
+// they expire.
+func (store *SQLStore) ResendVerifyEmailTx(ctx context.Context, resendParams ResendVerifyEmailTxParams) (error, ResendVerifyEmailTxResult) {
+	var result ResendVerifyEmailTxResult
+
+	secretCode, err := util.RandomSecretCode(32)
+	if err != nil {
+		return err, result
+	}
+
+	err = store.execTx(ctx, func(q *Queries) error {
+		result = ResendVerifyEmailTxResult{}
+		var err error
+		result.VerifyEmail, err = q.CreateVerifyEmail(ctx, CreateVerifyEmailParams{
+			Username:   resendParams.Username,
+			Email:      resendParams.Email,
+			SecretCode: secretCode,
+		})
+		if err != nil {
+			return err
+		}
+
+		if resendParams.AfterCreate != nil {
+			return resendParams.AfterCreate(q, result)
+		}
+		return nil
+	})
+
+	return err, result
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func randomAlias() string {
+	return strings.ToLower(util.RandomString(10))
+}
+
+func TestSetUserAlias(t *testing.T) {
+	user := RandomUser(t)
+	alias := randomAlias()
+
+	userAlias, err := testQuery.SetUserAlias(context.Background(), SetUserAliasParams{Alias: alias, Username: user.Username})
+	require.NoError(t, err)
+	require.Equal(t, alias, userAlias.Alias)
+
+	// a new alias replaces the old one
+	newAlias := randomAlias()
+	_, err = testQuery.SetUserAlias(context.Background(), SetUserAliasParams{Alias: newAlias, Username: user.Username})
+	require.NoError(t, err)
+	_, err = testQuery.GetUserAlias(context.Background(), alias)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	found, err := testQuery.GetUserAlias(context.Background(), newAlias)
+	require.NoError(t, err)
+	require.Equal(t, user.Username, found.Username)
+
+	// an alias belongs to one user
+	other := RandomUser(t)
+	_, err = testQuery.SetUserAlias(context.Background(), SetUserAliasParams{Alias: newAlias, Username: other.Username})
+	var pgErr *pgconn.PgError
+	require.True(t, errors.As(err, &pgErr))
+	require.Equal(t, "user_aliases_pkey", pgErr.ConstraintName)
+
+	require.NoError(t, testQuery.DeleteUserAlias(context.Background(), user.Username))
+	_, err = testQuery.GetUserAlias(context.Background(), newAlias)
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestGetAccountByOwnerCurrency(t *testing.T) {
+	account := RandomAccount(t)
+
+	found, err := testQuery.GetAccountByOwnerCurrency(context.Background(), GetAccountByOwnerCurrencyParams{
+		Owner:    account.Owner,
+		Currency: account.Currency,
+	})
+	require.NoError(t, err)
+	require.Equal(t, account.ID, found.ID)
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jxgzzztang/simplebank/util"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -16,6 +17,7 @@ func RandomUser(t *testing.T) User {
 		FullName: util.RandomOwner(),
 		HashedPassword: hashPassword,
 		Email: util.RandomEmail(),
+		Role: util.DepositorRole,
 	}
 
 	result, err := testQuery.CreateUser(ctx, accountsParams)
@@ -27,6 +29,7 @@ func RandomUser(t *testing.T) User {
 	require.Equal(t, accountsParams.FullName, result.FullName)
 	require.Equal(t, accountsParams.Username, result.Username)
 	require.Equal(t, accountsParams.Email, result.Email)
+	require.Equal(t, accountsParams.Role, result.Role)
 	require.NotZero(t, result.CreatedAt)
 	require.NotEmpty(t, result.HashedPassword)
 
@@ -50,4 +53,59 @@ func TestGetUser(t *testing.T)  {
 	require.Equal(t, user.FullName, resultUser.FullName)
 	require.Equal(t, user.Email, resultUser.Email)
 	require.NotZero(t, resultUser.CreatedAt)
-}
\ No newline at end of file
+}
+
+func TestUpdateUserFullName(t *testing.T) {
+	user := RandomUser(t)
+	newName := util.RandomOwner()
+
+	updated, err := testQuery.UpdateUser(context.Background(), UpdateUserParams{
+		Username: user.Username,
+		FullName: pgtype.Text{String: newName, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, newName, updated.FullName)
+	require.Equal(t, user.Email, updated.Email)
+	require.Equal(t, user.IsEmailVerified, updated.IsEmailVerified)
+}
+
+func TestUpdateUserTxEmail(t *testing.T) {
+	store := NewStore(testDB)
+	created := createRandomUserTx(t, store)
+
+	err, verified := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailID:    created.VerifyEmail.ID,
+		SecretCode: created.VerifyEmail.SecretCode,
+	})
+	require.NoError(t, err)
+	require.True(t, verified.User.IsEmailVerified)
+
+	newEmail := util.RandomEmail()
+	err, result := store.UpdateUserTx(context.Background(), UpdateUserTxParams{
+		UpdateUserParams: UpdateUserParams{
+			Username: created.User.Username,
+			Email:    pgtype.Text{String: newEmail, Valid: true},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, newEmail, result.User.Email)
+	require.False(t, result.User.IsEmailVerified)
+	require.Equal(t, newEmail, result.VerifyEmail.Email)
+	require.NotZero(t, result.VerifyEmail.ID)
+}
+
+func TestChangePasswordTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := RandomUser(t)
+
+	hashPassword, err := util.HashPassword(util.RandomString(8))
+	require.NoError(t, err)
+
+	err, result := store.ChangePasswordTx(context.Background(), ChangePasswordTxParams{
+		Username:       user.Username,
+		HashedPassword: hashPassword,
+	})
+	require.NoError(t, err)
+	require.Equal(t, hashPassword, result.User.HashedPassword)
+	require.True(t, result.User.PasswordChangedAt.Time.After(user.PasswordChangedAt.Time))
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmTOTPTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := RandomUser(t)
+
+	pending, err := testQuery.UpsertUserTOTP(context.Background(), UpsertUserTOTPParams{
+		Username: user.Username,
+		Secret:   util.RandomString(32),
+	})
+	require.NoError(t, err)
+	require.False(t, pending.ConfirmedAt.Valid)
+
+	// an unconfirmed secret can be replaced
+	secret := util.RandomString(32)
+	pending, err = testQuery.UpsertUserTOTP(context.Background(), UpsertUserTOTPParams{
+		Username: user.Username,
+		Secret:   secret,
+	})
+	require.NoError(t, err)
+	require.Equal(t, secret, pending.Secret)
+
+	codeHashes := []string{util.HashToken(util.RandomString(10)), util.HashToken(util.RandomString(10))}
+	err, result := store.ConfirmTOTPTx(context.Background(), ConfirmTOTPTxParams{
+		Username:           user.Username,
+		RecoveryCodeHashes: codeHashes,
+	})
+	require.NoError(t, err)
+	require.True(t, result.UserTOTP.ConfirmedAt.Valid)
+	require.Len(t, result.RecoveryCodes, len(codeHashes))
+
+	// a confirmed secret cannot be replaced or confirmed again
+	_, err = testQuery.UpsertUserTOTP(context.Background(), UpsertUserTOTPParams{
+		Username: user.Username,
+		Secret:   util.RandomString(32),
+	})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+	err, _ = store.ConfirmTOTPTx(context.Background(), ConfirmTOTPTxParams{Username: user.Username})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// steps and recovery codes are single use
+	_, err = testQuery.UseTOTPStep(context.Background(), UseTOTPStepParams{Step: 100, Username: user.Username})
+	require.NoError(t, err)
+	_, err = testQuery.UseTOTPStep(context.Background(), UseTOTPStepParams{Step: 100, Username: user.Username})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	used, err := testQuery.UseRecoveryCode(context.Background(), UseRecoveryCodeParams{
+		Username: user.Username,
+		CodeHash: codeHashes[0],
+	})
+	require.NoError(t, err)
+	require.True(t, used.UsedAt.Valid)
+	_, err = testQuery.UseRecoveryCode(context.Background(), UseRecoveryCodeParams{
+		Username: user.Username,
+		CodeHash: codeHashes[0],
+	})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
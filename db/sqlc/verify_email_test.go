@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomUserTx(t *testing.T, store Store) CreateUserTxResult {
+	hashPassword, err := util.HashPassword(util.RandomString(6))
+	require.NoError(t, err)
+
+	err, result := store.CreateUserTx(context.Background(), CreateUserTxParams{
+		CreateUserParams: CreateUserParams{
+			Username:       util.RandomOwner(),
+			HashedPassword: hashPassword,
+			FullName:       util.RandomOwner(),
+			Email:          util.RandomEmail(),
+			Role:           util.DepositorRole,
+		},
+	})
+	require.NoError(t, err)
+	require.False(t, result.User.IsEmailVerified)
+	require.Equal(t, result.User.Username, result.VerifyEmail.Username)
+	require.Equal(t, result.User.Email, result.VerifyEmail.Email)
+	require.Len(t, result.VerifyEmail.SecretCode, 32)
+	require.False(t, result.VerifyEmail.IsUsed)
+	require.True(t, result.VerifyEmail.ExpiredAt.Time.After(result.VerifyEmail.CreatedAt.Time))
+	return result
+}
+
+func TestCreateUserTx(t *testing.T) {
+	createRandomUserTx(t, NewStore(testDB))
+}
+
+func TestVerifyEmailTx(t *testing.T) {
+	store := NewStore(testDB)
+	created := createRandomUserTx(t, store)
+
+	err, _ := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailID:    created.VerifyEmail.ID,
+		SecretCode: util.RandomString(32),
+	})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+
+	err, result := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailID:    created.VerifyEmail.ID,
+		SecretCode: created.VerifyEmail.SecretCode,
+	})
+	require.NoError(t, err)
+	require.True(t, result.VerifyEmail.IsUsed)
+	require.True(t, result.User.IsEmailVerified)
+
+	// a code can only be used once
+	err, _ = store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailID:    created.VerifyEmail.ID,
+		SecretCode: created.VerifyEmail.SecretCode,
+	})
+	require.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestResendVerifyEmailTx(t *testing.T) {
+	store := NewStore(testDB)
+	created := createRandomUserTx(t, store)
+
+	err, result := store.ResendVerifyEmailTx(context.Background(), ResendVerifyEmailTxParams{
+		Username: created.User.Username,
+		Email:    created.User.Email,
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, created.VerifyEmail.ID, result.VerifyEmail.ID)
+	require.NotEqual(t, created.VerifyEmail.SecretCode, result.VerifyEmail.SecretCode)
+
+	// the new code verifies the email like the first one would have
+	err, verified := store.VerifyEmailTx(context.Background(), VerifyEmailTxParams{
+		EmailID:    result.VerifyEmail.ID,
+		SecretCode: result.VerifyEmail.SecretCode,
+	})
+	require.NoError(t, err)
+	require.True(t, verified.User.IsEmailVerified)
+}
+
+func TestCreateVerifiedUser(t *testing.T) {
+	user, err := testQuery.CreateUser(context.Background(), CreateUserParams{
+		Username:        util.RandomOwner(),
+		HashedPassword:  util.RandomString(16),
+		FullName:        util.RandomOwner(),
+		Email:           util.RandomEmail(),
+		Role:            util.DepositorRole,
+		IsEmailVerified: true,
+	})
+	require.NoError(t, err)
+	require.True(t, user.IsEmailVerified)
+}
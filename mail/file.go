@@ -0,0 +1,44 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileMailer writes every email as an .eml file instead of sending it, which
+// makes verification links easy to follow during local development.
+type FileMailer struct {
+	dir     string
+	name    string
+	address string
+}
+
+func NewFileMailer(dir string, name string, address string) Mailer {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return &FileMailer{
+		dir:     dir,
+		name:    name,
+		address: address,
+	}
+}
+
+func (sender *FileMailer) SendEmail(subject string, content string, to []string) error {
+	if len(to) == 0 {
+		return fmt.Errorf("no recipient for email %q", subject)
+	}
+	if err := os.MkdirAll(sender.dir, 0o755); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	message := buildMessage(sender.name, sender.address, subject, content, to, now)
+
+	recipient := strings.NewReplacer("@", "_at_", "/", "_", string(filepath.Separator), "_").Replace(to[0])
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405.000000000"), recipient)
+	return os.WriteFile(filepath.Join(sender.dir, name), message, 0o644)
+}
@@ -0,0 +1,32 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	mailer := NewFileMailer(dir, "Simple Bank", "no-reply@simplebank.local")
+
+	to := util.RandomEmail()
+	err := mailer.SendEmail("Welcome", "<h1>Hello</h1>", []string{to})
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	require.Contains(t, string(data), "To: "+to)
+	require.Contains(t, string(data), "Subject: Welcome")
+	require.Contains(t, string(data), "<h1>Hello</h1>")
+
+	err = mailer.SendEmail("Welcome", "<h1>Hello</h1>", nil)
+	require.Error(t, err)
+}
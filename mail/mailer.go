@@ -0,0 +1,29 @@
+package mail
+
+import (
+	"fmt"
+
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const (
+	DriverSMTP = "smtp"
+	DriverFile = "file"
+)
+
+// Mailer delivers html emails to a list of recipients.
+type Mailer interface {
+	SendEmail(subject string, content string, to []string) error
+}
+
+// NewMailer returns the Mailer selected by the email driver in the config.
+func NewMailer(config util.Email) (Mailer, error) {
+	switch config.Driver {
+	case DriverSMTP:
+		return NewSMTPMailer(config), nil
+	case DriverFile, "":
+		return NewFileMailer(config.DumpDir, config.SenderName, config.SenderAddress), nil
+	default:
+		return nil, fmt.Errorf("unknown email driver %q", config.Driver)
+	}
+}
@@ -0,0 +1,66 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+type SMTPMailer struct {
+	name     string
+	address  string
+	host     string
+	port     int
+	username string
+	password string
+}
+
+func NewSMTPMailer(config util.Email) Mailer {
+	return &SMTPMailer{
+		name:     config.SenderName,
+		address:  config.SenderAddress,
+		host:     config.SMTPHost,
+		port:     config.SMTPPort,
+		username: config.SMTPUsername,
+		password: config.SMTPPassword,
+	}
+}
+
+func (sender *SMTPMailer) SendEmail(subject string, content string, to []string) error {
+	if len(to) == 0 {
+		return fmt.Errorf("no recipient for email %q", subject)
+	}
+
+	message := buildMessage(sender.name, sender.address, subject, content, to, time.Now())
+
+	var auth smtp.Auth
+	if sender.username != "" {
+		auth = smtp.PlainAuth("", sender.username, sender.password, sender.host)
+	}
+	addr := net.JoinHostPort(sender.host, strconv.Itoa(sender.port))
+	return smtp.SendMail(addr, auth, sender.address, to, message)
+}
+
+// buildMessage renders an RFC 5322 message with an html body.
+func buildMessage(name, address, subject, content string, to []string, date time.Time) []byte {
+	from := mail.Address{Name: name, Address: address}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=\"utf-8\"\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(content)
+	return buf.Bytes()
+}
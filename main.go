@@ -2,27 +2,176 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jxgzzztang/simplebank/api"
+	"github.com/jxgzzztang/simplebank/db/migration"
 	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/mail"
+	"github.com/jxgzzztang/simplebank/metrics"
+	"github.com/jxgzzztang/simplebank/ratelimit"
+	"github.com/jxgzzztang/simplebank/telemetry"
 	"github.com/jxgzzztang/simplebank/util"
+	"github.com/jxgzzztang/simplebank/worker"
 )
 
+const usage = `usage: simplebank [command]
+
+commands:
+  server                  start the http server (default)
+  migrate up              apply all pending migrations
+  migrate down [N]        roll back the last N migrations (default 1)
+  migrate status          print the applied and latest migration version
+  user create             create a user (-username -password -full-name -email [-role] [-verified])
+  account list            list accounts ([-owner] [-limit] [-offset])
+  account freeze          freeze an account (-id)
+  account unfreeze        unfreeze an account (-id)
+  account overdraft       set how far below zero an account may go (-id -limit [-operator])
+  currency list           list the currencies accounts can be opened in
+  currency add            add a currency (-code -exponent [-disabled])
+  currency enable         allow new accounts and transfers in a currency (-code)
+  currency disable        stop new accounts and transfers in a currency (-code)
+  session list            list the sessions of a user (-username)
+  session block           block a session (-id) or every session of a user (-username)
+  reconcile               compare account balances with their ledger entries
+  audit verify            check the hash chain of the audit log
+  adjust                  move money between accounts as an operator
+                          (-from -to -amount -reason [-operator])`
+
 func main() {
 	err := util.LoadConfig(".")
 	if err != nil {
 		return
 	}
+
+	command := "server"
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	switch command {
+	case "server":
+		runServer()
+	case "migrate":
+		runMigrate(os.Args[2:])
+	case "user":
+		runUser(os.Args[2:])
+	case "account":
+		runAccount(os.Args[2:])
+	case "currency":
+		runCurrency(os.Args[2:])
+	case "session":
+		runSession(os.Args[2:])
+	case "reconcile":
+		runReconcile(os.Args[2:])
+	case "audit":
+		runAudit(os.Args[2:])
+	case "adjust":
+		runAdjust(os.Args[2:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+func runServer() {
+	slog.SetDefault(util.NewLogger(os.Stdout, util.Config.Log))
+
+	if util.Config.MigrateOnStart {
+		if err := migration.Up(util.Config.DBSource); err != nil {
+			log.Fatal("cannot migrate database: ", err)
+		}
+	}
+
 	ctx := context.Background()
-	conn, err := pgxpool.New(ctx, util.Config.DBSource)
+	shutdownTracing, err := telemetry.Setup(ctx, util.Config.Tracing)
+	if err != nil {
+		log.Fatal("cannot set up tracing: ", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	poolConfig, err := pgxpool.ParseConfig(util.Config.DBSource)
 	if err != nil {
 		panic(err)
 	}
-	store := db.NewStore(conn)
-	server := api.NewServer(store)
-	err = server.Start(util.Config.Port)
+	poolConfig.ConnConfig.Tracer = telemetry.QueryTracer{}
+	conn, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
-		return
+		panic(err)
 	}
 	defer conn.Close()
-}
\ No newline at end of file
+	metrics.Registry.MustRegister(metrics.NewPoolCollector(conn))
+	store := db.NewStore(conn)
+	mailer, err := mail.NewMailer(util.Config.Email)
+	if err != nil {
+		log.Fatal("cannot create mailer: ", err)
+	}
+
+	taskProcessor := worker.NewPostgresTaskProcessor(store, mailer, util.Config.Worker)
+	taskProcessor.Start(ctx)
+	defer taskProcessor.Shutdown()
+
+	taskDistributor := worker.NewPostgresTaskDistributor()
+	holdSweeper := worker.NewHoldSweeper(store, util.Config.Holds)
+	holdSweeper.Start(ctx)
+	defer holdSweeper.Shutdown()
+
+	paymentRequestSweeper := worker.NewPaymentRequestSweeper(store, taskDistributor, util.Config.PaymentRequests)
+	paymentRequestSweeper.Start(ctx)
+	defer paymentRequestSweeper.Shutdown()
+
+	interestAccruer := worker.NewInterestAccruer(store, util.Config.Interest)
+	interestAccruer.Start(ctx)
+	defer interestAccruer.Shutdown()
+
+	limiter, err := ratelimit.NewLimiter(util.Config.RateLimit, store)
+	if err != nil {
+		log.Fatal("cannot create rate limiter: ", err)
+	}
+
+	server := api.NewServer(store, taskDistributor, limiter)
+	server.AddReadinessCheck("database", conn.Ping)
+	server.AddReadinessCheck("migrations", func(ctx context.Context) error {
+		status, err := migration.CurrentStatus(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if !status.UpToDate() {
+			return fmt.Errorf("schema version %d (dirty: %t), latest is %d", status.Version, status.Dirty, status.Latest)
+		}
+		return nil
+	})
+	workers := []struct {
+		name   string
+		worker interface{ Running() bool }
+	}{
+		{"task processor", taskProcessor},
+		{"hold sweeper", holdSweeper},
+		{"payment request sweeper", paymentRequestSweeper},
+		{"interest accruer", interestAccruer},
+	}
+	server.AddReadinessCheck("workers", func(ctx context.Context) error {
+		var err error
+		for _, w := range workers {
+			if !w.worker.Running() {
+				err = errors.Join(err, fmt.Errorf("%s is not running", w.name))
+			}
+		}
+		return err
+	})
+
+	// the workers keep running until the server has drained
+	signalCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = server.Start(signalCtx, util.Config.Port)
+	if err != nil {
+		slog.Error("server stopped", "error", err)
+	}
+}
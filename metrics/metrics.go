@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "simplebank"
+
+// Outcomes of a transfer transaction.
+const (
+	TransferSuccess           = "success"
+	TransferInsufficientFunds = "insufficient_funds"
+	TransferDeadlock          = "deadlock"
+	TransferError             = "error"
+)
+
+// Registry holds every metric of the service. It is separate from the global
+// prometheus registry so tests and libraries cannot add to it by accident.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	httpRequests = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time to serve HTTP requests by route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	transferTx = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "transfer_tx",
+		Name:      "total",
+		Help:      "Transfer transactions by outcome.",
+	}, []string{"outcome"})
+
+	transferTxDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "transfer_tx",
+		Name:      "duration_seconds",
+		Help:      "Time to run transfer transactions by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	txAttempts = factory.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db_tx",
+		Name:      "attempts",
+		Help:      "Times a database transaction ran before it committed or gave up.",
+		Buckets:   prometheus.LinearBuckets(1, 1, 5),
+	})
+
+	txRetries = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "db_tx",
+		Name:      "retries_total",
+		Help:      "Database transactions run again by the postgres error code that failed them.",
+	}, []string{"code"})
+
+	loginAttempts = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "login",
+		Name:      "attempts_total",
+		Help:      "Login attempts by outcome.",
+	}, []string{"outcome"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics in the prometheus text format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveHTTPRequest counts a served request. route is the route pattern,
+// never the raw path, to keep the number of series bounded.
+func ObserveHTTPRequest(method string, route string, status string, duration time.Duration) {
+	httpRequests.WithLabelValues(method, route, status).Inc()
+	httpRequestDuration.WithLabelValues(method, route, status).Observe(duration.Seconds())
+}
+
+// ObserveTransferTx counts a finished transfer transaction.
+func ObserveTransferTx(outcome string, duration time.Duration) {
+	transferTx.WithLabelValues(outcome).Inc()
+	transferTxDuration.WithLabelValues(outcome).Observe(duration.Seconds())
+}
+
+// ObserveTxAttempts records how often a database transaction ran.
+func ObserveTxAttempts(attempts int) {
+	txAttempts.Observe(float64(attempts))
+}
+
+// ObserveTxRetry counts a database transaction that is run again after it
+// failed with the given postgres error code.
+func ObserveTxRetry(code string) {
+	txRetries.WithLabelValues(code).Inc()
+}
+
+// ObserveLoginAttempt counts a login attempt, as recorded in login_attempts.
+func ObserveLoginAttempt(outcome string) {
+	loginAttempts.WithLabelValues(outcome).Inc()
+}
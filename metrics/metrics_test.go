@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserve(t *testing.T) {
+	ObserveHTTPRequest(http.MethodGet, "/account/:id", "200", time.Millisecond)
+	require.Equal(t, 1.0, testutil.ToFloat64(httpRequests.WithLabelValues(http.MethodGet, "/account/:id", "200")))
+
+	before := testutil.ToFloat64(transferTx.WithLabelValues(TransferInsufficientFunds))
+	ObserveTransferTx(TransferInsufficientFunds, time.Millisecond)
+	require.Equal(t, before+1, testutil.ToFloat64(transferTx.WithLabelValues(TransferInsufficientFunds)))
+
+	ObserveLoginAttempt("success")
+	require.Equal(t, 1.0, testutil.ToFloat64(loginAttempts.WithLabelValues("success")))
+
+	ObserveTxRetry("40P01")
+	require.Equal(t, 1.0, testutil.ToFloat64(txRetries.WithLabelValues("40P01")))
+	ObserveTxAttempts(2)
+	require.Equal(t, 1, testutil.CollectAndCount(txAttempts))
+}
+
+func TestHandler(t *testing.T) {
+	ObserveLoginAttempt("invalid_credentials")
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `simplebank_login_attempts_total{outcome="invalid_credentials"}`)
+	require.Contains(t, recorder.Body.String(), "go_goroutines")
+}
+
+func TestPoolCollector(t *testing.T) {
+	// the pool only connects on first use
+	pool, err := pgxpool.New(context.Background(), "postgres://user@localhost:1/db?pool_max_conns=7")
+	require.NoError(t, err)
+	defer pool.Close()
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(NewPoolCollector(pool))
+
+	count, err := testutil.GatherAndCount(registry)
+	require.NoError(t, err)
+	require.Equal(t, 9, count)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() == "simplebank_pgxpool_max_conns" {
+			require.Equal(t, 7.0, family.GetMetric()[0].GetGauge().GetValue())
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/jxgzzztang/simplebank/db/migration"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "up":
+		if err := migration.Up(util.Config.DBSource); err != nil {
+			log.Fatal("migrate up: ", err)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil {
+				log.Fatal("migrate down: invalid number of steps: ", args[1])
+			}
+			steps = n
+		}
+		if err := migration.Down(util.Config.DBSource, steps); err != nil {
+			log.Fatal("migrate down: ", err)
+		}
+	case "status":
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	status, err := migration.GetStatus(util.Config.DBSource)
+	if err != nil {
+		log.Fatal("migrate status: ", err)
+	}
+	fmt.Printf("version: %d\nlatest: %d\ndirty: %t\nup to date: %t\n",
+		status.Version, status.Latest, status.Dirty, status.UpToDate())
+}
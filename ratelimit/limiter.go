@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+
+	// cleanupInterval is how often idle buckets are dropped.
+	cleanupInterval = time.Minute
+)
+
+// Result describes the state of a bucket after a request was counted.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next request is allowed, zero when
+	// this one was.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Limiter takes one token from the bucket of a key, refilling it according
+// to the policy first.
+type Limiter interface {
+	Allow(ctx context.Context, key string, policy util.RateLimitPolicy) (Result, error)
+}
+
+// NewLimiter returns the Limiter selected by the store in the config. The
+// postgres store shares buckets between replicas.
+func NewLimiter(config util.RateLimit, store db.Querier) (Limiter, error) {
+	switch config.Store {
+	case StoreMemory, "":
+		return NewMemoryLimiter(), nil
+	case StorePostgres:
+		return NewPostgresLimiter(store), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", config.Store)
+	}
+}
+
+// rate returns the refill rate of a policy in tokens per second.
+func rate(policy util.RateLimitPolicy) float64 {
+	return float64(policy.Limit) / policy.Period.Seconds()
+}
+
+// burst returns the capacity of a bucket, which defaults to the limit.
+func burst(policy util.RateLimitPolicy) float64 {
+	if policy.Burst > 0 {
+		return float64(policy.Burst)
+	}
+	return float64(policy.Limit)
+}
+
+// refill adds the tokens earned over elapsed, up to the capacity.
+func refill(tokens float64, elapsed time.Duration, policy util.RateLimitPolicy) float64 {
+	return math.Min(burst(policy), tokens+elapsed.Seconds()*rate(policy))
+}
+
+// newResult builds the result for a bucket holding tokens after the request.
+func newResult(allowed bool, tokens float64, policy util.RateLimitPolicy) Result {
+	r := rate(policy)
+	result := Result{
+		Allowed:   allowed,
+		Limit:     int(burst(policy)),
+		Remaining: max(int(math.Floor(tokens)), 0),
+		Reset:     secondsToDuration((burst(policy) - tokens) / r),
+	}
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / r)
+	}
+	return result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds * float64(time.Second)))
+}
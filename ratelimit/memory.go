@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	// fullAt is when the bucket will have refilled completely, after which
+	// it is no different from a missing one.
+	fullAt time.Time
+}
+
+// MemoryLimiter keeps buckets in the memory of a single process.
+type MemoryLimiter struct {
+	mu          sync.Mutex
+	buckets     map[string]*bucket
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (limiter *MemoryLimiter) Allow(ctx context.Context, key string, policy util.RateLimitPolicy) (Result, error) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	now := limiter.now()
+	limiter.cleanup(now)
+
+	b, ok := limiter.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst(policy), updatedAt: now}
+		limiter.buckets[key] = b
+	}
+
+	b.tokens = refill(b.tokens, now.Sub(b.updatedAt), policy)
+	b.updatedAt = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	result := newResult(allowed, b.tokens, policy)
+	b.fullAt = now.Add(result.Reset)
+	return result, nil
+}
+
+func (limiter *MemoryLimiter) cleanup(now time.Time) {
+	if now.Sub(limiter.lastCleanup) < cleanupInterval {
+		return
+	}
+	limiter.lastCleanup = now
+
+	for key, b := range limiter.buckets {
+		if now.After(b.fullAt) {
+			delete(limiter.buckets, key)
+		}
+	}
+}
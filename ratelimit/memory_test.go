@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLimiter(t *testing.T) {
+	now := time.Now()
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+
+	policy := util.RateLimitPolicy{Limit: 3, Period: 3 * time.Second}
+	key := util.RandomString(8)
+
+	for i := 2; i >= 0; i-- {
+		result, err := limiter.Allow(context.Background(), key, policy)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+		require.Equal(t, 3, result.Limit)
+		require.Equal(t, i, result.Remaining)
+		require.Zero(t, result.RetryAfter)
+	}
+
+	result, err := limiter.Allow(context.Background(), key, policy)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+	require.Zero(t, result.Remaining)
+	require.Equal(t, time.Second, result.RetryAfter)
+	require.Equal(t, 3*time.Second, result.Reset)
+
+	// other keys have their own bucket
+	result, err = limiter.Allow(context.Background(), util.RandomString(8), policy)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+
+	now = now.Add(time.Second)
+	result, err = limiter.Allow(context.Background(), key, policy)
+	require.NoError(t, err)
+	require.True(t, result.Allowed)
+	require.Zero(t, result.Remaining)
+}
+
+func TestMemoryLimiterBurst(t *testing.T) {
+	now := time.Now()
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+
+	policy := util.RateLimitPolicy{Limit: 60, Period: time.Minute, Burst: 2}
+	key := util.RandomString(8)
+
+	for i := 0; i < 2; i++ {
+		result, err := limiter.Allow(context.Background(), key, policy)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+	}
+	result, err := limiter.Allow(context.Background(), key, policy)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+
+	// a long pause never refills more than the burst
+	now = now.Add(time.Hour)
+	for i := 0; i < 2; i++ {
+		result, err = limiter.Allow(context.Background(), key, policy)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+	}
+	result, err = limiter.Allow(context.Background(), key, policy)
+	require.NoError(t, err)
+	require.False(t, result.Allowed)
+}
+
+func TestMemoryLimiterCleanup(t *testing.T) {
+	now := time.Now()
+	limiter := NewMemoryLimiter()
+	limiter.now = func() time.Time { return now }
+
+	policy := util.RateLimitPolicy{Limit: 1, Period: time.Second}
+	_, err := limiter.Allow(context.Background(), util.RandomString(8), policy)
+	require.NoError(t, err)
+	require.Len(t, limiter.buckets, 1)
+
+	now = now.Add(cleanupInterval)
+	key := util.RandomString(8)
+	_, err = limiter.Allow(context.Background(), key, policy)
+	require.NoError(t, err)
+	require.Len(t, limiter.buckets, 1)
+	require.Contains(t, limiter.buckets, key)
+}
+
+func TestNewLimiter(t *testing.T) {
+	limiter, err := NewLimiter(util.RateLimit{}, nil)
+	require.NoError(t, err)
+	require.IsType(t, &MemoryLimiter{}, limiter)
+
+	limiter, err = NewLimiter(util.RateLimit{Store: StorePostgres}, nil)
+	require.NoError(t, err)
+	require.IsType(t, &PostgresLimiter{}, limiter)
+
+	_, err = NewLimiter(util.RateLimit{Store: "redis"}, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+// PostgresLimiter keeps buckets in the rate_limit_buckets table so every
+// replica of the server counts against the same limits. Each request is a
+// single upsert, which serializes concurrent requests for a key on its row.
+type PostgresLimiter struct {
+	store       db.Querier
+	lastCleanup atomic.Int64
+}
+
+func NewPostgresLimiter(store db.Querier) *PostgresLimiter {
+	return &PostgresLimiter{store: store}
+}
+
+func (limiter *PostgresLimiter) Allow(ctx context.Context, key string, policy util.RateLimitPolicy) (Result, error) {
+	if err := limiter.cleanup(ctx); err != nil {
+		return Result{}, err
+	}
+
+	bucket, err := limiter.store.TakeRateLimitToken(ctx, db.TakeRateLimitTokenParams{
+		Key:   key,
+		Burst: burst(policy),
+		Rate:  rate(policy),
+	})
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(bucket.Allowed, bucket.Tokens, policy), nil
+}
+
+// cleanup drops buckets that have refilled completely, at most once per
+// cleanupInterval on each replica.
+func (limiter *PostgresLimiter) cleanup(ctx context.Context) error {
+	now := time.Now()
+	last := limiter.lastCleanup.Load()
+	if now.Sub(time.Unix(0, last)) < cleanupInterval || !limiter.lastCleanup.CompareAndSwap(last, now.UnixNano()) {
+		return nil
+	}
+	return limiter.store.DeleteFullRateLimitBuckets(ctx)
+}
@@ -0,0 +1,28 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryName(t *testing.T) {
+	require.Equal(t, "CreateTransfer", queryName("-- name: CreateTransfer :one\nINSERT INTO transfers"))
+	require.Equal(t, "BEGIN", queryName("begin isolation level read committed"))
+	require.Equal(t, "query", queryName("  "))
+}
+
+func TestSetup(t *testing.T) {
+	shutdown, err := Setup(context.Background(), util.Tracing{})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	shutdown, err = Setup(context.Background(), util.Tracing{Exporter: ExporterStdout})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	_, err = Setup(context.Background(), util.Tracing{Exporter: "jaeger"})
+	require.Error(t, err)
+}
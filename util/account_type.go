@@ -0,0 +1,7 @@
+package util
+
+const (
+	CheckingAccount = "checking"
+	// SavingsAccount accrues interest and is not paid into by username.
+	SavingsAccount = "savings"
+)
@@ -1,17 +1,76 @@
 package util
 
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
 
+// Currencies every database starts with, see the currencies table for the
+// ones that are enabled.
 const (
 	USD = "USD"
 	EUR = "EUR"
 	CNY = "CNY"
 )
 
-func IsValidCurrency(currency string) bool {
-	switch currency {
-	case USD, EUR, CNY:
-		return true
-	default:
-		return false
+var ErrInvalidAmount = errors.New("invalid amount")
+
+// FormatAmount renders an amount of minor units as a decimal string with the
+// given number of digits after the separator, like 1234 as "12.34".
+func FormatAmount(amount int64, exponent int) string {
+	digits := strconv.FormatUint(absAmount(amount), 10)
+	if exponent > 0 {
+		if len(digits) <= exponent {
+			digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+	}
+	if amount < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// ParseAmount converts a decimal string to minor units. It accepts at most
+// exponent digits after the separator, so no amount is ever rounded.
+func ParseAmount(s string, exponent int) (int64, error) {
+	digits, negative := strings.CutPrefix(s, "-")
+	whole, fraction, hasFraction := strings.Cut(digits, ".")
+	if whole == "" || !isDigits(whole) || !isDigits(fraction) || (hasFraction && fraction == "") {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidAmount, s)
+	}
+	if len(fraction) > exponent {
+		return 0, fmt.Errorf("%w: %q has more than %d decimal places", ErrInvalidAmount, s, exponent)
+	}
+
+	amount, err := strconv.ParseInt(whole+fraction+strings.Repeat("0", exponent-len(fraction)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q is out of range", ErrInvalidAmount, s)
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
+
+func absAmount(amount int64) uint64 {
+	if amount == math.MinInt64 {
+		return uint64(math.MaxInt64) + 1
+	}
+	if amount < 0 {
+		return uint64(-amount)
+	}
+	return uint64(amount)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
 	}
+	return true
 }
@@ -0,0 +1,42 @@
+package util
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatAmount(t *testing.T) {
+	require.Equal(t, "12.34", FormatAmount(1234, 2))
+	require.Equal(t, "0.05", FormatAmount(5, 2))
+	require.Equal(t, "-0.50", FormatAmount(-50, 2))
+	require.Equal(t, "1234", FormatAmount(1234, 0))
+	require.Equal(t, "1.234", FormatAmount(1234, 3))
+	require.Equal(t, "-92233720368547758.08", FormatAmount(math.MinInt64, 2))
+}
+
+func TestParseAmount(t *testing.T) {
+	for s, want := range map[string]int64{
+		"12.34": 1234,
+		"12.3":  1230,
+		"12":    1200,
+		"0.05":  5,
+		"-0.50": -50,
+	} {
+		amount, err := ParseAmount(s, 2)
+		require.NoError(t, err, s)
+		require.Equal(t, want, amount, s)
+	}
+
+	amount, err := ParseAmount("1234", 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(1234), amount)
+
+	for _, s := range []string{"", "-", ".5", "1.", "1.234", "1,00", "+1", "1e3", " 1", "92233720368547758.08"} {
+		_, err := ParseAmount(s, 2)
+		require.ErrorIs(t, err, ErrInvalidAmount, s)
+	}
+	_, err = ParseAmount("1.5", 0)
+	require.ErrorIs(t, err, ErrInvalidAmount)
+}
@@ -9,13 +9,51 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// TokenPurposeMFA marks the short-lived token handed out after the password
+// step of a login that still needs a second factor. It is not an access token.
+const TokenPurposeMFA = "mfa"
+
+// Authentication methods recorded in the amr claim.
+const (
+	AMRPassword = "pwd"
+	AMROTP      = "otp"
+)
+
 type TokenPayload struct {
 	Username string      `json:"username"`
 	ID       pgtype.UUID `json:"id"`
+	Purpose  string      `json:"purpose,omitempty"`
+	// AuthTime and AMR tell when and how the user last proved their
+	// identity, as the OpenID Connect claims of the same name.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	AMR      []string         `json:"amr,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func CreatePayload(username string, duration time.Duration) (TokenPayload, error) {
+// AuthenticatedWithin reports whether the user proved their identity less
+// than maxAge ago.
+func (payload *TokenPayload) AuthenticatedWithin(maxAge time.Duration) bool {
+	return payload.AuthTime != nil && time.Since(payload.AuthTime.Time) <= maxAge
+}
+
+type TokenOption func(payload *TokenPayload)
+
+// WithPurpose restricts a token to a single use such as TokenPurposeMFA.
+func WithPurpose(purpose string) TokenOption {
+	return func(payload *TokenPayload) {
+		payload.Purpose = purpose
+	}
+}
+
+// WithAuthentication sets the auth_time and amr claims.
+func WithAuthentication(authTime time.Time, amr ...string) TokenOption {
+	return func(payload *TokenPayload) {
+		payload.AuthTime = jwt.NewNumericDate(authTime)
+		payload.AMR = amr
+	}
+}
+
+func CreatePayload(username string, duration time.Duration, opts ...TokenOption) (TokenPayload, error) {
 	// 生成新的UUID
 	uuidObj := uuid.New()
 
@@ -36,11 +74,14 @@ func CreatePayload(username string, duration time.Duration) (TokenPayload, error
 			Issuer:    Config.Jwt.Issuer,
 		},
 	}
+	for _, opt := range opts {
+		opt(&payload)
+	}
 	return payload, nil
 }
 
-func CreateToken(username string, duration time.Duration) (string, TokenPayload, error) {
-	payload, err := CreatePayload(username, duration)
+func CreateToken(username string, duration time.Duration, opts ...TokenOption) (string, TokenPayload, error) {
+	payload, err := CreatePayload(username, duration, opts...)
 	if err != nil {
 		return "", TokenPayload{}, err
 	}
@@ -16,3 +16,33 @@ func TestJWT(t *testing.T) {
 	require.True(t, ok)
 	require.Equal(t, username, claims.Username)
 }
+
+func TestJWTPurpose(t *testing.T) {
+	token, payload, err := CreateToken("Test", time.Minute, WithPurpose(TokenPurposeMFA))
+	require.NoError(t, err)
+	require.Equal(t, TokenPurposeMFA, payload.Purpose)
+
+	claims, ok := ParseToken(token)
+	require.True(t, ok)
+	require.Equal(t, TokenPurposeMFA, claims.Purpose)
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	authTime := time.Now().Add(-time.Hour)
+	token, _, err := CreateToken("Test", time.Minute, WithAuthentication(authTime, AMRPassword, AMROTP))
+	require.NoError(t, err)
+
+	claims, ok := ParseToken(token)
+	require.True(t, ok)
+	require.Equal(t, []string{AMRPassword, AMROTP}, claims.AMR)
+	require.WithinDuration(t, authTime, claims.AuthTime.Time, time.Second)
+	require.False(t, claims.AuthenticatedWithin(time.Minute))
+	require.True(t, claims.AuthenticatedWithin(2*time.Hour))
+
+	token, _, err = CreateToken("Test", time.Minute)
+	require.NoError(t, err)
+	claims, ok = ParseToken(token)
+	require.True(t, ok)
+	require.Nil(t, claims.AuthTime)
+	require.False(t, claims.AuthenticatedWithin(time.Hour))
+}
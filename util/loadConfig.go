@@ -14,10 +14,171 @@ type JWT struct {
 	Issuer string `mapstructure:"ISSUER"`
 }
 
+type Email struct {
+	Driver        string `mapstructure:"DRIVER"`
+	SenderName    string `mapstructure:"SENDER_NAME"`
+	SenderAddress string `mapstructure:"SENDER_ADDRESS"`
+	SMTPHost      string `mapstructure:"SMTP_HOST"`
+	SMTPPort      int    `mapstructure:"SMTP_PORT"`
+	SMTPUsername  string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword  string `mapstructure:"SMTP_PASSWORD"`
+	DumpDir       string `mapstructure:"DUMP_DIR"`
+}
+
+// Login configures brute-force protection of the login endpoint. Zero values
+// disable the corresponding check.
+type Login struct {
+	// MaxFailures locks a username after this many failed attempts in a row.
+	MaxFailures int `mapstructure:"MAX_FAILURES"`
+	// MaxIPFailures locks a client ip after this many failed attempts.
+	MaxIPFailures int `mapstructure:"MAX_IP_FAILURES"`
+	// FailureWindow is how far back failed attempts are counted.
+	FailureWindow time.Duration `mapstructure:"FAILURE_WINDOW"`
+	// LockoutDuration is how long a lock lasts after the last failure.
+	LockoutDuration time.Duration `mapstructure:"LOCKOUT_DURATION"`
+	// DelayStep is the wait after the first failure, doubled for each further one.
+	DelayStep time.Duration `mapstructure:"DELAY_STEP"`
+}
+
+type MFA struct {
+	Issuer            string        `mapstructure:"ISSUER"`
+	ChallengeDuration time.Duration `mapstructure:"CHALLENGE_DURATION"`
+}
+
+// StepUp configures when a transfer needs a recent authentication.
+type StepUp struct {
+	// Threshold is a decimal amount in whole units of any currency, like
+	// 1000.00, so it means the same in currencies with different exponents.
+	// Empty or zero disables the check.
+	Threshold string        `mapstructure:"THRESHOLD"`
+	MaxAge    time.Duration `mapstructure:"MAX_AGE"`
+}
+
+// RateLimitPolicy allows Limit requests per Period with bursts of up to Burst
+// requests, which defaults to Limit. A zero Limit means no limit.
+type RateLimitPolicy struct {
+	// Route is the method and path pattern of the route, like "POST /login".
+	Route  string        `mapstructure:"ROUTE"`
+	Limit  int           `mapstructure:"LIMIT"`
+	Period time.Duration `mapstructure:"PERIOD"`
+	Burst  int           `mapstructure:"BURST"`
+}
+
+type RateLimit struct {
+	// Store is memory or postgres, the latter shares limits between replicas.
+	Store   string            `mapstructure:"STORE"`
+	Default RateLimitPolicy   `mapstructure:"DEFAULT"`
+	Routes  []RateLimitPolicy `mapstructure:"ROUTES"`
+}
+
+type Log struct {
+	// Level is debug, info, warn or error.
+	Level  string `mapstructure:"LEVEL"`
+	// Format is json or text.
+	Format string `mapstructure:"FORMAT"`
+}
+
+type Tracing struct {
+	// Exporter is otlp, stdout or none, the default.
+	Exporter string `mapstructure:"EXPORTER"`
+	// Endpoint is the host:port of the OTLP/HTTP collector.
+	Endpoint    string  `mapstructure:"ENDPOINT"`
+	Insecure    bool    `mapstructure:"INSECURE"`
+	ServiceName string  `mapstructure:"SERVICE_NAME"`
+	// SampleRatio is the share of new traces recorded, all of them when zero.
+	SampleRatio float64 `mapstructure:"SAMPLE_RATIO"`
+}
+
+type Transaction struct {
+	// MaxAttempts bounds how often a transaction runs when it keeps failing
+	// with a deadlock or a serialization failure, 3 when zero.
+	MaxAttempts   int           `mapstructure:"MAX_ATTEMPTS"`
+	MinRetryDelay time.Duration `mapstructure:"MIN_RETRY_DELAY"`
+	MaxRetryDelay time.Duration `mapstructure:"MAX_RETRY_DELAY"`
+}
+
+type Shutdown struct {
+	// DrainDelay is how long the server keeps serving with readiness failing
+	// before it stops accepting connections.
+	DrainDelay time.Duration `mapstructure:"DRAIN_DELAY"`
+	// Timeout bounds the wait for in-flight requests, none when zero.
+	Timeout time.Duration `mapstructure:"TIMEOUT"`
+}
+
+type Holds struct {
+	// MaxDuration bounds how long a hold can reserve funds, 7 days when zero.
+	MaxDuration time.Duration `mapstructure:"MAX_DURATION"`
+	// SweepInterval is how often expired holds are closed, 1 minute when zero.
+	SweepInterval time.Duration `mapstructure:"SWEEP_INTERVAL"`
+}
+
+type PaymentRequests struct {
+	// Duration is how long a payment request can be accepted, 7 days when zero.
+	Duration time.Duration `mapstructure:"DURATION"`
+	// SweepInterval is how often expired payment requests are closed,
+	// 1 minute when zero.
+	SweepInterval time.Duration `mapstructure:"SWEEP_INTERVAL"`
+}
+
+type InterestRate struct {
+	Currency string `mapstructure:"CURRENCY"`
+	// AnnualRateBps is the annual rate in basis points, 150 for 1.5%.
+	AnnualRateBps int32 `mapstructure:"ANNUAL_RATE_BPS"`
+	// ExpenseAccountID is the interest-expense system account that pays the
+	// interest in the currency. Interest accrues but is not posted without it.
+	ExpenseAccountID int64 `mapstructure:"EXPENSE_ACCOUNT_ID"`
+	// OverdraftRateBps is the annual rate overdrawn accounts are charged in
+	// basis points. Overdrafts are free when it is zero.
+	OverdraftRateBps int32 `mapstructure:"OVERDRAFT_RATE_BPS"`
+	// IncomeAccountID is the interest-income system account that receives
+	// overdraft interest in the currency. Overdraft interest accrues but is
+	// not charged without it.
+	IncomeAccountID int64 `mapstructure:"INCOME_ACCOUNT_ID"`
+}
+
+type Interest struct {
+	// Interval is how often missing accruals and postings are made, 1 hour
+	// when zero. Accounts accrue once a day and are paid or charged once a
+	// month however often it runs.
+	Interval time.Duration `mapstructure:"INTERVAL"`
+	// CatchUpDays is how many past days are checked for missing accruals on
+	// every run, 7 when zero. Days before them are still accrued when they come
+	// after the last accrual.
+	CatchUpDays int `mapstructure:"CATCH_UP_DAYS"`
+	// Rates lists the currencies savings accounts earn and overdrawn accounts
+	// pay interest in.
+	Rates []InterestRate `mapstructure:"RATES"`
+}
+
+type Worker struct {
+	Concurrency  int           `mapstructure:"CONCURRENCY"`
+	PollInterval time.Duration `mapstructure:"POLL_INTERVAL"`
+	Lease        time.Duration `mapstructure:"LEASE"`
+}
+
 type ViperConfig struct {
 	DBSource string `mapstructure:"dbSource"`
 	Port     string `mapstructure:"port"`
 	Jwt      JWT `mapstructure:"jwt"`
+	MigrateOnStart bool `mapstructure:"migrateOnStart"`
+	BaseURL  string `mapstructure:"baseURL"`
+	// PasswordResetURL is the page reset emails link to with the token in
+	// its query, {baseURL}/password/reset when empty.
+	PasswordResetURL string `mapstructure:"passwordResetURL"`
+	Email    Email `mapstructure:"email"`
+	Worker   Worker `mapstructure:"worker"`
+	PasswordResetDuration time.Duration `mapstructure:"passwordResetDuration"`
+	Login    Login `mapstructure:"login"`
+	MFA      MFA `mapstructure:"mfa"`
+	StepUp   StepUp `mapstructure:"stepUp"`
+	RateLimit RateLimit `mapstructure:"rateLimit"`
+	Log      Log `mapstructure:"log"`
+	Tracing  Tracing `mapstructure:"tracing"`
+	Shutdown Shutdown `mapstructure:"shutdown"`
+	Transaction Transaction `mapstructure:"transaction"`
+	Holds    Holds `mapstructure:"holds"`
+	PaymentRequests PaymentRequests `mapstructure:"paymentRequests"`
+	Interest Interest `mapstructure:"interest"`
 }
 
 var Config ViperConfig
@@ -0,0 +1,76 @@
+package util
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values never reach the logs.
+var sensitiveKeys = map[string]bool{
+	"authorization":    true,
+	"password":         true,
+	"current_password": true,
+	"new_password":     true,
+	"hashed_password":  true,
+	"token":            true,
+	"access_token":     true,
+	"refresh_token":    true,
+	"mfa_token":        true,
+	"secret":           true,
+	"secret_code":      true,
+	"code":             true,
+	"recovery_codes":   true,
+	"otpauth_uri":      true,
+	"token_hash":       true,
+	"code_hash":        true,
+}
+
+type loggerKey struct{}
+
+// NewLogger returns a logger writing to w in the format and from the level of
+// the config, json and info by default. Sensitive attributes are redacted.
+func NewLogger(w io.Writer, config Log) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(config.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}
+	if config.Format == "text" {
+		return slog.New(slog.NewTextHandler(w, opts))
+	}
+	return slog.New(slog.NewJSONHandler(w, opts))
+}
+
+// redact hides the value of sensitive attributes and of anything that looks
+// like a bearer token, whatever its key.
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, redacted)
+	}
+	if attr.Value.Kind() == slog.KindString && strings.HasPrefix(strings.ToLower(attr.Value.String()), "bearer ") {
+		return slog.String(attr.Key, redacted)
+	}
+	return attr
+}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// Logger returns the logger carried by ctx, which knows the request it is
+// logging for, or the default logger.
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,52 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLoggerRedacts(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, Log{})
+
+	logger.Info("login",
+		slog.String("username", "alice"),
+		slog.String("password", "secret"),
+		slog.Group("request", slog.String("Authorization", "Bearer abc")),
+		slog.String("header", "Bearer abc"),
+		slog.String("otpauth_uri", "otpauth://totp/simplebank:alice?secret=abc"),
+	)
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.Equal(t, "alice", entry["username"])
+	require.Equal(t, redacted, entry["password"])
+	require.Equal(t, redacted, entry["request"].(map[string]any)["Authorization"])
+	require.Equal(t, redacted, entry["header"])
+	require.Equal(t, redacted, entry["otpauth_uri"])
+	require.NotContains(t, buf.String(), "abc")
+}
+
+func TestNewLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(&buf, Log{Level: "warn", Format: "text"})
+
+	logger.Info("hidden")
+	require.Zero(t, buf.Len())
+
+	logger.Warn("shown")
+	require.Contains(t, buf.String(), "msg=shown")
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	require.Equal(t, slog.Default(), Logger(context.Background()))
+
+	logger := NewLogger(&bytes.Buffer{}, Log{})
+	ctx := WithLogger(context.Background(), logger)
+	require.Equal(t, logger, Logger(ctx))
+}
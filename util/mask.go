@@ -0,0 +1,18 @@
+package util
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaskName keeps the first letter of every word of a name and hides the rest,
+// so "John Doe" becomes "J*** D**". It lets a sender recognise a recipient
+// without learning their full name.
+func MaskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		first, size := utf8.DecodeRuneInString(word)
+		words[i] = string(first) + strings.Repeat("*", utf8.RuneCountInString(word[size:]))
+	}
+	return strings.Join(words, " ")
+}
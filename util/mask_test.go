@@ -0,0 +1,15 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaskName(t *testing.T) {
+	require.Equal(t, "J*** D**", MaskName("John Doe"))
+	require.Equal(t, "J*** D**", MaskName("  John   Doe "))
+	require.Equal(t, "A", MaskName("A"))
+	require.Equal(t, "张**", MaskName("张三丰"))
+	require.Equal(t, "", MaskName(""))
+}
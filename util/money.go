@@ -0,0 +1,130 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+)
+
+var (
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+	ErrAmountOverflow   = errors.New("amount overflow")
+	ErrUnknownCurrency  = errors.New("unknown currency")
+)
+
+// currencyLookup resolves the currency codes of amounts read from JSON.
+var currencyLookup atomic.Pointer[func(code string) (Currency, error)]
+
+// SetCurrencyLookup makes lookup, normally the currency registry of the
+// server, the source of the exponents of the amounts UnmarshalJSON reads.
+func SetCurrencyLookup(lookup func(code string) (Currency, error)) {
+	currencyLookup.Store(&lookup)
+}
+
+// Currency is what amounts of money need to know about their currency.
+type Currency struct {
+	Code string
+	// Exponent is the number of digits after the decimal separator.
+	Exponent int
+}
+
+// Money is an amount in minor units of a currency, like 1234 for 12.34 USD.
+// Arithmetic on it fails instead of overflowing or mixing currencies.
+type Money struct {
+	Amount   int64
+	Currency Currency
+}
+
+func NewMoney(amount int64, currency Currency) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// ParseMoney reads a decimal string, like "12.34", as an amount of currency.
+func ParseMoney(s string, currency Currency) (Money, error) {
+	amount, err := ParseAmount(s, currency.Exponent)
+	if err != nil {
+		return Money{}, err
+	}
+	return NewMoney(amount, currency), nil
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m.Currency.Code, other.Currency.Code)
+	}
+	sum := m.Amount + other.Amount
+	// the sum of two numbers of the same sign has that sign, unless it wrapped
+	if (m.Amount >= 0) == (other.Amount >= 0) && (sum >= 0) != (m.Amount >= 0) {
+		return Money{}, fmt.Errorf("%w: %s + %s", ErrAmountOverflow, m, other)
+	}
+	return NewMoney(sum, m.Currency), nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	negated, err := other.Neg()
+	if err != nil {
+		return Money{}, err
+	}
+	return m.Add(negated)
+}
+
+func (m Money) Neg() (Money, error) {
+	if m.Amount == math.MinInt64 {
+		return Money{}, fmt.Errorf("%w: -(%s)", ErrAmountOverflow, m)
+	}
+	return NewMoney(-m.Amount, m.Currency), nil
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// String formats the amount with its currency code, like "12.34 USD".
+func (m Money) String() string {
+	return FormatAmount(m.Amount, m.Currency.Exponent) + " " + m.Currency.Code
+}
+
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON encodes the amount as a decimal string with exactly as many
+// decimal places as the currency has, like {"amount":"12.30","currency":"USD"}.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{
+		Amount:   FormatAmount(m.Amount, m.Currency.Exponent),
+		Currency: m.Currency.Code,
+	})
+}
+
+// UnmarshalJSON reads what MarshalJSON wrote. The exponent comes from the
+// currency lookup, never from the amount, and amounts with more decimal places
+// than the currency has are rejected.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var v moneyJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	lookup := currencyLookup.Load()
+	if lookup == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownCurrency, v.Currency)
+	}
+	currency, err := (*lookup)(v.Currency)
+	if err != nil {
+		return err
+	}
+
+	money, err := ParseMoney(v.Amount, currency)
+	if err != nil {
+		return err
+	}
+	*m = money
+	return nil
+}
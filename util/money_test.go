@@ -0,0 +1,94 @@
+package util
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testUSD = Currency{Code: USD, Exponent: 2}
+	testCNY = Currency{Code: CNY, Exponent: 2}
+	testJPY = Currency{Code: "JPY", Exponent: 0}
+)
+
+func TestMoneyArithmetic(t *testing.T) {
+	sum, err := NewMoney(1050, testUSD).Add(NewMoney(25, testUSD))
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(1075, testUSD), sum)
+
+	difference, err := NewMoney(1050, testUSD).Sub(NewMoney(2000, testUSD))
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(-950, testUSD), difference)
+	require.True(t, difference.IsNegative())
+
+	negated, err := difference.Neg()
+	require.NoError(t, err)
+	require.True(t, negated.IsPositive())
+
+	_, err = NewMoney(1, testUSD).Add(NewMoney(1, testCNY))
+	require.ErrorIs(t, err, ErrCurrencyMismatch)
+
+	_, err = NewMoney(math.MaxInt64, testUSD).Add(NewMoney(1, testUSD))
+	require.ErrorIs(t, err, ErrAmountOverflow)
+	_, err = NewMoney(math.MinInt64, testUSD).Add(NewMoney(-1, testUSD))
+	require.ErrorIs(t, err, ErrAmountOverflow)
+	_, err = NewMoney(0, testUSD).Sub(NewMoney(math.MinInt64, testUSD))
+	require.ErrorIs(t, err, ErrAmountOverflow)
+	_, err = NewMoney(math.MinInt64, testUSD).Neg()
+	require.ErrorIs(t, err, ErrAmountOverflow)
+
+	sum, err = NewMoney(math.MaxInt64, testUSD).Add(NewMoney(math.MinInt64, testUSD))
+	require.NoError(t, err)
+	require.Equal(t, int64(-1), sum.Amount)
+}
+
+func TestMoneyJSON(t *testing.T) {
+	SetCurrencyLookup(func(code string) (Currency, error) {
+		for _, currency := range []Currency{testUSD, testCNY, testJPY} {
+			if currency.Code == code {
+				return currency, nil
+			}
+		}
+		return Currency{}, ErrUnknownCurrency
+	})
+
+	for _, money := range []Money{
+		NewMoney(1230, testUSD),
+		NewMoney(-5, testCNY),
+		NewMoney(1234, testJPY),
+	} {
+		data, err := json.Marshal(money)
+		require.NoError(t, err)
+
+		var decoded Money
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.Equal(t, money, decoded)
+	}
+
+	data, err := json.Marshal(NewMoney(1230, testUSD))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"amount":"12.30","currency":"USD"}`, string(data))
+
+	var money Money
+	require.ErrorIs(t, json.Unmarshal([]byte(`{"amount":"1e3","currency":"USD"}`), &money), ErrInvalidAmount)
+
+	// the exponent is the currency's, not the number of decimal places typed
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":"12.3","currency":"USD"}`), &money))
+	require.Equal(t, NewMoney(1230, testUSD), money)
+	require.ErrorIs(t, json.Unmarshal([]byte(`{"amount":"12.345","currency":"USD"}`), &money), ErrInvalidAmount)
+	require.ErrorIs(t, json.Unmarshal([]byte(`{"amount":"12.5","currency":"JPY"}`), &money), ErrInvalidAmount)
+	require.ErrorIs(t, json.Unmarshal([]byte(`{"amount":"12.34","currency":"XXX"}`), &money), ErrUnknownCurrency)
+}
+
+func TestParseMoney(t *testing.T) {
+	money, err := ParseMoney("12.3", testUSD)
+	require.NoError(t, err)
+	require.Equal(t, NewMoney(1230, testUSD), money)
+	require.Equal(t, "12.30 USD", money.String())
+
+	_, err = ParseMoney("12.3", testJPY)
+	require.ErrorIs(t, err, ErrInvalidAmount)
+}
@@ -1,6 +1,8 @@
 package util
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -15,4 +17,11 @@ func HashPassword(password string) (string, error)  {
 
 func CheckPassword(password, hashPassword string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hashPassword), []byte(password))
-}
\ No newline at end of file
+}
+
+// HashToken returns the hex encoded sha256 of a high entropy token, so that
+// single-use tokens can be stored and looked up without keeping them in clear.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
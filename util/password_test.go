@@ -19,4 +19,13 @@ func TestHashPassword(t *testing.T)  {
 	err = CheckPassword(wrongPassword, hashPwd)
 	require.EqualError(t, err, bcrypt.ErrMismatchedHashAndPassword.Error())
 
-}
\ No newline at end of file
+}
+
+func TestHashToken(t *testing.T) {
+	token := RandomString(32)
+
+	hash := HashToken(token)
+	require.Len(t, hash, 64)
+	require.Equal(t, hash, HashToken(token))
+	require.NotEqual(t, hash, HashToken(RandomString(32)))
+}
@@ -1,7 +1,9 @@
 package util
 
 import (
+	cryptorand "crypto/rand"
 	"fmt"
+	"math/big"
 	"math/rand"
 	"strings"
 	"time"
@@ -47,4 +49,20 @@ func RandomCurrency() string {
 
 func RandomEmail() string {
 	return fmt.Sprintf("%s@gmail.com", RandomString(6))
-}
\ No newline at end of file
+}
+
+// RandomSecretCode returns a string of n lowercase letters drawn from a
+// cryptographically secure source, suitable for one-time codes sent to users.
+func RandomSecretCode(n int) (string, error) {
+	var sb strings.Builder
+	k := big.NewInt(int64(len(alphabet)))
+
+	for i := 0; i < n; i++ {
+		idx, err := cryptorand.Int(cryptorand.Reader, k)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(alphabet[idx.Int64()])
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,15 @@
+package util
+
+const (
+	DepositorRole = "depositor"
+	AdminRole     = "admin"
+)
+
+func IsValidRole(role string) bool {
+	switch role {
+	case DepositorRole, AdminRole:
+		return true
+	default:
+		return false
+	}
+}
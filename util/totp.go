@@ -0,0 +1,99 @@
+package util
+
+import (
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	totpPeriod = 30
+	// totpSkew is how many periods before and after the current one are
+	// accepted, to allow for clock drift on the device.
+	totpSkew = 1
+
+	defaultTOTPIssuer = "Simple Bank"
+)
+
+func totpOpts() totp.ValidateOpts {
+	return totp.ValidateOpts{
+		Period:    totpPeriod,
+		Digits:    otp.DigitsSix,
+		Algorithm: otp.AlgorithmSHA1,
+	}
+}
+
+// GenerateTOTPSecret creates a new TOTP key for the account and returns its
+// base32 secret together with the otpauth:// uri authenticator apps scan.
+func GenerateTOTPSecret(accountName string) (secret string, uri string, err error) {
+	issuer := Config.MFA.Issuer
+	if issuer == "" {
+		issuer = defaultTOTPIssuer
+	}
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: accountName,
+		Period:      totpPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return key.Secret(), key.URL(), nil
+}
+
+// ValidateTOTP checks a code against the secret at time t. It returns the
+// time step the code belongs to, so callers can refuse to accept the same
+// step twice.
+func ValidateTOTP(code string, secret string, t time.Time) (step int64, ok bool) {
+	current := t.Unix() / totpPeriod
+	for skew := int64(-totpSkew); skew <= totpSkew; skew++ {
+		step := current + skew
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix(step*totpPeriod, 0), totpOpts())
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// IsTOTPCode reports whether the input has the shape of a TOTP code rather
+// than a recovery code.
+func IsTOTPCode(code string) bool {
+	if len(code) != int(otp.DigitsSix) {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateRecoveryCodes returns n single use codes formatted as xxxxx-xxxxx.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		code, err := RandomSecretCode(10)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode strips the formatting users may type along with a
+// recovery code, so it can be hashed and compared.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
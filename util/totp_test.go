@@ -0,0 +1,53 @@
+package util
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	secret, uri, err := GenerateTOTPSecret("alice")
+	require.NoError(t, err)
+	require.NotEmpty(t, secret)
+	require.Contains(t, uri, "otpauth://totp/")
+	require.Contains(t, uri, "alice")
+
+	now := time.Now()
+	code, err := totp.GenerateCodeCustom(secret, now, totpOpts())
+	require.NoError(t, err)
+	require.True(t, IsTOTPCode(code))
+
+	step, ok := ValidateTOTP(code, secret, now)
+	require.True(t, ok)
+	require.Equal(t, now.Unix()/totpPeriod, step)
+
+	// the previous period is still accepted, older ones are not
+	_, ok = ValidateTOTP(code, secret, now.Add(totpPeriod*time.Second))
+	require.True(t, ok)
+	_, ok = ValidateTOTP(code, secret, now.Add(3*totpPeriod*time.Second))
+	require.False(t, ok)
+
+	_, ok = ValidateTOTP("000000", "not base32!", now)
+	require.False(t, ok)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	require.Len(t, codes, 10)
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		require.Len(t, code, 11)
+		require.Equal(t, byte('-'), code[5])
+		require.False(t, IsTOTPCode(code))
+		require.NotContains(t, seen, code)
+		seen[code] = true
+
+		require.Equal(t, code[:5]+code[6:], NormalizeRecoveryCode(code))
+	}
+	require.Equal(t, "abcdefghij", NormalizeRecoveryCode(" ABCDE-fghij "))
+}
@@ -0,0 +1,74 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+)
+
+const DefaultMaxAttempts = 10
+
+// TaskDistributor enqueues tasks for the TaskProcessor. Every method takes the
+// Querier to insert the task with, so callers running inside a store
+// transaction pass the transaction's Querier and the task only becomes visible
+// to workers once the triggering write commits.
+type TaskDistributor interface {
+	DistributeTaskSendVerifyEmail(ctx context.Context, q db.Querier, payload *PayloadSendVerifyEmail, opts ...Option) error
+	DistributeTaskSendResetPassword(ctx context.Context, q db.Querier, payload *PayloadSendResetPassword, opts ...Option) error
+	DistributeTaskSendPaymentRequest(ctx context.Context, q db.Querier, payload *PayloadSendPaymentRequest, opts ...Option) error
+}
+
+type PostgresTaskDistributor struct{}
+
+func NewPostgresTaskDistributor() TaskDistributor {
+	return &PostgresTaskDistributor{}
+}
+
+// Option customizes a task before it is enqueued.
+type Option func(arg *db.CreateTaskParams)
+
+// ProcessIn delays the first attempt of a task.
+func ProcessIn(delay time.Duration) Option {
+	return ProcessAt(time.Now().Add(delay))
+}
+
+// ProcessAt schedules the first attempt of a task.
+func ProcessAt(at time.Time) Option {
+	return func(arg *db.CreateTaskParams) {
+		arg.ScheduledAt = pgtype.Timestamptz{Time: at, Valid: true}
+	}
+}
+
+// MaxAttempts sets how often a task is tried before it is marked dead.
+func MaxAttempts(n int32) Option {
+	return func(arg *db.CreateTaskParams) {
+		arg.MaxAttempts = n
+	}
+}
+
+func distribute(ctx context.Context, q db.Querier, taskType string, payload any, opts []Option) (db.Task, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return db.Task{}, fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+
+	arg := db.CreateTaskParams{
+		Type:        taskType,
+		Payload:     data,
+		MaxAttempts: DefaultMaxAttempts,
+		ScheduledAt: pgtype.Timestamptz{Time: time.Now(), Valid: true},
+	}
+	for _, opt := range opts {
+		opt(&arg)
+	}
+
+	task, err := q.CreateTask(ctx, arg)
+	if err != nil {
+		return task, fmt.Errorf("failed to enqueue task %s: %w", taskType, err)
+	}
+	return task, nil
+}
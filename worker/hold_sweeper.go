@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const defaultSweepInterval = time.Minute
+
+// HoldSweeper periodically marks active holds past their expiry as expired.
+// Expired holds stop reserving funds as soon as they expire, so the sweeper
+// only keeps the status of the holds current.
+type HoldSweeper struct {
+	*periodic
+	store db.Store
+}
+
+func NewHoldSweeper(store db.Store, config util.Holds) *HoldSweeper {
+	interval := config.SweepInterval
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+	sweeper := &HoldSweeper{store: store}
+	sweeper.periodic = newPeriodic("hold sweeper", interval, func(ctx context.Context) error {
+		_, err := sweeper.sweep(ctx)
+		return err
+	})
+	return sweeper
+}
+
+// sweep expires the holds that are past their expiry and returns how many.
+func (sweeper *HoldSweeper) sweep(ctx context.Context) (int64, error) {
+	expired, err := sweeper.store.ExpireHolds(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("cannot expire holds: %w", err)
+	}
+	if expired > 0 {
+		util.Logger(ctx).Info("expired holds", "count", expired)
+	}
+	return expired, nil
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mockdb "github.com/jxgzzztang/simplebank/db/mock"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestHoldSweeperSweep(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	gomock.InOrder(
+		store.EXPECT().ExpireHolds(gomock.Any()).Times(1).Return(int64(3), nil),
+		store.EXPECT().ExpireHolds(gomock.Any()).Times(1).Return(int64(0), errors.New("connection reset")),
+	)
+
+	sweeper := NewHoldSweeper(store, util.Holds{})
+	require.Equal(t, defaultSweepInterval, sweeper.interval)
+	expired, err := sweeper.sweep(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(3), expired)
+	expired, err = sweeper.sweep(context.Background())
+	require.Error(t, err)
+	require.Zero(t, expired)
+}
+
+func TestHoldSweeperStart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	swept := make(chan struct{}, 2)
+	store.EXPECT().ExpireHolds(gomock.Any()).MinTimes(2).DoAndReturn(func(ctx context.Context) (int64, error) {
+		select {
+		case swept <- struct{}{}:
+		default:
+		}
+		return 0, nil
+	})
+
+	sweeper := NewHoldSweeper(store, util.Holds{SweepInterval: 10 * time.Millisecond})
+	require.False(t, sweeper.Running())
+	sweeper.Start(context.Background())
+	<-swept
+	<-swept
+	require.True(t, sweeper.Running())
+	sweeper.Shutdown()
+	require.False(t, sweeper.Running())
+}
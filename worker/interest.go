@@ -0,0 +1,204 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const (
+	defaultInterestInterval    = time.Hour
+	defaultInterestCatchUpDays = 7
+)
+
+// InterestAccruer accrues interest on savings accounts and on overdrawn
+// accounts with an overdraft limit every day, and pays or charges it every
+// month. Days are UTC days, and a day accrues on the balance at its end.
+// Accruals are unique per account, day and kind and postings per account,
+// month and kind, so runs only fill in what is missing.
+type InterestAccruer struct {
+	*periodic
+	store       db.Store
+	rates       map[string]util.InterestRate
+	catchUpDays int
+}
+
+func NewInterestAccruer(store db.Store, config util.Interest) *InterestAccruer {
+	interval := config.Interval
+	if interval <= 0 {
+		interval = defaultInterestInterval
+	}
+	catchUpDays := config.CatchUpDays
+	if catchUpDays <= 0 {
+		catchUpDays = defaultInterestCatchUpDays
+	}
+	rates := make(map[string]util.InterestRate, len(config.Rates))
+	for _, rate := range config.Rates {
+		rates[rate.Currency] = rate
+	}
+	accruer := &InterestAccruer{
+		store:       store,
+		rates:       rates,
+		catchUpDays: catchUpDays,
+	}
+	accruer.periodic = newPeriodic("interest accruer", interval, func(ctx context.Context) error {
+		now := time.Now()
+		_, accrueErr := accruer.accrue(ctx, now)
+		_, postErr := accruer.post(ctx, now)
+		return errors.Join(accrueErr, postErr)
+	})
+	return accruer
+}
+
+// accrue accrues the days before now that are missing an accrual and returns
+// how many accruals it made. It goes over the last catchUpDays days, or every
+// day since the last accrual when that is longer ago, so days the accruer was
+// down for are not skipped.
+func (accruer *InterestAccruer) accrue(ctx context.Context, now time.Time) (int64, error) {
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	from := today.AddDate(0, 0, -accruer.catchUpDays)
+	latest, err := accruer.store.GetLatestInterestAccrualDate(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("cannot get the latest interest accrual: %w", err)
+	}
+	if latest.Valid && latest.Time.AddDate(0, 0, 1).Before(from) {
+		from = latest.Time.AddDate(0, 0, 1)
+		util.Logger(ctx).Warn("interest accrual is behind", "since", from.Format(time.DateOnly),
+			"days", int(today.Sub(from)/(24*time.Hour)))
+	}
+
+	var accrued int64
+	for date := from; date.Before(today); date = date.AddDate(0, 0, 1) {
+		n, err := accruer.accrueDay(ctx, date)
+		accrued += n
+		if err != nil {
+			return accrued, fmt.Errorf("cannot accrue interest for %s: %w", date.Format(time.DateOnly), err)
+		}
+	}
+	if accrued > 0 {
+		util.Logger(ctx).Info("accrued interest", "count", accrued)
+	}
+	return accrued, nil
+}
+
+func (accruer *InterestAccruer) accrueDay(ctx context.Context, date time.Time) (int64, error) {
+	balances, err := accruer.store.ListSavingsBalancesAt(ctx, pgtype.Timestamptz{Time: date.AddDate(0, 0, 1), Valid: true})
+	if err != nil {
+		return 0, err
+	}
+
+	var accrued int64
+	for _, balance := range balances {
+		rate, ok := accruer.rates[balance.Currency]
+		if !ok {
+			continue
+		}
+		n, err := accruer.accrueAccount(ctx, db.CreateInterestAccrualParams{
+			AccountID:     balance.ID,
+			AccrualDate:   pgtype.Date{Time: date, Valid: true},
+			Kind:          db.InterestSavings,
+			Balance:       balance.Balance,
+			AnnualRateBps: rate.AnnualRateBps,
+		}, balance.Balance)
+		if err != nil {
+			return accrued, err
+		}
+		accrued += n
+	}
+
+	overdrawn, err := accruer.store.ListOverdrawnBalancesAt(ctx, pgtype.Timestamptz{Time: date.AddDate(0, 0, 1), Valid: true})
+	if err != nil {
+		return accrued, err
+	}
+	for _, balance := range overdrawn {
+		rate, ok := accruer.rates[balance.Currency]
+		if !ok || rate.OverdraftRateBps <= 0 {
+			continue
+		}
+		n, err := accruer.accrueAccount(ctx, db.CreateInterestAccrualParams{
+			AccountID:     balance.ID,
+			AccrualDate:   pgtype.Date{Time: date, Valid: true},
+			Kind:          db.InterestOverdraft,
+			Balance:       balance.Balance,
+			AnnualRateBps: rate.OverdraftRateBps,
+		}, -balance.Balance)
+		if err != nil {
+			return accrued, err
+		}
+		accrued += n
+	}
+	return accrued, nil
+}
+
+// accrueAccount records the interest arg.AnnualRateBps earns on principal.
+// An amount too large to compute is logged and left unaccrued rather than
+// stopping the accrual of every other account, a later run tries it again.
+func (accruer *InterestAccruer) accrueAccount(ctx context.Context, arg db.CreateInterestAccrualParams, principal int64) (int64, error) {
+	var err error
+	arg.AmountMicros, err = db.DailyInterestMicros(principal, arg.AnnualRateBps)
+	if err != nil {
+		util.Logger(ctx).Error("cannot compute interest", "account_id", arg.AccountID, "kind", arg.Kind,
+			"date", arg.AccrualDate.Time.Format(time.DateOnly), "error", err)
+		return 0, nil
+	}
+	return accruer.store.CreateInterestAccrual(ctx, arg)
+}
+
+// post pays or charges the interest accrued up to the end of the month before
+// now on the accounts that were not posted for it yet and returns how many it
+// posted.
+func (accruer *InterestAccruer) post(ctx context.Context, now time.Time) (int, error) {
+	now = now.UTC()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	period := thisMonth.AddDate(0, -1, 0)
+
+	accounts, err := accruer.store.ListInterestAccountsToPost(ctx, db.ListInterestAccountsToPostParams{
+		Before: pgtype.Date{Time: thisMonth, Valid: true},
+		Period: pgtype.Date{Time: period, Valid: true},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot list interest to post: %w", err)
+	}
+
+	posted := 0
+	unpaid := make(map[string]bool)
+	for _, account := range accounts {
+		rate := accruer.rates[account.Currency]
+		systemAccountID, msg := rate.ExpenseAccountID, "no interest expense account"
+		if account.Kind == db.InterestOverdraft {
+			systemAccountID, msg = rate.IncomeAccountID, "no interest income account"
+		}
+		if systemAccountID == 0 {
+			if !unpaid[account.Currency+account.Kind] {
+				util.Logger(ctx).Warn(msg, "currency", account.Currency)
+				unpaid[account.Currency+account.Kind] = true
+			}
+			continue
+		}
+		err, _ := accruer.store.PostInterestTx(ctx, db.PostInterestTxParams{
+			AccountID:       account.ID,
+			Kind:            account.Kind,
+			SystemAccountID: systemAccountID,
+			Period:          period,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return posted, err
+			}
+			util.Logger(ctx).Error("cannot post interest", "account_id", account.ID, "kind", account.Kind, "error", err)
+			continue
+		}
+		posted++
+	}
+	if posted > 0 {
+		util.Logger(ctx).Info("posted interest", "period", period.Format("2006-01"), "count", posted)
+	}
+	return posted, nil
+}
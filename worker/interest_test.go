@@ -0,0 +1,216 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+var testInterest = util.Interest{
+	CatchUpDays: 2,
+	Rates: []util.InterestRate{
+		{Currency: util.USD, AnnualRateBps: 365, ExpenseAccountID: 100, OverdraftRateBps: 1825, IncomeAccountID: 200},
+		{Currency: util.CNY, AnnualRateBps: 200},
+	},
+}
+
+func TestAccrueInterest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	balances := []db.ListSavingsBalancesAtRow{
+		{ID: 1, Currency: util.USD, Balance: 10000},
+		// no rate, no interest
+		{ID: 2, Currency: util.EUR, Balance: 10000},
+		{ID: 3, Currency: util.USD, Balance: -50},
+	}
+	overdrawn := []db.ListOverdrawnBalancesAtRow{
+		{ID: 4, Currency: util.USD, Balance: -10000},
+		// no overdraft rate, no interest
+		{ID: 5, Currency: util.CNY, Balance: -10000},
+	}
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetLatestInterestAccrualDate(gomock.Any()).Times(1).
+		Return(pgtype.Date{Time: time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC), Valid: true}, nil)
+	for _, endOfDay := range []time.Time{
+		time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	} {
+		store.EXPECT().ListSavingsBalancesAt(gomock.Any(), gomock.Eq(pgtype.Timestamptz{Time: endOfDay, Valid: true})).Times(1).Return(balances, nil)
+		store.EXPECT().ListOverdrawnBalancesAt(gomock.Any(), gomock.Eq(pgtype.Timestamptz{Time: endOfDay, Valid: true})).Times(1).Return(overdrawn, nil)
+	}
+
+	accrued := make(map[int64][]db.CreateInterestAccrualParams)
+	store.EXPECT().CreateInterestAccrual(gomock.Any(), gomock.Any()).Times(6).
+		DoAndReturn(func(ctx context.Context, arg db.CreateInterestAccrualParams) (int64, error) {
+			accrued[arg.AccountID] = append(accrued[arg.AccountID], arg)
+			// the first day was accrued by an earlier run
+			if arg.AccrualDate.Time.Day() == 28 {
+				return 0, nil
+			}
+			return 1, nil
+		})
+
+	accruer := NewInterestAccruer(store, testInterest)
+	require.Equal(t, defaultInterestInterval, accruer.interval)
+	n, err := accruer.accrue(context.Background(), now)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+
+	require.Len(t, accrued[1], 2)
+	require.Equal(t, time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC), accrued[1][0].AccrualDate.Time)
+	require.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), accrued[1][1].AccrualDate.Time)
+	// 3.65% of 100.00 a year is a cent a day
+	require.Equal(t, int64(1_000_000), accrued[1][1].AmountMicros)
+	require.Equal(t, int32(365), accrued[1][1].AnnualRateBps)
+	require.Empty(t, accrued[2])
+	require.Equal(t, int64(0), accrued[3][0].AmountMicros)
+	require.Equal(t, db.InterestSavings, accrued[1][0].Kind)
+
+	// 18.25% of 100.00 a year is five cents a day
+	require.Len(t, accrued[4], 2)
+	require.Equal(t, db.InterestOverdraft, accrued[4][1].Kind)
+	require.Equal(t, int64(-10000), accrued[4][1].Balance)
+	require.Equal(t, int64(5_000_000), accrued[4][1].AmountMicros)
+	require.Empty(t, accrued[5])
+}
+
+func TestAccrueInterestError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetLatestInterestAccrualDate(gomock.Any()).Times(1).Return(pgtype.Date{}, nil)
+	// a failing day stops the run, later days are accrued by the next one
+	store.EXPECT().ListSavingsBalancesAt(gomock.Any(), gomock.Any()).Times(1).Return(nil, errors.New("connection reset"))
+
+	accruer := NewInterestAccruer(store, testInterest)
+	n, err := accruer.accrue(context.Background(), time.Now())
+	require.Error(t, err)
+	require.Zero(t, n)
+}
+
+func TestAccrueInterestBehind(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	store := mockdb.NewMockStore(ctrl)
+	// the last accrual is older than the two catch-up days
+	store.EXPECT().GetLatestInterestAccrualDate(gomock.Any()).Times(1).
+		Return(pgtype.Date{Time: time.Date(2024, 2, 24, 0, 0, 0, 0, time.UTC), Valid: true}, nil)
+
+	var days []time.Time
+	store.EXPECT().ListSavingsBalancesAt(gomock.Any(), gomock.Any()).Times(5).
+		DoAndReturn(func(ctx context.Context, at pgtype.Timestamptz) ([]db.ListSavingsBalancesAtRow, error) {
+			days = append(days, at.Time.AddDate(0, 0, -1))
+			return []db.ListSavingsBalancesAtRow{{ID: 1, Currency: util.USD, Balance: 10000}}, nil
+		})
+	store.EXPECT().ListOverdrawnBalancesAt(gomock.Any(), gomock.Any()).Times(5).Return(nil, nil)
+	store.EXPECT().CreateInterestAccrual(gomock.Any(), gomock.Any()).Times(5).Return(int64(1), nil)
+
+	accruer := NewInterestAccruer(store, testInterest)
+	n, err := accruer.accrue(context.Background(), now)
+	require.NoError(t, err)
+	require.Equal(t, int64(5), n)
+	require.Equal(t, time.Date(2024, 2, 25, 0, 0, 0, 0, time.UTC), days[0])
+	require.Equal(t, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC), days[4])
+}
+
+func TestAccrueInterestOverflow(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetLatestInterestAccrualDate(gomock.Any()).Times(1).Return(pgtype.Date{}, nil)
+	// an interest too large to compute does not hold up the other accounts
+	store.EXPECT().ListSavingsBalancesAt(gomock.Any(), gomock.Any()).Times(2).Return([]db.ListSavingsBalancesAtRow{
+		{ID: 1, Currency: util.USD, Balance: math.MaxInt64 / 10},
+		{ID: 2, Currency: util.USD, Balance: 10000},
+	}, nil)
+	store.EXPECT().ListOverdrawnBalancesAt(gomock.Any(), gomock.Any()).Times(2).Return(nil, nil)
+	store.EXPECT().CreateInterestAccrual(gomock.Any(), gomock.Any()).Times(2).
+		DoAndReturn(func(ctx context.Context, arg db.CreateInterestAccrualParams) (int64, error) {
+			require.Equal(t, int64(2), arg.AccountID)
+			return 1, nil
+		})
+
+	accruer := NewInterestAccruer(store, testInterest)
+	n, err := accruer.accrue(context.Background(), now)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+}
+
+func TestPostInterest(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	period := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().ListInterestAccountsToPost(gomock.Any(), gomock.Eq(db.ListInterestAccountsToPostParams{
+		Before: pgtype.Date{Time: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Valid: true},
+		Period: pgtype.Date{Time: period, Valid: true},
+	})).Times(1).Return([]db.ListInterestAccountsToPostRow{
+		{ID: 1, Currency: util.USD, Kind: db.InterestSavings},
+		// without an expense account the interest stays accrued
+		{ID: 2, Currency: util.CNY, Kind: db.InterestSavings},
+		{ID: 3, Currency: util.USD, Kind: db.InterestSavings},
+		{ID: 4, Currency: util.USD, Kind: db.InterestOverdraft},
+		// nor without an income account
+		{ID: 5, Currency: util.CNY, Kind: db.InterestOverdraft},
+	}, nil)
+	store.EXPECT().PostInterestTx(gomock.Any(), gomock.Eq(db.PostInterestTxParams{AccountID: 1, Kind: db.InterestSavings, SystemAccountID: 100, Period: period})).
+		Times(1).Return(nil, db.PostInterestTxResult{})
+	store.EXPECT().PostInterestTx(gomock.Any(), gomock.Eq(db.PostInterestTxParams{AccountID: 3, Kind: db.InterestSavings, SystemAccountID: 100, Period: period})).
+		Times(1).Return(errors.New("connection reset"), db.PostInterestTxResult{})
+	store.EXPECT().PostInterestTx(gomock.Any(), gomock.Eq(db.PostInterestTxParams{AccountID: 4, Kind: db.InterestOverdraft, SystemAccountID: 200, Period: period})).
+		Times(1).Return(nil, db.PostInterestTxResult{})
+
+	accruer := NewInterestAccruer(store, testInterest)
+	// a failing account is logged and the others are still posted
+	posted, err := accruer.post(context.Background(), now)
+	require.NoError(t, err)
+	require.Equal(t, 2, posted)
+}
+
+func TestInterestAccruerStart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	ran := make(chan struct{}, 2)
+	store.EXPECT().GetLatestInterestAccrualDate(gomock.Any()).MinTimes(2).Return(pgtype.Date{}, nil)
+	store.EXPECT().ListSavingsBalancesAt(gomock.Any(), gomock.Any()).MinTimes(2).Return(nil, nil)
+	store.EXPECT().ListOverdrawnBalancesAt(gomock.Any(), gomock.Any()).MinTimes(2).Return(nil, nil)
+	store.EXPECT().ListInterestAccountsToPost(gomock.Any(), gomock.Any()).MinTimes(2).
+		DoAndReturn(func(ctx context.Context, arg db.ListInterestAccountsToPostParams) ([]db.ListInterestAccountsToPostRow, error) {
+			select {
+			case ran <- struct{}{}:
+			default:
+			}
+			return nil, nil
+		})
+
+	accruer := NewInterestAccruer(store, util.Interest{Interval: 10 * time.Millisecond, CatchUpDays: 1})
+	require.False(t, accruer.Running())
+	accruer.Start(context.Background())
+	<-ran
+	<-ran
+	require.True(t, accruer.Running())
+	accruer.Shutdown()
+	require.False(t, accruer.Running())
+}
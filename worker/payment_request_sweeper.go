@@ -0,0 +1,53 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+// PaymentRequestSweeper periodically expires the pending payment requests
+// past their expiry and tells their requesters that they expired.
+type PaymentRequestSweeper struct {
+	*periodic
+	store       db.Store
+	distributor TaskDistributor
+}
+
+func NewPaymentRequestSweeper(store db.Store, distributor TaskDistributor, config util.PaymentRequests) *PaymentRequestSweeper {
+	interval := config.SweepInterval
+	if interval <= 0 {
+		interval = defaultSweepInterval
+	}
+	sweeper := &PaymentRequestSweeper{
+		store:       store,
+		distributor: distributor,
+	}
+	sweeper.periodic = newPeriodic("payment request sweeper", interval, func(ctx context.Context) error {
+		_, err := sweeper.sweep(ctx)
+		return err
+	})
+	return sweeper
+}
+
+// sweep expires the pending payment requests that are past their expiry and
+// returns how many.
+func (sweeper *PaymentRequestSweeper) sweep(ctx context.Context) (int, error) {
+	err, result := sweeper.store.ExpirePaymentRequestsTx(ctx, db.ExpirePaymentRequestsTxParams{
+		AfterExpire: func(q db.Querier, paymentRequest db.PaymentRequest) error {
+			return sweeper.distributor.DistributeTaskSendPaymentRequest(ctx, q, &PayloadSendPaymentRequest{
+				PaymentRequestID: paymentRequest.ID,
+				Status:           paymentRequest.Status,
+			})
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot expire payment requests: %w", err)
+	}
+	if expired := len(result.PaymentRequests); expired > 0 {
+		util.Logger(ctx).Info("expired payment requests", "count", expired)
+	}
+	return len(result.PaymentRequests), nil
+}
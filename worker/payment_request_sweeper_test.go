@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mockdb "github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestPaymentRequestSweeperSweep(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	expired := []db.PaymentRequest{
+		{ID: 1, Status: db.PaymentRequestExpired},
+		{ID: 2, Status: db.PaymentRequestExpired},
+	}
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().ExpirePaymentRequestsTx(gomock.Any(), gomock.Any()).Times(1).
+		DoAndReturn(func(ctx context.Context, arg db.ExpirePaymentRequestsTxParams) (error, db.ExpirePaymentRequestsTxResult) {
+			for _, paymentRequest := range expired {
+				require.NoError(t, arg.AfterExpire(store, paymentRequest))
+			}
+			return nil, db.ExpirePaymentRequestsTxResult{PaymentRequests: expired}
+		})
+	// the requester of every expired request is told about it
+	store.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Times(len(expired)).
+		DoAndReturn(func(ctx context.Context, arg db.CreateTaskParams) (db.Task, error) {
+			require.Equal(t, TaskSendPaymentRequest, arg.Type)
+			require.Contains(t, string(arg.Payload), `"status":"expired"`)
+			return db.Task{Type: arg.Type, Payload: arg.Payload}, nil
+		})
+
+	sweeper := NewPaymentRequestSweeper(store, NewPostgresTaskDistributor(), util.PaymentRequests{})
+	require.Equal(t, defaultSweepInterval, sweeper.interval)
+	n, err := sweeper.sweep(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, len(expired), n)
+}
+
+func TestPaymentRequestSweeperStart(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	swept := make(chan struct{}, 2)
+	store.EXPECT().ExpirePaymentRequestsTx(gomock.Any(), gomock.Any()).MinTimes(2).
+		DoAndReturn(func(ctx context.Context, arg db.ExpirePaymentRequestsTxParams) (error, db.ExpirePaymentRequestsTxResult) {
+			select {
+			case swept <- struct{}{}:
+			default:
+			}
+			return nil, db.ExpirePaymentRequestsTxResult{}
+		})
+
+	sweeper := NewPaymentRequestSweeper(store, NewPostgresTaskDistributor(), util.PaymentRequests{SweepInterval: 10 * time.Millisecond})
+	require.False(t, sweeper.Running())
+	sweeper.Start(context.Background())
+	<-swept
+	<-swept
+	require.True(t, sweeper.Running())
+	sweeper.Shutdown()
+	require.False(t, sweeper.Running())
+}
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+// periodic runs a job right away and then every interval until it is shut
+// down. A failed run is logged and the job runs again on the next tick.
+type periodic struct {
+	name     string
+	interval time.Duration
+	job      func(ctx context.Context) error
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	running  atomic.Bool
+}
+
+func newPeriodic(name string, interval time.Duration, job func(ctx context.Context) error) *periodic {
+	return &periodic{
+		name:     name,
+		interval: interval,
+		job:      job,
+	}
+}
+
+func (p *periodic) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(ctx)
+	p.wg.Add(1)
+	p.running.Store(true)
+	go p.run(ctx)
+}
+
+// Shutdown stops the worker and waits for a running job to finish.
+func (p *periodic) Shutdown() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
+
+// Running reports whether the worker has been started and is still running.
+func (p *periodic) Running() bool {
+	return p.running.Load()
+}
+
+func (p *periodic) run(ctx context.Context) {
+	defer p.wg.Done()
+	defer p.running.Store(false)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := p.job(ctx); err != nil && ctx.Err() == nil {
+			util.Logger(ctx).Error("periodic job failed", "worker", p.name, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeriodic(t *testing.T) {
+	ran := make(chan struct{}, 2)
+	p := newPeriodic("test", 10*time.Millisecond, func(ctx context.Context) error {
+		select {
+		case ran <- struct{}{}:
+		default:
+		}
+		// a failing run does not stop the next one
+		return errors.New("connection reset")
+	})
+	require.False(t, p.Running())
+	p.Start(context.Background())
+	<-ran
+	<-ran
+	require.True(t, p.Running())
+	p.Shutdown()
+	require.False(t, p.Running())
+}
+
+func TestPeriodicShutdownBeforeStart(t *testing.T) {
+	p := newPeriodic("test", time.Minute, func(ctx context.Context) error { return nil })
+	p.Shutdown()
+	require.False(t, p.Running())
+}
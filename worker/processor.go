@@ -0,0 +1,197 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/mail"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const (
+	defaultConcurrency  = 4
+	defaultPollInterval = time.Second
+	defaultLease        = time.Minute
+
+	minRetryDelay = time.Second
+	maxRetryDelay = time.Hour
+)
+
+// ErrSkipRetry marks a task failure as permanent. The task is moved to the
+// dead state instead of being retried.
+var ErrSkipRetry = errors.New("skip retry")
+
+type TaskHandler func(ctx context.Context, task db.Task) error
+
+// TaskProcessor polls the tasks table and runs the handler registered for
+// every due task.
+type TaskProcessor interface {
+	Handle(taskType string, handler TaskHandler)
+	Start(ctx context.Context)
+	Shutdown()
+	// Running reports whether the processor has been started and is still
+	// polling for tasks.
+	Running() bool
+}
+
+type PostgresTaskProcessor struct {
+	store    db.Store
+	mailer   mail.Mailer
+	config   util.Worker
+	handlers map[string]TaskHandler
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	// running counts the polling goroutines that have not returned yet.
+	running atomic.Int32
+}
+
+func NewPostgresTaskProcessor(store db.Store, mailer mail.Mailer, config util.Worker) TaskProcessor {
+	if config.Concurrency <= 0 {
+		config.Concurrency = defaultConcurrency
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = defaultPollInterval
+	}
+	if config.Lease <= 0 {
+		config.Lease = defaultLease
+	}
+
+	processor := &PostgresTaskProcessor{
+		store:    store,
+		mailer:   mailer,
+		config:   config,
+		handlers: make(map[string]TaskHandler),
+	}
+	processor.Handle(TaskSendVerifyEmail, processor.ProcessTaskSendVerifyEmail)
+	processor.Handle(TaskSendResetPassword, processor.ProcessTaskSendResetPassword)
+	processor.Handle(TaskSendPaymentRequest, processor.ProcessTaskSendPaymentRequest)
+	return processor
+}
+
+// Handle registers the handler for a task type. It must be called before Start.
+func (processor *PostgresTaskProcessor) Handle(taskType string, handler TaskHandler) {
+	processor.handlers[taskType] = handler
+}
+
+func (processor *PostgresTaskProcessor) Start(ctx context.Context) {
+	ctx, processor.cancel = context.WithCancel(ctx)
+	for i := 0; i < processor.config.Concurrency; i++ {
+		processor.wg.Add(1)
+		processor.running.Add(1)
+		go processor.run(ctx)
+	}
+}
+
+// Shutdown stops polling and waits for the running tasks to finish.
+func (processor *PostgresTaskProcessor) Shutdown() {
+	if processor.cancel != nil {
+		processor.cancel()
+	}
+	processor.wg.Wait()
+}
+
+func (processor *PostgresTaskProcessor) Running() bool {
+	return processor.running.Load() > 0
+}
+
+func (processor *PostgresTaskProcessor) run(ctx context.Context) {
+	defer processor.wg.Done()
+	defer processor.running.Add(-1)
+
+	ticker := time.NewTicker(processor.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		claimed, err := processor.processNext(ctx)
+		if err != nil && ctx.Err() == nil {
+			util.Logger(ctx).Error("cannot claim task", "error", err)
+		}
+		if claimed && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processNext claims at most one due task and runs it. Tasks whose lease ran
+// out while running, because their worker died, are claimed again.
+func (processor *PostgresTaskProcessor) processNext(ctx context.Context) (bool, error) {
+	tasks, err := processor.store.ClaimTasks(ctx, db.ClaimTasksParams{
+		Lease:     pgtype.Interval{Microseconds: processor.config.Lease.Microseconds(), Valid: true},
+		BatchSize: 1,
+	})
+	if err != nil {
+		return false, err
+	}
+	for _, task := range tasks {
+		processor.process(ctx, task)
+	}
+	return len(tasks) > 0, nil
+}
+
+func (processor *PostgresTaskProcessor) process(ctx context.Context, task db.Task) {
+	var err error
+	handler, ok := processor.handlers[task.Type]
+	switch {
+	case !ok:
+		err = fmt.Errorf("%w: no handler for task type %s", ErrSkipRetry, task.Type)
+	case task.Attempts > task.MaxAttempts:
+		err = fmt.Errorf("%w: exceeded %d attempts", ErrSkipRetry, task.MaxAttempts)
+	default:
+		err = handler(ctx, task)
+	}
+
+	// record the outcome even if the processor is shutting down
+	ctx = context.WithoutCancel(ctx)
+	logger := util.Logger(ctx).With("task_id", task.ID, "task_type", task.Type)
+
+	if err == nil {
+		if err := processor.store.CompleteTask(ctx, task.ID); err != nil {
+			logger.Error("cannot complete task", "error", err)
+		}
+		return
+	}
+
+	if errors.Is(err, ErrSkipRetry) || task.Attempts >= task.MaxAttempts {
+		logger.Error("task failed permanently", "attempts", task.Attempts, "error", err)
+		if err := processor.store.FailTask(ctx, db.FailTaskParams{
+			ID:        task.ID,
+			LastError: err.Error(),
+		}); err != nil {
+			logger.Error("cannot fail task", "error", err)
+		}
+		return
+	}
+
+	delay := retryDelay(task.Attempts)
+	logger.Warn("task failed, retrying", "attempts", task.Attempts, "delay", delay, "error", err)
+	if err := processor.store.RetryTask(ctx, db.RetryTaskParams{
+		ID:          task.ID,
+		LastError:   err.Error(),
+		ScheduledAt: pgtype.Timestamptz{Time: time.Now().Add(delay), Valid: true},
+	}); err != nil {
+		logger.Error("cannot retry task", "error", err)
+	}
+}
+
+// retryDelay doubles the delay with every attempt and picks a random point in
+// the upper half of it so that tasks failing together do not retry together.
+func retryDelay(attempts int32) time.Duration {
+	delay := maxRetryDelay
+	if attempts < 32 {
+		delay = min(minRetryDelay<<max(attempts-1, 0), maxRetryDelay)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
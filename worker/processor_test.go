@@ -0,0 +1,277 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	mockdb "github.com/jxgzzztang/simplebank/db/mock"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	mockmail "github.com/jxgzzztang/simplebank/mail/mock"
+	"github.com/jxgzzztang/simplebank/util"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+)
+
+func TestProcessTask(t *testing.T) {
+	const testTask = "task:test"
+
+	testCases := []struct {
+		Name       string
+		Task       db.Task
+		HandlerErr error
+		BuildStubs func(store *mockdb.MockStore, task db.Task)
+	}{
+		{
+			Name: "ok",
+			Task: db.Task{ID: 1, Type: testTask, Attempts: 1, MaxAttempts: 3},
+			BuildStubs: func(store *mockdb.MockStore, task db.Task) {
+				store.EXPECT().CompleteTask(gomock.Any(), gomock.Eq(task.ID)).Times(1).Return(nil)
+			},
+		},
+		{
+			Name:       "retry",
+			Task:       db.Task{ID: 2, Type: testTask, Attempts: 1, MaxAttempts: 3},
+			HandlerErr: errors.New("temporary failure"),
+			BuildStubs: func(store *mockdb.MockStore, task db.Task) {
+				store.EXPECT().RetryTask(gomock.Any(), gomock.Any()).Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.RetryTaskParams) error {
+						require.Equal(t, task.ID, arg.ID)
+						require.Equal(t, "temporary failure", arg.LastError)
+						require.True(t, arg.ScheduledAt.Time.After(time.Now()))
+						return nil
+					})
+			},
+		},
+		{
+			Name:       "last attempt",
+			Task:       db.Task{ID: 3, Type: testTask, Attempts: 3, MaxAttempts: 3},
+			HandlerErr: errors.New("temporary failure"),
+			BuildStubs: func(store *mockdb.MockStore, task db.Task) {
+				store.EXPECT().FailTask(gomock.Any(), gomock.Eq(db.FailTaskParams{
+					ID:        task.ID,
+					LastError: "temporary failure",
+				})).Times(1).Return(nil)
+			},
+		},
+		{
+			Name:       "skip retry",
+			Task:       db.Task{ID: 4, Type: testTask, Attempts: 1, MaxAttempts: 3},
+			HandlerErr: ErrSkipRetry,
+			BuildStubs: func(store *mockdb.MockStore, task db.Task) {
+				store.EXPECT().FailTask(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+				store.EXPECT().RetryTask(gomock.Any(), gomock.Any()).Times(0)
+			},
+		},
+		{
+			Name: "unknown type",
+			Task: db.Task{ID: 5, Type: "task:unknown", Attempts: 1, MaxAttempts: 3},
+			BuildStubs: func(store *mockdb.MockStore, task db.Task) {
+				store.EXPECT().FailTask(gomock.Any(), gomock.Any()).Times(1).Return(nil)
+			},
+		},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.BuildStubs(store, tc.Task)
+
+			processor := NewPostgresTaskProcessor(store, mockmail.NewMockMailer(ctrl), util.Worker{}).(*PostgresTaskProcessor)
+			processor.Handle(testTask, func(ctx context.Context, task db.Task) error {
+				return tc.HandlerErr
+			})
+			processor.process(context.Background(), tc.Task)
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts := int32(1); attempts < 40; attempts++ {
+		delay := retryDelay(attempts)
+		require.GreaterOrEqual(t, delay, minRetryDelay/2)
+		require.LessOrEqual(t, delay, maxRetryDelay)
+	}
+	require.LessOrEqual(t, retryDelay(1), minRetryDelay)
+}
+
+func TestProcessTaskSendVerifyEmail(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	user := db.User{
+		Username: util.RandomOwner(),
+		FullName: util.RandomOwner(),
+		Email:    util.RandomEmail(),
+	}
+	verifyEmail := db.VerifyEmail{
+		ID:         util.RandomInt(1, 1000),
+		Username:   user.Username,
+		Email:      user.Email,
+		SecretCode: util.RandomString(32),
+	}
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().CreateTask(gomock.Any(), gomock.Any()).Times(1).
+		DoAndReturn(func(ctx context.Context, arg db.CreateTaskParams) (db.Task, error) {
+			require.Equal(t, TaskSendVerifyEmail, arg.Type)
+			require.EqualValues(t, DefaultMaxAttempts, arg.MaxAttempts)
+			return db.Task{Type: arg.Type, Payload: arg.Payload, Attempts: 1, MaxAttempts: arg.MaxAttempts}, nil
+		})
+	store.EXPECT().GetVerifyEmail(gomock.Any(), gomock.Eq(verifyEmail.ID)).Times(1).Return(verifyEmail, nil)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+
+	mailer := mockmail.NewMockMailer(ctrl)
+	mailer.EXPECT().SendEmail(gomock.Any(), gomock.Any(), gomock.Eq([]string{user.Email})).Times(1).
+		DoAndReturn(func(subject string, content string, to []string) error {
+			require.Contains(t, content, verifyEmail.SecretCode)
+			return nil
+		})
+
+	payload := &PayloadSendVerifyEmail{Username: user.Username, VerifyEmailID: verifyEmail.ID}
+	task, err := distribute(context.Background(), store, TaskSendVerifyEmail, payload, nil)
+	require.NoError(t, err)
+
+	var decoded PayloadSendVerifyEmail
+	require.NoError(t, json.Unmarshal(task.Payload, &decoded))
+	require.Equal(t, *payload, decoded)
+
+	processor := NewPostgresTaskProcessor(store, mailer, util.Worker{}).(*PostgresTaskProcessor)
+	require.NoError(t, processor.ProcessTaskSendVerifyEmail(context.Background(), task))
+}
+
+func TestProcessTaskSendResetPassword(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	user := db.User{
+		Username: util.RandomOwner(),
+		FullName: util.RandomOwner(),
+		Email:    util.RandomEmail(),
+	}
+
+	var tokenHash string
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(user, nil)
+	store.EXPECT().InvalidatePasswordResets(gomock.Any(), gomock.Eq(user.Username)).Times(1).Return(nil)
+	store.EXPECT().CreatePasswordReset(gomock.Any(), gomock.Any()).Times(1).
+		DoAndReturn(func(ctx context.Context, arg db.CreatePasswordResetParams) (db.PasswordReset, error) {
+			require.Equal(t, user.Username, arg.Username)
+			require.True(t, arg.ExpiredAt.Time.After(time.Now()))
+			tokenHash = arg.TokenHash
+			return db.PasswordReset{Username: arg.Username, TokenHash: arg.TokenHash, ExpiredAt: arg.ExpiredAt}, nil
+		})
+
+	mailer := mockmail.NewMockMailer(ctrl)
+	mailer.EXPECT().SendEmail(gomock.Any(), gomock.Any(), gomock.Eq([]string{user.Email})).Times(1).
+		DoAndReturn(func(subject string, content string, to []string) error {
+			// the email carries the token itself, never its hash
+			require.NotContains(t, content, tokenHash)
+			i := strings.Index(content, "token=")
+			require.GreaterOrEqual(t, i, 0)
+			require.Equal(t, tokenHash, util.HashToken(content[i+len("token="):i+len("token=")+32]))
+			return nil
+		})
+
+	payload, err := json.Marshal(PayloadSendResetPassword{Username: user.Username})
+	require.NoError(t, err)
+
+	processor := NewPostgresTaskProcessor(store, mailer, util.Worker{}).(*PostgresTaskProcessor)
+	task := db.Task{Type: TaskSendResetPassword, Payload: payload, Attempts: 1, MaxAttempts: DefaultMaxAttempts}
+	require.NoError(t, processor.ProcessTaskSendResetPassword(context.Background(), task))
+}
+
+func TestPasswordResetURL(t *testing.T) {
+	config := util.Config
+	defer func() { util.Config = config }()
+
+	util.Config.BaseURL = "http://localhost:8080"
+	util.Config.PasswordResetURL = ""
+	require.Equal(t, "http://localhost:8080/password/reset?token=abc", PasswordResetURL("abc"))
+
+	util.Config.PasswordResetURL = "https://app.example.com/reset"
+	require.Equal(t, "https://app.example.com/reset?token=abc", PasswordResetURL("abc"))
+
+	util.Config.PasswordResetURL = "https://app.example.com/#/reset?lang=en"
+	require.Equal(t, "https://app.example.com/#/reset?lang=en&token=abc", PasswordResetURL("abc"))
+}
+
+func TestProcessTaskSendPaymentRequest(t *testing.T) {
+	requester := db.User{Username: util.RandomOwner(), FullName: util.RandomOwner(), Email: util.RandomEmail()}
+	payer := db.User{Username: util.RandomOwner(), FullName: util.RandomOwner(), Email: util.RandomEmail()}
+	paymentRequest := db.PaymentRequest{
+		ID:        util.RandomInt(1, 1000),
+		Requester: requester.Username,
+		Payer:     payer.Username,
+		Amount:    1250,
+		Currency:  util.USD,
+		Note:      "dinner",
+		Status:    db.PaymentRequestPending,
+		ExpiresAt: pgtype.Timestamptz{Time: time.Now().Add(time.Hour), Valid: true},
+	}
+
+	testCases := []struct {
+		Name    string
+		Status  string
+		To      db.User
+		Subject string
+	}{
+		{Name: "Pending", Status: db.PaymentRequestPending, To: payer, Subject: "You have a new payment request"},
+		{Name: "Accepted", Status: db.PaymentRequestAccepted, To: requester, Subject: "Your payment request was paid"},
+		{Name: "Declined", Status: db.PaymentRequestDeclined, To: requester, Subject: "Your payment request was declined"},
+		{Name: "Expired", Status: db.PaymentRequestExpired, To: requester, Subject: "Your payment request expired"},
+	}
+
+	for i := range testCases {
+		tc := testCases[i]
+		t.Run(tc.Name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetPaymentRequest(gomock.Any(), gomock.Eq(paymentRequest.ID)).Times(1).Return(paymentRequest, nil)
+			store.EXPECT().GetCurrency(gomock.Any(), gomock.Eq(util.USD)).Times(1).Return(db.Currency{Code: util.USD, Exponent: 2}, nil)
+			store.EXPECT().GetUser(gomock.Any(), gomock.Eq(tc.To.Username)).Times(1).Return(tc.To, nil)
+
+			mailer := mockmail.NewMockMailer(ctrl)
+			mailer.EXPECT().SendEmail(gomock.Eq(tc.Subject), gomock.Any(), gomock.Eq([]string{tc.To.Email})).Times(1).
+				DoAndReturn(func(subject string, content string, to []string) error {
+					require.Contains(t, content, "12.50 USD")
+					require.Contains(t, content, paymentRequest.Note)
+					return nil
+				})
+
+			payload, err := json.Marshal(PayloadSendPaymentRequest{PaymentRequestID: paymentRequest.ID, Status: tc.Status})
+			require.NoError(t, err)
+
+			processor := NewPostgresTaskProcessor(store, mailer, util.Worker{}).(*PostgresTaskProcessor)
+			task := db.Task{Type: TaskSendPaymentRequest, Payload: payload, Attempts: 1, MaxAttempts: DefaultMaxAttempts}
+			require.NoError(t, processor.ProcessTaskSendPaymentRequest(context.Background(), task))
+		})
+	}
+}
+
+func TestProcessorRunning(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().ClaimTasks(gomock.Any(), gomock.Any()).AnyTimes().Return(nil, nil)
+
+	processor := NewPostgresTaskProcessor(store, mockmail.NewMockMailer(ctrl), util.Worker{Concurrency: 2})
+	require.False(t, processor.Running())
+
+	processor.Start(context.Background())
+	require.True(t, processor.Running())
+
+	processor.Shutdown()
+	require.False(t, processor.Running())
+}
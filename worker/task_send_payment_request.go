@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+
+	"github.com/jackc/pgx/v5"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const TaskSendPaymentRequest = "task:send_payment_request"
+
+// PayloadSendPaymentRequest tells a party of a payment request that it
+// reached Status. A pending request is sent to the payer, every other status
+// to the requester.
+type PayloadSendPaymentRequest struct {
+	PaymentRequestID int64  `json:"payment_request_id"`
+	Status           string `json:"status"`
+}
+
+func (distributor *PostgresTaskDistributor) DistributeTaskSendPaymentRequest(
+	ctx context.Context,
+	q db.Querier,
+	payload *PayloadSendPaymentRequest,
+	opts ...Option,
+) error {
+	_, err := distribute(ctx, q, TaskSendPaymentRequest, payload, opts)
+	return err
+}
+
+func (processor *PostgresTaskProcessor) ProcessTaskSendPaymentRequest(ctx context.Context, task db.Task) error {
+	var payload PayloadSendPaymentRequest
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		return fmt.Errorf("%w: failed to unmarshal payload: %v", ErrSkipRetry, err)
+	}
+
+	paymentRequest, err := processor.store.GetPaymentRequest(ctx, payload.PaymentRequestID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: payment request %d does not exist", ErrSkipRetry, payload.PaymentRequestID)
+		}
+		return fmt.Errorf("failed to get payment request: %w", err)
+	}
+	currency, err := processor.store.GetCurrency(ctx, paymentRequest.Currency)
+	if err != nil {
+		return fmt.Errorf("failed to get currency: %w", err)
+	}
+	amount := util.NewMoney(paymentRequest.Amount, util.Currency{Code: currency.Code, Exponent: int(currency.Exponent)})
+
+	var recipient, subject, message string
+	switch payload.Status {
+	case db.PaymentRequestPending:
+		recipient = paymentRequest.Payer
+		subject = "You have a new payment request"
+		message = fmt.Sprintf("%s requests %s from you. Sign in to Simple Bank to accept or decline it before %s.",
+			paymentRequest.Requester, amount, paymentRequest.ExpiresAt.Time.Format("2006-01-02 15:04 MST"))
+	case db.PaymentRequestAccepted:
+		recipient = paymentRequest.Requester
+		subject = "Your payment request was paid"
+		message = fmt.Sprintf("%s paid your request for %s.", paymentRequest.Payer, amount)
+	case db.PaymentRequestDeclined:
+		recipient = paymentRequest.Requester
+		subject = "Your payment request was declined"
+		message = fmt.Sprintf("%s declined your request for %s.", paymentRequest.Payer, amount)
+	case db.PaymentRequestExpired:
+		recipient = paymentRequest.Requester
+		subject = "Your payment request expired"
+		message = fmt.Sprintf("%s did not answer your request for %s in time.", paymentRequest.Payer, amount)
+	default:
+		return fmt.Errorf("%w: unknown payment request status %q", ErrSkipRetry, payload.Status)
+	}
+
+	user, err := processor.store.GetUser(ctx, recipient)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: user %s does not exist", ErrSkipRetry, recipient)
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	content := fmt.Sprintf(`Hello %s,<br/>
+	%s<br/>
+	`, html.EscapeString(user.FullName), html.EscapeString(message))
+	if paymentRequest.Note != "" {
+		content += fmt.Sprintf("Note: %s<br/>\n", html.EscapeString(paymentRequest.Note))
+	}
+
+	if err := processor.mailer.SendEmail(subject, content, []string{user.Email}); err != nil {
+		return fmt.Errorf("failed to send payment request email: %w", err)
+	}
+	return nil
+}
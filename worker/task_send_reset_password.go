@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const (
+	TaskSendResetPassword = "task:send_reset_password"
+
+	defaultPasswordResetDuration = 30 * time.Minute
+)
+
+type PayloadSendResetPassword struct {
+	Username string `json:"username"`
+}
+
+// PasswordResetURL is the link a reset email carries the token in. It points
+// to the page configured as passwordResetURL, or to the form the server
+// serves at GET /password/reset when that is empty.
+func PasswordResetURL(token string) string {
+	page := util.Config.PasswordResetURL
+	if page == "" {
+		page = util.Config.BaseURL + "/password/reset"
+	}
+	separator := "?"
+	if strings.Contains(page, "?") {
+		separator = "&"
+	}
+	query := url.Values{}
+	query.Set("token", token)
+	return page + separator + query.Encode()
+}
+
+func (distributor *PostgresTaskDistributor) DistributeTaskSendResetPassword(
+	ctx context.Context,
+	q db.Querier,
+	payload *PayloadSendResetPassword,
+	opts ...Option,
+) error {
+	_, err := distribute(ctx, q, TaskSendResetPassword, payload, opts)
+	return err
+}
+
+// ProcessTaskSendResetPassword issues a new reset token and mails it to the
+// user. The token only exists in the email; the database keeps its hash, and
+// any token issued before is invalidated.
+func (processor *PostgresTaskProcessor) ProcessTaskSendResetPassword(ctx context.Context, task db.Task) error {
+	var payload PayloadSendResetPassword
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		return fmt.Errorf("%w: failed to unmarshal payload: %v", ErrSkipRetry, err)
+	}
+
+	user, err := processor.store.GetUser(ctx, payload.Username)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: user %s does not exist", ErrSkipRetry, payload.Username)
+		}
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	token, err := util.RandomSecretCode(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	duration := util.Config.PasswordResetDuration
+	if duration <= 0 {
+		duration = defaultPasswordResetDuration
+	}
+
+	if err := processor.store.InvalidatePasswordResets(ctx, user.Username); err != nil {
+		return fmt.Errorf("failed to invalidate password resets: %w", err)
+	}
+	_, err = processor.store.CreatePasswordReset(ctx, db.CreatePasswordResetParams{
+		Username:  user.Username,
+		TokenHash: util.HashToken(token),
+		ExpiredAt: pgtype.Timestamptz{Time: time.Now().Add(duration), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+
+	resetURL := PasswordResetURL(token)
+
+	subject := "Reset your Simple Bank password"
+	content := fmt.Sprintf(`Hello %s,<br/>
+	We received a request to reset your password.<br/>
+	Please <a href="%s">click here</a> to choose a new one. The link expires in %s.<br/>
+	If you did not ask for a new password you can ignore this email.<br/>
+	`, html.EscapeString(user.FullName), html.EscapeString(resetURL), duration)
+
+	if err := processor.mailer.SendEmail(subject, content, []string{user.Email}); err != nil {
+		return fmt.Errorf("failed to send reset password email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+
+	"github.com/jackc/pgx/v5"
+	db "github.com/jxgzzztang/simplebank/db/sqlc"
+	"github.com/jxgzzztang/simplebank/util"
+)
+
+const TaskSendVerifyEmail = "task:send_verify_email"
+
+type PayloadSendVerifyEmail struct {
+	Username      string `json:"username"`
+	VerifyEmailID int64  `json:"verify_email_id"`
+}
+
+func (distributor *PostgresTaskDistributor) DistributeTaskSendVerifyEmail(
+	ctx context.Context,
+	q db.Querier,
+	payload *PayloadSendVerifyEmail,
+	opts ...Option,
+) error {
+	_, err := distribute(ctx, q, TaskSendVerifyEmail, payload, opts)
+	return err
+}
+
+func (processor *PostgresTaskProcessor) ProcessTaskSendVerifyEmail(ctx context.Context, task db.Task) error {
+	var payload PayloadSendVerifyEmail
+	if err := json.Unmarshal(task.Payload, &payload); err != nil {
+		return fmt.Errorf("%w: failed to unmarshal payload: %v", ErrSkipRetry, err)
+	}
+
+	verifyEmail, err := processor.store.GetVerifyEmail(ctx, payload.VerifyEmailID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("%w: verify email %d does not exist", ErrSkipRetry, payload.VerifyEmailID)
+		}
+		return fmt.Errorf("failed to get verify email: %w", err)
+	}
+
+	user, err := processor.store.GetUser(ctx, verifyEmail.Username)
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+	if user.IsEmailVerified {
+		return nil
+	}
+
+	query := url.Values{}
+	query.Set("email_id", fmt.Sprint(verifyEmail.ID))
+	query.Set("secret_code", verifyEmail.SecretCode)
+	verifyURL := fmt.Sprintf("%s/verify_email?%s", util.Config.BaseURL, query.Encode())
+
+	subject := "Welcome to Simple Bank"
+	content := fmt.Sprintf(`Hello %s,<br/>
+	Thank you for registering with us!<br/>
+	Please <a href="%s">click here</a> to verify your email address.<br/>
+	`, html.EscapeString(user.FullName), html.EscapeString(verifyURL))
+
+	if err := processor.mailer.SendEmail(subject, content, []string{verifyEmail.Email}); err != nil {
+		return fmt.Errorf("failed to send verify email: %w", err)
+	}
+	return nil
+}